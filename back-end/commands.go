@@ -0,0 +1,53 @@
+package main
+
+import (
+	"back-end/config"
+	"back-end/storage"
+	"flag"
+	"fmt"
+	"os"
+)
+
+// runCommand - รันคำสั่งดูแลระบบจาก command line (เช่น ./main gc-uploads -delete) แทนการเปิด server
+func runCommand(args []string) int {
+	switch args[0] {
+	case "gc-uploads":
+		return gcUploadsCommand(args[1:])
+	default:
+		fmt.Fprintf(os.Stderr, "unknown command %q\n\navailable commands:\n  gc-uploads   report (and delete) orphaned files in ./uploads\n", args[0])
+		return 2
+	}
+}
+
+// gcUploadsCommand - รายงานไฟล์ใน ./uploads ที่ไม่มีข้อมูลใน database อ้างถึง (dry run เป็นค่าเริ่มต้น)
+func gcUploadsCommand(args []string) int {
+	flags := flag.NewFlagSet("gc-uploads", flag.ExitOnError)
+	deleteFiles := flags.Bool("delete", false, "delete orphaned files (default is a dry run that only reports them)")
+	minAge := flags.Duration("min-age", storage.DefaultMinAge, "ignore files modified more recently than this")
+	flags.Parse(args)
+
+	report, err := storage.CollectGarbage(config.DB, *minAge, !*deleteFiles)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "gc-uploads:", err)
+		return 1
+	}
+
+	for _, orphan := range report.Orphans {
+		fmt.Printf("%10d  %s  %s\n", orphan.Size, orphan.ModTime.Format("2006-01-02 15:04"), orphan.Path)
+	}
+	fmt.Printf("\nscanned %d files, %d referenced, %d orphaned (%d bytes)\n",
+		report.Scanned, report.Referenced, len(report.Orphans), report.OrphanSize)
+
+	if report.DryRun {
+		fmt.Println("dry run: no files were deleted (use -delete to remove them)")
+		return 0
+	}
+	fmt.Printf("deleted %d files\n", report.Deleted)
+	for _, e := range report.Errors {
+		fmt.Fprintln(os.Stderr, "error:", e)
+	}
+	if len(report.Errors) > 0 {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,185 @@
+package events
+
+import (
+	"strconv"
+	"sync"
+	"time"
+)
+
+// ชนิดของ event ที่ส่งให้ client แบบ real-time
+const (
+	NotePending   = "note.pending"   // มี note ใหม่รออนุมัติ (ส่งถึง admin)
+	NoteApproved  = "note.approved"  // note ผ่านการอนุมัติ (ส่งถึง seller และ admin)
+	NoteRejected  = "note.rejected"  // note ถูกปฏิเสธ (ส่งถึง seller และ admin)
+	NoteSold      = "note.sold"      // มีคนซื้อ note หรือ bundle (ส่งถึง seller)
+	ReviewCreated = "review.created" // มีรีวิวใหม่ (ส่งถึง seller)
+	ReviewReplied = "review.replied" // seller ตอบรีวิว (ส่งถึงผู้รีวิว)
+	ReportCreated = "report.created" // มีรายงานเนื้อหาใหม่ (ส่งถึง admin)
+
+	SellerApplicationCreated  = "seller_application.created"  // มีใบสมัครผู้ขายใหม่รอตรวจ (ส่งถึง admin)
+	SellerApplicationReviewed = "seller_application.reviewed" // ใบสมัครผู้ขายได้รับการตรวจแล้ว (ส่งถึงผู้สมัครและ admin)
+)
+
+// AdminTopic - topic ที่ admin ทุกคนฟังอยู่
+const AdminTopic = "admin"
+
+// subscriptionBuffer - จำนวน event ที่ค้างได้ต่อ subscriber (เกินนี้ event ใหม่จะถูกทิ้งสำหรับคนนั้น)
+const subscriptionBuffer = 32
+
+// Event - ข้อมูลที่ส่งให้ client
+type Event struct {
+	Type string      `json:"type"`
+	Data interface{} `json:"data"`
+	Time time.Time   `json:"time"`
+}
+
+// UserTopic - topic ของ user แต่ละคน
+func UserTopic(userID int) string {
+	return "user:" + strconv.Itoa(userID)
+}
+
+// Broker - ตัวกลาง publish/subscribe
+// ตอนนี้มีแค่แบบ in-process ถ้ารันหลาย instance ให้ทำ Broker ที่ใช้ Postgres LISTEN/NOTIFY แล้วเรียก SetBroker
+type Broker interface {
+	Publish(topic string, event Event)
+	Subscribe(topics ...string) *Subscription
+	Close()
+}
+
+// Subscription - การฟัง event ของ client หนึ่งคน (อ่านจาก C จนกว่าจะถูกปิด)
+type Subscription struct {
+	C      <-chan Event
+	ch     chan Event
+	topics []string
+	done   bool // ปิดแล้ว (ใช้ lock ของ broker)
+	cancel func(*Subscription)
+}
+
+// Close - เลิกฟัง event (เรียกซ้ำได้)
+func (s *Subscription) Close() {
+	s.cancel(s)
+}
+
+// memoryBroker - Broker ภายใน process เดียว
+type memoryBroker struct {
+	mu     sync.RWMutex
+	subs   map[string]map[*Subscription]struct{}
+	closed bool
+}
+
+// NewMemoryBroker - สร้าง Broker ที่ส่ง event ภายใน process เดียว
+func NewMemoryBroker() Broker {
+	return &memoryBroker{subs: map[string]map[*Subscription]struct{}{}}
+}
+
+// Publish - ส่ง event ให้ทุกคนที่ฟัง topic นี้อยู่ (ไม่ block ถ้า subscriber อ่านไม่ทัน)
+func (b *memoryBroker) Publish(topic string, event Event) {
+	if event.Time.IsZero() {
+		event.Time = time.Now()
+	}
+
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	for sub := range b.subs[topic] {
+		select {
+		case sub.ch <- event:
+		default:
+		}
+	}
+}
+
+// Subscribe - ฟัง event จากหลาย topic (ถ้า broker ปิดแล้วจะได้ subscription ที่ปิดไปแล้ว)
+func (b *memoryBroker) Subscribe(topics ...string) *Subscription {
+	ch := make(chan Event, subscriptionBuffer)
+	sub := &Subscription{C: ch, ch: ch, topics: topics, cancel: b.unsubscribe}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed {
+		sub.done = true
+		close(ch)
+		return sub
+	}
+	for _, topic := range topics {
+		if b.subs[topic] == nil {
+			b.subs[topic] = map[*Subscription]struct{}{}
+		}
+		b.subs[topic][sub] = struct{}{}
+	}
+	return sub
+}
+
+func (b *memoryBroker) unsubscribe(sub *Subscription) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.remove(sub)
+}
+
+// remove - เอา subscription ออกจากทุก topic แล้วปิด channel (ต้องถือ lock อยู่)
+func (b *memoryBroker) remove(sub *Subscription) {
+	if sub.done {
+		return
+	}
+	sub.done = true
+	for _, topic := range sub.topics {
+		delete(b.subs[topic], sub)
+		if len(b.subs[topic]) == 0 {
+			delete(b.subs, topic)
+		}
+	}
+	close(sub.ch)
+}
+
+// Close - ปิดทุก subscription (ใช้ตอนปิด server เพื่อให้ connection ที่ค้างอยู่จบ)
+func (b *memoryBroker) Close() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed {
+		return
+	}
+	b.closed = true
+
+	for _, subs := range b.subs {
+		for sub := range subs {
+			b.remove(sub)
+		}
+	}
+}
+
+var (
+	brokerMu      sync.RWMutex
+	defaultBroker = NewMemoryBroker()
+)
+
+// SetBroker - เปลี่ยน Broker ที่ใช้ทั้งระบบ (เรียกตอนเริ่มโปรแกรมก่อนรับ request)
+func SetBroker(b Broker) {
+	brokerMu.Lock()
+	defer brokerMu.Unlock()
+	defaultBroker = b
+}
+
+func current() Broker {
+	brokerMu.RLock()
+	defer brokerMu.RUnlock()
+	return defaultBroker
+}
+
+// Publish - ส่ง event ผ่าน Broker ของระบบ
+func Publish(topic, eventType string, data interface{}) {
+	current().Publish(topic, Event{Type: eventType, Data: data})
+}
+
+// PublishToUser - ส่ง event ถึง user คนเดียว
+func PublishToUser(userID int, eventType string, data interface{}) {
+	Publish(UserTopic(userID), eventType, data)
+}
+
+// Subscribe - ฟัง event ผ่าน Broker ของระบบ
+func Subscribe(topics ...string) *Subscription {
+	return current().Subscribe(topics...)
+}
+
+// Close - ปิด Broker ของระบบ
+func Close() {
+	current().Close()
+}
@@ -2,9 +2,14 @@ package handlers
 
 import (
 	"back-end/config"
+	"back-end/events"
+	"back-end/jobs"
 	"database/sql"
+	"errors"
 	"fmt"
+	"log"
 	"net/http"
+	"strconv"
 
 	"github.com/gin-gonic/gin"
 	"github.com/lib/pq"
@@ -71,9 +76,9 @@ func GetAllSellers(c *gin.Context) {
 			COALESCE(u.phone, '') as phone,
 			COALESCE(ns.note_count, 0) as total_summaries,
 			COALESCE(ns.total_sales, 0) as total_sales,
-			COALESCE(ns.revenue, 0) as revenue,
+			COALESCE(rs.revenue, 0) as revenue,
 			TO_CHAR(u.created_at, 'YYYY-MM-DD') as join_date,
-			'active' as status
+			` + accountStatusSQL + ` as status
 		FROM users u
 		INNER JOIN user_roles ur ON u.id = ur.user_id
 		INNER JOIN roles r ON ur.role_id = r.id
@@ -81,17 +86,24 @@ func GetAllSellers(c *gin.Context) {
 			SELECT 
 				n.seller_id,
 				COUNT(DISTINCT n.id) as note_count,
-				COUNT(b.id) as total_sales,
-				COALESCE(SUM(n.price), 0) as revenue
+				COUNT(b.id) as total_sales
 			FROM notes_for_sale n
 			LEFT JOIN buyed_note b ON n.id = b.note_id
 			GROUP BY n.seller_id
 		) ns ON u.id = ns.seller_id
+		LEFT JOIN (
+			-- ยอดขายสุทธิจากคำสั่งซื้อที่ชำระแล้ว (ราคาตอนซื้อหลังหักส่วนลด ไม่รวมภาษี)
+			SELECT oi.seller_id, SUM(oi.price - oi.discount_amount) as revenue
+			FROM order_items oi
+			JOIN orders o ON o.id = oi.order_id
+			WHERE o.status = $1
+			GROUP BY oi.seller_id
+		) rs ON u.id = rs.seller_id
 		WHERE r.name = 'seller'
 		ORDER BY u.created_at DESC
 	`
 
-	rows, err := config.DB.Query(query)
+	rows, err := config.DB.Query(query, OrderPaid)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"error":   "Database error",
@@ -154,7 +166,8 @@ func GetAllUsers(c *gin.Context) {
 			COALESCE(u.phone, '') as phone,
 			COALESCE(u.avatar_url, '') as avatar_url,
 			TO_CHAR(u.created_at, 'YYYY-MM-DD') as join_date,
-			ARRAY_AGG(r.name) as roles
+			ARRAY_AGG(r.name) as roles,
+			` + accountStatusSQL + ` as status
 		FROM users u
 		LEFT JOIN user_roles ur ON u.id = ur.user_id
 		LEFT JOIN roles r ON ur.role_id = r.id
@@ -186,6 +199,7 @@ func GetAllUsers(c *gin.Context) {
 			&avatarURL,
 			&user.JoinDate,
 			&roles,
+			&user.Status,
 		)
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{
@@ -199,7 +213,6 @@ func GetAllUsers(c *gin.Context) {
 			user.AvatarURL = avatarURL.String
 		}
 		user.Roles = []string(roles)
-		user.Status = "active"
 		users = append(users, user)
 	}
 
@@ -212,109 +225,75 @@ func GetAllUsers(c *gin.Context) {
 
 // GetDashboardStats godoc
 // @Summary Get dashboard statistics
-// @Description Get admin dashboard statistics including users, sellers, revenue (2% commission), and pending approvals
+// @Description Get admin dashboard statistics including users, sellers, revenue (2% commission on paid orders after discounts, excluding tax), paid orders and pending approvals
 // @Tags admin
 // @Accept json
 // @Produce json
 // @Security BearerAuth
 // @Success 200 {object} map[string]interface{} "Dashboard statistics"
 // @Failure 401 {object} map[string]string "Unauthorized"
+// @Failure 500 {object} map[string]string "Database error"
 // @Router /api/admin/dashboard [get]
 func GetDashboardStats(c *gin.Context) {
 	var stats DashboardStats
 
-	// นับจำนวน Users ทั้งหมด
-	err := config.DB.QueryRow("SELECT COUNT(*) FROM users").Scan(&stats.TotalUsers)
-	if err != nil {
-		stats.TotalUsers = 0
-	}
-
-	// นับจำนวน Sellers
-	err = config.DB.QueryRow(`
-		SELECT COUNT(DISTINCT u.id)
-		FROM users u
-		INNER JOIN user_roles ur ON u.id = ur.user_id
-		INNER JOIN roles r ON ur.role_id = r.id
-		WHERE r.name = 'seller'
-	`).Scan(&stats.TotalSellers)
-	if err != nil {
-		stats.TotalSellers = 0
-	}
-
-	// นับจำนวน Summaries ทั้งหมด
-	err = config.DB.QueryRow("SELECT COUNT(*) FROM notes_for_sale").Scan(&stats.TotalSummaries)
-	if err != nil {
-		stats.TotalSummaries = 0
-	}
-
-	// คำนวณรายได้รวมจากตาราง buyed_note (เก็บ 2% ของราคาขาย)
-	err = config.DB.QueryRow(`
-		SELECT COALESCE(SUM(n.price) * 0.02, 0) 
-		FROM buyed_note b
-		INNER JOIN notes_for_sale n ON b.note_id = n.id
-	`).Scan(&stats.TotalRevenue)
-	if err != nil {
-		stats.TotalRevenue = 0
-	}
-
-	// คำนวณรายได้เดือนนี้ (เก็บ 2% ของราคาขาย)
-	// หากตาราง buyed_note ไม่มี created_at ให้ใช้รายได้ทั้งหมดแทน
-	var monthlyRevenueQuery string
-	var hasCreatedAt bool
-	err = config.DB.QueryRow(`
-		SELECT EXISTS (
-			SELECT 1 
-			FROM information_schema.columns 
-			WHERE table_name='buyed_note' AND column_name='created_at'
-		)
-	`).Scan(&hasCreatedAt)
-	
-	if hasCreatedAt {
-		monthlyRevenueQuery = `
-			SELECT COALESCE(SUM(n.price) * 0.02, 0) 
-			FROM buyed_note b
-			INNER JOIN notes_for_sale n ON b.note_id = n.id
-			WHERE b.created_at >= DATE_TRUNC('month', CURRENT_DATE)
-		`
-	} else {
-		// ถ้าไม่มี created_at ให้ใช้รายได้ทั้งหมด
-		monthlyRevenueQuery = `
-			SELECT COALESCE(SUM(n.price) * 0.02, 0) 
-			FROM buyed_note b
-			INNER JOIN notes_for_sale n ON b.note_id = n.id
-		`
-	}
-	
-	err = config.DB.QueryRow(monthlyRevenueQuery).Scan(&stats.MonthlyRevenue)
-	if err != nil {
-		stats.MonthlyRevenue = 0
-	}
-
-	// นับจำนวน orders ทั้งหมด
-	err = config.DB.QueryRow("SELECT COUNT(*) FROM orders WHERE status = 'completed'").Scan(&stats.TotalOrders)
-	if err != nil {
-		stats.TotalOrders = 0
-	}
-
-	// นับจำนวน notes ที่รออนุมัติ (pending)
-	err = config.DB.QueryRow("SELECT COUNT(*) FROM notes_for_sale WHERE status = 'pending'").Scan(&stats.PendingApprovals)
-	if err != nil {
-		stats.PendingApprovals = 0
+	// ยอดเงินคิดจากคำสั่งซื้อที่ชำระแล้ว (orders / order_items) ซึ่งเก็บราคาตอนซื้อ ส่วนลด และภาษีไว้แล้ว
+	// ยอดขายสุทธิ = subtotal - discount_amount (ไม่รวมภาษี) และรายได้ของเว็บคือ 2% ของยอดขายสุทธิ
+	queries := []struct {
+		query string
+		args  []interface{}
+		dest  interface{}
+	}{
+		// จำนวน Users ทั้งหมด
+		{`SELECT COUNT(*) FROM users`, nil, &stats.TotalUsers},
+		// จำนวน Sellers
+		{`
+			SELECT COUNT(DISTINCT u.id)
+			FROM users u
+			INNER JOIN user_roles ur ON u.id = ur.user_id
+			INNER JOIN roles r ON ur.role_id = r.id
+			WHERE r.name = 'seller'
+		`, nil, &stats.TotalSellers},
+		// จำนวน Summaries ทั้งหมด
+		{`SELECT COUNT(*) FROM notes_for_sale WHERE deleted_at IS NULL`, nil, &stats.TotalSummaries},
+		// รายได้รวม (2% ของยอดขายสุทธิ)
+		{`
+			SELECT COALESCE(SUM(subtotal - discount_amount) * 0.02, 0)
+			FROM orders WHERE status = $1
+		`, []interface{}{OrderPaid}, &stats.TotalRevenue},
+		// รายได้เดือนนี้
+		{`
+			SELECT COALESCE(SUM(subtotal - discount_amount) * 0.02, 0)
+			FROM orders WHERE status = $1 AND created_at >= DATE_TRUNC('month', CURRENT_DATE)
+		`, []interface{}{OrderPaid}, &stats.MonthlyRevenue},
+		// จำนวนคำสั่งซื้อที่ชำระแล้ว
+		{`SELECT COUNT(*) FROM orders WHERE status = $1`, []interface{}{OrderPaid}, &stats.TotalOrders},
+		// จำนวน notes ที่รออนุมัติ (pending)
+		{`SELECT COUNT(*) FROM notes_for_sale WHERE status = 'pending'`, nil, &stats.PendingApprovals},
+		// ยอดที่ผู้ซื้อจ่ายจริงทั้งหมด (หลังหักส่วนลด รวมภาษี)
+		{`SELECT COALESCE(SUM(total), 0) FROM orders WHERE status = $1`, []interface{}{OrderPaid}, &stats.TotalSalesAmount},
+	}
+	for _, q := range queries {
+		if err := config.DB.QueryRow(q.query, q.args...).Scan(q.dest); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error":   "Database error",
+				"message": err.Error(),
+			})
+			return
+		}
 	}
 
-	// คำนวณยอดขายทั้งหมดจาก buyed_note
-	err = config.DB.QueryRow(`
-		SELECT COALESCE(SUM(n.price), 0) 
-		FROM buyed_note b
-		INNER JOIN notes_for_sale n ON b.note_id = n.id
-	`).Scan(&stats.TotalSalesAmount)
+	// จำนวนรายงานที่ยังไม่ปิด
+	var err error
+	stats.ReportedIssues, err = countOpenReports()
 	if err != nil {
-		stats.TotalSalesAmount = 0
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Database error",
+			"message": err.Error(),
+		})
+		return
 	}
 
-	// สำหรับ reported issues (mock ไว้ก่อน)
-	stats.ReportedIssues = 0
-
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
 		"data":    stats,
@@ -323,7 +302,7 @@ func GetDashboardStats(c *gin.Context) {
 
 // AddSellerRole godoc
 // @Summary Add seller role to user
-// @Description Assign the seller role to a specific user
+// @Description Assign the seller role to a specific user (same as POST /api/admin/users/{id}/roles with role "seller"). The role alone does not allow uploading notes; that requires an approved seller application
 // @Tags admin
 // @Accept json
 // @Produce json
@@ -332,6 +311,7 @@ func GetDashboardStats(c *gin.Context) {
 // @Success 200 {object} map[string]interface{} "Role assigned successfully"
 // @Failure 400 {object} map[string]string "Invalid request"
 // @Failure 401 {object} map[string]string "Unauthorized"
+// @Failure 404 {object} map[string]string "User not found"
 // @Failure 500 {object} map[string]string "Server error"
 // @Router /api/admin/users/seller [post]
 func AddSellerRole(c *gin.Context) {
@@ -347,28 +327,9 @@ func AddSellerRole(c *gin.Context) {
 		return
 	}
 
-	// ตรวจสอบว่ามี role seller หรือไม่
-	var roleID int
-	err := config.DB.QueryRow("SELECT id FROM roles WHERE name = $1", "seller").Scan(&roleID)
-	if err != nil {
-		// สร้าง role seller ถ้ายังไม่มี
-		err = config.DB.QueryRow("INSERT INTO roles (name) VALUES ($1) RETURNING id", "seller").Scan(&roleID)
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{
-				"error":   "Failed to create seller role",
-				"message": err.Error(),
-			})
-			return
-		}
-	}
-
-	// เพิ่ม role ให้ user
-	_, err = config.DB.Exec("INSERT INTO user_roles (user_id, role_id) VALUES ($1, $2) ON CONFLICT DO NOTHING", req.UserID, roleID)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error":   "Failed to assign seller role",
-			"message": err.Error(),
-		})
+	// เพิ่ม role ให้ user (บันทึก audit log เฉพาะเมื่อ role เปลี่ยนจริง)
+	if _, err := changeUserRole(c, req.UserID, "seller", true); err != nil {
+		respondRoleError(c, err)
 		return
 	}
 
@@ -380,7 +341,7 @@ func AddSellerRole(c *gin.Context) {
 
 // RemoveSellerRole godoc
 // @Summary Remove seller role from user
-// @Description Remove the seller role from a specific user
+// @Description Remove the seller role from a specific user (same as DELETE /api/admin/users/{id}/roles/seller)
 // @Tags admin
 // @Accept json
 // @Produce json
@@ -389,7 +350,7 @@ func AddSellerRole(c *gin.Context) {
 // @Success 200 {object} map[string]interface{} "Role removed successfully"
 // @Failure 400 {object} map[string]string "Invalid request"
 // @Failure 401 {object} map[string]string "Unauthorized"
-// @Failure 404 {object} map[string]string "Seller role not found"
+// @Failure 404 {object} map[string]string "User not found"
 // @Failure 500 {object} map[string]string "Server error"
 // @Router /api/admin/users/seller [delete]
 func RemoveSellerRole(c *gin.Context) {
@@ -405,24 +366,9 @@ func RemoveSellerRole(c *gin.Context) {
 		return
 	}
 
-	// หา role ID ของ seller
-	var roleID int
-	err := config.DB.QueryRow("SELECT id FROM roles WHERE name = $1", "seller").Scan(&roleID)
-	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{
-			"error":   "Seller role not found",
-			"message": err.Error(),
-		})
-		return
-	}
-
 	// ลบ role ออกจาก user
-	_, err = config.DB.Exec("DELETE FROM user_roles WHERE user_id = $1 AND role_id = $2", req.UserID, roleID)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error":   "Failed to remove seller role",
-			"message": err.Error(),
-		})
+	if _, err := changeUserRole(c, req.UserID, "seller", false); err != nil {
+		respondRoleError(c, err)
 		return
 	}
 
@@ -444,6 +390,7 @@ type NoteInfo struct {
 	CourseName  string  `json:"course_name"`
 	CreatedAt   string  `json:"created_at"`
 	Sales       int     `json:"sales"`
+	DeletedAt   *string `json:"deleted_at"`
 }
 
 // GetAllNotesAdmin godoc
@@ -469,7 +416,8 @@ func GetAllNotesAdmin(c *gin.Context) {
 			COALESCE(n.exam_term, '') as exam_term,
 			COALESCE(c.name, '') as course_name,
 			TO_CHAR(n.created_at, 'YYYY-MM-DD') as created_at,
-			0 as sales
+			0 as sales,
+			TO_CHAR(n.deleted_at, 'YYYY-MM-DD HH24:MI') as deleted_at
 		FROM notes_for_sale n
 		LEFT JOIN users u ON n.seller_id = u.id
 		LEFT JOIN courses c ON n.course_id = c.id
@@ -500,6 +448,7 @@ func GetAllNotesAdmin(c *gin.Context) {
 			&note.CourseName,
 			&note.CreatedAt,
 			&note.Sales,
+			&note.DeletedAt,
 		)
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{
@@ -531,11 +480,15 @@ type PendingNoteInfo struct {
 	CreatedAt   string  `json:"created_at"`
 	Description string  `json:"description"`
 	CoverImage  string  `json:"cover_image"`
+
+	// note เดิมที่น่าจะซ้ำ (คล้ายมากสุดก่อน) และความคล้ายสูงสุด 0-1
+	PossibleDuplicates []DuplicateMatch `json:"possible_duplicates"`
+	MaxSimilarity      float64          `json:"max_similarity"`
 }
 
 // GetPendingNotes godoc
 // @Summary Get pending notes
-// @Description Get a list of notes waiting for admin approval
+// @Description Get a list of notes waiting for admin approval, with likely duplicates of existing notes (similarity 0-1 and a link to the original)
 // @Tags admin
 // @Accept json
 // @Produce json
@@ -557,10 +510,7 @@ func GetPendingNotes(c *gin.Context) {
 			COALESCE(c.name, '') as course_name,
 			TO_CHAR(n.created_at, 'YYYY-MM-DD HH24:MI') as created_at,
 			COALESCE(n.description, '') as description,
-			COALESCE(
-				(SELECT path FROM note_images WHERE note_id = n.id ORDER BY image_order LIMIT 1),
-				''
-			) as cover_image
+			COALESCE(` + coverImageSQL("card") + `, '') as cover_image
 		FROM notes_for_sale n
 		LEFT JOIN users u ON n.seller_id = u.id
 		LEFT JOIN courses c ON n.course_id = c.id
@@ -604,6 +554,28 @@ func GetPendingNotes(c *gin.Context) {
 		notes = append(notes, note)
 	}
 
+	// ใส่ note เดิมที่น่าจะซ้ำ
+	noteIDs := make([]int, len(notes))
+	for i := range notes {
+		noteIDs[i] = notes[i].ID
+	}
+	duplicates, err := loadDuplicateMatches(config.DB, noteIDs)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Database error",
+			"message": err.Error(),
+		})
+		return
+	}
+	for i := range notes {
+		notes[i].PossibleDuplicates = duplicates[notes[i].ID]
+		if notes[i].PossibleDuplicates == nil {
+			notes[i].PossibleDuplicates = []DuplicateMatch{}
+		} else {
+			notes[i].MaxSimilarity = notes[i].PossibleDuplicates[0].Similarity
+		}
+	}
+
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
 		"data":    notes,
@@ -635,12 +607,14 @@ func ApproveNote(c *gin.Context) {
 	}
 
 	// อัปเดตสถานะเป็น available
-	result, err := config.DB.Exec(`
-		UPDATE notes_for_sale 
-		SET status = 'available'
-		WHERE id = $1 AND status = 'pending'
-	`, noteID)
+	id, sellerID, title, err := reviewPendingNote(c, noteID, NoteStatusAvailable, AuditNoteApprove, nil)
 
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "Note not found or already processed",
+		})
+		return
+	}
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"error":   "Database error",
@@ -649,13 +623,18 @@ func ApproveNote(c *gin.Context) {
 		return
 	}
 
-	rowsAffected, _ := result.RowsAffected()
-	if rowsAffected == 0 {
-		c.JSON(http.StatusNotFound, gin.H{
-			"error": "Note not found or already processed",
-		})
-		return
+	// แจ้ง seller (ถ้าแจ้งไม่สำเร็จไม่ต้องยกเลิกการอนุมัติ)
+	err = notify(config.DB, sellerID, NotifyNoteApproved, "Your note was approved",
+		fmt.Sprintf("%s is now available for sale", title), gin.H{"note_id": id})
+	if err != nil {
+		log.Printf("⚠️  Failed to notify seller %d of approval: %v", sellerID, err)
+	}
+	eventData := gin.H{"note_id": id, "seller_id": sellerID, "book_title": title}
+	if err := emitWebhook(config.DB, WebhookNoteApproved, sellerID, eventData); err != nil {
+		log.Printf("⚠️  Failed to queue webhooks for note %d: %v", id, err)
 	}
+	events.PublishToUser(sellerID, events.NoteApproved, eventData)
+	events.Publish(events.AdminTopic, events.NoteApproved, eventData)
 
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
@@ -694,12 +673,14 @@ func RejectNote(c *gin.Context) {
 	c.ShouldBindJSON(&req)
 
 	// อัปเดตสถานะเป็น rejected
-	result, err := config.DB.Exec(`
-		UPDATE notes_for_sale 
-		SET status = 'rejected'
-		WHERE id = $1 AND status = 'pending'
-	`, noteID)
+	id, sellerID, title, err := reviewPendingNote(c, noteID, NoteStatusRejected, AuditNoteReject, gin.H{"reason": req.Reason})
 
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "Note not found or already processed",
+		})
+		return
+	}
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"error":   "Database error",
@@ -708,13 +689,22 @@ func RejectNote(c *gin.Context) {
 		return
 	}
 
-	rowsAffected, _ := result.RowsAffected()
-	if rowsAffected == 0 {
-		c.JSON(http.StatusNotFound, gin.H{
-			"error": "Note not found or already processed",
-		})
-		return
+	// แจ้ง seller พร้อมเหตุผล (ถ้าแจ้งไม่สำเร็จไม่ต้องยกเลิกการปฏิเสธ)
+	message := fmt.Sprintf("%s was not approved for sale", title)
+	if req.Reason != "" {
+		message += ": " + req.Reason
 	}
+	err = notify(config.DB, sellerID, NotifyNoteRejected, "Your note was rejected", message,
+		gin.H{"note_id": id, "reason": req.Reason})
+	if err != nil {
+		log.Printf("⚠️  Failed to notify seller %d of rejection: %v", sellerID, err)
+	}
+	eventData := gin.H{"note_id": id, "seller_id": sellerID, "book_title": title, "reason": req.Reason}
+	if err := emitWebhook(config.DB, WebhookNoteRejected, sellerID, eventData); err != nil {
+		log.Printf("⚠️  Failed to queue webhooks for note %d: %v", id, err)
+	}
+	events.PublishToUser(sellerID, events.NoteRejected, eventData)
+	events.Publish(events.AdminTopic, events.NoteRejected, eventData)
 
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
@@ -723,35 +713,73 @@ func RejectNote(c *gin.Context) {
 	})
 }
 
+// reviewPendingNote - เปลี่ยนสถานะของ note ที่รออนุมัติ พร้อมบันทึก audit log ใน transaction เดียวกัน
+// คืนค่า sql.ErrNoRows ถ้าไม่พบ note หรือไม่ได้อยู่ในสถานะ pending แล้ว
+func reviewPendingNote(c *gin.Context, noteID, status, action string, details interface{}) (id, sellerID int, title string, err error) {
+	tx, err := config.DB.Begin()
+	if err != nil {
+		return 0, 0, "", err
+	}
+	defer tx.Rollback()
+
+	err = tx.QueryRow(`
+		UPDATE notes_for_sale
+		SET status = $2, approved_at = CASE WHEN $2 = 'available' THEN NOW() ELSE approved_at END
+		WHERE id = $1 AND status = 'pending'
+		RETURNING id, seller_id, book_title
+	`, noteID, status).Scan(&id, &sellerID, &title)
+	if err != nil {
+		return 0, 0, "", err
+	}
+	// แจ้งผู้ติดตามของ seller แบบ background (job ถูกสร้างก็ต่อเมื่อ transaction commit)
+	if status == NoteStatusAvailable {
+		payload := followersNewNotePayload{NoteID: id}
+		_, err = jobs.EnqueueTx(tx, JobFollowersNewNote, payload, jobs.EnqueueOptions{UniqueKey: fmt.Sprintf("%s:%d", JobFollowersNewNote, id)})
+		if err != nil && !errors.Is(err, jobs.ErrDuplicateJob) {
+			return 0, 0, "", err
+		}
+	}
+	err = recordAudit(tx, c, action, AuditTargetNote, id,
+		gin.H{"status": NoteStatusPending}, gin.H{"status": status}, details)
+	if err != nil {
+		return 0, 0, "", err
+	}
+	return id, sellerID, title, tx.Commit()
+}
+
 // DeleteNote godoc
 // @Summary Delete a note
-// @Description Delete a note from the system (Admin only)
+// @Description Soft delete a note (Admin only). The note is delisted and removed from the catalogue and carts, but buyers keep it in their purchase history and can still download it. Delisted notes can be restored; unpurchased ones are purged permanently after the retention period
 // @Tags admin
 // @Accept json
 // @Produce json
 // @Security BearerAuth
 // @Param id path int true "Note ID"
 // @Success 200 {object} map[string]interface{} "Note deleted successfully"
-// @Failure 400 {object} map[string]string "Note ID is required"
+// @Failure 400 {object} map[string]string "Invalid note ID"
 // @Failure 401 {object} map[string]string "Unauthorized"
 // @Failure 404 {object} map[string]string "Note not found"
 // @Failure 500 {object} map[string]string "Database error"
 // @Router /api/admin/notes/{id} [delete]
 func DeleteNote(c *gin.Context) {
-	noteID := c.Param("id")
-	if noteID == "" {
+	noteID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{
-			"error": "Note ID is required",
+			"error": "Invalid note ID",
 		})
 		return
 	}
 
-	// ลบ note จาก database (note_images และ cart จะถูกลบอัตโนมัติเพราะ ON DELETE CASCADE)
-	result, err := config.DB.Exec(`
-		DELETE FROM notes_for_sale 
-		WHERE id = $1
-	`, noteID)
+	tx, err := config.DB.Begin()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Database error",
+		})
+		return
+	}
+	defer tx.Rollback()
 
+	delisted, err := delistNoteAudited(tx, c, noteID, nil)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"error":   "Database error",
@@ -760,101 +788,119 @@ func DeleteNote(c *gin.Context) {
 		return
 	}
 
-	rowsAffected, _ := result.RowsAffected()
-	if rowsAffected == 0 {
+	if !delisted {
 		c.JSON(http.StatusNotFound, gin.H{
 			"error": "Note not found",
 		})
 		return
 	}
 
+	if err := tx.Commit(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to commit transaction",
+		})
+		return
+	}
+
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
 		"message": "Note deleted successfully",
 	})
 }
 
-// UpdateNote godoc
-// @Summary อัปเดตข้อมูลสรุปวิชา (Admin)
-// @Description Admin สามารถอัปเดตราคาและข้อมูลของสรุปวิชาได้
+// delistNote - เปลี่ยนสถานะเป็น delisted (เก็บสถานะเดิมไว้สำหรับ restore) แทนการลบข้อมูลจริง
+// และเอาออกจากตะกร้าของทุกคน คืนค่า false ถ้าไม่พบหรือถูก delist ไปแล้ว
+func delistNote(tx *sql.Tx, noteID interface{}) (bool, error) {
+	result, err := tx.Exec(`
+		UPDATE notes_for_sale
+		SET status_before_delete = status, status = 'delisted', deleted_at = NOW()
+		WHERE id = $1 AND deleted_at IS NULL
+	`, noteID)
+	if err != nil {
+		return false, err
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return false, nil
+	}
+
+	if _, err := tx.Exec(`DELETE FROM cart WHERE note_id = $1`, noteID); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// delistNoteAudited - delistNote พร้อมบันทึก audit log (ใช้กับการลบโดย admin)
+func delistNoteAudited(tx *sql.Tx, c *gin.Context, noteID int, details interface{}) (bool, error) {
+	var status string
+	err := tx.QueryRow(`
+		SELECT COALESCE(status, '') FROM notes_for_sale WHERE id = $1 AND deleted_at IS NULL FOR UPDATE
+	`, noteID).Scan(&status)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	if _, err := delistNote(tx, noteID); err != nil {
+		return false, err
+	}
+	err = recordAudit(tx, c, AuditNoteDelete, AuditTargetNote, noteID,
+		gin.H{"status": status}, gin.H{"status": NoteStatusDelisted}, details)
+	return err == nil, err
+}
+
+// RestoreNote godoc
+// @Summary Restore a deleted note
+// @Description Restore a soft-deleted (delisted) note to the status it had before deletion (Admin only)
 // @Tags admin
 // @Accept json
 // @Produce json
 // @Security BearerAuth
 // @Param id path int true "Note ID"
-// @Param body body object true "ข้อมูลที่ต้องการอัปเดต (price, title, description)"
-// @Success 200 {object} map[string]interface{} "อัปเดตสำเร็จ"
-// @Failure 400 {object} map[string]interface{} "ข้อมูลไม่ถูกต้อง"
-// @Failure 404 {object} map[string]interface{} "ไม่พบสรุปวิชา"
-// @Failure 500 {object} map[string]interface{} "เกิดข้อผิดพลาด"
-// @Router /admin/notes/{id} [put]
-func UpdateNote(c *gin.Context) {
-	noteID := c.Param("id")
-	if noteID == "" {
+// @Success 200 {object} map[string]interface{} "Note restored successfully"
+// @Failure 400 {object} map[string]string "Invalid note ID"
+// @Failure 401 {object} map[string]string "Unauthorized"
+// @Failure 404 {object} map[string]string "Deleted note not found"
+// @Failure 500 {object} map[string]string "Database error"
+// @Router /api/admin/notes/{id}/restore [post]
+func RestoreNote(c *gin.Context) {
+	noteID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{
-			"error": "Note ID is required",
+			"error": "Invalid note ID",
 		})
 		return
 	}
 
-	var input struct {
-		Price       *float64 `json:"price"`
-		Title       *string  `json:"title"`
-		Description *string  `json:"description"`
-	}
-
-	if err := c.ShouldBindJSON(&input); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error":   "Invalid input",
-			"message": err.Error(),
+	tx, err := config.DB.Begin()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Database error",
 		})
 		return
 	}
+	defer tx.Rollback()
 
-	// ตรวจสอบว่ามีอะไรจะอัปเดตบ้าง
-	updates := make(map[string]interface{})
-	if input.Price != nil {
-		if *input.Price < 0 {
-			c.JSON(http.StatusBadRequest, gin.H{
-				"error": "Price must be positive",
-			})
-			return
-		}
-		updates["price"] = *input.Price
-	}
-	if input.Title != nil {
-		updates["title"] = *input.Title
+	var status string
+	err = tx.QueryRow(`
+		UPDATE notes_for_sale
+		SET status = COALESCE(status_before_delete, 'pending'), status_before_delete = NULL, deleted_at = NULL
+		WHERE id = $1 AND deleted_at IS NOT NULL
+		RETURNING status
+	`, noteID).Scan(&status)
+	if err == nil {
+		err = recordAudit(tx, c, AuditNoteRestore, AuditTargetNote, noteID,
+			gin.H{"status": NoteStatusDelisted}, gin.H{"status": status}, nil)
 	}
-	if input.Description != nil {
-		updates["description"] = *input.Description
+	if err == nil {
+		err = tx.Commit()
 	}
-
-	if len(updates) == 0 {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error": "No fields to update",
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "Deleted note not found",
 		})
 		return
 	}
-
-	// สร้าง query string
-	query := "UPDATE notes_for_sale SET "
-	args := []interface{}{}
-	argIndex := 1
-
-	for field, value := range updates {
-		if argIndex > 1 {
-			query += ", "
-		}
-		query += field + " = $" + fmt.Sprintf("%d", argIndex)
-		args = append(args, value)
-		argIndex++
-	}
-
-	query += " WHERE id = $" + fmt.Sprintf("%d", argIndex)
-	args = append(args, noteID)
-
-	// Execute update
-	result, err := config.DB.Exec(query, args...)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"error":   "Database error",
@@ -863,17 +909,32 @@ func UpdateNote(c *gin.Context) {
 		return
 	}
 
-	rowsAffected, _ := result.RowsAffected()
-	if rowsAffected == 0 {
-		c.JSON(http.StatusNotFound, gin.H{
-			"error": "Note not found",
-		})
-		return
-	}
-
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
-		"message": "Note updated successfully",
-		"updates": updates,
+		"message": "Note restored successfully",
+		"status":  status,
+	})
+}
+
+// UpdateNote godoc
+// @Summary อัปเดตข้อมูลสรุปวิชา (Admin)
+// @Description Admin สามารถอัปเดตชื่อ คำอธิบาย ราคา วิชา เทอมสอบ และสถานะของสรุปวิชาได้
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Note ID"
+// @Param body body NotePatch true "ข้อมูลที่ต้องการอัปเดต (title, description, price, course_id, exam_term, status)"
+// @Success 200 {object} map[string]interface{} "อัปเดตสำเร็จ พร้อมข้อมูล note ล่าสุด"
+// @Failure 400 {object} map[string]interface{} "ข้อมูลไม่ถูกต้อง"
+// @Failure 404 {object} map[string]interface{} "ไม่พบสรุปวิชา"
+// @Failure 500 {object} map[string]interface{} "เกิดข้อผิดพลาด"
+// @Router /admin/notes/{id} [put]
+func UpdateNote(c *gin.Context) {
+	handleNotePatch(c, noteEditor{
+		isAdmin: true,
+		onUpdate: func(tx *sql.Tx, before, after *UpdatedNote) error {
+			return recordAudit(tx, c, AuditNoteUpdate, AuditTargetNote, after.ID, before, after, nil)
+		},
 	})
 }
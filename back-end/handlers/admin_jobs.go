@@ -0,0 +1,174 @@
+package handlers
+
+import (
+	"back-end/jobs"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetJobs godoc
+// @Summary Get background jobs (Admin)
+// @Description List background jobs (newest first) with counts per status. Filter by status (pending, running, completed, dead) and job type
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param status query string false "Job status (pending, running, completed, dead)"
+// @Param type query string false "Job type"
+// @Param page query int false "Page number (default 1)"
+// @Param limit query int false "Items per page (default 50, max 200)"
+// @Success 200 {object} map[string]interface{} "List of jobs with total and status counts"
+// @Failure 400 {object} map[string]string "Invalid status"
+// @Failure 401 {object} map[string]string "Unauthorized"
+// @Failure 500 {object} map[string]string "Database error"
+// @Router /api/admin/jobs [get]
+func GetJobs(c *gin.Context) {
+	status := c.Query("status")
+	switch status {
+	case "", jobs.StatusPending, jobs.StatusRunning, jobs.StatusCompleted, jobs.StatusDead:
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid status",
+		})
+		return
+	}
+
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "50"))
+	if page < 1 {
+		page = 1
+	}
+	if limit < 1 || limit > 200 {
+		limit = 50
+	}
+
+	list, total, err := jobs.List(jobs.ListFilter{
+		Status: status,
+		Type:   c.Query("type"),
+		Limit:  limit,
+		Offset: (page - 1) * limit,
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Database error",
+			"message": err.Error(),
+		})
+		return
+	}
+
+	counts, err := jobs.CountByStatus()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Database error",
+			"message": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    list,
+		"total":   total,
+		"page":    page,
+		"limit":   limit,
+		"counts":  counts,
+	})
+}
+
+// GetJobByID godoc
+// @Summary Get a background job (Admin)
+// @Description Get a background job including its payload and last error
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Job ID"
+// @Success 200 {object} map[string]interface{} "Job detail"
+// @Failure 400 {object} map[string]string "Invalid job ID"
+// @Failure 401 {object} map[string]string "Unauthorized"
+// @Failure 404 {object} map[string]string "Job not found"
+// @Failure 500 {object} map[string]string "Database error"
+// @Router /api/admin/jobs/{id} [get]
+func GetJobByID(c *gin.Context) {
+	jobID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid job ID",
+		})
+		return
+	}
+
+	job, err := jobs.Get(jobID)
+	if errors.Is(err, jobs.ErrJobNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "Job not found",
+		})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Database error",
+			"message": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    job,
+	})
+}
+
+// RetryJob godoc
+// @Summary Retry a background job (Admin)
+// @Description Move a dead (or waiting-to-retry) job back to the queue to run immediately with its attempt counter reset
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Job ID"
+// @Success 200 {object} map[string]interface{} "Job queued for retry"
+// @Failure 400 {object} map[string]string "Invalid job ID"
+// @Failure 401 {object} map[string]string "Unauthorized"
+// @Failure 404 {object} map[string]string "Job not found"
+// @Failure 409 {object} map[string]string "Job is running or already completed"
+// @Failure 500 {object} map[string]string "Database error"
+// @Router /api/admin/jobs/{id}/retry [post]
+func RetryJob(c *gin.Context) {
+	jobID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid job ID",
+		})
+		return
+	}
+
+	job, err := jobs.Retry(jobID)
+	switch {
+	case errors.Is(err, jobs.ErrJobNotFound):
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "Job not found",
+		})
+		return
+	case errors.Is(err, jobs.ErrJobNotRetryable):
+		c.JSON(http.StatusConflict, gin.H{
+			"error": err.Error(),
+		})
+		return
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Database error",
+			"message": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "Job queued for retry",
+		"data":    job,
+	})
+}
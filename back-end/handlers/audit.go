@@ -0,0 +1,336 @@
+package handlers
+
+import (
+	"back-end/config"
+	"bytes"
+	"database/sql"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ชนิดของการกระทำใน audit log (รูปแบบ "<สิ่งที่ถูกกระทำ>.<การกระทำ>")
+const (
+	AuditUserSuspend   = "user.suspend"
+	AuditUserBan       = "user.ban"
+	AuditUserReinstate = "user.reinstate"
+	AuditRoleGrant     = "user.role_grant"
+	AuditRoleRevoke    = "user.role_revoke"
+	AuditNoteApprove   = "note.approve"
+	AuditNoteReject    = "note.reject"
+	AuditNoteUpdate    = "note.update"
+	AuditNoteDelete    = "note.delete"
+	AuditNoteRestore   = "note.restore"
+	AuditSliderCreate  = "slider.create"
+	AuditSliderUpdate  = "slider.update"
+	AuditSliderDelete  = "slider.delete"
+
+	AuditRoleCreate       = "role.create"
+	AuditRoleUpdate       = "role.update"
+	AuditRoleDelete       = "role.delete"
+	AuditPermissionCreate = "permission.create"
+	AuditPermissionUpdate = "permission.update"
+	AuditPermissionDelete = "permission.delete"
+
+	AuditSellerApplicationApprove = "seller_application.approve"
+	AuditSellerApplicationReject  = "seller_application.reject"
+)
+
+// ชนิดของสิ่งที่ถูกกระทำใน audit log
+const (
+	AuditTargetUser   = "user"
+	AuditTargetNote   = "note"
+	AuditTargetSlider = "slider_image"
+
+	AuditTargetRole       = "role"
+	AuditTargetPermission = "permission"
+
+	AuditTargetSellerApplication = "seller_application"
+)
+
+const auditTimeLayout = "2006-01-02"
+
+// auditDiff - ค่าก่อนและหลังการเปลี่ยนแปลง (nil = ไม่มี) และข้อมูลประกอบ เช่น เหตุผล
+type auditDiff struct {
+	Before  interface{} `json:"before"`
+	After   interface{} `json:"after"`
+	Details interface{} `json:"details,omitempty"`
+}
+
+// AuditEntry - รายการใน audit log
+type AuditEntry struct {
+	ID         int64           `json:"id"`
+	ActorID    *int            `json:"actor_id"`
+	ActorEmail string          `json:"actor_email"`
+	Action     string          `json:"action" example:"note.approve"`
+	TargetType string          `json:"target_type" example:"note"`
+	TargetID   int             `json:"target_id"`
+	Diff       json.RawMessage `json:"diff" swaggertype:"object"`
+	IP         string          `json:"ip"`
+	CreatedAt  time.Time       `json:"created_at"`
+}
+
+// recordAudit - บันทึกการกระทำของ admin ลง audit_log (ควรเรียกใน transaction เดียวกับการเปลี่ยนแปลง)
+// ถ้า before และ after เป็น object ทั้งคู่ จะเก็บเฉพาะ field ที่เปลี่ยน
+func recordAudit(db notifyDB, c *gin.Context, action, targetType string, targetID int, before, after, details interface{}) error {
+	before, after, err := changedFields(before, after)
+	if err != nil {
+		return err
+	}
+	diff, err := json.Marshal(auditDiff{Before: before, After: after, Details: details})
+	if err != nil {
+		return err
+	}
+	_, err = db.Exec(`
+		INSERT INTO audit_log (actor_id, actor_email, action, target_type, target_id, diff, ip, created_at)
+		VALUES ($1, (SELECT email FROM users WHERE id = $1), $2, $3, $4, $5, $6, NOW())
+	`, c.GetInt("user_id"), action, targetType, targetID, diff, c.ClientIP())
+	return err
+}
+
+// changedFields - ตัด field ที่ค่าเท่าเดิมออกจาก before/after (ค่าอื่นที่ไม่ใช่ object คืนตามเดิม)
+func changedFields(before, after interface{}) (interface{}, interface{}, error) {
+	if before == nil || after == nil {
+		return before, after, nil
+	}
+	b, err := toJSONObject(before)
+	if err != nil || b == nil {
+		return before, after, err
+	}
+	a, err := toJSONObject(after)
+	if err != nil || a == nil {
+		return before, after, err
+	}
+	for key, value := range b {
+		if other, ok := a[key]; ok && reflect.DeepEqual(value, other) {
+			delete(a, key)
+			delete(b, key)
+		}
+	}
+	return b, a, nil
+}
+
+// toJSONObject - แปลงค่าเป็น map ผ่าน JSON (คืน nil ถ้าไม่ใช่ object)
+func toJSONObject(v interface{}) (map[string]interface{}, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	if !bytes.HasPrefix(bytes.TrimSpace(data), []byte("{")) {
+		return nil, nil
+	}
+	var m map[string]interface{}
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// auditFilter - เงื่อนไขจาก query string ที่ใช้ร่วมกันระหว่างการดูและ export
+func auditFilter(c *gin.Context) (string, []interface{}, error) {
+	actorID := c.Query("actor_id")
+	if _, err := strconv.Atoi(actorID); actorID != "" && err != nil {
+		return "", nil, &NoteFieldError{Field: "actor_id", Message: "must be an integer"}
+	}
+	targetID := c.Query("target_id")
+	if _, err := strconv.Atoi(targetID); targetID != "" && err != nil {
+		return "", nil, &NoteFieldError{Field: "target_id", Message: "must be an integer"}
+	}
+
+	// from/to เป็นวันที่ (YYYY-MM-DD) นับรวมทั้งวันของ to
+	var from, to interface{}
+	if s := c.Query("from"); s != "" {
+		t, err := time.Parse(auditTimeLayout, s)
+		if err != nil {
+			return "", nil, &NoteFieldError{Field: "from", Message: "must be a date (YYYY-MM-DD)"}
+		}
+		from = t
+	}
+	if s := c.Query("to"); s != "" {
+		t, err := time.Parse(auditTimeLayout, s)
+		if err != nil {
+			return "", nil, &NoteFieldError{Field: "to", Message: "must be a date (YYYY-MM-DD)"}
+		}
+		to = t.AddDate(0, 0, 1)
+	}
+
+	args := []interface{}{actorID, c.Query("action"), c.Query("target_type"), targetID, from, to}
+	where := `($1 = '' OR a.actor_id::text = $1) AND ($2 = '' OR a.action = $2)
+		AND ($3 = '' OR a.target_type = $3) AND ($4 = '' OR a.target_id::text = $4)
+		AND ($5::timestamptz IS NULL OR a.created_at >= $5) AND ($6::timestamptz IS NULL OR a.created_at < $6)`
+	return where, args, nil
+}
+
+const auditSelect = `
+	SELECT a.id, a.actor_id, COALESCE(a.actor_email, ''), a.action, a.target_type, a.target_id,
+		a.diff, COALESCE(a.ip, ''), a.created_at
+	FROM audit_log a`
+
+func scanAuditEntry(rows *sql.Rows) (*AuditEntry, error) {
+	var e AuditEntry
+	var actorID sql.NullInt64
+	var diff []byte
+	if err := rows.Scan(&e.ID, &actorID, &e.ActorEmail, &e.Action, &e.TargetType, &e.TargetID, &diff, &e.IP, &e.CreatedAt); err != nil {
+		return nil, err
+	}
+	if actorID.Valid {
+		id := int(actorID.Int64)
+		e.ActorID = &id
+	}
+	e.Diff = diff
+	return &e, nil
+}
+
+// respondAuditFilterError - 400 สำหรับ filter ที่ไม่ถูกต้อง
+func respondAuditFilterError(c *gin.Context, err error) {
+	fieldErr := err.(*NoteFieldError)
+	c.JSON(http.StatusBadRequest, gin.H{
+		"error":   "Invalid filter",
+		"field":   fieldErr.Field,
+		"message": fieldErr.Message,
+	})
+}
+
+// GetAuditLog godoc
+// @Summary Get audit log (Admin)
+// @Description Privileged actions taken by admins, newest first
+// @Tags admin
+// @Produce json
+// @Security BearerAuth
+// @Param actor_id query int false "Admin who performed the action"
+// @Param action query string false "Action, e.g. note.approve"
+// @Param target_type query string false "Target type (user, note, slider_image, role, permission)"
+// @Param target_id query int false "Target ID"
+// @Param from query string false "From date (YYYY-MM-DD)"
+// @Param to query string false "To date, inclusive (YYYY-MM-DD)"
+// @Param page query int false "Page number" default(1)
+// @Param limit query int false "Items per page (max 200)" default(50)
+// @Success 200 {object} map[string]interface{} "Audit log entries"
+// @Failure 400 {object} map[string]string "Invalid filter"
+// @Failure 401 {object} map[string]string "Unauthorized"
+// @Failure 500 {object} map[string]string "Database error"
+// @Router /api/admin/audit-log [get]
+func GetAuditLog(c *gin.Context) {
+	where, args, err := auditFilter(c)
+	if err != nil {
+		respondAuditFilterError(c, err)
+		return
+	}
+
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "50"))
+	if page < 1 {
+		page = 1
+	}
+	if limit < 1 || limit > 200 {
+		limit = 50
+	}
+
+	var total int
+	if err := config.DB.QueryRow(`SELECT COUNT(*) FROM audit_log a WHERE `+where, args...).Scan(&total); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+
+	args = append(args, limit, (page-1)*limit)
+	rows, err := config.DB.Query(fmt.Sprintf(auditSelect+`
+		WHERE %s
+		ORDER BY a.created_at DESC, a.id DESC
+		LIMIT $%d OFFSET $%d
+	`, where, len(args)-1, len(args)), args...)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+	defer rows.Close()
+
+	entries := []AuditEntry{}
+	for rows.Next() {
+		entry, err := scanAuditEntry(rows)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error scanning audit log"})
+			return
+		}
+		entries = append(entries, *entry)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    entries,
+		"total":   total,
+		"page":    page,
+		"limit":   limit,
+	})
+}
+
+// ExportAuditLog godoc
+// @Summary Export audit log as CSV (Admin)
+// @Description All audit log entries matching the filters as a CSV file, newest first
+// @Tags admin
+// @Produce text/csv
+// @Security BearerAuth
+// @Param actor_id query int false "Admin who performed the action"
+// @Param action query string false "Action, e.g. note.approve"
+// @Param target_type query string false "Target type (user, note, slider_image, role, permission)"
+// @Param target_id query int false "Target ID"
+// @Param from query string false "From date (YYYY-MM-DD)"
+// @Param to query string false "To date, inclusive (YYYY-MM-DD)"
+// @Success 200 {file} file "CSV file"
+// @Failure 400 {object} map[string]string "Invalid filter"
+// @Failure 401 {object} map[string]string "Unauthorized"
+// @Failure 500 {object} map[string]string "Database error"
+// @Router /api/admin/audit-log/export [get]
+func ExportAuditLog(c *gin.Context) {
+	where, args, err := auditFilter(c)
+	if err != nil {
+		respondAuditFilterError(c, err)
+		return
+	}
+
+	rows, err := config.DB.Query(auditSelect+`
+		WHERE `+where+`
+		ORDER BY a.created_at DESC, a.id DESC
+	`, args...)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+	defer rows.Close()
+
+	filename := fmt.Sprintf("audit-log-%s.csv", time.Now().Format(auditTimeLayout))
+	c.Header("Content-Type", "text/csv; charset=utf-8")
+	c.Header("Content-Disposition", "attachment; filename="+filename)
+	c.Status(http.StatusOK)
+
+	w := csv.NewWriter(c.Writer)
+	w.Write([]string{"id", "created_at", "actor_id", "actor_email", "action", "target_type", "target_id", "ip", "diff"})
+	for rows.Next() {
+		entry, err := scanAuditEntry(rows)
+		if err != nil {
+			// header ถูกส่งไปแล้ว เปลี่ยนเป็น error response ไม่ได้
+			break
+		}
+		actorID := ""
+		if entry.ActorID != nil {
+			actorID = strconv.Itoa(*entry.ActorID)
+		}
+		w.Write([]string{
+			strconv.FormatInt(entry.ID, 10),
+			entry.CreatedAt.Format(time.RFC3339),
+			actorID,
+			entry.ActorEmail,
+			entry.Action,
+			entry.TargetType,
+			strconv.Itoa(entry.TargetID),
+			entry.IP,
+			string(entry.Diff),
+		})
+	}
+	w.Flush()
+}
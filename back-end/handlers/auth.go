@@ -3,6 +3,7 @@ package handlers
 import (
 	"back-end/config"
 	"back-end/models"
+	"back-end/permissions"
 	"back-end/utils"
 	"database/sql"
 	"net/http"
@@ -21,6 +22,7 @@ import (
 // @Success 200 {object} map[string]interface{} "เข้าสู่ระบบสำเร็จ"
 // @Failure 400 {object} map[string]interface{} "ข้อมูลไม่ถูกต้อง"
 // @Failure 401 {object} map[string]interface{} "รหัสผ่านไม่ถูกต้อง"
+// @Failure 403 {object} map[string]interface{} "บัญชีถูกระงับหรือแบน"
 // @Failure 500 {object} map[string]interface{} "Server error"
 // @Router /login [post]
 func Login(c *gin.Context) {
@@ -78,6 +80,19 @@ func Login(c *gin.Context) {
 		return
 	}
 
+	// บัญชีที่ถูกแบนหรือระงับ login ไม่ได้ (ระงับ = จนกว่าจะพ้นกำหนด)
+	account, err := loadAccountStatus(config.DB, user.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Database error",
+			"message": err.Error(),
+		})
+		return
+	}
+	if respondAccountBlocked(c, account) {
+		return
+	}
+
 	// ดึง roles ของ user
 	roles, err := getUserRoles(user.ID)
 	if err != nil {
@@ -88,8 +103,18 @@ func Login(c *gin.Context) {
 		return
 	}
 
+	// ดึงสิทธิ์ของ user (ให้ frontend รู้ว่าแสดงเมนูไหนได้ การตรวจจริงทำที่ RequirePermission)
+	perms, err := permissions.ForUser(config.DB, user.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Failed to get user permissions",
+			"message": err.Error(),
+		})
+		return
+	}
+
 	// สร้าง JWT access token
-	accessToken, err := utils.GenerateJWT(user.ID, user.Email, roles)
+	accessToken, err := utils.GenerateJWT(user.ID, user.Email, roles, account.TokenVersion)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"error":   "Failed to generate token",
@@ -125,8 +150,9 @@ func Login(c *gin.Context) {
 
 	// สร้าง response
 	userWithRoles := models.UserWithRoles{
-		User:  user,
-		Roles: roles,
+		User:        user,
+		Roles:       roles,
+		Permissions: perms,
 	}
 
 	response := models.LoginResponse{
@@ -2,12 +2,12 @@ package handlers
 
 import (
 	"back-end/config"
+	"back-end/utils"
+	"database/sql"
 	"fmt"
-	"io"
 	"net/http"
 	"os"
 	"path/filepath"
-	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
@@ -15,12 +15,12 @@ import (
 
 // UploadAvatar godoc
 // @Summary อัปโหลดรูป avatar ของผู้ใช้
-// @Description อัปโหลดรูปภาพ avatar และบันทึก URL ลงในฐานข้อมูล
+// @Description อัปโหลดรูปภาพ avatar ตรวจสอบเนื้อหาไฟล์ ลบ EXIF และสร้างรูปขนาด thumb/card/full แล้วบันทึก URL (ขนาด card) ลงในฐานข้อมูล
 // @Tags users
 // @Accept multipart/form-data
 // @Produce json
 // @Security BearerAuth
-// @Param avatar formData file true "ไฟล์รูปภาพ avatar"
+// @Param avatar formData file true "ไฟล์รูปภาพ avatar (jpg, png, gif, webp ไม่เกิน 5MB)"
 // @Success 200 {object} map[string]interface{} "อัปโหลดสำเร็จ"
 // @Failure 400 {object} map[string]string "ข้อมูลไม่ถูกต้อง"
 // @Failure 401 {object} map[string]string "ไม่ได้เข้าสู่ระบบ"
@@ -45,94 +45,62 @@ func UploadAvatar(c *gin.Context) {
 		return
 	}
 
-	// ตรวจสอบประเภทไฟล์ (รับเฉพาะรูปภาพ)
-	ext := strings.ToLower(filepath.Ext(file.Filename))
-	allowedExts := map[string]bool{
-		".jpg":  true,
-		".jpeg": true,
-		".png":  true,
-		".gif":  true,
-		".webp": true,
-	}
-
-	if !allowedExts[ext] {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error": "Invalid file type. Only jpg, jpeg, png, gif, and webp are allowed",
-		})
-		return
-	}
-
-	// ตรวจสอบขนาดไฟล์ (ไม่เกิน 5MB)
-	if file.Size > 5*1024*1024 {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error": "File size too large. Maximum 5MB allowed",
-		})
-		return
-	}
-
-	// สร้างชื่อไฟล์ใหม่ (เพื่อป้องกันชื่อซ้ำ)
-	timestamp := time.Now().Unix()
-	newFilename := fmt.Sprintf("avatar_%d_%d%s", userID, timestamp, ext)
-
-	// สร้างโฟลเดอร์ถ้ายังไม่มี
-	uploadDir := "./uploads/images"
-	if err := os.MkdirAll(uploadDir, 0755); err != nil {
+	// ตรวจสอบเนื้อหาไฟล์ (ต้องเป็นรูปจริง ไม่เกิน 5MB) และสร้างรูปขนาด thumb/card/full
+	variants, err := processUploadedImage(file, maxAvatarUploadSize, utils.AvatarVariants)
+	if err != nil {
+		if validationErr, ok := err.(*ImageValidationError); ok {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": validationErr.Message,
+			})
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{
-			"error":   "Failed to create upload directory",
+			"error":   "Failed to process image",
 			"message": err.Error(),
 		})
 		return
 	}
 
-	// เส้นทางเต็มของไฟล์
-	filePath := filepath.Join(uploadDir, newFilename)
-
-	// บันทึกไฟล์
-	src, err := file.Open()
+	// บันทึกไฟล์ (ตั้งชื่อใหม่เพื่อป้องกันชื่อซ้ำ)
+	timestamp := time.Now().Unix()
+	saved, err := writeImageVariants("./uploads/images", fmt.Sprintf("avatar_%d_%d", userID, timestamp), variants)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
-			"error":   "Failed to open uploaded file",
+			"error":   "Failed to save file",
 			"message": err.Error(),
 		})
 		return
 	}
-	defer src.Close()
 
-	dst, err := os.Create(filePath)
+	// avatar_url ใช้รูปขนาด card
+	avatarURL := "/" + filepath.ToSlash(variantPath(saved, "card"))
+
+	tx, err := config.DB.Begin()
 	if err != nil {
+		removeVariantFiles(saved)
 		c.JSON(http.StatusInternalServerError, gin.H{
-			"error":   "Failed to create file",
-			"message": err.Error(),
+			"error": "Database error",
 		})
 		return
 	}
-	defer dst.Close()
-
-	if _, err := io.Copy(dst, src); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error":   "Failed to save file",
-			"message": err.Error(),
-		})
-		return
+	defer tx.Rollback()
+
+	// ดึงรูปเก่าไว้ลบหลังบันทึกสำเร็จ
+	var oldAvatarURL sql.NullString
+	tx.QueryRow("SELECT avatar_url FROM users WHERE id = $1", userID).Scan(&oldAvatarURL)
+	oldPaths, err := deleteImageVariants(tx, avatarOwner(userID))
+	if err == nil {
+		_, err = tx.Exec("UPDATE users SET avatar_url = $1 WHERE id = $2", avatarURL, userID)
 	}
-
-	// บันทึก URL ลงในฐานข้อมูล
-	avatarURL := fmt.Sprintf("/uploads/images/%s", newFilename)
-	
-	// ลบรูปเก่าออกก่อน (ถ้ามี)
-	var oldAvatarURL string
-	err = config.DB.QueryRow("SELECT avatar_url FROM users WHERE id = $1", userID).Scan(&oldAvatarURL)
-	if err == nil && oldAvatarURL != "" {
-		// ลบไฟล์เก่า
-		oldFilePath := filepath.Join(".", oldAvatarURL)
-		os.Remove(oldFilePath)
+	if err == nil {
+		err = insertImageVariants(tx, avatarOwner(userID), saved)
+	}
+	if err == nil {
+		err = tx.Commit()
 	}
-
-	// อัปเดต avatar_url ในฐานข้อมูล
-	_, err = config.DB.Exec("UPDATE users SET avatar_url = $1 WHERE id = $2", avatarURL, userID)
 	if err != nil {
 		// ลบไฟล์ที่เพิ่งอัปโหลดถ้าบันทึกในฐานข้อมูลไม่สำเร็จ
-		os.Remove(filePath)
+		removeVariantFiles(saved)
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"error":   "Failed to update database",
 			"message": err.Error(),
@@ -140,10 +108,23 @@ func UploadAvatar(c *gin.Context) {
 		return
 	}
 
+	// ลบไฟล์รูปเก่า (ทุกขนาด)
+	for _, path := range oldPaths {
+		os.Remove(path)
+	}
+	if oldAvatarURL.Valid && oldAvatarURL.String != "" {
+		os.Remove(filepath.Join(".", oldAvatarURL.String))
+	}
+
 	c.JSON(http.StatusOK, gin.H{
 		"success":    true,
 		"message":    "Avatar uploaded successfully",
 		"avatar_url": avatarURL,
+		"avatar_variants": gin.H{
+			"thumb": "/" + filepath.ToSlash(variantPath(saved, "thumb")),
+			"card":  avatarURL,
+			"full":  "/" + filepath.ToSlash(variantPath(saved, "full")),
+		},
 	})
 }
 
@@ -168,7 +149,7 @@ func DeleteAvatar(c *gin.Context) {
 	userID := userIDInterface.(int)
 
 	// ดึง avatar URL จากฐานข้อมูล
-	var avatarURL string
+	var avatarURL sql.NullString
 	err := config.DB.QueryRow("SELECT avatar_url FROM users WHERE id = $1", userID).Scan(&avatarURL)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
@@ -179,11 +160,18 @@ func DeleteAvatar(c *gin.Context) {
 	}
 
 	// ลบไฟล์ (ถ้ามี)
-	if avatarURL != "" {
-		filePath := filepath.Join(".", avatarURL)
+	if avatarURL.Valid && avatarURL.String != "" {
+		filePath := filepath.Join(".", avatarURL.String)
 		os.Remove(filePath) // ไม่สนใจ error ถ้าไฟล์ไม่มีอยู่
 	}
 
+	// ลบรูปทุกขนาดของ avatar
+	if paths, err := deleteImageVariants(config.DB, avatarOwner(userID)); err == nil {
+		for _, path := range paths {
+			os.Remove(path)
+		}
+	}
+
 	// อัปเดตฐานข้อมูล (ตั้งค่า avatar_url เป็น NULL)
 	_, err = config.DB.Exec("UPDATE users SET avatar_url = NULL WHERE id = $1", userID)
 	if err != nil {
@@ -0,0 +1,502 @@
+package handlers
+
+import (
+	"back-end/config"
+	"database/sql"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lib/pq"
+)
+
+// BundleInput - ข้อมูลสำหรับสร้าง/แก้ไข bundle (แก้ไขแบบแทนที่ทั้งชุด)
+type BundleInput struct {
+	Title       string  `json:"title" example:"สรุป Database กลางภาค + ปลายภาค"`
+	Description string  `json:"description"`
+	Price       float64 `json:"price" example:"149"`
+	NoteIDs     []int   `json:"note_ids"`
+	Status      *string `json:"status"` // available | inactive (ตอนแก้ไขเท่านั้น, nil = ไม่เปลี่ยน)
+}
+
+// validate - ตรวจสอบข้อมูล bundle (ยกเว้นเจ้าของและสถานะของ note ซึ่งตรวจใน database)
+func (in *BundleInput) validate() error {
+	in.Title = strings.TrimSpace(in.Title)
+	in.Description = strings.TrimSpace(in.Description)
+
+	if in.Title == "" {
+		return &NoteFieldError{"title", "must not be empty"}
+	}
+	if utf8.RuneCountInString(in.Title) > maxBundleTitleLength {
+		return &NoteFieldError{"title", fmt.Sprintf("must be at most %d characters", maxBundleTitleLength)}
+	}
+	if utf8.RuneCountInString(in.Description) > maxBundleDescriptionLength {
+		return &NoteFieldError{"description", fmt.Sprintf("must be at most %d characters", maxBundleDescriptionLength)}
+	}
+	if in.Price <= 0 || in.Price > maxNotePrice {
+		return &NoteFieldError{"price", "must be greater than 0"}
+	}
+	if in.Status != nil && *in.Status != BundleAvailable && *in.Status != BundleInactive {
+		return &NoteFieldError{"status", "must be 'available' or 'inactive'"}
+	}
+
+	// ตัด id ซ้ำโดยคงลำดับเดิมไว้ (ใช้เป็นลำดับการแสดง)
+	seen := map[int]bool{}
+	noteIDs := []int{}
+	for _, id := range in.NoteIDs {
+		if !seen[id] {
+			seen[id] = true
+			noteIDs = append(noteIDs, id)
+		}
+	}
+	in.NoteIDs = noteIDs
+	if len(in.NoteIDs) < minBundleNotes || len(in.NoteIDs) > maxBundleNotes {
+		return &NoteFieldError{"note_ids", fmt.Sprintf("must contain %d-%d different notes", minBundleNotes, maxBundleNotes)}
+	}
+	return nil
+}
+
+// saveBundle - สร้าง (bundleID = 0) หรือแก้ไข bundle ของ seller
+// note ทุกเล่มต้องเป็นของ seller คนนี้และพร้อมขาย ราคา bundle ต้องไม่เกินราคารวมของ note
+func saveBundle(in *BundleInput, sellerID, bundleID int) (int, error) {
+	if err := in.validate(); err != nil {
+		return 0, err
+	}
+
+	tx, err := config.DB.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.Query(`
+		SELECT id, seller_id, price, status = 'available' AND deleted_at IS NULL
+		FROM notes_for_sale WHERE id = ANY($1)
+	`, pq.Array(in.NoteIDs))
+	if err != nil {
+		return 0, err
+	}
+	found := 0
+	listPrice := 0.0
+	for rows.Next() {
+		var id, noteSellerID int
+		var price float64
+		var available bool
+		if err := rows.Scan(&id, &noteSellerID, &price, &available); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		if noteSellerID != sellerID {
+			rows.Close()
+			return 0, &NoteFieldError{"note_ids", fmt.Sprintf("note %d does not belong to you", id)}
+		}
+		if !available {
+			rows.Close()
+			return 0, &NoteFieldError{"note_ids", fmt.Sprintf("note %d is not available for sale", id)}
+		}
+		found++
+		listPrice += price
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+	if found != len(in.NoteIDs) {
+		return 0, &NoteFieldError{"note_ids", "note not found"}
+	}
+	if in.Price > roundMoney(listPrice) {
+		return 0, &NoteFieldError{"price", fmt.Sprintf("must not exceed the combined price of the notes (%.2f)", roundMoney(listPrice))}
+	}
+
+	if bundleID == 0 {
+		err = tx.QueryRow(`
+			INSERT INTO bundles (seller_id, title, description, price, status, created_at, updated_at)
+			VALUES ($1, $2, $3, $4, 'available', NOW(), NOW())
+			RETURNING id
+		`, sellerID, in.Title, in.Description, in.Price).Scan(&bundleID)
+	} else {
+		err = tx.QueryRow(`
+			UPDATE bundles SET title = $1, description = $2, price = $3, status = COALESCE($4, status), updated_at = NOW()
+			WHERE id = $5 AND seller_id = $6
+			RETURNING id
+		`, in.Title, in.Description, in.Price, in.Status, bundleID, sellerID).Scan(&bundleID)
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	if _, err := tx.Exec(`DELETE FROM bundle_items WHERE bundle_id = $1`, bundleID); err != nil {
+		return 0, err
+	}
+	for position, noteID := range in.NoteIDs {
+		_, err := tx.Exec(`INSERT INTO bundle_items (bundle_id, note_id, position) VALUES ($1, $2, $3)`,
+			bundleID, noteID, position)
+		if err != nil {
+			return 0, err
+		}
+	}
+	return bundleID, tx.Commit()
+}
+
+// loadBundles - ดึง bundle ตามเงื่อนไข (ใช้ alias b) พร้อม note ทุกเล่มในชุด
+func loadBundles(where string, args ...interface{}) ([]Bundle, error) {
+	rows, err := config.DB.Query(`
+		SELECT b.id, b.title, COALESCE(b.description, ''), b.price, b.status, b.created_at, b.updated_at,
+			u.id, u.username, u.fullname
+		FROM bundles b
+		JOIN users u ON b.seller_id = u.id
+		WHERE `+where+`
+		ORDER BY b.created_at DESC
+	`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	bundles := []Bundle{}
+	ids := []int{}
+	for rows.Next() {
+		var bundle Bundle
+		if err := rows.Scan(&bundle.ID, &bundle.Title, &bundle.Description, &bundle.Price, &bundle.Status,
+			&bundle.CreatedAt, &bundle.UpdatedAt,
+			&bundle.Seller.ID, &bundle.Seller.Username, &bundle.Seller.Fullname); err != nil {
+			return nil, err
+		}
+		bundle.Notes = []BundleNote{}
+		bundles = append(bundles, bundle)
+		ids = append(ids, bundle.ID)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if len(bundles) == 0 {
+		return bundles, nil
+	}
+
+	itemRows, err := config.DB.Query(`
+		SELECT bi.bundle_id, n.id, n.book_title, n.price, COALESCE(n.exam_term, ''), n.status,
+			COALESCE(`+coverImageSQL("card")+`, ''),
+			c.id, c.code, c.name, c.year, c.major
+		FROM bundle_items bi
+		JOIN notes_for_sale n ON bi.note_id = n.id
+		LEFT JOIN courses c ON n.course_id = c.id
+		WHERE bi.bundle_id = ANY($1)
+		ORDER BY bi.position, n.id
+	`, pq.Array(ids))
+	if err != nil {
+		return nil, err
+	}
+	defer itemRows.Close()
+
+	byID := map[int]*Bundle{}
+	for i := range bundles {
+		bundles[i].IsSellable = bundles[i].Status == BundleAvailable
+		byID[bundles[i].ID] = &bundles[i]
+	}
+	for itemRows.Next() {
+		var bundleID int
+		var note BundleNote
+		var courseID sql.NullInt64
+		var courseCode, courseName, courseYear, courseMajor sql.NullString
+		if err := itemRows.Scan(&bundleID, &note.ID, &note.BookTitle, &note.Price, &note.ExamTerm, &note.Status,
+			&note.CoverImage, &courseID, &courseCode, &courseName, &courseYear, &courseMajor); err != nil {
+			return nil, err
+		}
+		if courseID.Valid {
+			note.Course = &Course{
+				ID:    int(courseID.Int64),
+				Code:  courseCode.String,
+				Name:  courseName.String,
+				Year:  courseYear.String,
+				Major: courseMajor.String,
+			}
+		}
+
+		bundle := byID[bundleID]
+		bundle.Notes = append(bundle.Notes, note)
+		bundle.ListPrice += note.Price
+		if note.Status != NoteStatusAvailable {
+			bundle.IsSellable = false
+		}
+	}
+	if err := itemRows.Err(); err != nil {
+		return nil, err
+	}
+
+	for i := range bundles {
+		bundles[i].ListPrice = roundMoney(bundles[i].ListPrice)
+		bundles[i].Savings = roundMoney(bundles[i].ListPrice - bundles[i].Price)
+	}
+	return bundles, nil
+}
+
+// getNoteBundles - bundle ที่ขายได้ซึ่งมี note เล่มนี้อยู่
+func getNoteBundles(noteID int) []BundleSummary {
+	rows, err := config.DB.Query(`
+		SELECT b.id, b.title, b.price,
+			(SELECT COALESCE(SUM(n.price), 0) FROM bundle_items bi JOIN notes_for_sale n ON bi.note_id = n.id WHERE bi.bundle_id = b.id),
+			(SELECT COUNT(*) FROM bundle_items bi WHERE bi.bundle_id = b.id)
+		FROM bundles b
+		WHERE b.id IN (SELECT bundle_id FROM bundle_items WHERE note_id = $1) AND `+bundleSellableSQL+`
+		ORDER BY b.price
+	`, noteID)
+	if err != nil {
+		return nil
+	}
+	defer rows.Close()
+
+	bundles := []BundleSummary{}
+	for rows.Next() {
+		var bundle BundleSummary
+		if err := rows.Scan(&bundle.ID, &bundle.Title, &bundle.Price, &bundle.ListPrice, &bundle.NoteCount); err == nil {
+			bundles = append(bundles, bundle)
+		}
+	}
+	return bundles
+}
+
+// GetBundles godoc
+// @Summary ดึงรายการ bundle ที่พร้อมขาย
+// @Description ดึง bundle ที่เปิดขายและ note ทุกเล่มในชุดพร้อมขาย พร้อม filter
+// @Tags bundles
+// @Produce json
+// @Param major query string false "กรองตามสาขาของ note ในชุด"
+// @Param search query string false "ค้นหาตามชื่อหรือรายละเอียด"
+// @Param seller_id query int false "กรองตาม seller"
+// @Success 200 {array} Bundle "รายการ bundle"
+// @Failure 500 {object} map[string]interface{} "Server error"
+// @Router /api/bundles [get]
+func GetBundles(c *gin.Context) {
+	where := bundleSellableSQL
+	args := []interface{}{}
+
+	if major := c.Query("major"); major != "" {
+		args = append(args, major)
+		where += fmt.Sprintf(` AND EXISTS (
+			SELECT 1 FROM bundle_items bi JOIN notes_for_sale n ON bi.note_id = n.id JOIN courses c ON n.course_id = c.id
+			WHERE bi.bundle_id = b.id AND c.major = $%d)`, len(args))
+	}
+	if search := c.Query("search"); search != "" {
+		args = append(args, "%"+search+"%")
+		where += fmt.Sprintf(` AND (b.title ILIKE $%d OR b.description ILIKE $%d)`, len(args), len(args))
+	}
+	if sellerID, err := strconv.Atoi(c.Query("seller_id")); err == nil {
+		args = append(args, sellerID)
+		where += fmt.Sprintf(` AND b.seller_id = $%d`, len(args))
+	}
+
+	bundles, err := loadBundles(where, args...)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Database error",
+			"message": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, bundles)
+}
+
+// GetBundleByID godoc
+// @Summary ดึง bundle ตาม ID
+// @Description ดึงรายละเอียด bundle พร้อม note ทุกเล่มในชุด (is_sellable = false ถ้ามี note ที่ไม่พร้อมขาย)
+// @Tags bundles
+// @Produce json
+// @Param id path int true "Bundle ID"
+// @Success 200 {object} map[string]interface{} "รายละเอียด bundle ใน field data"
+// @Failure 404 {object} map[string]string "Bundle not found"
+// @Failure 500 {object} map[string]string "Database error"
+// @Router /api/bundles/{id} [get]
+func GetBundleByID(c *gin.Context) {
+	bundleID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid bundle ID",
+		})
+		return
+	}
+
+	bundles, err := loadBundles(`b.id = $1 AND b.status = 'available'`, bundleID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Database error",
+			"message": err.Error(),
+		})
+		return
+	}
+	if len(bundles) == 0 {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "Bundle not found",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": bundles[0],
+	})
+}
+
+// handleSaveBundle - logic ร่วมของการสร้างและแก้ไข bundle
+func handleSaveBundle(c *gin.Context, bundleID int) {
+	sellerID := c.GetInt("user_id")
+
+	var input BundleInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid input",
+			"message": err.Error(),
+		})
+		return
+	}
+	if bundleID == 0 {
+		input.Status = nil
+	}
+
+	savedID, err := saveBundle(&input, sellerID, bundleID)
+	if err != nil {
+		if fieldErr, ok := err.(*NoteFieldError); ok {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error":   "Invalid input",
+				"field":   fieldErr.Field,
+				"message": fieldErr.Message,
+			})
+			return
+		}
+		if err == sql.ErrNoRows {
+			c.JSON(http.StatusNotFound, gin.H{
+				"error": "Bundle not found",
+			})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Database error",
+			"message": err.Error(),
+		})
+		return
+	}
+
+	bundles, err := loadBundles(`b.id = $1`, savedID)
+	if err != nil || len(bundles) == 0 {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to load bundle",
+		})
+		return
+	}
+
+	status, message := http.StatusOK, "Bundle updated successfully"
+	if bundleID == 0 {
+		status, message = http.StatusCreated, "Bundle created successfully"
+	}
+	c.JSON(status, gin.H{
+		"success": true,
+		"message": message,
+		"data":    bundles[0],
+	})
+}
+
+// CreateBundle godoc
+// @Summary สร้าง bundle (Seller)
+// @Description Seller รวม note ของตัวเองที่พร้อมขาย 2-20 เล่มขายในราคาเดียว (ราคาต้องไม่เกินราคารวมของ note)
+// @Tags bundles
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param body body BundleInput true "ข้อมูล bundle"
+// @Success 201 {object} map[string]interface{} "สร้างสำเร็จ"
+// @Failure 400 {object} map[string]interface{} "ข้อมูลไม่ถูกต้อง"
+// @Failure 401 {object} map[string]string "Unauthorized"
+// @Failure 500 {object} map[string]string "Server error"
+// @Router /api/seller/bundles [post]
+func CreateBundle(c *gin.Context) {
+	handleSaveBundle(c, 0)
+}
+
+// UpdateMyBundle godoc
+// @Summary แก้ไข bundle ของตัวเอง (Seller)
+// @Description แทนที่ชื่อ รายละเอียด ราคา และรายการ note ของ bundle ทั้งหมด และเปิด/ปิดการขายผ่าน status ได้
+// @Tags bundles
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Bundle ID"
+// @Param body body BundleInput true "ข้อมูล bundle"
+// @Success 200 {object} map[string]interface{} "แก้ไขสำเร็จ"
+// @Failure 400 {object} map[string]interface{} "ข้อมูลไม่ถูกต้อง"
+// @Failure 404 {object} map[string]string "ไม่พบ bundle หรือไม่ใช่เจ้าของ"
+// @Failure 500 {object} map[string]string "Server error"
+// @Router /api/seller/bundles/{id} [put]
+func UpdateMyBundle(c *gin.Context) {
+	bundleID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid bundle ID",
+		})
+		return
+	}
+	handleSaveBundle(c, bundleID)
+}
+
+// GetMyBundles godoc
+// @Summary ดึง bundle ของตัวเอง (Seller)
+// @Description ดึง bundle ทั้งหมดของ seller รวมที่ปิดการขายแล้ว
+// @Tags bundles
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} map[string]interface{} "รายการ bundle"
+// @Failure 401 {object} map[string]string "Unauthorized"
+// @Failure 500 {object} map[string]string "Server error"
+// @Router /api/seller/bundles [get]
+func GetMyBundles(c *gin.Context) {
+	bundles, err := loadBundles(`b.seller_id = $1`, c.GetInt("user_id"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Database error",
+			"message": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    bundles,
+		"count":   len(bundles),
+	})
+}
+
+// DeactivateMyBundle godoc
+// @Summary ปิดการขาย bundle (Seller)
+// @Description ปิดการขาย bundle ของตัวเอง (ผู้ที่ซื้อไปแล้วยังมีสิทธิ์ใน note ตามเดิม)
+// @Tags bundles
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Bundle ID"
+// @Success 200 {object} map[string]interface{} "ปิดการขายสำเร็จ"
+// @Failure 404 {object} map[string]string "ไม่พบ bundle หรือไม่ใช่เจ้าของ"
+// @Router /api/seller/bundles/{id} [delete]
+func DeactivateMyBundle(c *gin.Context) {
+	result, err := config.DB.Exec(`
+		UPDATE bundles SET status = 'inactive', updated_at = NOW()
+		WHERE id = $1 AND seller_id = $2
+	`, c.Param("id"), c.GetInt("user_id"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Database error",
+			"message": err.Error(),
+		})
+		return
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "Bundle not found",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "Bundle deactivated successfully",
+	})
+}
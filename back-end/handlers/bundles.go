@@ -0,0 +1,308 @@
+package handlers
+
+import (
+	"database/sql"
+	"math"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// สถานะของ bundle
+const (
+	BundleAvailable = "available"
+	BundleInactive  = "inactive"
+)
+
+// รหัสเหตุผลที่ซื้อ bundle ไม่ได้ (นอกจาก CartOwnItem และ CartAlreadyOwned)
+const (
+	BundleNotFound    = "bundle_not_found"
+	BundleUnavailable = "bundle_unavailable" // bundle ถูกปิด หรือมี note ในชุดที่ไม่พร้อมขาย
+	BundleOverlaps    = "bundle_overlaps"    // ทุกเล่มในชุดอยู่ใน bundle อื่นที่ซื้อพร้อมกันแล้ว
+)
+
+const (
+	minBundleNotes             = 2
+	maxBundleNotes             = 20
+	maxBundleTitleLength       = 255
+	maxBundleDescriptionLength = 5000
+)
+
+// bundleSellableSQL - เงื่อนไขของ bundle ที่ขายได้ (ใช้ alias b): เปิดขายอยู่ และ note ทุกเล่มพร้อมขาย
+const bundleSellableSQL = `b.status = 'available' AND NOT EXISTS (
+	SELECT 1 FROM bundle_items bi JOIN notes_for_sale bn ON bi.note_id = bn.id
+	WHERE bi.bundle_id = b.id AND (bn.status <> 'available' OR bn.deleted_at IS NOT NULL))`
+
+// BundleNote - note หนึ่งเล่มใน bundle
+type BundleNote struct {
+	ID         int     `json:"id"`
+	BookTitle  string  `json:"book_title"`
+	Price      float64 `json:"price"`
+	ExamTerm   string  `json:"exam_term"`
+	Status     string  `json:"status"`
+	CoverImage string  `json:"cover_image"`
+	Course     *Course `json:"course"`
+}
+
+// Bundle - ชุด note ที่ขายรวมกันในราคาเดียว
+type Bundle struct {
+	ID          int          `json:"id"`
+	Title       string       `json:"title"`
+	Description string       `json:"description"`
+	Price       float64      `json:"price"`      // ราคา bundle
+	ListPrice   float64      `json:"list_price"` // ผลรวมราคาของ note ทุกเล่มถ้าซื้อแยก
+	Savings     float64      `json:"savings"`
+	Status      string       `json:"status"`
+	IsSellable  bool         `json:"is_sellable"` // note ทุกเล่มพร้อมขาย
+	Seller      Seller       `json:"seller"`
+	Notes       []BundleNote `json:"notes"`
+	CreatedAt   time.Time    `json:"created_at"`
+	UpdatedAt   time.Time    `json:"updated_at"`
+}
+
+// BundleSummary - ข้อมูลย่อของ bundle ที่ note เล่มหนึ่งอยู่ด้วย (แสดงในหน้ารายละเอียด note)
+type BundleSummary struct {
+	ID        int     `json:"id"`
+	Title     string  `json:"title"`
+	Price     float64 `json:"price"`
+	ListPrice float64 `json:"list_price"`
+	NoteCount int     `json:"note_count"`
+}
+
+// BundleQuote - ราคา bundle สำหรับผู้ซื้อคนหนึ่ง (หักส่วนของ note ที่ซื้อไปแล้ว)
+type BundleQuote struct {
+	BundleID       int     `json:"bundle_id"`
+	Title          string  `json:"title"`
+	SellerID       int     `json:"seller_id"`
+	BundlePrice    float64 `json:"bundle_price"`
+	ListPrice      float64 `json:"list_price"`
+	Price          float64 `json:"price"`            // ราคาที่ต้องจ่ายจริง
+	NoteIDs        []int   `json:"note_ids"`         // note ที่จะได้รับเมื่อซื้อ
+	OwnedNoteIDs   []int   `json:"owned_note_ids"`   // note ในชุดที่ซื้อไปแล้ว
+	CoveredNoteIDs []int   `json:"covered_note_ids"` // note ในชุดที่ได้จาก bundle ก่อนหน้าในคำสั่งซื้อเดียวกัน (คิดราคาเหมือนเล่มที่ซื้อไปแล้ว)
+	Reason         string  `json:"reason,omitempty"` // มีค่าเมื่อซื้อ bundle นี้ไม่ได้
+
+	problem *CartItemError
+}
+
+// bundlePrice - ราคาที่ต้องจ่ายเมื่อมี note บางเล่มในชุดอยู่แล้ว
+// ลดตามสัดส่วนราคาของเล่มที่มีอยู่แล้ว และไม่แพงกว่าการซื้อเล่มที่เหลือแยกกัน
+func bundlePrice(price, listPrice, ownedValue float64) float64 {
+	if ownedValue <= 0 {
+		return price
+	}
+	if listPrice <= 0 {
+		return 0
+	}
+	remaining := listPrice - ownedValue
+	return roundMoney(math.Min(price*remaining/listPrice, remaining))
+}
+
+// loadBundleQuotes - คำนวณราคา bundle ตาม id ที่ขอ (เรียงตามลำดับที่ขอและตัด id ซ้ำ)
+// bundle ที่ซื้อไม่ได้จะมี problem ติดมาด้วย
+// note ที่อยู่ใน bundle ก่อนหน้า (ที่ซื้อได้) ถือว่ามีอยู่แล้วเมื่อคิดราคา bundle ถัดไป เพื่อไม่ให้จ่ายเล่มเดียวกันซ้ำ
+func loadBundleQuotes(q sqlQuerier, userID int, bundleIDs []int) ([]BundleQuote, error) {
+	if len(bundleIDs) == 0 {
+		return []BundleQuote{}, nil
+	}
+
+	rows, err := q.Query(`
+		SELECT b.id, b.title, b.seller_id, b.price, b.status,
+			n.id, n.price, n.status = 'available' AND n.deleted_at IS NULL,
+			EXISTS(SELECT 1 FROM buyed_note bn WHERE bn.user_id = $1 AND bn.note_id = n.id)
+		FROM bundles b
+		JOIN bundle_items bi ON bi.bundle_id = b.id
+		JOIN notes_for_sale n ON bi.note_id = n.id
+		WHERE b.id = ANY($2)
+		ORDER BY b.id, bi.position, n.id
+	`, userID, pq.Array(bundleIDs))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	type bundleNote struct {
+		id    int
+		price float64
+		owned bool
+	}
+	found := map[int]*BundleQuote{}
+	notes := map[int][]bundleNote{}
+	for rows.Next() {
+		var (
+			bundleID, sellerID, noteID int
+			title, status              string
+			price, notePrice           float64
+			noteAvailable, owned       bool
+		)
+		if err := rows.Scan(&bundleID, &title, &sellerID, &price, &status,
+			&noteID, &notePrice, &noteAvailable, &owned); err != nil {
+			return nil, err
+		}
+
+		quote := found[bundleID]
+		if quote == nil {
+			quote = &BundleQuote{
+				BundleID:       bundleID,
+				Title:          title,
+				SellerID:       sellerID,
+				BundlePrice:    price,
+				NoteIDs:        []int{},
+				OwnedNoteIDs:   []int{},
+				CoveredNoteIDs: []int{},
+			}
+			if sellerID == userID {
+				quote.problem = &CartItemError{BundleID: bundleID, Reason: CartOwnItem, Message: "You cannot buy your own bundle"}
+			} else if status != BundleAvailable {
+				quote.problem = &CartItemError{BundleID: bundleID, Reason: BundleUnavailable, Message: "Bundle is no longer available"}
+			}
+			found[bundleID] = quote
+		}
+		if !noteAvailable && quote.problem == nil {
+			quote.problem = &CartItemError{BundleID: bundleID, Reason: BundleUnavailable, Message: "Some notes in this bundle are no longer available"}
+		}
+		quote.ListPrice += notePrice
+		notes[bundleID] = append(notes[bundleID], bundleNote{noteID, notePrice, owned})
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	quotes := []BundleQuote{}
+	seen := map[int]bool{}
+	covered := map[int]bool{}
+	for _, id := range bundleIDs {
+		if seen[id] {
+			continue
+		}
+		seen[id] = true
+
+		quote := found[id]
+		if quote == nil {
+			quotes = append(quotes, BundleQuote{
+				BundleID:       id,
+				NoteIDs:        []int{},
+				OwnedNoteIDs:   []int{},
+				CoveredNoteIDs: []int{},
+				Reason:         BundleNotFound,
+				problem:        &CartItemError{BundleID: id, Reason: BundleNotFound, Message: "Bundle not found"},
+			})
+			continue
+		}
+		ownedValue := 0.0
+		for _, note := range notes[id] {
+			switch {
+			case note.owned:
+				quote.OwnedNoteIDs = append(quote.OwnedNoteIDs, note.id)
+				ownedValue += note.price
+			case covered[note.id]:
+				quote.CoveredNoteIDs = append(quote.CoveredNoteIDs, note.id)
+				ownedValue += note.price
+			default:
+				quote.NoteIDs = append(quote.NoteIDs, note.id)
+			}
+		}
+		quote.ListPrice = roundMoney(quote.ListPrice)
+		quote.Price = bundlePrice(quote.BundlePrice, quote.ListPrice, ownedValue)
+		if quote.problem == nil && len(quote.NoteIDs) == 0 {
+			if len(quote.CoveredNoteIDs) > 0 {
+				quote.problem = &CartItemError{BundleID: id, Reason: BundleOverlaps, Message: "Every note in this bundle is already included in another bundle in your order"}
+			} else {
+				quote.problem = &CartItemError{BundleID: id, Reason: CartAlreadyOwned, Message: "You already own every note in this bundle"}
+			}
+		}
+		if quote.problem != nil {
+			quote.Reason = quote.problem.Reason
+		} else {
+			for _, noteID := range quote.NoteIDs {
+				covered[noteID] = true
+			}
+		}
+		quotes = append(quotes, *quote)
+	}
+	return quotes, nil
+}
+
+// quoteBundles - เหมือน loadBundleQuotes แต่คืน *CartItemError ของ bundle แรกที่ซื้อไม่ได้
+func quoteBundles(q sqlQuerier, userID int, bundleIDs []int) ([]BundleQuote, error) {
+	quotes, err := loadBundleQuotes(q, userID, bundleIDs)
+	if err != nil {
+		return nil, err
+	}
+	for _, quote := range quotes {
+		if quote.problem != nil {
+			return nil, quote.problem
+		}
+	}
+	return quotes, nil
+}
+
+// bundleCouponLines - แปลง bundle เป็นรายการสำหรับคิดส่วนลดคูปอง (คิดจากราคาที่ต้องจ่ายจริง)
+func bundleCouponLines(quotes []BundleQuote) []couponLine {
+	lines := []couponLine{}
+	for _, quote := range quotes {
+		if quote.problem != nil {
+			continue
+		}
+		lines = append(lines, couponLine{
+			BundleID: quote.BundleID,
+			Title:    quote.Title,
+			SellerID: quote.SellerID,
+			Price:    quote.Price,
+		})
+	}
+	return lines
+}
+
+// cartBundleIDs - bundle ที่อยู่ในตะกร้าของ user
+func cartBundleIDs(q sqlQuerier, userID int) ([]int, error) {
+	rows, err := q.Query(`SELECT bundle_id FROM cart WHERE user_id = $1 AND bundle_id IS NOT NULL ORDER BY id`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	ids := []int{}
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// recordBundlePurchase - ให้สิทธิ์ note ทุกเล่มที่ยังไม่มี บันทึกการซื้อ bundle และเอาออกจากตะกร้า
+// คืนจำนวน note ที่ได้รับ
+func recordBundlePurchase(tx *sql.Tx, userID int, quote BundleQuote) (int, error) {
+	granted := 0
+	for _, noteID := range quote.NoteIDs {
+		result, err := tx.Exec(`
+			INSERT INTO buyed_note (user_id, note_id, review, is_liked)
+			SELECT $1, id, '', NULL FROM notes_for_sale
+			WHERE id = $2 AND deleted_at IS NULL
+			ON CONFLICT DO NOTHING
+		`, userID, noteID)
+		if err != nil {
+			return 0, err
+		}
+		n, _ := result.RowsAffected()
+		granted += int(n)
+	}
+
+	_, err := tx.Exec(`
+		INSERT INTO bundle_purchases (user_id, bundle_id, note_ids, bundle_price, price_paid, created_at)
+		VALUES ($1, $2, $3, $4, $5, NOW())
+	`, userID, quote.BundleID, pq.Array(quote.NoteIDs), quote.BundlePrice, quote.Price)
+	if err != nil {
+		return 0, err
+	}
+
+	// note ในชุดที่อยู่ในตะกร้าแยกไว้ก็ไม่ต้องซื้อซ้ำแล้ว
+	_, err = tx.Exec(`
+		DELETE FROM cart WHERE user_id = $1
+		AND (bundle_id = $2 OR note_id IN (SELECT note_id FROM bundle_items WHERE bundle_id = $2))
+	`, userID, quote.BundleID)
+	return granted, err
+}
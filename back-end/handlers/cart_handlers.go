@@ -4,35 +4,45 @@ import (
 	"back-end/config"
 	"database/sql"
 	"net/http"
+	"sort"
 	"strconv"
 
 	"github.com/gin-gonic/gin"
 )
 
 // CartItem represents an item in the cart with note details
+// A bundle item has bundle_id set instead of note_id, its price is what this user pays
+// (reduced for notes already owned) and bundle holds the pricing breakdown.
+// Items that cannot be bought have purchasable = false and a reason code; removed is set
+// when the item was taken out of the cart because it can never be bought by this user
 type CartItem struct {
-	ID         int     `json:"id"`
-	NoteID     int     `json:"note_id"`
-	Quantity   int     `json:"quantity"`
-	BookTitle  string  `json:"book_title"`
-	Price      float64 `json:"price"`
-	ExamTerm   string  `json:"exam_term"`
-	Status     string  `json:"status"`
-	CoverImage string  `json:"cover_image"`
-	Course     *Course `json:"course"`
-	Seller     *Seller `json:"seller"`
+	ID          int          `json:"id"`
+	NoteID      int          `json:"note_id,omitempty"`
+	BundleID    int          `json:"bundle_id,omitempty"`
+	Quantity    int          `json:"quantity"`
+	BookTitle   string       `json:"book_title"`
+	Price       float64      `json:"price"`
+	ExamTerm    string       `json:"exam_term"`
+	Status      string       `json:"status"`
+	CoverImage  string       `json:"cover_image"`
+	Course      *Course      `json:"course"`
+	Seller      *Seller      `json:"seller"`
+	Bundle      *BundleQuote `json:"bundle,omitempty"`
+	Purchasable bool         `json:"purchasable"`
+	Reason      string       `json:"reason,omitempty"`
+	Removed     bool         `json:"removed,omitempty"`
 }
 
 // AddToCart godoc
 // @Summary Add item to cart
-// @Description Add a note to the user's shopping cart
+// @Description Add a note or a bundle (send either note_id or bundle_id) to the user's shopping cart. Notes are digital so quantity is always 1 and adding an item twice is a no-op. Own items, items already owned and items not available for sale are rejected with a reason code
 // @Tags cart
 // @Accept json
 // @Produce json
 // @Security BearerAuth
-// @Param request body object{note_id=int,quantity=int} true "Add to cart request"
+// @Param request body object{note_id=int,bundle_id=int} true "Add to cart request"
 // @Success 200 {object} map[string]string "Item added successfully"
-// @Failure 400 {object} map[string]string "Bad request or note not found"
+// @Failure 400 {object} map[string]string "Bad request or item cannot be purchased (with reason code)"
 // @Failure 401 {object} map[string]string "Unauthorized"
 // @Failure 500 {object} map[string]string "Server error"
 // @Router /api/cart [post]
@@ -44,8 +54,8 @@ func AddToCart(c *gin.Context) {
 	}
 
 	var request struct {
-		NoteID   int `json:"note_id" binding:"required"`
-		Quantity int `json:"quantity"`
+		NoteID   int `json:"note_id"`
+		BundleID int `json:"bundle_id"`
 	}
 
 	if err := c.ShouldBindJSON(&request); err != nil {
@@ -53,40 +63,42 @@ func AddToCart(c *gin.Context) {
 		return
 	}
 
-	if request.Quantity <= 0 {
-		request.Quantity = 1
-	}
-
-	// Verify note exists
-	var noteExists bool
-	err := config.DB.QueryRow("SELECT EXISTS(SELECT 1 FROM notes_for_sale WHERE id = $1)", request.NoteID).Scan(&noteExists)
-	if err != nil || !noteExists {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Note not found"})
+	if (request.NoteID == 0) == (request.BundleID == 0) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Either note_id or bundle_id is required"})
 		return
 	}
 
-	// Check if item already exists in cart
-	var existingQuantity int
-	err = config.DB.QueryRow("SELECT quantity FROM cart WHERE user_id = $1 AND note_id = $2", userID, request.NoteID).Scan(&existingQuantity)
-
-	if err == sql.ErrNoRows {
-		// Insert new item
-		_, err = config.DB.Exec("INSERT INTO cart (user_id, note_id, quantity) VALUES ($1, $2, $3)", userID, request.NoteID, request.Quantity)
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to add item to cart"})
+	// ตรวจว่าซื้อได้จริงก่อนใส่ตะกร้า
+	var err error
+	if request.BundleID != 0 {
+		_, err = quoteBundles(config.DB, userID.(int), []int{request.BundleID})
+	} else {
+		err = validateNote(config.DB, userID.(int), request.NoteID)
+	}
+	if err != nil {
+		if itemErr, ok := err.(*CartItemError); ok {
+			c.JSON(http.StatusBadRequest, gin.H{"error": itemErr.Message, "reason": itemErr.Reason})
 			return
 		}
-	} else if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
 		return
+	}
+
+	// สินค้าดิจิทัลมีได้ชิ้นเดียวต่อตะกร้า (ใส่ซ้ำจะไม่มีผล)
+	if request.BundleID != 0 {
+		_, err = config.DB.Exec(`
+			INSERT INTO cart (user_id, bundle_id, quantity) VALUES ($1, $2, 1)
+			ON CONFLICT (user_id, bundle_id) DO NOTHING
+		`, userID, request.BundleID)
 	} else {
-		// Update existing item quantity
-		newQuantity := existingQuantity + request.Quantity
-		_, err = config.DB.Exec("UPDATE cart SET quantity = $1 WHERE user_id = $2 AND note_id = $3", newQuantity, userID, request.NoteID)
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update cart item"})
-			return
-		}
+		_, err = config.DB.Exec(`
+			INSERT INTO cart (user_id, note_id, quantity) VALUES ($1, $2, 1)
+			ON CONFLICT (user_id, note_id) DO NOTHING
+		`, userID, request.NoteID)
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to add item to cart"})
+		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{"message": "Item added to cart successfully"})
@@ -94,7 +106,7 @@ func AddToCart(c *gin.Context) {
 
 // GetCart godoc
 // @Summary Get cart items
-// @Description Retrieve all items in the user's cart with note details
+// @Description Retrieve all items in the user's cart with note details. Each item is validated: items that cannot be bought are flagged with a reason code, and own items or items already owned are removed from the cart (returned once with removed = true)
 // @Tags cart
 // @Accept json
 // @Produce json
@@ -115,7 +127,7 @@ func GetCart(c *gin.Context) {
 		SELECT 
 			ct.id, ct.note_id, ct.quantity,
 			n.book_title, n.price, n.exam_term, n.status,
-			COALESCE((SELECT path FROM note_images WHERE note_id = n.id ORDER BY image_order LIMIT 1), '') as cover_image,
+			COALESCE(` + coverImageSQL("thumb") + `, '') as cover_image,
 			c.id, c.code, c.name, c.year, c.major,
 			u.id, u.username, u.fullname
 		FROM cart ct
@@ -174,6 +186,19 @@ func GetCart(c *gin.Context) {
 		cartItems = append(cartItems, item)
 	}
 
+	bundleItems, err := getCartBundleItems(userID.(int))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch cart items"})
+		return
+	}
+	cartItems = append(cartItems, bundleItems...)
+	sort.Slice(cartItems, func(i, j int) bool { return cartItems[i].ID > cartItems[j].ID })
+
+	if err := validateCartItems(userID.(int), cartItems); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to validate cart items"})
+		return
+	}
+
 	if cartItems == nil {
 		cartItems = []CartItem{}
 	}
@@ -181,9 +206,73 @@ func GetCart(c *gin.Context) {
 	c.JSON(http.StatusOK, cartItems)
 }
 
+// getCartBundleItems - bundle ในตะกร้า พร้อมราคาที่ user คนนี้ต้องจ่าย (รูปปกใช้ของ note เล่มแรกในชุด)
+func getCartBundleItems(userID int) ([]CartItem, error) {
+	rows, err := config.DB.Query(`
+		SELECT ct.id, ct.bundle_id, ct.quantity, b.title, b.status,
+			COALESCE((
+				SELECT `+coverImageSQL("thumb")+`
+				FROM bundle_items bi JOIN notes_for_sale n ON bi.note_id = n.id
+				WHERE bi.bundle_id = b.id
+				ORDER BY bi.position, n.id
+				LIMIT 1
+			), '') as cover_image,
+			u.id, u.username, u.fullname
+		FROM cart ct
+		JOIN bundles b ON ct.bundle_id = b.id
+		LEFT JOIN users u ON b.seller_id = u.id
+		WHERE ct.user_id = $1
+	`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	items := []CartItem{}
+	bundleIDs := []int{}
+	for rows.Next() {
+		var item CartItem
+		var sellerID sql.NullInt64
+		var sellerUsername, sellerFullname sql.NullString
+		if err := rows.Scan(
+			&item.ID, &item.BundleID, &item.Quantity, &item.BookTitle, &item.Status, &item.CoverImage,
+			&sellerID, &sellerUsername, &sellerFullname,
+		); err != nil {
+			return nil, err
+		}
+		if sellerID.Valid {
+			item.Seller = &Seller{
+				ID:       int(sellerID.Int64),
+				Username: sellerUsername.String,
+				Fullname: sellerFullname.String,
+			}
+		}
+		items = append(items, item)
+		bundleIDs = append(bundleIDs, item.BundleID)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	quotes, err := loadBundleQuotes(config.DB, userID, bundleIDs)
+	if err != nil {
+		return nil, err
+	}
+	byID := map[int]BundleQuote{}
+	for _, quote := range quotes {
+		byID[quote.BundleID] = quote
+	}
+	for i := range items {
+		quote := byID[items[i].BundleID]
+		items[i].Price = quote.Price
+		items[i].Bundle = &quote
+	}
+	return items, nil
+}
+
 // UpdateCartItem godoc
 // @Summary Update cart item quantity
-// @Description Update the quantity of a specific item in the cart
+// @Description Update the quantity of a specific item in the cart. Notes are digital so the only accepted quantity is 1
 // @Tags cart
 // @Accept json
 // @Produce json
@@ -218,6 +307,11 @@ func UpdateCartItem(c *gin.Context) {
 		return
 	}
 
+	if request.Quantity != 1 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Quantity is fixed at 1 for digital items"})
+		return
+	}
+
 	// Verify the cart item belongs to the user
 	query := `
 		UPDATE cart 
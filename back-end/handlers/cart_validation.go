@@ -0,0 +1,177 @@
+package handlers
+
+import (
+	"back-end/config"
+
+	"github.com/lib/pq"
+)
+
+// รหัสเหตุผลที่สินค้าในตะกร้าซื้อไม่ได้ (bundle มีรหัสเพิ่มเติมใน bundles.go)
+const (
+	CartNoteNotFound    = "note_not_found"
+	CartNoteUnavailable = "note_unavailable" // ยังไม่ผ่านการอนุมัติ ถูกปฏิเสธ หรือถูกลบไปแล้ว
+	CartOwnItem         = "own_item"         // เป็นสินค้าของตัวเอง
+	CartAlreadyOwned    = "already_owned"    // ซื้อไปแล้ว
+)
+
+// CartItemError - สินค้าชิ้นหนึ่งซื้อไม่ได้ (note หรือ bundle อย่างใดอย่างหนึ่ง)
+type CartItemError struct {
+	NoteID   int    `json:"note_id,omitempty"`
+	BundleID int    `json:"bundle_id,omitempty"`
+	Reason   string `json:"reason"`
+	Message  string `json:"message"`
+}
+
+func (e *CartItemError) Error() string {
+	return e.Message
+}
+
+// checkNotes - ตรวจว่า user ซื้อ note แต่ละเล่มได้หรือไม่ คืนเฉพาะเล่มที่ซื้อไม่ได้ (key = note id)
+func checkNotes(q sqlQuerier, userID int, noteIDs []int) (map[int]*CartItemError, error) {
+	problems := map[int]*CartItemError{}
+	if len(noteIDs) == 0 {
+		return problems, nil
+	}
+
+	rows, err := q.Query(`
+		SELECT n.id, n.seller_id, n.status = 'available' AND n.deleted_at IS NULL,
+			EXISTS(SELECT 1 FROM buyed_note bn WHERE bn.user_id = $1 AND bn.note_id = n.id)
+		FROM notes_for_sale n
+		WHERE n.id = ANY($2)
+	`, userID, pq.Array(noteIDs))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	found := map[int]bool{}
+	for rows.Next() {
+		var id, sellerID int
+		var available, owned bool
+		if err := rows.Scan(&id, &sellerID, &available, &owned); err != nil {
+			return nil, err
+		}
+		found[id] = true
+
+		switch {
+		case owned:
+			problems[id] = &CartItemError{NoteID: id, Reason: CartAlreadyOwned, Message: "You already own this note"}
+		case sellerID == userID:
+			problems[id] = &CartItemError{NoteID: id, Reason: CartOwnItem, Message: "You cannot buy your own note"}
+		case !available:
+			problems[id] = &CartItemError{NoteID: id, Reason: CartNoteUnavailable, Message: "Note is not available for sale"}
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for _, id := range noteIDs {
+		if !found[id] {
+			problems[id] = &CartItemError{NoteID: id, Reason: CartNoteNotFound, Message: "Note not found"}
+		}
+	}
+	return problems, nil
+}
+
+// validateNote - ตรวจ note เล่มเดียว (คืน *CartItemError ถ้าซื้อไม่ได้)
+func validateNote(q sqlQuerier, userID, noteID int) error {
+	problems, err := checkNotes(q, userID, []int{noteID})
+	if err != nil {
+		return err
+	}
+	if problem := problems[noteID]; problem != nil {
+		return problem
+	}
+	return nil
+}
+
+// validatePurchase - ตรวจ note และ bundle ที่กำลังจะซื้อทั้งหมด
+// คืนราคา bundle, note ที่ต้องซื้อแยก (ตัดเล่มที่ซ้ำหรืออยู่ใน bundle ออกแล้ว) และรายการที่ซื้อไม่ได้
+func validatePurchase(q sqlQuerier, userID int, noteIDs, bundleIDs []int) ([]BundleQuote, []int, []*CartItemError, error) {
+	bundles, err := loadBundleQuotes(q, userID, bundleIDs)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	issues := []*CartItemError{}
+	covered := map[int]bool{}
+	for _, bundle := range bundles {
+		if bundle.problem != nil {
+			issues = append(issues, bundle.problem)
+		}
+		for _, noteID := range bundle.NoteIDs {
+			covered[noteID] = true
+		}
+		for _, noteID := range bundle.OwnedNoteIDs {
+			covered[noteID] = true
+		}
+	}
+
+	// note ที่อยู่ใน bundle ที่ซื้อด้วยไม่ต้องซื้อแยกซ้ำ
+	toBuy := []int{}
+	for _, noteID := range noteIDs {
+		if !covered[noteID] {
+			covered[noteID] = true
+			toBuy = append(toBuy, noteID)
+		}
+	}
+
+	problems, err := checkNotes(q, userID, toBuy)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	for _, noteID := range toBuy {
+		if problem := problems[noteID]; problem != nil {
+			issues = append(issues, problem)
+		}
+	}
+	return bundles, toBuy, issues, nil
+}
+
+// isPermanentCartProblem - สินค้าที่ user คนนี้ไม่มีทางซื้อได้อีก (เอาออกจากตะกร้าได้เลย)
+func isPermanentCartProblem(reason string) bool {
+	switch reason {
+	case CartOwnItem, CartAlreadyOwned, CartNoteNotFound, BundleNotFound:
+		return true
+	}
+	return false
+}
+
+// validateCartItems - กำหนด purchasable/reason ให้สินค้าทุกชิ้นในตะกร้า
+// และลบชิ้นที่ไม่มีทางซื้อได้ออกจากตะกร้า (ยังคืนกลับไปใน items พร้อม removed = true)
+func validateCartItems(userID int, items []CartItem) error {
+	noteIDs := []int{}
+	for _, item := range items {
+		if item.NoteID != 0 {
+			noteIDs = append(noteIDs, item.NoteID)
+		}
+	}
+	problems, err := checkNotes(config.DB, userID, noteIDs)
+	if err != nil {
+		return err
+	}
+
+	removeIDs := []int{}
+	for i := range items {
+		item := &items[i]
+		if item.NoteID != 0 {
+			if problem := problems[item.NoteID]; problem != nil {
+				item.Reason = problem.Reason
+			}
+		} else if item.Bundle != nil {
+			item.Reason = item.Bundle.Reason
+		}
+
+		item.Purchasable = item.Reason == ""
+		if isPermanentCartProblem(item.Reason) {
+			item.Removed = true
+			removeIDs = append(removeIDs, item.ID)
+		}
+	}
+
+	if len(removeIDs) > 0 {
+		_, err = config.DB.Exec(`DELETE FROM cart WHERE user_id = $1 AND id = ANY($2)`, userID, pq.Array(removeIDs))
+	}
+	return err
+}
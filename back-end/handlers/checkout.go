@@ -0,0 +1,405 @@
+package handlers
+
+import (
+	"back-end/config"
+	"back-end/events"
+	"database/sql"
+	"errors"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lib/pq"
+)
+
+// OrderPaid - สถานะของคำสั่งซื้อที่ชำระแล้ว
+const OrderPaid = "paid"
+
+// errEmptyOrder - ไม่มีสินค้าให้สั่งซื้อ
+var errEmptyOrder = errors.New("no items to purchase")
+
+// orderIssuesError - มีสินค้าที่ซื้อไม่ได้ (ตอบกลับเป็น 400 พร้อมรายการ)
+type orderIssuesError struct {
+	Issues []*CartItemError
+}
+
+func (e *orderIssuesError) Error() string {
+	return "some items cannot be purchased"
+}
+
+// OrderItem - สินค้าหนึ่งรายการในคำสั่งซื้อ (note หรือ bundle อย่างใดอย่างหนึ่ง)
+type OrderItem struct {
+	NoteID   int     `json:"note_id,omitempty"`
+	BundleID int     `json:"bundle_id,omitempty"`
+	Title    string  `json:"title"`
+	SellerID int     `json:"seller_id"`
+	Price    float64 `json:"price"` // ราคาก่อนส่วนลด
+	Discount float64 `json:"discount"`
+	Tax      float64 `json:"tax"`
+	Total    float64 `json:"total"`
+	NoteIDs  []int   `json:"note_ids"` // note ที่ได้รับจากรายการนี้
+}
+
+// Order - คำสั่งซื้อ (ราคาทั้งหมดคำนวณที่ server)
+type Order struct {
+	ID         int         `json:"id"`
+	Status     string      `json:"status"`
+	Subtotal   float64     `json:"subtotal"`
+	Discount   float64     `json:"discount"`
+	TaxRate    float64     `json:"tax_rate"` // เปอร์เซ็นต์
+	Tax        float64     `json:"tax"`
+	Total      float64     `json:"total"`
+	CouponCode string      `json:"coupon_code,omitempty"`
+	Items      []OrderItem `json:"items"`
+	CreatedAt  time.Time   `json:"created_at"`
+
+	coupon  *CouponQuote
+	bundles []BundleQuote
+	noteIDs []int
+	granted int
+}
+
+// taxRate - อัตราภาษีเป็นเปอร์เซ็นต์ บวกเพิ่มจากราคาหลังหักส่วนลด (TAX_RATE_PERCENT, ค่าเริ่มต้น 0)
+func taxRate() float64 {
+	if rate, err := strconv.ParseFloat(os.Getenv("TAX_RATE_PERCENT"), 64); err == nil && rate >= 0 && rate <= 100 {
+		return rate
+	}
+	return 0
+}
+
+// lockBuyer - ล็อกแถว user ไว้จนจบ transaction ให้คำสั่งซื้อของ user เดียวกัน (ทั้ง checkout และ purchase)
+// ทำทีละรายการ คำสั่งที่ส่งซ้ำจะเห็นว่า note ถูกซื้อไปแล้ว ต้องเรียกก่อนล็อกตะกร้าเสมอ (กัน deadlock)
+func lockBuyer(tx *sql.Tx, userID int) error {
+	_, err := tx.Exec(`SELECT 1 FROM users WHERE id = $1 FOR UPDATE`, userID)
+	return err
+}
+
+// placeOrder - ตรวจสินค้า คำนวณยอด (ส่วนลด + ภาษี) บันทึกคำสั่งซื้อ ให้สิทธิ์ note และเอาออกจากตะกร้า
+// ทุกอย่างอยู่ใน transaction ของผู้เรียก
+func placeOrder(tx *sql.Tx, userID int, noteIDs, bundleIDs []int, couponCode string) (*Order, error) {
+	bundles, toBuy, issues, err := validatePurchase(tx, userID, noteIDs, bundleIDs)
+	if err != nil {
+		return nil, err
+	}
+	if len(issues) > 0 {
+		return nil, &orderIssuesError{Issues: issues}
+	}
+	if len(toBuy) == 0 && len(bundles) == 0 {
+		return nil, errEmptyOrder
+	}
+
+	lines, err := loadPurchaseCouponLines(tx, userID, toBuy)
+	if err != nil {
+		return nil, err
+	}
+	lines = append(lines, bundleCouponLines(bundles)...)
+
+	order := &Order{
+		Status:  OrderPaid,
+		TaxRate: taxRate(),
+		Items:   []OrderItem{},
+		bundles: bundles,
+		noteIDs: toBuy,
+	}
+
+	// ตรวจสอบและคำนวณส่วนลดจากคูปอง (ล็อกคูปองไว้จนจบ transaction กันการใช้เกินจำนวน)
+	var couponID *int
+	if couponCode != "" {
+		coupon, quote, err := resolveCoupon(tx, couponCode, userID, lines, time.Now(), true)
+		if err != nil {
+			return nil, err
+		}
+		order.coupon = quote
+		order.CouponCode = coupon.Code
+		couponID = &coupon.ID
+	}
+
+	bundleNotes := map[int][]int{}
+	for _, bundle := range bundles {
+		bundleNotes[bundle.BundleID] = bundle.NoteIDs
+	}
+	for i, line := range lines {
+		item := OrderItem{
+			NoteID:   line.NoteID,
+			BundleID: line.BundleID,
+			Title:    line.Title,
+			SellerID: line.SellerID,
+			Price:    line.Price,
+			NoteIDs:  []int{line.NoteID},
+		}
+		if line.BundleID != 0 {
+			item.NoteIDs = bundleNotes[line.BundleID]
+		}
+		if order.coupon != nil {
+			item.Discount = order.coupon.Items[i].Discount
+		}
+		// ภาษีคิดรายการต่อรายการ เพื่อให้ผลรวมของรายการตรงกับยอดรวมพอดี
+		item.Tax = roundMoney((item.Price - item.Discount) * order.TaxRate / 100)
+		item.Total = roundMoney(item.Price - item.Discount + item.Tax)
+
+		order.Subtotal += item.Price
+		order.Discount += item.Discount
+		order.Tax += item.Tax
+		order.Total += item.Total
+		order.Items = append(order.Items, item)
+	}
+	order.Subtotal = roundMoney(order.Subtotal)
+	order.Discount = roundMoney(order.Discount)
+	order.Tax = roundMoney(order.Tax)
+	order.Total = roundMoney(order.Total)
+
+	err = tx.QueryRow(`
+		INSERT INTO orders (user_id, status, subtotal, discount_amount, tax_rate, tax_amount, total, coupon_id, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, NOW())
+		RETURNING id, created_at
+	`, userID, order.Status, order.Subtotal, order.Discount, order.TaxRate, order.Tax, order.Total, couponID).
+		Scan(&order.ID, &order.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	for _, item := range order.Items {
+		_, err := tx.Exec(`
+			INSERT INTO order_items (order_id, note_id, bundle_id, title, seller_id, price, discount_amount, tax_amount, total, note_ids)
+			VALUES ($1, NULLIF($2, 0), NULLIF($3, 0), $4, $5, $6, $7, $8, $9, $10)
+		`, order.ID, item.NoteID, item.BundleID, item.Title, item.SellerID, item.Price, item.Discount, item.Tax, item.Total,
+			pq.Array(item.NoteIDs))
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// ให้สิทธิ์ note ที่ซื้อแยก (ข้าม note ที่ถูกลบไปแล้ว)
+	for _, noteID := range toBuy {
+		result, err := tx.Exec(`
+			INSERT INTO buyed_note (user_id, note_id, review, is_liked)
+			SELECT $1, id, '', NULL FROM notes_for_sale
+			WHERE id = $2 AND deleted_at IS NULL
+			ON CONFLICT DO NOTHING
+		`, userID, noteID)
+		if err != nil {
+			return nil, err
+		}
+		n, _ := result.RowsAffected()
+		order.granted += int(n)
+	}
+
+	// ให้สิทธิ์ note ใน bundle และบันทึกการซื้อ bundle
+	for _, bundle := range bundles {
+		granted, err := recordBundlePurchase(tx, userID, bundle)
+		if err != nil {
+			return nil, err
+		}
+		order.granted += granted
+	}
+
+	if _, err := tx.Exec(`DELETE FROM cart WHERE user_id = $1 AND note_id = ANY($2)`, userID, pq.Array(toBuy)); err != nil {
+		return nil, err
+	}
+
+	if order.coupon != nil {
+		if err := redeemCoupon(tx, order.coupon, userID); err != nil {
+			return nil, err
+		}
+	}
+
+	// แจ้ง seller ทุกรายการที่ขายได้ (จะถูกบันทึกก็ต่อเมื่อ order commit)
+	// event แบบ real-time ส่งแยกด้วย publishOrderEvents หลัง commit
+	for _, item := range order.Items {
+		title := "Your note was sold"
+		if item.BundleID != 0 {
+			title = "Your bundle was sold"
+		}
+		data := gin.H{"order_id": order.ID, "note_id": item.NoteID, "bundle_id": item.BundleID, "amount": roundMoney(item.Price - item.Discount)}
+		if err := notify(tx, item.SellerID, NotifyNoteSold, title, item.Title+" was purchased", data); err != nil {
+			return nil, err
+		}
+	}
+	if err := emitOrderWebhooks(tx, userID, order); err != nil {
+		return nil, err
+	}
+	return order, nil
+}
+
+// publishOrderEvents - ส่ง event ให้ seller ทุกรายการใน order (เรียกหลัง commit เท่านั้น)
+func publishOrderEvents(order *Order) {
+	for _, item := range order.Items {
+		events.PublishToUser(item.SellerID, events.NoteSold, gin.H{
+			"order_id":  order.ID,
+			"note_id":   item.NoteID,
+			"bundle_id": item.BundleID,
+			"title":     item.Title,
+			"amount":    roundMoney(item.Price - item.Discount),
+		})
+	}
+}
+
+// respondOrderError - ตอบกลับ error จาก placeOrder (คืน false ถ้าไม่ใช่ error ที่รู้จัก)
+func respondOrderError(c *gin.Context, err error) bool {
+	switch e := err.(type) {
+	case *CouponError:
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":  e.Message,
+			"reason": e.Reason,
+		})
+	case *orderIssuesError:
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":  "Some items cannot be purchased",
+			"reason": e.Issues[0].Reason,
+			"issues": e.Issues,
+		})
+	default:
+		if err != errEmptyOrder {
+			return false
+		}
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "No items to purchase",
+		})
+	}
+	return true
+}
+
+// CheckoutRequest - ข้อมูลสำหรับ checkout (สินค้ามาจากตะกร้าที่ server ทั้งหมด)
+type CheckoutRequest struct {
+	CouponCode string `json:"coupon_code"` // optional
+}
+
+// Checkout godoc
+// @Summary Checkout the cart
+// @Description Place an order for everything in the server-side cart. Totals, coupon discounts and taxes are computed on the server. Send an Idempotency-Key header to make retries safe: a request repeated with the same key returns the original result (with header Idempotent-Replayed: true) instead of ordering twice
+// @Tags purchase
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param Idempotency-Key header string false "Unique key per checkout attempt"
+// @Param request body CheckoutRequest false "Optional coupon code"
+// @Success 201 {object} map[string]interface{} "Order placed"
+// @Failure 400 {object} map[string]interface{} "Empty cart, invalid coupon or items that cannot be purchased (with reason codes)"
+// @Failure 401 {object} map[string]string "Unauthorized"
+// @Failure 422 {object} map[string]string "Idempotency-Key reused with a different request"
+// @Failure 500 {object} map[string]string "Server error"
+// @Router /api/checkout [post]
+func Checkout(c *gin.Context) {
+	userID := c.GetInt("user_id")
+
+	var req CheckoutRequest
+	if err := c.ShouldBindJSON(&req); err != nil && err != io.EOF {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid request format",
+		})
+		return
+	}
+	req.CouponCode = normalizeCouponCode(req.CouponCode)
+
+	key, ok := idempotencyKey(c)
+	if !ok {
+		return
+	}
+
+	tx, err := config.DB.Begin()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Database error",
+		})
+		return
+	}
+	defer tx.Rollback()
+
+	if key != "" {
+		stored, err := claimIdempotencyKey(tx, userID, key, "checkout", requestFingerprint("checkout", req))
+		if err == errIdempotencyKeyReused {
+			c.JSON(http.StatusUnprocessableEntity, gin.H{
+				"error": "Idempotency-Key was already used with a different request",
+			})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error": "Database error",
+			})
+			return
+		}
+		if stored != nil {
+			replayIdempotentResponse(c, stored)
+			return
+		}
+	}
+
+	if err := lockBuyer(tx, userID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Database error",
+		})
+		return
+	}
+
+	// ล็อกตะกร้าไว้จนจบ transaction (checkout ซ้อนกันจะเห็นตะกร้าที่ว่างแล้ว)
+	rows, err := tx.Query(`SELECT note_id, bundle_id FROM cart WHERE user_id = $1 ORDER BY id FOR UPDATE`, userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to load cart",
+		})
+		return
+	}
+	noteIDs, bundleIDs := []int{}, []int{}
+	for rows.Next() {
+		var noteID, bundleID sql.NullInt64
+		if err := rows.Scan(&noteID, &bundleID); err != nil {
+			rows.Close()
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error": "Failed to load cart",
+			})
+			return
+		}
+		if noteID.Valid {
+			noteIDs = append(noteIDs, int(noteID.Int64))
+		}
+		if bundleID.Valid {
+			bundleIDs = append(bundleIDs, int(bundleID.Int64))
+		}
+	}
+	rows.Close()
+
+	if len(noteIDs) == 0 && len(bundleIDs) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Cart is empty",
+		})
+		return
+	}
+
+	order, err := placeOrder(tx, userID, noteIDs, bundleIDs, req.CouponCode)
+	if err != nil {
+		if !respondOrderError(c, err) {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error":   "Failed to place order",
+				"details": err.Error(),
+			})
+		}
+		return
+	}
+
+	response := gin.H{
+		"success": true,
+		"message": "Order placed successfully",
+		"data":    order,
+	}
+	if key != "" {
+		if err := saveIdempotentResponse(tx, userID, key, http.StatusCreated, response); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error": "Failed to place order",
+			})
+			return
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to place order",
+		})
+		return
+	}
+	publishOrderEvents(order)
+
+	c.JSON(http.StatusCreated, response)
+}
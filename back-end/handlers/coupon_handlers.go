@@ -0,0 +1,408 @@
+package handlers
+
+import (
+	"back-end/config"
+	"database/sql"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lib/pq"
+)
+
+// CouponInput - ข้อมูลสำหรับสร้างคูปอง
+type CouponInput struct {
+	Code          string     `json:"code" example:"MIDTERM50"`
+	Description   string     `json:"description"`
+	DiscountType  string     `json:"discount_type" example:"percent"` // percent | fixed
+	DiscountValue float64    `json:"discount_value" example:"20"`
+	MaxDiscount   *float64   `json:"max_discount"` // เพดานส่วนลดสำหรับแบบ percent
+	MinCartValue  float64    `json:"min_cart_value"`
+	UsageLimit    *int       `json:"usage_limit"`    // จำนวนครั้งที่ใช้ได้ทั้งหมด (null = ไม่จำกัด)
+	PerUserLimit  *int       `json:"per_user_limit"` // จำนวนครั้งที่ใช้ได้ต่อคน (null = ไม่จำกัด)
+	StartsAt      *time.Time `json:"starts_at"`
+	EndsAt        *time.Time `json:"ends_at"`
+	SellerID      *int       `json:"seller_id"` // seller สร้างคูปองได้เฉพาะของตัวเอง (กำหนดให้อัตโนมัติ)
+	CourseID      *int       `json:"course_id"`
+	Major         *string    `json:"major"`
+	NoteID        *int       `json:"note_id"`
+}
+
+// couponEditor - ผู้จัดการคูปอง (admin จัดการได้ทุกคูปอง, seller จัดการได้เฉพาะคูปองของ note ตัวเอง)
+type couponEditor struct {
+	isAdmin bool
+	userID  int
+}
+
+// couponInputError - ข้อมูลคูปองไม่ถูกต้อง
+type couponInputError struct {
+	Field   string
+	Message string
+}
+
+func (e *couponInputError) Error() string {
+	return e.Field + ": " + e.Message
+}
+
+// validate - ตรวจสอบข้อมูลคูปอง (ยกเว้นการมีอยู่ของ course/note ซึ่งตรวจใน database)
+func (in *CouponInput) validate(editor couponEditor) error {
+	in.Code = normalizeCouponCode(in.Code)
+	in.Description = strings.TrimSpace(in.Description)
+
+	if !couponCodePattern.MatchString(in.Code) {
+		return &couponInputError{"code", "must be 3-50 characters of A-Z, 0-9, _ or -"}
+	}
+	if utf8.RuneCountInString(in.Description) > maxCouponDescriptionLength {
+		return &couponInputError{"description", fmt.Sprintf("must be at most %d characters", maxCouponDescriptionLength)}
+	}
+	switch in.DiscountType {
+	case CouponPercent:
+		if in.DiscountValue <= 0 || in.DiscountValue > 100 {
+			return &couponInputError{"discount_value", "must be between 0 and 100 for percent coupons"}
+		}
+	case CouponFixed:
+		if in.DiscountValue <= 0 || in.DiscountValue > maxNotePrice {
+			return &couponInputError{"discount_value", "must be greater than 0"}
+		}
+		in.MaxDiscount = nil
+	default:
+		return &couponInputError{"discount_type", "must be 'percent' or 'fixed'"}
+	}
+	if in.MaxDiscount != nil && *in.MaxDiscount <= 0 {
+		return &couponInputError{"max_discount", "must be greater than 0"}
+	}
+	if in.MinCartValue < 0 {
+		return &couponInputError{"min_cart_value", "must not be negative"}
+	}
+	if in.UsageLimit != nil && *in.UsageLimit <= 0 {
+		return &couponInputError{"usage_limit", "must be greater than 0"}
+	}
+	if in.PerUserLimit != nil && *in.PerUserLimit <= 0 {
+		return &couponInputError{"per_user_limit", "must be greater than 0"}
+	}
+	if in.StartsAt != nil && in.EndsAt != nil && !in.EndsAt.After(*in.StartsAt) {
+		return &couponInputError{"ends_at", "must be after starts_at"}
+	}
+	if in.Major != nil {
+		major := strings.TrimSpace(*in.Major)
+		if major == "" {
+			in.Major = nil
+		} else {
+			in.Major = &major
+		}
+	}
+
+	// seller สร้างคูปองได้เฉพาะสำหรับ note ของตัวเอง
+	if !editor.isAdmin {
+		if in.SellerID != nil && *in.SellerID != editor.userID {
+			return &couponInputError{"seller_id", "sellers can only create coupons for their own notes"}
+		}
+		in.SellerID = &editor.userID
+	}
+	return nil
+}
+
+// createCoupon - ตรวจสอบ scope และบันทึกคูปอง
+func createCoupon(in *CouponInput, editor couponEditor) (*Coupon, error) {
+	if err := in.validate(editor); err != nil {
+		return nil, err
+	}
+
+	tx, err := config.DB.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	if in.CourseID != nil {
+		var exists bool
+		if err := tx.QueryRow(`SELECT EXISTS(SELECT 1 FROM courses WHERE id = $1)`, *in.CourseID).Scan(&exists); err != nil {
+			return nil, err
+		}
+		if !exists {
+			return nil, &couponInputError{"course_id", "course not found"}
+		}
+	}
+	if in.NoteID != nil {
+		var sellerID int
+		err := tx.QueryRow(`SELECT seller_id FROM notes_for_sale WHERE id = $1 AND deleted_at IS NULL`, *in.NoteID).Scan(&sellerID)
+		if err == sql.ErrNoRows {
+			return nil, &couponInputError{"note_id", "note not found"}
+		}
+		if err != nil {
+			return nil, err
+		}
+		if in.SellerID != nil && *in.SellerID != sellerID {
+			return nil, &couponInputError{"note_id", "note does not belong to the coupon's seller"}
+		}
+	}
+
+	var id int
+	err = tx.QueryRow(`
+		INSERT INTO coupons (code, description, discount_type, discount_value, max_discount, min_cart_value,
+			usage_limit, per_user_limit, starts_at, ends_at, seller_id, course_id, major, note_id,
+			is_active, created_by, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, true, $15, NOW())
+		RETURNING id
+	`, in.Code, in.Description, in.DiscountType, in.DiscountValue, in.MaxDiscount, in.MinCartValue,
+		in.UsageLimit, in.PerUserLimit, in.StartsAt, in.EndsAt, in.SellerID, in.CourseID, in.Major, in.NoteID,
+		editor.userID).Scan(&id)
+	if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23505" {
+		if in.SellerID != nil {
+			return nil, &couponInputError{"code", "this seller already has a coupon with this code"}
+		}
+		return nil, &couponInputError{"code", "a site-wide coupon with this code already exists"}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	coupon, err := scanCoupon(tx.QueryRow(`SELECT `+couponColumns+` FROM coupons c WHERE c.id = $1`, id))
+	if err != nil {
+		return nil, err
+	}
+	return coupon, tx.Commit()
+}
+
+func handleCreateCoupon(c *gin.Context, editor couponEditor) {
+	var input CouponInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid input",
+			"message": err.Error(),
+		})
+		return
+	}
+
+	coupon, err := createCoupon(&input, editor)
+	if err != nil {
+		if inputErr, ok := err.(*couponInputError); ok {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error":   "Invalid input",
+				"field":   inputErr.Field,
+				"message": inputErr.Message,
+			})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Database error",
+			"message": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"success": true,
+		"message": "Coupon created successfully",
+		"data":    coupon,
+	})
+}
+
+func handleListCoupons(c *gin.Context, editor couponEditor) {
+	query := `SELECT ` + couponColumns + ` FROM coupons c`
+	args := []interface{}{}
+	if !editor.isAdmin {
+		query += ` WHERE c.created_by = $1`
+		args = append(args, editor.userID)
+	}
+	query += ` ORDER BY c.created_at DESC`
+
+	rows, err := config.DB.Query(query, args...)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Database error",
+			"message": err.Error(),
+		})
+		return
+	}
+	defer rows.Close()
+
+	coupons := []Coupon{}
+	for rows.Next() {
+		coupon, err := scanCoupon(rows)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error":   "Error scanning coupon data",
+				"message": err.Error(),
+			})
+			return
+		}
+		coupons = append(coupons, *coupon)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    coupons,
+		"count":   len(coupons),
+	})
+}
+
+func handleDeactivateCoupon(c *gin.Context, editor couponEditor) {
+	query := `UPDATE coupons SET is_active = false WHERE id = $1`
+	args := []interface{}{c.Param("id")}
+	if !editor.isAdmin {
+		query += ` AND created_by = $2`
+		args = append(args, editor.userID)
+	}
+
+	result, err := config.DB.Exec(query, args...)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Database error",
+			"message": err.Error(),
+		})
+		return
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "Coupon not found",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "Coupon deactivated successfully",
+	})
+}
+
+// ApplyCoupon godoc
+// @Summary Preview a coupon on the cart
+// @Description Validate a coupon code against the current cart and return the discount per item and the new total. Codes are unique per seller, so the code is matched against site-wide coupons and coupons of the sellers whose notes are in the cart; if several match, the one with the largest discount is used. The coupon is not redeemed until checkout
+// @Tags cart
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body object{code=string} true "Coupon code"
+// @Success 200 {object} map[string]interface{} "Discount preview"
+// @Failure 400 {object} map[string]string "Coupon cannot be applied (with reason code)"
+// @Failure 401 {object} map[string]string "Unauthorized"
+// @Failure 500 {object} map[string]string "Server error"
+// @Router /api/cart/apply-coupon [post]
+func ApplyCoupon(c *gin.Context) {
+	userID := c.GetInt("user_id")
+
+	var request struct {
+		Code string `json:"code" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Coupon code is required"})
+		return
+	}
+
+	lines, err := loadCartCouponLines(config.DB, userID)
+	var quote *CouponQuote
+	if err == nil {
+		_, quote, err = resolveCoupon(config.DB, request.Code, userID, lines, time.Now(), false)
+	}
+	if err != nil {
+		if couponErr, ok := err.(*CouponError); ok {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error":  couponErr.Message,
+				"reason": couponErr.Reason,
+			})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    quote,
+	})
+}
+
+// CreateMyCoupon godoc
+// @Summary Create a coupon (Seller)
+// @Description Seller creates a coupon that only applies to their own notes (optionally narrowed to a course, major or single note)
+// @Tags coupons
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body CouponInput true "Coupon data"
+// @Success 201 {object} map[string]interface{} "Coupon created"
+// @Failure 400 {object} map[string]interface{} "Invalid input"
+// @Failure 401 {object} map[string]string "Unauthorized"
+// @Failure 403 {object} map[string]string "Not a seller"
+// @Failure 500 {object} map[string]string "Server error"
+// @Router /api/seller/coupons [post]
+func CreateMyCoupon(c *gin.Context) {
+	handleCreateCoupon(c, couponEditor{userID: c.GetInt("user_id")})
+}
+
+// GetMyCoupons godoc
+// @Summary Get my coupons (Seller)
+// @Description List coupons created by the current seller with usage counts
+// @Tags coupons
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} map[string]interface{} "List of coupons"
+// @Failure 401 {object} map[string]string "Unauthorized"
+// @Failure 500 {object} map[string]string "Server error"
+// @Router /api/seller/coupons [get]
+func GetMyCoupons(c *gin.Context) {
+	handleListCoupons(c, couponEditor{userID: c.GetInt("user_id")})
+}
+
+// DeactivateMyCoupon godoc
+// @Summary Deactivate my coupon (Seller)
+// @Description Stop a coupon created by the current seller from being used
+// @Tags coupons
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Coupon ID"
+// @Success 200 {object} map[string]interface{} "Coupon deactivated"
+// @Failure 404 {object} map[string]string "Coupon not found"
+// @Router /api/seller/coupons/{id} [delete]
+func DeactivateMyCoupon(c *gin.Context) {
+	handleDeactivateCoupon(c, couponEditor{userID: c.GetInt("user_id")})
+}
+
+// CreateCoupon godoc
+// @Summary Create a coupon (Admin)
+// @Description Create a site-wide or scoped (seller, course, major, note) coupon
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body CouponInput true "Coupon data"
+// @Success 201 {object} map[string]interface{} "Coupon created"
+// @Failure 400 {object} map[string]interface{} "Invalid input"
+// @Failure 401 {object} map[string]string "Unauthorized"
+// @Failure 500 {object} map[string]string "Server error"
+// @Router /api/admin/coupons [post]
+func CreateCoupon(c *gin.Context) {
+	handleCreateCoupon(c, couponEditor{isAdmin: true, userID: c.GetInt("user_id")})
+}
+
+// GetAllCoupons godoc
+// @Summary Get all coupons (Admin)
+// @Description List all coupons with usage counts
+// @Tags admin
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} map[string]interface{} "List of coupons"
+// @Failure 401 {object} map[string]string "Unauthorized"
+// @Failure 500 {object} map[string]string "Server error"
+// @Router /api/admin/coupons [get]
+func GetAllCoupons(c *gin.Context) {
+	handleListCoupons(c, couponEditor{isAdmin: true, userID: c.GetInt("user_id")})
+}
+
+// DeactivateCoupon godoc
+// @Summary Deactivate a coupon (Admin)
+// @Description Stop any coupon from being used
+// @Tags admin
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Coupon ID"
+// @Success 200 {object} map[string]interface{} "Coupon deactivated"
+// @Failure 404 {object} map[string]string "Coupon not found"
+// @Router /api/admin/coupons/{id} [delete]
+func DeactivateCoupon(c *gin.Context) {
+	handleDeactivateCoupon(c, couponEditor{isAdmin: true, userID: c.GetInt("user_id")})
+}
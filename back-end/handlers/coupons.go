@@ -0,0 +1,407 @@
+package handlers
+
+import (
+	"database/sql"
+	"fmt"
+	"math"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// ชนิดของส่วนลด
+const (
+	CouponPercent = "percent" // ลดเป็นเปอร์เซ็นต์ของยอดสินค้าที่เข้าเงื่อนไข
+	CouponFixed   = "fixed"   // ลดเป็นจำนวนเงิน
+)
+
+// รหัสเหตุผลที่ใช้คูปองไม่ได้
+const (
+	CouponNotFound      = "coupon_not_found"
+	CouponInactive      = "coupon_inactive"
+	CouponNotStarted    = "coupon_not_started"
+	CouponExpired       = "coupon_expired"
+	CouponUsageLimit    = "coupon_usage_limit_reached"
+	CouponPerUserLimit  = "coupon_per_user_limit_reached"
+	CouponMinCartValue  = "coupon_min_cart_value_not_met"
+	CouponNotApplicable = "coupon_not_applicable"
+)
+
+const maxCouponDescriptionLength = 500
+
+// couponCodePattern - รหัสคูปอง 3-50 ตัว (A-Z, 0-9, _ และ -)
+var couponCodePattern = regexp.MustCompile(`^[A-Z0-9_-]{3,50}$`)
+
+// CouponError - คูปองใช้กับตะกร้านี้ไม่ได้ (ตอบกลับเป็น 400 พร้อม reason)
+type CouponError struct {
+	Reason  string
+	Message string
+}
+
+func (e *CouponError) Error() string {
+	return e.Message
+}
+
+// Coupon - คูปองส่วนลด (scope ที่เป็น nil หมายถึงไม่จำกัด ถ้ากำหนดหลาย scope สินค้าต้องตรงทุกข้อ)
+type Coupon struct {
+	ID            int        `json:"id"`
+	Code          string     `json:"code"`
+	Description   string     `json:"description"`
+	DiscountType  string     `json:"discount_type"`
+	DiscountValue float64    `json:"discount_value"`
+	MaxDiscount   *float64   `json:"max_discount"`
+	MinCartValue  float64    `json:"min_cart_value"`
+	UsageLimit    *int       `json:"usage_limit"`
+	PerUserLimit  *int       `json:"per_user_limit"`
+	StartsAt      *time.Time `json:"starts_at"`
+	EndsAt        *time.Time `json:"ends_at"`
+	SellerID      *int       `json:"seller_id"`
+	CourseID      *int       `json:"course_id"`
+	Major         *string    `json:"major"`
+	NoteID        *int       `json:"note_id"`
+	IsActive      bool       `json:"is_active"`
+	CreatedBy     int        `json:"created_by"`
+	TimesUsed     int        `json:"times_used"`
+	CreatedAt     time.Time  `json:"created_at"`
+}
+
+// couponLine - สินค้าหนึ่งรายการที่นำมาคิดส่วนลด (note หรือ bundle อย่างใดอย่างหนึ่ง)
+type couponLine struct {
+	NoteID   int
+	BundleID int
+	Title    string
+	SellerID int
+	CourseID *int
+	Major    string
+	Price    float64
+}
+
+// CouponLineDiscount - ส่วนลดที่กระจายลงแต่ละรายการ
+type CouponLineDiscount struct {
+	NoteID   int     `json:"note_id,omitempty"`
+	BundleID int     `json:"bundle_id,omitempty"`
+	Title    string  `json:"title"`
+	Price    float64 `json:"price"`
+	Eligible bool    `json:"eligible"`
+	Discount float64 `json:"discount"`
+}
+
+// CouponQuote - ผลการคำนวณส่วนลดของคูปองกับรายการสินค้า
+type CouponQuote struct {
+	CouponID         int                  `json:"coupon_id"`
+	Code             string               `json:"code"`
+	DiscountType     string               `json:"discount_type"`
+	DiscountValue    float64              `json:"discount_value"`
+	Subtotal         float64              `json:"subtotal"`
+	EligibleSubtotal float64              `json:"eligible_subtotal"`
+	Discount         float64              `json:"discount"`
+	Total            float64              `json:"total"`
+	Items            []CouponLineDiscount `json:"items"`
+}
+
+// sqlQuerier - ใช้ได้ทั้ง *sql.DB และ *sql.Tx
+type sqlQuerier interface {
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+	QueryRow(query string, args ...interface{}) *sql.Row
+}
+
+func roundMoney(v float64) float64 {
+	return math.Round(v*100) / 100
+}
+
+// normalizeCouponCode - รหัสคูปองไม่สนตัวพิมพ์เล็กใหญ่ (เก็บเป็นตัวพิมพ์ใหญ่)
+func normalizeCouponCode(code string) string {
+	return strings.ToUpper(strings.TrimSpace(code))
+}
+
+const couponColumns = `c.id, c.code, COALESCE(c.description, ''), c.discount_type, c.discount_value, c.max_discount,
+	c.min_cart_value, c.usage_limit, c.per_user_limit, c.starts_at, c.ends_at,
+	c.seller_id, c.course_id, c.major, c.note_id, c.is_active, c.created_by,
+	(SELECT COUNT(*) FROM coupon_redemptions r WHERE r.coupon_id = c.id), c.created_at`
+
+func scanCoupon(row interface{ Scan(...interface{}) error }) (*Coupon, error) {
+	var coupon Coupon
+	var maxDiscount sql.NullFloat64
+	var usageLimit, perUserLimit, sellerID, courseID, noteID sql.NullInt64
+	var startsAt, endsAt sql.NullTime
+	var major sql.NullString
+
+	err := row.Scan(&coupon.ID, &coupon.Code, &coupon.Description, &coupon.DiscountType, &coupon.DiscountValue, &maxDiscount,
+		&coupon.MinCartValue, &usageLimit, &perUserLimit, &startsAt, &endsAt,
+		&sellerID, &courseID, &major, &noteID, &coupon.IsActive, &coupon.CreatedBy,
+		&coupon.TimesUsed, &coupon.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	if maxDiscount.Valid {
+		coupon.MaxDiscount = &maxDiscount.Float64
+	}
+	coupon.UsageLimit = nullIntPtr(usageLimit)
+	coupon.PerUserLimit = nullIntPtr(perUserLimit)
+	coupon.SellerID = nullIntPtr(sellerID)
+	coupon.CourseID = nullIntPtr(courseID)
+	coupon.NoteID = nullIntPtr(noteID)
+	if startsAt.Valid {
+		coupon.StartsAt = &startsAt.Time
+	}
+	if endsAt.Valid {
+		coupon.EndsAt = &endsAt.Time
+	}
+	if major.Valid {
+		coupon.Major = &major.String
+	}
+	return &coupon, nil
+}
+
+func nullIntPtr(v sql.NullInt64) *int {
+	if !v.Valid {
+		return nil
+	}
+	i := int(v.Int64)
+	return &i
+}
+
+// resolveCoupon - หาคูปองตามรหัสจากคูปองทั้งเว็บและคูปองของผู้ขายที่มี note อยู่ในรายการสินค้า
+// (ผู้ขายต่างคนใช้รหัสเดียวกันได้) ถ้าตรงหลายใบเลือกใบที่ลดได้มากที่สุด
+// forUpdate = ล็อกคูปองที่ตรงไว้จนจบ transaction เพื่อกันการใช้เกินจำนวน
+func resolveCoupon(q sqlQuerier, code string, userID int, lines []couponLine, now time.Time, forUpdate bool) (*Coupon, *CouponQuote, error) {
+	code = normalizeCouponCode(code)
+	sellerIDs := []int{}
+	for _, line := range lines {
+		sellerIDs = append(sellerIDs, line.SellerID)
+	}
+	const match = `code = $1 AND (seller_id IS NULL OR seller_id = ANY($2))`
+
+	if forUpdate {
+		// ล็อกก่อนแล้วค่อยอ่าน เพื่อให้จำนวนครั้งที่ใช้ไปรวม redemption ที่ commit ระหว่างรอล็อกด้วย
+		rows, err := q.Query(`SELECT id FROM coupons WHERE `+match+` ORDER BY id FOR UPDATE`, code, pq.Array(sellerIDs))
+		if err != nil {
+			return nil, nil, err
+		}
+		for rows.Next() {
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	// คูปองของผู้ขายมาก่อนคูปองทั้งเว็บ (ถ้าลดได้เท่ากันจะใช้ของผู้ขาย)
+	rows, err := q.Query(`SELECT `+couponColumns+` FROM coupons c WHERE `+match+`
+		ORDER BY c.seller_id NULLS LAST, c.id`, code, pq.Array(sellerIDs))
+	if err != nil {
+		return nil, nil, err
+	}
+	coupons := []*Coupon{}
+	for rows.Next() {
+		coupon, err := scanCoupon(rows)
+		if err != nil {
+			rows.Close()
+			return nil, nil, err
+		}
+		coupons = append(coupons, coupon)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, nil, err
+	}
+	if len(coupons) == 0 {
+		return nil, nil, &CouponError{CouponNotFound, "Coupon code not found"}
+	}
+
+	var best *Coupon
+	var bestQuote *CouponQuote
+	var firstErr error
+	for _, coupon := range coupons {
+		quote, err := evaluateCoupon(q, coupon, userID, lines, now)
+		if err != nil {
+			if _, ok := err.(*CouponError); !ok {
+				return nil, nil, err
+			}
+			if firstErr == nil {
+				firstErr = err
+			}
+			continue
+		}
+		if bestQuote == nil || quote.Discount > bestQuote.Discount {
+			best, bestQuote = coupon, quote
+		}
+	}
+	if best == nil {
+		return nil, nil, firstErr
+	}
+	return best, bestQuote, nil
+}
+
+// couponLinesQuery - ข้อมูลสินค้าที่ใช้คิดส่วนลด (เฉพาะ note ที่ยังไม่ถูกลบ)
+const couponLinesQuery = `
+	SELECT n.id, n.book_title, n.seller_id, n.course_id, COALESCE(co.major, ''), n.price
+	FROM notes_for_sale n
+	LEFT JOIN courses co ON n.course_id = co.id
+	WHERE n.deleted_at IS NULL AND %s
+	ORDER BY n.id`
+
+func scanCouponLines(rows *sql.Rows, err error) ([]couponLine, error) {
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	lines := []couponLine{}
+	for rows.Next() {
+		var line couponLine
+		var courseID sql.NullInt64
+		if err := rows.Scan(&line.NoteID, &line.Title, &line.SellerID, &courseID, &line.Major, &line.Price); err != nil {
+			return nil, err
+		}
+		line.CourseID = nullIntPtr(courseID)
+		lines = append(lines, line)
+	}
+	return lines, rows.Err()
+}
+
+// loadCartCouponLines - สินค้าในตะกร้าของ user (ชิ้นที่ซื้อไม่ได้จะไม่ถูกนำมาคิด)
+func loadCartCouponLines(q sqlQuerier, userID int) ([]couponLine, error) {
+	lines, err := scanCouponLines(q.Query(fmt.Sprintf(couponLinesQuery,
+		`n.id IN (SELECT note_id FROM cart WHERE user_id = $1) AND n.status = 'available' AND n.seller_id <> $1
+		AND NOT EXISTS (SELECT 1 FROM buyed_note bn WHERE bn.user_id = $1 AND bn.note_id = n.id)`), userID))
+	if err != nil {
+		return nil, err
+	}
+	bundleIDs, err := cartBundleIDs(q, userID)
+	if err != nil {
+		return nil, err
+	}
+	quotes, err := loadBundleQuotes(q, userID, bundleIDs)
+	if err != nil {
+		return nil, err
+	}
+	return append(lines, bundleCouponLines(quotes)...), nil
+}
+
+// loadPurchaseCouponLines - note ที่กำลังจะซื้อ (ไม่รวม note ที่ user ซื้อไปแล้ว)
+func loadPurchaseCouponLines(q sqlQuerier, userID int, noteIDs []int) ([]couponLine, error) {
+	return scanCouponLines(q.Query(fmt.Sprintf(couponLinesQuery,
+		`n.id = ANY($2) AND NOT EXISTS (SELECT 1 FROM buyed_note bn WHERE bn.user_id = $1 AND bn.note_id = n.id)`),
+		userID, pq.Array(noteIDs)))
+}
+
+// appliesTo - สินค้าอยู่ใน scope ของคูปองหรือไม่
+// bundle ไม่มีวิชา/สาขา/note เดียว จึงเข้าได้เฉพาะคูปองที่จำกัดแค่ seller
+func (cp *Coupon) appliesTo(line couponLine) bool {
+	if cp.SellerID != nil && *cp.SellerID != line.SellerID {
+		return false
+	}
+	if cp.CourseID != nil && (line.CourseID == nil || *cp.CourseID != *line.CourseID) {
+		return false
+	}
+	if cp.Major != nil && !strings.EqualFold(*cp.Major, line.Major) {
+		return false
+	}
+	if cp.NoteID != nil && *cp.NoteID != line.NoteID {
+		return false
+	}
+	return true
+}
+
+// evaluateCoupon - ตรวจเงื่อนไขทั้งหมดของคูปองและคำนวณส่วนลด
+// ยอดขั้นต่ำเทียบกับยอดของสินค้าที่อยู่ใน scope ของคูปอง
+func evaluateCoupon(q sqlQuerier, coupon *Coupon, userID int, lines []couponLine, now time.Time) (*CouponQuote, error) {
+	if !coupon.IsActive {
+		return nil, &CouponError{CouponInactive, "Coupon is no longer active"}
+	}
+	if coupon.StartsAt != nil && now.Before(*coupon.StartsAt) {
+		return nil, &CouponError{CouponNotStarted, "Coupon is not valid yet"}
+	}
+	if coupon.EndsAt != nil && !now.Before(*coupon.EndsAt) {
+		return nil, &CouponError{CouponExpired, "Coupon has expired"}
+	}
+	if coupon.UsageLimit != nil && coupon.TimesUsed >= *coupon.UsageLimit {
+		return nil, &CouponError{CouponUsageLimit, "Coupon usage limit has been reached"}
+	}
+	if coupon.PerUserLimit != nil {
+		var used int
+		err := q.QueryRow(`SELECT COUNT(*) FROM coupon_redemptions WHERE coupon_id = $1 AND user_id = $2`,
+			coupon.ID, userID).Scan(&used)
+		if err != nil {
+			return nil, err
+		}
+		if used >= *coupon.PerUserLimit {
+			return nil, &CouponError{CouponPerUserLimit, "You have already used this coupon the maximum number of times"}
+		}
+	}
+
+	quote := &CouponQuote{
+		CouponID:      coupon.ID,
+		Code:          coupon.Code,
+		DiscountType:  coupon.DiscountType,
+		DiscountValue: coupon.DiscountValue,
+		Items:         []CouponLineDiscount{},
+	}
+	eligible := []int{}
+	for i, line := range lines {
+		quote.Subtotal += line.Price
+		item := CouponLineDiscount{NoteID: line.NoteID, BundleID: line.BundleID, Title: line.Title, Price: line.Price}
+		if coupon.appliesTo(line) && line.Price > 0 {
+			item.Eligible = true
+			quote.EligibleSubtotal += line.Price
+			eligible = append(eligible, i)
+		}
+		quote.Items = append(quote.Items, item)
+	}
+	quote.Subtotal = roundMoney(quote.Subtotal)
+	quote.EligibleSubtotal = roundMoney(quote.EligibleSubtotal)
+
+	if len(eligible) == 0 {
+		return nil, &CouponError{CouponNotApplicable, "Coupon does not apply to any item in your cart"}
+	}
+	if quote.EligibleSubtotal < coupon.MinCartValue {
+		return nil, &CouponError{CouponMinCartValue,
+			fmt.Sprintf("Minimum purchase of %.2f is required for this coupon", coupon.MinCartValue)}
+	}
+
+	discount := coupon.DiscountValue
+	if coupon.DiscountType == CouponPercent {
+		discount = quote.EligibleSubtotal * coupon.DiscountValue / 100
+		if coupon.MaxDiscount != nil {
+			discount = math.Min(discount, *coupon.MaxDiscount)
+		}
+	}
+	quote.Discount = roundMoney(math.Min(discount, quote.EligibleSubtotal))
+	quote.Total = roundMoney(quote.Subtotal - quote.Discount)
+
+	// กระจายส่วนลดตามสัดส่วนราคา (รายการสุดท้ายรับเศษที่เหลือ เพื่อให้ผลรวมตรงกับส่วนลดทั้งหมด)
+	remaining := quote.Discount
+	for n, i := range eligible {
+		share := remaining
+		if n < len(eligible)-1 {
+			share = roundMoney(quote.Discount * quote.Items[i].Price / quote.EligibleSubtotal)
+			remaining = roundMoney(remaining - share)
+		}
+		quote.Items[i].Discount = share
+	}
+	return quote, nil
+}
+
+// redeemCoupon - บันทึกการใช้คูปอง (ต้องเรียกใน transaction เดียวกับ resolveCoupon แบบ forUpdate)
+func redeemCoupon(tx *sql.Tx, quote *CouponQuote, userID int) error {
+	noteIDs, bundleIDs := []int{}, []int{}
+	for _, item := range quote.Items {
+		if !item.Eligible {
+			continue
+		}
+		if item.BundleID != 0 {
+			bundleIDs = append(bundleIDs, item.BundleID)
+		} else {
+			noteIDs = append(noteIDs, item.NoteID)
+		}
+	}
+	_, err := tx.Exec(`
+		INSERT INTO coupon_redemptions (coupon_id, user_id, note_ids, bundle_ids, subtotal, discount_amount, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, NOW())
+	`, quote.CouponID, userID, pq.Array(noteIDs), pq.Array(bundleIDs), quote.Subtotal, quote.Discount)
+	return err
+}
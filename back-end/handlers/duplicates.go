@@ -0,0 +1,256 @@
+package handlers
+
+import (
+	"back-end/config"
+	"back-end/storage"
+	"back-end/utils"
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+
+	"github.com/lib/pq"
+)
+
+// วิธีที่ใช้ตรวจว่า note ซ้ำ
+const (
+	DuplicateExactFile = "exact_file" // ไฟล์เหมือนกันทุก byte (SHA-256 ตรงกัน)
+	DuplicateText      = "text"       // ข้อความในไฟล์คล้ายกัน (MinHash ของ shingle)
+)
+
+const (
+	duplicateThreshold   = 0.5 // ความคล้ายขั้นต่ำที่นับว่าน่าจะซ้ำ
+	maxDuplicateMatches  = 5
+	fingerprintBatchSize = 50 // จำนวน note เดิมที่ทำ fingerprint ต่อรอบของ job
+)
+
+// DuplicateMatch - note เดิมที่ note นี้น่าจะซ้ำ
+type DuplicateMatch struct {
+	NoteID     int     `json:"note_id"`
+	Title      string  `json:"title"`
+	SellerID   int     `json:"seller_id"`
+	SellerName string  `json:"seller_name"`
+	Status     string  `json:"status"`
+	Similarity float64 `json:"similarity" example:"0.87"` // 0-1
+	Method     string  `json:"method" example:"text"`
+	Link       string  `json:"link" example:"/api/notes/12"`
+}
+
+// fingerprintNote - บันทึก fingerprint ของ PDF แล้วเทียบกับ note ที่เก่ากว่า
+// เก็บผลที่น่าจะซ้ำไว้ใน note_duplicate_matches และคืนรายการ (คล้ายมากสุดก่อน)
+// doc เป็น nil ได้ (parse ไม่ได้) จะเทียบแค่ SHA-256
+func fingerprintNote(tx *sql.Tx, noteID int, data []byte, doc *utils.PDFDocument) ([]DuplicateMatch, error) {
+	fileHash := utils.FileSHA256(data)
+	var text utils.TextFingerprint
+	if doc != nil {
+		text = utils.FingerprintText(doc.Text())
+	}
+	if text.Shingles < utils.MinTextShingles {
+		text.MinHash = nil
+	}
+
+	_, err := tx.Exec(`
+		INSERT INTO note_fingerprints (note_id, file_sha256, text_minhash, text_shingles, created_at)
+		VALUES ($1, $2, $3, $4, NOW())
+		ON CONFLICT (note_id) DO UPDATE
+		SET file_sha256 = EXCLUDED.file_sha256, text_minhash = EXCLUDED.text_minhash,
+			text_shingles = EXCLUDED.text_shingles, created_at = NOW()
+	`, noteID, fileHash, pq.Array(text.MinHash), text.Shingles)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := tx.Exec(`DELETE FROM note_fingerprint_bands WHERE note_id = $1`, noteID); err != nil {
+		return nil, err
+	}
+	bands := text.Bands()
+	if text.MinHash != nil {
+		_, err = tx.Exec(`
+			INSERT INTO note_fingerprint_bands (note_id, band, hash)
+			SELECT $1, b.ord - 1, b.hash FROM unnest($2::bigint[]) WITH ORDINALITY AS b(hash, ord)
+		`, noteID, pq.Array(bands))
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	scores := map[int]float64{}
+	methods := map[int]string{}
+
+	// ไฟล์เดียวกันทุก byte
+	rows, err := tx.Query(`
+		SELECT note_id FROM note_fingerprints WHERE file_sha256 = $1 AND note_id < $2
+	`, fileHash, noteID)
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		scores[id], methods[id] = 1, DuplicateExactFile
+	}
+	rows.Close()
+
+	// ข้อความคล้ายกัน: หาผู้สมัครจาก band ที่ตรงกันก่อน แล้วค่อยเทียบ MinHash ทั้งชุด
+	if text.MinHash != nil {
+		rows, err := tx.Query(`
+			SELECT f.note_id, f.text_minhash FROM note_fingerprints f
+			WHERE f.note_id IN (
+				SELECT DISTINCT nb.note_id FROM note_fingerprint_bands nb
+				JOIN unnest($1::bigint[]) WITH ORDINALITY AS b(hash, ord) ON nb.band = b.ord - 1 AND nb.hash = b.hash
+				WHERE nb.note_id < $2
+			)
+		`, pq.Array(bands), noteID)
+		if err != nil {
+			return nil, err
+		}
+		for rows.Next() {
+			var id int
+			var minHash []int64
+			if err := rows.Scan(&id, (*pq.Int64Array)(&minHash)); err != nil {
+				rows.Close()
+				return nil, err
+			}
+			similarity := utils.MinHashSimilarity(text.MinHash, minHash)
+			if similarity >= duplicateThreshold && similarity > scores[id] {
+				scores[id], methods[id] = similarity, DuplicateText
+			}
+		}
+		rows.Close()
+	}
+
+	ids := make([]int, 0, len(scores))
+	for id := range scores {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool {
+		if scores[ids[i]] != scores[ids[j]] {
+			return scores[ids[i]] > scores[ids[j]]
+		}
+		return ids[i] < ids[j]
+	})
+	if len(ids) > maxDuplicateMatches {
+		ids = ids[:maxDuplicateMatches]
+	}
+
+	if _, err := tx.Exec(`DELETE FROM note_duplicate_matches WHERE note_id = $1`, noteID); err != nil {
+		return nil, err
+	}
+	for _, id := range ids {
+		_, err := tx.Exec(`
+			INSERT INTO note_duplicate_matches (note_id, matched_note_id, similarity, method, created_at)
+			VALUES ($1, $2, $3, $4, NOW())
+		`, noteID, id, scores[id], methods[id])
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	matches, err := loadDuplicateMatches(tx, []int{noteID})
+	if err != nil {
+		return nil, err
+	}
+	return matches[noteID], nil
+}
+
+// loadDuplicateMatches - note เดิมที่แต่ละ note น่าจะซ้ำ (คล้ายมากสุดก่อน)
+func loadDuplicateMatches(q sqlQuerier, noteIDs []int) (map[int][]DuplicateMatch, error) {
+	result := map[int][]DuplicateMatch{}
+	if len(noteIDs) == 0 {
+		return result, nil
+	}
+
+	rows, err := q.Query(`
+		SELECT m.note_id, m.matched_note_id, n.book_title, n.seller_id,
+			COALESCE(u.fullname, u.username), COALESCE(n.status, ''), m.similarity, m.method
+		FROM note_duplicate_matches m
+		JOIN notes_for_sale n ON m.matched_note_id = n.id
+		LEFT JOIN users u ON n.seller_id = u.id
+		WHERE m.note_id = ANY($1)
+		ORDER BY m.note_id, m.similarity DESC, m.matched_note_id
+	`, pq.Array(noteIDs))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var noteID int
+		var m DuplicateMatch
+		if err := rows.Scan(&noteID, &m.NoteID, &m.Title, &m.SellerID, &m.SellerName, &m.Status, &m.Similarity, &m.Method); err != nil {
+			return nil, err
+		}
+		m.Link = fmt.Sprintf("/api/notes/%d", m.NoteID)
+		result[noteID] = append(result[noteID], m)
+	}
+	return result, rows.Err()
+}
+
+// fingerprintNotesJob - ทำ fingerprint ให้ note ที่ยังไม่มี (note ที่อัปโหลดก่อนมีระบบตรวจซ้ำ) ทีละ batch
+func fingerprintNotesJob(ctx context.Context, _ struct{}) error {
+	rows, err := config.DB.QueryContext(ctx, `
+		SELECT n.id, n.pdf_file FROM notes_for_sale n
+		WHERE NOT EXISTS (SELECT 1 FROM note_fingerprints f WHERE f.note_id = n.id)
+		ORDER BY n.id
+		LIMIT $1
+	`, fingerprintBatchSize)
+	if err != nil {
+		return err
+	}
+	type pending struct {
+		id   int
+		path string
+	}
+	var notes []pending
+	for rows.Next() {
+		var n pending
+		if err := rows.Scan(&n.id, &n.path); err != nil {
+			rows.Close()
+			return err
+		}
+		notes = append(notes, n)
+	}
+	rows.Close()
+
+	done := 0
+	for _, n := range notes {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		localPath, ok := storage.LocalPath(n.path)
+		if !ok {
+			log.Printf("⚠️  Skipping fingerprint of note %d: PDF outside uploads (%q)", n.id, n.path)
+			continue
+		}
+		data, err := os.ReadFile(localPath)
+		if err != nil {
+			log.Printf("⚠️  Skipping fingerprint of note %d: %v", n.id, err)
+			continue
+		}
+		doc, err := utils.ParsePDF(data)
+		if err != nil {
+			doc = nil
+		}
+
+		tx, err := config.DB.BeginTx(ctx, nil)
+		if err != nil {
+			return err
+		}
+		if _, err := fingerprintNote(tx, n.id, data, doc); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("fingerprint note %d: %w", n.id, err)
+		}
+		if err := tx.Commit(); err != nil {
+			return err
+		}
+		done++
+	}
+	if done > 0 {
+		log.Printf("🔎 Fingerprinted %d existing note(s)", done)
+	}
+	return nil
+}
@@ -0,0 +1,128 @@
+package handlers
+
+import (
+	"back-end/config"
+	"back-end/events"
+	"back-end/middleware"
+	"back-end/permissions"
+	"back-end/utils"
+	"io"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// eventsHeartbeat - ส่ง comment เป็นระยะกัน proxy ตัด connection ที่เงียบนานเกินไป
+// และตรวจซ้ำว่า token ยังใช้ได้และยังมีสิทธิ์รับ event ของ admin อยู่
+const eventsHeartbeat = 25 * time.Second
+
+// staffEventPermissions - สิทธิ์ที่ทำให้ได้รับ event ของ admin/ผู้ตรวจ (events.AdminTopic)
+var staffEventPermissions = []string{permissions.NotesApprove, permissions.ReportsManage, permissions.SellersVerify}
+
+// CreateEventsTicket godoc
+// @Summary ขอ ticket สำหรับเปิด event stream
+// @Description ออก ticket ที่ใช้ได้ครั้งเดียว อายุ 30 วินาที สำหรับเปิด GET /api/events?ticket= ด้วย EventSource (ซึ่งส่ง Authorization header ไม่ได้) โดยไม่ต้องใส่ access token ใน URL
+// @Tags events
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} map[string]interface{} "ticket และ expires_in (วินาที)"
+// @Failure 401 {object} map[string]string "Unauthorized"
+// @Failure 500 {object} map[string]string "Failed to create ticket"
+// @Router /api/events/ticket [post]
+func CreateEventsTicket(c *gin.Context) {
+	roles, _ := c.Get("roles")
+	roleNames, _ := roles.([]string)
+	ticket, err := utils.IssueStreamTicket(utils.JWTClaims{
+		UserID:       c.GetInt("user_id"),
+		Email:        c.GetString("email"),
+		Roles:        roleNames,
+		TokenVersion: c.GetInt("token_version"),
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create ticket"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"ticket":     ticket,
+		"expires_in": int(utils.StreamTicketTTL.Seconds()),
+	})
+}
+
+// StreamEvents godoc
+// @Summary รับ event แบบ real-time (Server-Sent Events)
+// @Description เปิด stream ค้างไว้เพื่อรับ event ของตัวเอง (note.approved, note.rejected, note.sold, review.created) และของ admin/ผู้ตรวจ (note.pending, note.approved, note.rejected, report.created, seller_application.created) สำหรับคนที่มีสิทธิ์ notes.approve, reports.manage หรือ sellers.verify แทนการ polling. EventSource ส่ง header ไม่ได้ จึงใช้ ?ticket= จาก POST /api/events/ticket แทน. stream จะถูกปิดเมื่อ token ถูกยกเลิก บัญชีถูกระงับ/แบน หรือสิทธิ์ของ admin ถูกถอน
+// @Tags events
+// @Produce text/event-stream
+// @Security BearerAuth
+// @Param ticket query string false "Stream ticket (ใช้แทน Authorization header)"
+// @Success 200 {string} string "text/event-stream"
+// @Failure 401 {object} map[string]string "Unauthorized"
+// @Router /api/events [get]
+func StreamEvents(c *gin.Context) {
+	userID := c.GetInt("user_id")
+	topics := []string{events.UserTopic(userID)}
+	staff, err := permissions.UserHasAny(config.DB, userID, staffEventPermissions...)
+	if err != nil {
+		log.Printf("⚠️  Failed to check permissions of user %d: %v", userID, err)
+	}
+	if staff {
+		topics = append(topics, events.AdminTopic)
+	}
+	sub := events.Subscribe(topics...)
+	defer sub.Close()
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no") // ปิด buffering ของ nginx
+	c.Status(http.StatusOK)
+	io.WriteString(c.Writer, ": connected\n\n")
+	c.Writer.Flush()
+
+	heartbeat := time.NewTicker(eventsHeartbeat)
+	defer heartbeat.Stop()
+
+	c.Stream(func(w io.Writer) bool {
+		select {
+		case event, ok := <-sub.C:
+			if !ok {
+				return false // server กำลังปิด
+			}
+			c.SSEvent(event.Type, event)
+			return true
+		case <-heartbeat.C:
+			if !streamStillAllowed(c, staff) {
+				return false
+			}
+			io.WriteString(w, ": ping\n\n")
+			return true
+		case <-c.Request.Context().Done():
+			return false
+		}
+	})
+}
+
+// streamStillAllowed - ตรวจซ้ำระหว่าง stream ว่า token ยังใช้ได้ และถ้ารับ event ของ admin อยู่ก็ยังมีสิทธิ์นั้น
+// ถ้า database มีปัญหาชั่วคราวจะให้ stream ทำงานต่อแล้วตรวจใหม่รอบหน้า
+func streamStillAllowed(c *gin.Context, staff bool) bool {
+	userID := c.GetInt("user_id")
+	valid, err := middleware.SessionValid(c)
+	if err != nil {
+		log.Printf("⚠️  Failed to re-check session of user %d: %v", userID, err)
+		return true
+	}
+	if !valid {
+		return false
+	}
+	if !staff {
+		return true
+	}
+	stillStaff, err := permissions.UserHasAny(config.DB, userID, staffEventPermissions...)
+	if err != nil {
+		log.Printf("⚠️  Failed to check permissions of user %d: %v", userID, err)
+		return true
+	}
+	return stillStaff
+}
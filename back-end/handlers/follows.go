@@ -0,0 +1,379 @@
+package handlers
+
+import (
+	"back-end/config"
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// เหตุผลที่ note อยู่ใน feed
+const (
+	FeedReasonFollowedSeller  = "followed_seller"  // ผู้ขายที่ติดตามอยู่ลง note ใหม่
+	FeedReasonPurchasedCourse = "purchased_course" // note ของวิชาที่เคยซื้อ note ไปแล้ว
+)
+
+// FollowedSeller - ผู้ขายที่ user ติดตามอยู่
+type FollowedSeller struct {
+	ID          int       `json:"id" example:"2"`
+	Username    string    `json:"username" example:"seller1"`
+	DisplayName string    `json:"display_name" example:"ผู้ขายคนที่ 1"`
+	AvatarURL   string    `json:"avatar_url"`
+	Verified    bool      `json:"verified"`
+	NotesCount  int       `json:"notes_count"`
+	FollowedAt  time.Time `json:"followed_at"`
+}
+
+// FeedItem - note ใน feed พร้อมเหตุผลที่แสดง
+type FeedItem struct {
+	NoteResponse
+	Reason     string `json:"reason" example:"followed_seller"`
+	ApprovedAt string `json:"approved_at"`
+}
+
+// followersNewNotePayload - payload ของ job followers.new_note
+type followersNewNotePayload struct {
+	NoteID int `json:"note_id"`
+}
+
+// parseSellerID - seller ID จาก path (:id)
+func parseSellerID(c *gin.Context) (int, bool) {
+	sellerID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid seller ID"})
+		return 0, false
+	}
+	return sellerID, true
+}
+
+// isPublicSeller - เป็นผู้ขายที่แสดงต่อสาธารณะได้ (มี role seller หรือเคยลง note และไม่ถูกแบน)
+func isPublicSeller(q sqlQuerier, sellerID int) (bool, error) {
+	var ok bool
+	err := q.QueryRow(`
+		SELECT EXISTS (
+			SELECT 1 FROM users u
+			WHERE u.id = $1 AND u.banned_at IS NULL AND (
+				EXISTS (
+					SELECT 1 FROM user_roles ur JOIN roles r ON r.id = ur.role_id
+					WHERE ur.user_id = u.id AND r.name = 'seller'
+				) OR EXISTS (SELECT 1 FROM notes_for_sale n WHERE n.seller_id = u.id)
+			)
+		)
+	`, sellerID).Scan(&ok)
+	return ok, err
+}
+
+// followerCount - จำนวนผู้ติดตามของ seller
+func followerCount(q sqlQuerier, sellerID int) (int, error) {
+	var count int
+	err := q.QueryRow(`SELECT COUNT(*) FROM seller_follows WHERE seller_id = $1`, sellerID).Scan(&count)
+	return count, err
+}
+
+// FollowSeller godoc
+// @Summary ติดตามผู้ขาย
+// @Description ติดตามผู้ขายเพื่อเห็น note ใหม่ใน feed และได้รับแจ้งเตือนเมื่อ note ใหม่ผ่านการอนุมัติ (ติดตามซ้ำได้โดยไม่ error)
+// @Tags sellers
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Seller ID"
+// @Success 200 {object} map[string]interface{} "สถานะการติดตามและจำนวนผู้ติดตาม"
+// @Failure 400 {object} map[string]string "Invalid seller ID or following yourself"
+// @Failure 401 {object} map[string]string "Unauthorized"
+// @Failure 404 {object} map[string]string "Seller not found"
+// @Failure 500 {object} map[string]string "Database error"
+// @Router /api/sellers/{id}/follow [post]
+func FollowSeller(c *gin.Context) {
+	sellerID, ok := parseSellerID(c)
+	if !ok {
+		return
+	}
+	userID := c.GetInt("user_id")
+	if sellerID == userID {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "You cannot follow yourself"})
+		return
+	}
+
+	isSeller, err := isPublicSeller(config.DB, sellerID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error", "message": err.Error()})
+		return
+	}
+	if !isSeller {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Seller not found"})
+		return
+	}
+
+	_, err = config.DB.Exec(`
+		INSERT INTO seller_follows (follower_id, seller_id, created_at)
+		VALUES ($1, $2, NOW())
+		ON CONFLICT (follower_id, seller_id) DO NOTHING
+	`, userID, sellerID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to follow seller", "message": err.Error()})
+		return
+	}
+
+	count, err := followerCount(config.DB, sellerID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error", "message": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success":         true,
+		"message":         "Seller followed",
+		"is_following":    true,
+		"followers_count": count,
+	})
+}
+
+// UnfollowSeller godoc
+// @Summary เลิกติดตามผู้ขาย
+// @Description เลิกติดตามผู้ขาย
+// @Tags sellers
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Seller ID"
+// @Success 200 {object} map[string]interface{} "สถานะการติดตามและจำนวนผู้ติดตาม"
+// @Failure 400 {object} map[string]string "Invalid seller ID"
+// @Failure 401 {object} map[string]string "Unauthorized"
+// @Failure 404 {object} map[string]string "Not following this seller"
+// @Failure 500 {object} map[string]string "Database error"
+// @Router /api/sellers/{id}/follow [delete]
+func UnfollowSeller(c *gin.Context) {
+	sellerID, ok := parseSellerID(c)
+	if !ok {
+		return
+	}
+
+	result, err := config.DB.Exec(`
+		DELETE FROM seller_follows WHERE follower_id = $1 AND seller_id = $2
+	`, c.GetInt("user_id"), sellerID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to unfollow seller", "message": err.Error()})
+		return
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "You are not following this seller"})
+		return
+	}
+
+	count, err := followerCount(config.DB, sellerID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error", "message": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success":         true,
+		"message":         "Seller unfollowed",
+		"is_following":    false,
+		"followers_count": count,
+	})
+}
+
+// GetFollowing godoc
+// @Summary ดึงผู้ขายที่ติดตามอยู่
+// @Description ดึงรายชื่อผู้ขายที่ user ติดตามอยู่ (ติดตามล่าสุดก่อน)
+// @Tags sellers
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {array} FollowedSeller "ผู้ขายที่ติดตาม"
+// @Failure 401 {object} map[string]string "Unauthorized"
+// @Failure 500 {object} map[string]string "Database error"
+// @Router /api/following [get]
+func GetFollowing(c *gin.Context) {
+	rows, err := config.DB.Query(`
+		SELECT u.id, u.username, COALESCE(NULLIF(u.fullname, ''), u.username), COALESCE(u.avatar_url, ''),
+			u.seller_verified_at IS NOT NULL,
+			(SELECT COUNT(*) FROM notes_for_sale n WHERE n.seller_id = u.id AND n.status = 'available'),
+			f.created_at
+		FROM seller_follows f
+		JOIN users u ON f.seller_id = u.id
+		WHERE f.follower_id = $1
+		ORDER BY f.created_at DESC
+	`, c.GetInt("user_id"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch followed sellers"})
+		return
+	}
+	defer rows.Close()
+
+	sellers := []FollowedSeller{}
+	for rows.Next() {
+		var s FollowedSeller
+		if err := rows.Scan(&s.ID, &s.Username, &s.DisplayName, &s.AvatarURL, &s.Verified, &s.NotesCount, &s.FollowedAt); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error scanning followed seller"})
+			return
+		}
+		sellers = append(sellers, s)
+	}
+
+	c.JSON(http.StatusOK, sellers)
+}
+
+// GetFeed godoc
+// @Summary ดึง feed ส่วนตัว
+// @Description ดึง note ที่เพิ่งผ่านการอนุมัติจากผู้ขายที่ติดตามอยู่ และจากวิชาที่เคยซื้อ note ไปแล้ว (ใหม่สุดก่อน ไม่รวม note ของตัวเองและที่ซื้อแล้ว)
+// @Tags notes
+// @Produce json
+// @Security BearerAuth
+// @Param page query int false "หน้าที่ (ค่าเริ่มต้น 1)"
+// @Param limit query int false "จำนวนต่อหน้า (ค่าเริ่มต้น 20, สูงสุด 100)"
+// @Success 200 {object} map[string]interface{} "รายการ note ใน feed"
+// @Failure 401 {object} map[string]string "Unauthorized"
+// @Failure 500 {object} map[string]string "Database error"
+// @Router /api/feed [get]
+func GetFeed(c *gin.Context) {
+	userID := c.GetInt("user_id")
+
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
+	if page < 1 {
+		page = 1
+	}
+	if limit < 1 || limit > 100 {
+		limit = 20
+	}
+
+	// ผู้ขายที่ติดตามมาก่อนวิชาที่เคยซื้อ (ถ้าเข้าทั้งสองเงื่อนไขแสดงเหตุผลเป็น followed_seller)
+	rows, err := config.DB.Query(`
+		WITH followed AS (
+			SELECT seller_id FROM seller_follows WHERE follower_id = $1
+		), purchased_courses AS (
+			SELECT DISTINCT n.course_id FROM buyed_note b
+			JOIN notes_for_sale n ON b.note_id = n.id
+			WHERE b.user_id = $1 AND n.course_id IS NOT NULL
+		)
+		SELECT `+noteRowColumns+`,
+			CASE WHEN n.seller_id IN (SELECT seller_id FROM followed) THEN $2 ELSE $3 END,
+			COALESCE(n.approved_at, n.created_at)
+		FROM notes_for_sale n
+		LEFT JOIN courses c ON n.course_id = c.id
+		LEFT JOIN users u ON n.seller_id = u.id
+		WHERE n.status = 'available' AND n.deleted_at IS NULL AND n.seller_id <> $1
+			AND (n.seller_id IN (SELECT seller_id FROM followed) OR n.course_id IN (SELECT course_id FROM purchased_courses))
+			AND NOT EXISTS (SELECT 1 FROM buyed_note b WHERE b.user_id = $1 AND b.note_id = n.id)
+		ORDER BY COALESCE(n.approved_at, n.created_at) DESC, n.id DESC
+		LIMIT $4 OFFSET $5
+	`, userID, FeedReasonFollowedSeller, FeedReasonPurchasedCourse, limit+1, (page-1)*limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Database error",
+			"message": err.Error(),
+		})
+		return
+	}
+	defer rows.Close()
+
+	items := []FeedItem{}
+	for rows.Next() {
+		var item FeedItem
+		var approvedAt time.Time
+		if err := scanNoteRow(rows, &item.NoteResponse, &item.Reason, &approvedAt); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error":   "Database error",
+				"message": err.Error(),
+			})
+			return
+		}
+		item.ApprovedAt = approvedAt.Format(time.RFC3339)
+		loadNoteImages(&item.NoteResponse)
+
+		items = append(items, item)
+	}
+	if err := rows.Err(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Database error",
+			"message": err.Error(),
+		})
+		return
+	}
+
+	// ดึงเกินมาหนึ่งรายการเพื่อดูว่ามีหน้าถัดไปหรือไม่
+	hasMore := len(items) > limit
+	if hasMore {
+		items = items[:limit]
+	}
+
+	notes := make([]NoteResponse, len(items))
+	for i := range items {
+		notes[i] = items[i].NoteResponse
+	}
+	applyWishlistInfo(c, notes)
+	applySellerBadges(notes)
+	for i := range items {
+		items[i].NoteResponse = notes[i]
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success":  true,
+		"data":     items,
+		"page":     page,
+		"limit":    limit,
+		"has_more": hasMore,
+	})
+}
+
+// followersNewNoteJob - แจ้งผู้ติดตามของ seller เมื่อ note ใหม่ผ่านการอนุมัติ
+// ใช้สถานะปัจจุบันตอนที่ job ทำงาน (ถ้า note ถูกเอาออกจากการขายไปแล้วจะไม่แจ้ง)
+func followersNewNoteJob(ctx context.Context, payload followersNewNotePayload) error {
+	var title, sellerName string
+	var sellerID int
+	err := config.DB.QueryRowContext(ctx, `
+		SELECT n.book_title, n.seller_id, COALESCE(NULLIF(u.fullname, ''), u.username)
+		FROM notes_for_sale n
+		JOIN users u ON n.seller_id = u.id
+		WHERE n.id = $1 AND n.status = 'available' AND n.deleted_at IS NULL
+	`, payload.NoteID).Scan(&title, &sellerID, &sellerName)
+	if err == sql.ErrNoRows {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	rows, err := config.DB.QueryContext(ctx, `
+		SELECT follower_id FROM seller_follows WHERE seller_id = $1
+	`, sellerID)
+	if err != nil {
+		return err
+	}
+	userIDs := []int{}
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err == nil {
+			userIDs = append(userIDs, id)
+		}
+	}
+	rows.Close()
+
+	// บันทึกทั้งหมดใน transaction เดียว ถ้า job ถูก retry จะไม่มีคนได้แจ้งเตือนซ้ำ
+	tx, err := config.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	data := gin.H{"note_id": payload.NoteID, "seller_id": sellerID}
+	message := fmt.Sprintf("%s published a new note: %s", sellerName, title)
+	for _, userID := range userIDs {
+		if err := notify(tx, userID, NotifyFollowedSellerNote, "New note from a seller you follow", message, data); err != nil {
+			return err
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	if len(userIDs) > 0 {
+		log.Printf("🔔 Notified %d followers of new note %d", len(userIDs), payload.NoteID)
+	}
+	return nil
+}
@@ -5,31 +5,88 @@ import (
 	"database/sql"
 	"fmt"
 	"net/http"
+	"strconv"
 
 	"github.com/gin-gonic/gin"
 )
 
 // NoteResponse - โครงสร้างข้อมูล note สำหรับ response
 type NoteResponse struct {
-	ID          int      `json:"id" example:"1"`
-	BookTitle   string   `json:"book_title" example:"สรุป Database Final"`
-	Price       float64  `json:"price" example:"99.00"`
-	ExamTerm    string   `json:"exam_term" example:"ปลายภาค"`
-	Description string   `json:"description" example:"สรุปเนื้อหาทั้งหมด"`
-	Status      string   `json:"status" example:"available"`
-	CreatedAt   string   `json:"created_at" example:"2024-01-01"`
-	CoverImage  string   `json:"cover_image" example:"/uploads/images/cover.jpg"`
-	Images      []string `json:"images"`
-	Course      Course   `json:"course"`
-	Seller      Seller   `json:"seller"`
-	TotalSales  int      `json:"total_sales" example:"5"`
-	LikedCount  int      `json:"liked_count" example:"10"`
+	ID             int             `json:"id" example:"1"`
+	BookTitle      string          `json:"book_title" example:"สรุป Database Final"`
+	Price          float64         `json:"price" example:"99.00"`
+	ExamTerm       string          `json:"exam_term" example:"ปลายภาค"`
+	Description    string          `json:"description" example:"สรุปเนื้อหาทั้งหมด"`
+	Status         string          `json:"status" example:"available"`
+	CreatedAt      string          `json:"created_at" example:"2024-01-01"`
+	CoverImage     string          `json:"cover_image" example:"/uploads/images/cover_card.jpg"`
+	CoverThumbnail string          `json:"cover_thumbnail" example:"/uploads/images/cover_thumb.jpg"`
+	Images         []string        `json:"images"`
+	Previews       []string        `json:"previews,omitempty"`
+	PreviewKind    string          `json:"preview_kind,omitempty" example:"pages"` // pages = รูปจากหน้า PDF, cover = รูปปกที่สร้างแทน
+	PageCount      int             `json:"page_count,omitempty" example:"24"`
+	Course         Course          `json:"course"`
+	Seller         Seller          `json:"seller"`
+	TotalSales     int             `json:"total_sales" example:"5"`
+	LikedCount     int             `json:"liked_count" example:"10"`
+	Bundles        []BundleSummary `json:"bundles,omitempty"`
+	WishlistCount  int             `json:"wishlist_count" example:"3"`
+	InWishlist     bool            `json:"in_wishlist" example:"false"`
 }
 
 type Seller struct {
 	ID       int    `json:"id" example:"1"`
 	Username string `json:"username" example:"seller1"`
 	Fullname string `json:"fullname" example:"ผู้ขายตัวอย่าง"`
+	Verified bool   `json:"verified" example:"true"` // ผ่านการยืนยันตัวตนผู้ขายแล้ว
+}
+
+// noteRowColumns - คอลัมน์ของ NoteResponse ที่ scanNoteRow อ่าน (alias n, c, u)
+const noteRowColumns = `
+	n.id, n.book_title, n.price, n.exam_term, n.description, n.status, n.created_at,
+	c.id, c.code, c.name, c.year, c.major,
+	u.id, u.username, u.fullname,
+	(SELECT COUNT(*) FROM buyed_note b WHERE b.note_id = n.id) as total_sales,
+	(SELECT COUNT(*) FROM reviews r WHERE r.note_id = n.id AND r.rating >= 4) as liked_count`
+
+// scanNoteRow - อ่าน note หนึ่งแถวที่ SELECT noteRowColumns ตามด้วยคอลัมน์ใน extra
+func scanNoteRow(rows *sql.Rows, note *NoteResponse, extra ...interface{}) error {
+	var courseID, sellerID sql.NullInt64
+	var courseCode, courseName, courseYear, courseMajor sql.NullString
+	var sellerUsername, sellerFullname sql.NullString
+	var examTerm sql.NullString
+
+	dest := []interface{}{
+		&note.ID, &note.BookTitle, &note.Price, &examTerm, &note.Description, &note.Status, &note.CreatedAt,
+		&courseID, &courseCode, &courseName, &courseYear, &courseMajor,
+		&sellerID, &sellerUsername, &sellerFullname,
+		&note.TotalSales,
+		&note.LikedCount,
+	}
+	if err := rows.Scan(append(dest, extra...)...); err != nil {
+		return err
+	}
+
+	if examTerm.Valid {
+		note.ExamTerm = examTerm.String
+	}
+	if courseID.Valid {
+		note.Course = Course{
+			ID:    int(courseID.Int64),
+			Code:  courseCode.String,
+			Name:  courseName.String,
+			Year:  courseYear.String,
+			Major: courseMajor.String,
+		}
+	}
+	if sellerID.Valid {
+		note.Seller = Seller{
+			ID:       int(sellerID.Int64),
+			Username: sellerUsername.String,
+			Fullname: sellerFullname.String,
+		}
+	}
+	return nil
 }
 
 // GetAllNotes godoc
@@ -61,7 +118,7 @@ func GetAllNotes(c *gin.Context) {
 			c.id, c.code, c.name, c.year, c.major,
 			u.id, u.username, u.fullname,
 			COALESCE(COUNT(b.id), 0) as total_sales,
-			COALESCE(SUM(CASE WHEN b.is_liked = true THEN 1 ELSE 0 END), 0) as liked_count
+			(SELECT COUNT(*) FROM reviews r WHERE r.note_id = n.id AND r.rating >= 4) as liked_count
 		FROM notes_for_sale n
 		LEFT JOIN courses c ON n.course_id = c.id
 		LEFT JOIN users u ON n.seller_id = u.id
@@ -168,28 +225,8 @@ func GetAllNotes(c *gin.Context) {
 			}
 		}
 
-		// ดึงรูปภาพ
-		imageQuery := `
-			SELECT path FROM note_images 
-			WHERE note_id = $1 
-			ORDER BY image_order ASC
-		`
-		imageRows, err := config.DB.Query(imageQuery, note.ID)
-		if err == nil {
-			images := []string{}
-			for imageRows.Next() {
-				var path string
-				if err := imageRows.Scan(&path); err == nil {
-					images = append(images, path)
-				}
-			}
-			imageRows.Close()
-
-			note.Images = images
-			if len(images) > 0 {
-				note.CoverImage = images[0] // รูปแรกเป็นหน้าปก
-			}
-		}
+		// ดึงรูปภาพ (แยกตามขนาด)
+		loadNoteImages(&note)
 
 		notes = append(notes, note)
 	}
@@ -199,6 +236,9 @@ func GetAllNotes(c *gin.Context) {
 		notes = []NoteResponse{}
 	}
 
+	applyWishlistInfo(c, notes)
+	applySellerBadges(notes)
+
 	c.JSON(http.StatusOK, notes)
 }
 
@@ -219,12 +259,13 @@ func GetNoteByID(c *gin.Context) {
 	query := `
 		SELECT 
 			n.id, n.book_title, n.price, n.exam_term, n.description, n.status, n.created_at, n.pdf_file,
+			COALESCE(n.page_count, 0),
 			c.id, c.code, c.name, c.year, c.major,
 			u.id, u.username, u.fullname
 		FROM notes_for_sale n
 		LEFT JOIN courses c ON n.course_id = c.id
 		LEFT JOIN users u ON n.seller_id = u.id
-		WHERE n.id = $1
+		WHERE n.id = $1 AND n.deleted_at IS NULL
 	`
 
 	var note NoteResponse
@@ -236,6 +277,7 @@ func GetNoteByID(c *gin.Context) {
 
 	err := config.DB.QueryRow(query, noteID).Scan(
 		&note.ID, &note.BookTitle, &note.Price, &examTerm, &note.Description, &note.Status, &note.CreatedAt, &pdfFile,
+		&note.PageCount,
 		&courseID, &courseCode, &courseName, &courseYear, &courseMajor,
 		&sellerID, &sellerUsername, &sellerFullname,
 	)
@@ -280,28 +322,20 @@ func GetNoteByID(c *gin.Context) {
 		}
 	}
 
-	// ดึงรูปภาพ
-	imageQuery := `
-		SELECT path FROM note_images 
-		WHERE note_id = $1 
-		ORDER BY image_order ASC
-	`
-	imageRows, err := config.DB.Query(imageQuery, note.ID)
-	if err == nil {
-		images := []string{}
-		for imageRows.Next() {
-			var path string
-			if err := imageRows.Scan(&path); err == nil {
-				images = append(images, path)
-			}
-		}
-		imageRows.Close()
+	// ดึงรูปภาพ (แยกตามขนาด)
+	loadNoteImages(&note)
 
-		note.Images = images
-		if len(images) > 0 {
-			note.CoverImage = images[0]
-		}
-	}
+	// ดึงรูปตัวอย่างหน้า PDF
+	note.Previews, note.PreviewKind = getNotePreviews(note.ID)
+
+	// bundle ที่มี note เล่มนี้อยู่
+	note.Bundles = getNoteBundles(note.ID)
+
+	// จำนวนคนที่บันทึก และ viewer บันทึกไว้หรือไม่
+	wishlist := []NoteResponse{note}
+	applyWishlistInfo(c, wishlist)
+	applySellerBadges(wishlist)
+	note = wishlist[0]
 
 	c.JSON(http.StatusOK, gin.H{
 		"data": note,
@@ -317,22 +351,33 @@ func GetNoteByID(c *gin.Context) {
 // @Security BearerAuth
 // @Param id path int true "User ID"
 // @Success 200 {array} NoteResponse "List of notes"
+// @Failure 400 {object} map[string]string "Invalid user ID"
 // @Failure 500 {object} map[string]string "Database error"
 // @Router /api/notes/user/{id} [get]
 func GetNotesByUserID(c *gin.Context) {
-	userID := c.Param("id")
-
-	// ตรวจสอบว่าเป็นเจ้าของร้านหรือไม่
-	isOwner := false
-	if userIDFromToken, exists := c.Get("user_id"); exists {
-		if fmt.Sprintf("%v", userIDFromToken) == userID {
-			isOwner = true
-		}
+	userID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
 	}
 
 	// ถ้าเป็นเจ้าของให้แสดง pending ด้วย ถ้าไม่ใช่แสดงแค่ available
+	notes, err := sellerNotes(c, userID, viewerID(c) == userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Database error",
+			"message": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, notes)
+}
+
+// sellerNotes - note ที่ seller วางขายอยู่ (includePending = รวม note ที่รออนุมัติ) พร้อมข้อมูล wishlist และ badge
+func sellerNotes(c *gin.Context, sellerID int, includePending bool) ([]NoteResponse, error) {
 	statusCondition := "n.status = 'available'"
-	if isOwner {
+	if includePending {
 		statusCondition = "(n.status = 'available' OR n.status = 'pending')"
 	}
 
@@ -342,7 +387,7 @@ func GetNotesByUserID(c *gin.Context) {
 			c.id, c.code, c.name, c.year, c.major,
 			u.id, u.username, u.fullname,
 			COALESCE(COUNT(b.id), 0) as total_sales,
-			COALESCE(SUM(CASE WHEN b.is_liked = true THEN 1 ELSE 0 END), 0) as liked_count
+			(SELECT COUNT(*) FROM reviews r WHERE r.note_id = n.id AND r.rating >= 4) as liked_count
 		FROM notes_for_sale n
 		LEFT JOIN courses c ON n.course_id = c.id
 		LEFT JOIN users u ON n.seller_id = u.id
@@ -354,13 +399,9 @@ func GetNotesByUserID(c *gin.Context) {
 		ORDER BY n.created_at DESC
 	`, statusCondition)
 
-	rows, err := config.DB.Query(query, userID)
+	rows, err := config.DB.Query(query, sellerID)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error":   "Database error",
-			"message": err.Error(),
-		})
-		return
+		return nil, err
 	}
 	defer rows.Close()
 
@@ -409,38 +450,16 @@ func GetNotesByUserID(c *gin.Context) {
 			}
 		}
 
-		// ดึงรูปภาพ
-		imageQuery := `
-			SELECT path FROM note_images 
-			WHERE note_id = $1 
-			ORDER BY image_order ASC
-		`
-		imageRows, err := config.DB.Query(imageQuery, note.ID)
-		if err == nil {
-			images := []string{}
-			for imageRows.Next() {
-				var path string
-				if err := imageRows.Scan(&path); err == nil {
-					images = append(images, path)
-				}
-			}
-			imageRows.Close()
-
-			note.Images = images
-			if len(images) > 0 {
-				note.CoverImage = images[0]
-			}
-		}
+		// ดึงรูปภาพ (แยกตามขนาด)
+		loadNoteImages(&note)
 
 		notes = append(notes, note)
 	}
 
-	// ถ้าไม่มีข้อมูล ส่ง array ว่าง
-	if notes == nil {
-		notes = []NoteResponse{}
-	}
+	applyWishlistInfo(c, notes)
+	applySellerBadges(notes)
 
-	c.JSON(http.StatusOK, notes)
+	return notes, nil
 }
 
 // GetBestSellingNotes godoc
@@ -459,7 +478,7 @@ func GetBestSellingNotes(c *gin.Context) {
 			c.id, c.code, c.name, c.year, c.major,
 			u.id, u.username, u.fullname,
 			COUNT(b.id) as sold_count,
-			COALESCE(SUM(CASE WHEN b.is_liked = true THEN 1 ELSE 0 END), 0) as liked_count
+			(SELECT COUNT(*) FROM reviews r WHERE r.note_id = n.id AND r.rating >= 4) as liked_count
 		FROM notes_for_sale n
 		LEFT JOIN courses c ON n.course_id = c.id
 		LEFT JOIN users u ON n.seller_id = u.id
@@ -531,43 +550,24 @@ func GetBestSellingNotes(c *gin.Context) {
 			}
 		}
 
-		// ดึงรูปภาพ
-		imageQuery := `
-			SELECT path FROM note_images 
-			WHERE note_id = $1 
-			ORDER BY image_order ASC
-		`
-		imageRows, err := config.DB.Query(imageQuery, note.ID)
-		if err == nil {
-			images := []string{}
-			for imageRows.Next() {
-				var path string
-				if err := imageRows.Scan(&path); err == nil {
-					images = append(images, path)
-				}
-			}
-			imageRows.Close()
-
-			note.Images = images
-			if len(images) > 0 {
-				note.CoverImage = images[0]
-			}
-		}
+		// ดึงรูปภาพ (แยกตามขนาด)
+		loadNoteImages(&note)
 
 		noteMap := map[string]interface{}{
-			"id":          note.ID,
-			"book_title":  note.BookTitle,
-			"price":       note.Price,
-			"exam_term":   note.ExamTerm,
-			"description": note.Description,
-			"status":      note.Status,
-			"created_at":  note.CreatedAt,
-			"cover_image": note.CoverImage,
-			"images":      note.Images,
-			"course":      note.Course,
-			"seller":      note.Seller,
-			"total_sales": soldCount,
-			"liked_count": note.LikedCount,
+			"id":              note.ID,
+			"book_title":      note.BookTitle,
+			"price":           note.Price,
+			"exam_term":       note.ExamTerm,
+			"description":     note.Description,
+			"status":          note.Status,
+			"created_at":      note.CreatedAt,
+			"cover_image":     note.CoverImage,
+			"cover_thumbnail": note.CoverThumbnail,
+			"images":          note.Images,
+			"course":          note.Course,
+			"seller":          note.Seller,
+			"total_sales":     soldCount,
+			"liked_count":     note.LikedCount,
 		}
 
 		notes = append(notes, noteMap)
@@ -577,6 +577,32 @@ func GetBestSellingNotes(c *gin.Context) {
 		notes = []map[string]interface{}{}
 	}
 
+	// จำนวนคนที่บันทึก และ viewer บันทึกไว้หรือไม่
+	noteIDs := make([]int, len(notes))
+	for i, note := range notes {
+		noteIDs[i] = note["id"].(int)
+	}
+	if counts, saved, err := wishlistInfo(viewerID(c), noteIDs); err == nil {
+		for _, note := range notes {
+			id := note["id"].(int)
+			note["wishlist_count"] = counts[id]
+			note["in_wishlist"] = saved[id]
+		}
+	}
+
+	// badge ยืนยันตัวตนของ seller
+	sellerIDs := make([]int, len(notes))
+	for i, note := range notes {
+		sellerIDs[i] = note["seller"].(Seller).ID
+	}
+	if verified, err := verifiedSellers(config.DB, sellerIDs); err == nil {
+		for _, note := range notes {
+			seller := note["seller"].(Seller)
+			seller.Verified = verified[seller.ID]
+			note["seller"] = seller
+		}
+	}
+
 	c.JSON(http.StatusOK, notes)
 }
 
@@ -596,7 +622,7 @@ func GetLatestNotes(c *gin.Context) {
 			c.id, c.code, c.name, c.year, c.major,
 			u.id, u.username, u.fullname,
 			COALESCE(COUNT(b.id), 0) as total_sales,
-			COALESCE(SUM(CASE WHEN b.is_liked = true THEN 1 ELSE 0 END), 0) as liked_count
+			(SELECT COUNT(*) FROM reviews r WHERE r.note_id = n.id AND r.rating >= 4) as liked_count
 		FROM notes_for_sale n
 		LEFT JOIN courses c ON n.course_id = c.id
 		LEFT JOIN users u ON n.seller_id = u.id
@@ -664,28 +690,8 @@ func GetLatestNotes(c *gin.Context) {
 			}
 		}
 
-		// ดึงรูปภาพ
-		imageQuery := `
-			SELECT path FROM note_images 
-			WHERE note_id = $1 
-			ORDER BY image_order ASC
-		`
-		imageRows, err := config.DB.Query(imageQuery, note.ID)
-		if err == nil {
-			images := []string{}
-			for imageRows.Next() {
-				var path string
-				if err := imageRows.Scan(&path); err == nil {
-					images = append(images, path)
-				}
-			}
-			imageRows.Close()
-
-			note.Images = images
-			if len(images) > 0 {
-				note.CoverImage = images[0]
-			}
-		}
+		// ดึงรูปภาพ (แยกตามขนาด)
+		loadNoteImages(&note)
 
 		notes = append(notes, note)
 	}
@@ -694,6 +700,9 @@ func GetLatestNotes(c *gin.Context) {
 		notes = []NoteResponse{}
 	}
 
+	applyWishlistInfo(c, notes)
+	applySellerBadges(notes)
+
 	c.JSON(http.StatusOK, notes)
 }
 
@@ -713,7 +722,7 @@ func GetMostLikedNotes(c *gin.Context) {
 			c.id, c.code, c.name, c.year, c.major,
 			u.id, u.username, u.fullname,
 			COALESCE(COUNT(b.id), 0) as total_sales,
-			COALESCE(SUM(CASE WHEN b.is_liked = true THEN 1 ELSE 0 END), 0) as liked_count
+			(SELECT COUNT(*) FROM reviews r WHERE r.note_id = n.id AND r.rating >= 4) as liked_count
 		FROM notes_for_sale n
 		LEFT JOIN courses c ON n.course_id = c.id
 		LEFT JOIN users u ON n.seller_id = u.id
@@ -786,28 +795,8 @@ func GetMostLikedNotes(c *gin.Context) {
 			}
 		}
 
-		// ดึงรูปภาพ
-		imageQuery := `
-			SELECT path FROM note_images 
-			WHERE note_id = $1 
-			ORDER BY image_order ASC
-		`
-		imageRows, err := config.DB.Query(imageQuery, note.ID)
-		if err == nil {
-			images := []string{}
-			for imageRows.Next() {
-				var path string
-				if err := imageRows.Scan(&path); err == nil {
-					images = append(images, path)
-				}
-			}
-			imageRows.Close()
-
-			note.Images = images
-			if len(images) > 0 {
-				note.CoverImage = images[0]
-			}
-		}
+		// ดึงรูปภาพ (แยกตามขนาด)
+		loadNoteImages(&note)
 
 		notes = append(notes, note)
 	}
@@ -816,5 +805,8 @@ func GetMostLikedNotes(c *gin.Context) {
 		notes = []NoteResponse{}
 	}
 
+	applyWishlistInfo(c, notes)
+	applySellerBadges(notes)
+
 	c.JSON(http.StatusOK, notes)
 }
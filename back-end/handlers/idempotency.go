@@ -0,0 +1,121 @@
+package handlers
+
+import (
+	"back-end/config"
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// IdempotencyKeyHeader - header ที่ client ส่งมาเพื่อกันการสั่งซื้อซ้ำ (กดซ้ำ/retry ด้วย key เดิมจะได้ผลลัพธ์เดิม)
+const IdempotencyKeyHeader = "Idempotency-Key"
+
+const (
+	maxIdempotencyKeyLength = 255
+	idempotencyKeyTTL       = 24 * time.Hour // เก็บผลลัพธ์ไว้ให้ replay ได้นานเท่านี้
+)
+
+// errIdempotencyKeyReused - ใช้ key เดิมกับ request ที่ต่างออกไป
+var errIdempotencyKeyReused = errors.New("idempotency key was already used with a different request")
+
+// idempotentResponse - ผลลัพธ์ที่เก็บไว้ของ request ที่ทำสำเร็จแล้ว
+type idempotentResponse struct {
+	Status int
+	Body   []byte
+}
+
+// idempotencyKey - อ่านและตรวจสอบ Idempotency-Key (ไม่ส่งมา = ไม่ใช้ idempotency)
+func idempotencyKey(c *gin.Context) (string, bool) {
+	key := c.GetHeader(IdempotencyKeyHeader)
+	if len(key) > maxIdempotencyKeyLength {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Idempotency-Key must be at most 255 characters",
+		})
+		return "", false
+	}
+	return key, true
+}
+
+// requestFingerprint - hash ของ endpoint และ request ที่ normalize แล้ว ใช้ตรวจว่า key เดิมถูกใช้กับ request เดิม
+func requestFingerprint(endpoint string, request interface{}) string {
+	body, _ := json.Marshal(request)
+	sum := sha256.Sum256(append([]byte(endpoint+"\n"), body...))
+	return hex.EncodeToString(sum[:])
+}
+
+// claimIdempotencyKey - จอง key ใน transaction ของการสั่งซื้อ
+// request อื่นที่ใช้ key เดียวกันจะรอจน transaction นี้จบ: ถ้า commit จะได้ผลลัพธ์เดิมกลับไป
+// ถ้า rollback (เช่น error) key จะถูกปล่อยและ retry จะทำงานใหม่ตามปกติ
+// คืนผลลัพธ์เดิมถ้า key นี้เคยทำสำเร็จแล้ว
+func claimIdempotencyKey(tx *sql.Tx, userID int, key, endpoint, fingerprint string) (*idempotentResponse, error) {
+	result, err := tx.Exec(`
+		INSERT INTO idempotency_keys (user_id, idem_key, endpoint, request_hash, created_at)
+		VALUES ($1, $2, $3, $4, NOW())
+		ON CONFLICT (user_id, idem_key) DO NOTHING
+	`, userID, key, endpoint, fingerprint)
+	if err != nil {
+		return nil, err
+	}
+	if n, _ := result.RowsAffected(); n == 1 {
+		return nil, nil
+	}
+
+	var storedEndpoint, storedHash string
+	var status sql.NullInt64
+	var body []byte
+	err = tx.QueryRow(`
+		SELECT endpoint, request_hash, status_code, response FROM idempotency_keys
+		WHERE user_id = $1 AND idem_key = $2
+	`, userID, key).Scan(&storedEndpoint, &storedHash, &status, &body)
+	if err != nil {
+		return nil, err
+	}
+	if storedEndpoint != endpoint || storedHash != fingerprint {
+		return nil, errIdempotencyKeyReused
+	}
+	if !status.Valid {
+		// ไม่ควรเกิด เพราะผลลัพธ์ถูกบันทึกใน transaction เดียวกับที่จอง key
+		return nil, errors.New("idempotency key has no stored response")
+	}
+	return &idempotentResponse{Status: int(status.Int64), Body: body}, nil
+}
+
+// saveIdempotentResponse - บันทึกผลลัพธ์ของ key (ต้องเรียกก่อน commit transaction เดียวกับ claimIdempotencyKey)
+func saveIdempotentResponse(tx *sql.Tx, userID int, key string, status int, response interface{}) error {
+	body, err := json.Marshal(response)
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec(`
+		UPDATE idempotency_keys SET status_code = $1, response = $2
+		WHERE user_id = $3 AND idem_key = $4
+	`, status, body, userID, key)
+	return err
+}
+
+// replayIdempotentResponse - ส่งผลลัพธ์เดิมกลับไป
+func replayIdempotentResponse(c *gin.Context, stored *idempotentResponse) {
+	c.Header("Idempotent-Replayed", "true")
+	c.Data(stored.Status, "application/json; charset=utf-8", stored.Body)
+}
+
+// purgeIdempotencyKeysJob - ลบ key ที่เก่ากว่า idempotencyKeyTTL
+func purgeIdempotencyKeysJob(ctx context.Context, _ struct{}) error {
+	result, err := config.DB.ExecContext(ctx, `DELETE FROM idempotency_keys WHERE created_at < $1`,
+		time.Now().Add(-idempotencyKeyTTL))
+	if err != nil {
+		return err
+	}
+	if n, _ := result.RowsAffected(); n > 0 {
+		log.Printf("🧹 Purged %d expired idempotency keys", n)
+	}
+	return nil
+}
@@ -0,0 +1,204 @@
+package handlers
+
+import (
+	"back-end/config"
+	"back-end/utils"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"os"
+	"path/filepath"
+)
+
+// ขีดจำกัดขนาดไฟล์รูปที่อัปโหลด
+const (
+	maxImageUploadSize  = 10 * 1024 * 1024 // 10MB
+	maxAvatarUploadSize = 5 * 1024 * 1024  // 5MB
+)
+
+// ImageValidationError - รูปภาพไม่ผ่านการตรวจสอบ (ตอบกลับเป็น 400)
+type ImageValidationError struct {
+	Message string
+}
+
+func (e *ImageValidationError) Error() string {
+	return e.Message
+}
+
+// imageOwner - เจ้าของรูปใน image_variants (note_image_id, user_id หรือ slider_image_id)
+type imageOwner struct {
+	column string
+	id     int
+}
+
+func noteImageOwner(noteImageID int) imageOwner { return imageOwner{"note_image_id", noteImageID} }
+func avatarOwner(userID int) imageOwner         { return imageOwner{"user_id", userID} }
+func sliderOwner(sliderImageID int) imageOwner  { return imageOwner{"slider_image_id", sliderImageID} }
+
+// sqlExecutor - ใช้ได้ทั้ง *sql.DB และ *sql.Tx
+type sqlExecutor interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+}
+
+// savedVariant - รูปที่บันทึกลงดิสก์แล้ว
+type savedVariant struct {
+	utils.ImageVariant
+	Path string
+}
+
+// processUploadedImage - อ่านไฟล์รูป ตรวจ MIME จากเนื้อหา decode เพื่อยืนยันว่าเป็นรูปจริง แล้วสร้างรูปตามขนาดมาตรฐาน
+func processUploadedImage(fileHeader *multipart.FileHeader, maxSize int64, specs []utils.ImageVariantSpec) ([]utils.ImageVariant, error) {
+	if fileHeader.Size > maxSize {
+		return nil, &ImageValidationError{fmt.Sprintf("File size too large. Maximum %dMB allowed", maxSize/(1024*1024))}
+	}
+
+	src, err := fileHeader.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer src.Close()
+
+	data, err := io.ReadAll(io.LimitReader(src, maxSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(data)) > maxSize {
+		return nil, &ImageValidationError{fmt.Sprintf("File size too large. Maximum %dMB allowed", maxSize/(1024*1024))}
+	}
+
+	variants, err := utils.ProcessImage(data, specs)
+	if errors.Is(err, utils.ErrImageType) || errors.Is(err, utils.ErrImageCorrupt) || errors.Is(err, utils.ErrImageTooLarge) {
+		return nil, &ImageValidationError{err.Error()}
+	}
+	return variants, err
+}
+
+// writeImageVariants - บันทึกรูปแต่ละขนาดลงดิสก์ในชื่อ {baseName}_{variant}.jpg
+func writeImageVariants(dir, baseName string, variants []utils.ImageVariant) ([]savedVariant, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+
+	saved := []savedVariant{}
+	for _, variant := range variants {
+		path := filepath.Join(dir, fmt.Sprintf("%s_%s.jpg", baseName, variant.Name))
+		if err := os.WriteFile(path, variant.Data, 0644); err != nil {
+			removeVariantFiles(saved)
+			return nil, err
+		}
+		saved = append(saved, savedVariant{ImageVariant: variant, Path: path})
+	}
+	return saved, nil
+}
+
+// variantPath - path ของรูปขนาดที่ต้องการ (ถ้าไม่มีใช้ขนาด full)
+func variantPath(saved []savedVariant, name string) string {
+	fallback := ""
+	for _, v := range saved {
+		if v.Name == name {
+			return v.Path
+		}
+		if v.Name == "full" {
+			fallback = v.Path
+		}
+	}
+	return fallback
+}
+
+func removeVariantFiles(saved []savedVariant) {
+	for _, v := range saved {
+		os.Remove(v.Path)
+	}
+}
+
+// insertImageVariants - บันทึกข้อมูลรูปแต่ละขนาดลง image_variants
+func insertImageVariants(exec sqlExecutor, owner imageOwner, saved []savedVariant) error {
+	query := fmt.Sprintf(`
+		INSERT INTO image_variants (%s, variant, path, width, height, mime_type, size_bytes, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, NOW())
+	`, owner.column)
+	for _, v := range saved {
+		if _, err := exec.Exec(query, owner.id, v.Name, v.Path, v.Width, v.Height, v.MimeType, len(v.Data)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// deleteImageVariants - ลบข้อมูล image_variants ของเจ้าของรูป และคืนค่า path ของไฟล์ที่ต้องลบ
+func deleteImageVariants(exec sqlExecutor, owner imageOwner) ([]string, error) {
+	rows, err := exec.Query(fmt.Sprintf(`DELETE FROM image_variants WHERE %s = $1 RETURNING path`, owner.column), owner.id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	paths := []string{}
+	for rows.Next() {
+		var path string
+		if err := rows.Scan(&path); err == nil {
+			paths = append(paths, path)
+		}
+	}
+	return paths, rows.Err()
+}
+
+// noteImageSet - รูปของ note แยกตามขนาด
+type noteImageSet struct {
+	Full  []string
+	Card  []string
+	Thumb []string
+}
+
+// getNoteImageSet - ดึงรูปของ note ทุกขนาด (รูปเก่าที่ไม่มี variant จะใช้ path เดิม)
+func getNoteImageSet(noteID int) noteImageSet {
+	set := noteImageSet{Full: []string{}, Card: []string{}, Thumb: []string{}}
+
+	rows, err := config.DB.Query(`
+		SELECT ni.path, COALESCE(card.path, ni.path), COALESCE(thumb.path, ni.path)
+		FROM note_images ni
+		LEFT JOIN image_variants card ON card.note_image_id = ni.id AND card.variant = 'card'
+		LEFT JOIN image_variants thumb ON thumb.note_image_id = ni.id AND thumb.variant = 'thumb'
+		WHERE ni.note_id = $1
+		ORDER BY ni.image_order ASC
+	`, noteID)
+	if err != nil {
+		return set
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var full, card, thumb string
+		if err := rows.Scan(&full, &card, &thumb); err == nil {
+			set.Full = append(set.Full, full)
+			set.Card = append(set.Card, card)
+			set.Thumb = append(set.Thumb, thumb)
+		}
+	}
+	return set
+}
+
+// loadNoteImages - ใส่รูปของ note ลงใน response (images = full, cover_image = card, cover_thumbnail = thumb)
+func loadNoteImages(note *NoteResponse) {
+	set := getNoteImageSet(note.ID)
+	note.Images = set.Full
+	if len(set.Full) > 0 {
+		note.CoverImage = set.Card[0] // รูปแรกเป็นหน้าปก
+		note.CoverThumbnail = set.Thumb[0]
+	}
+}
+
+// coverImageSQL - subquery สำหรับรูปหน้าปกของ note (alias n) ตามขนาดที่ต้องการ
+func coverImageSQL(variant string) string {
+	return fmt.Sprintf(`(
+		SELECT COALESCE(v.path, ni.path)
+		FROM note_images ni
+		LEFT JOIN image_variants v ON v.note_image_id = ni.id AND v.variant = '%s'
+		WHERE ni.note_id = n.id
+		ORDER BY ni.image_order
+		LIMIT 1
+	)`, variant)
+}
@@ -0,0 +1,138 @@
+package handlers
+
+import (
+	"back-end/config"
+	"back-end/jobs"
+	"back-end/storage"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ชนิดของ background job
+const (
+	JobDeleteFiles       = "files.delete"              // ลบไฟล์ใน ./uploads ที่ไม่ถูกใช้แล้ว
+	JobNotePreviews      = "notes.previews"            // สร้างรูปตัวอย่างหน้า PDF ของ note ที่อัปโหลดใหม่
+	JobUploadsGC         = "uploads.gc"                // รายงาน (และลบ) ไฟล์ใน ./uploads ที่ไม่มีข้อมูลใน database อ้างถึง
+	JobNotesPurge        = "notes.purge"               // ลบถาวร note ที่ถูก soft delete นานเกินกำหนดและไม่มีผู้ซื้อ
+	JobIdemPurge         = "idempotency.purge"         // ลบ Idempotency-Key ที่หมดอายุแล้ว
+	JobWishlistPriceDrop = "wishlist.price_drop"       // แจ้งเตือนคนที่บันทึก note ไว้เมื่อราคาลดลง
+	JobNotifyEmail       = "notifications.email"       // ส่ง email แจ้งเตือน
+	JobWebhookDeliver    = "webhooks.deliver"          // ส่ง webhook หนึ่งรายการ (retry แบบ backoff)
+	JobNotesFingerprint  = "notes.fingerprint"         // ทำ fingerprint (ตรวจซ้ำ) ให้ note เดิมที่ยังไม่มี
+	JobFollowersNewNote  = "followers.new_note"        // แจ้งผู้ติดตามเมื่อ seller มี note ใหม่ผ่านการอนุมัติ
+	JobRecommendations   = "recommendations.recompute" // คำนวณตาราง note ที่คล้ายกัน (สำหรับแนะนำ) ใหม่
+)
+
+// รอบการทำงานของ scheduled job
+const (
+	uploadsGCInterval       = 24 * time.Hour
+	notesPurgeInterval      = 24 * time.Hour
+	idemPurgeInterval       = time.Hour
+	fingerprintInterval     = time.Hour
+	recommendationsInterval = 6 * time.Hour
+)
+
+// RegisterJobs - ผูก job handler ทั้งหมดกับ worker pool
+func RegisterJobs(pool *jobs.Pool) {
+	pool.Register(JobDeleteFiles, jobs.Typed(deleteFilesJob))
+	pool.Register(JobNotePreviews, jobs.Typed(notePreviewsJob))
+	pool.Register(JobUploadsGC, jobs.Typed(uploadsGCJob))
+	pool.Register(JobNotesPurge, jobs.Typed(purgeDeletedNotesJob))
+	pool.Register(JobIdemPurge, jobs.Typed(purgeIdempotencyKeysJob))
+	pool.Register(JobWishlistPriceDrop, jobs.Typed(wishlistPriceDropJob))
+	pool.Register(JobNotifyEmail, jobs.Typed(notifyEmailJob))
+	pool.Register(JobWebhookDeliver, jobs.Typed(deliverWebhookJob))
+	pool.Register(JobNotesFingerprint, jobs.Typed(fingerprintNotesJob))
+	pool.Register(JobFollowersNewNote, jobs.Typed(followersNewNoteJob))
+	pool.Register(JobRecommendations, jobs.Typed(recomputeRecommendationsJob))
+
+	pool.Schedule("uploads-gc", JobUploadsGC, uploadsGCInterval, uploadsGCPayload{Delete: uploadsGCDeleteEnabled()})
+	pool.Schedule("notes-purge", JobNotesPurge, notesPurgeInterval, nil)
+	pool.Schedule("idempotency-purge", JobIdemPurge, idemPurgeInterval, nil)
+	pool.Schedule("notes-fingerprint", JobNotesFingerprint, fingerprintInterval, nil)
+	pool.Schedule("recommendations", JobRecommendations, recommendationsInterval, nil)
+}
+
+// deleteFilesPayload - payload ของ job files.delete
+type deleteFilesPayload struct {
+	Paths []string `json:"paths"`
+}
+
+// enqueueFileDeletion - สั่งลบไฟล์แบบ background (ไม่ทำให้ request ช้า) ถ้า enqueue ไม่ได้จะแค่ log ไว้
+func enqueueFileDeletion(paths []string) {
+	if len(paths) == 0 {
+		return
+	}
+	if _, err := jobs.Enqueue(JobDeleteFiles, deleteFilesPayload{Paths: paths}, jobs.EnqueueOptions{}); err != nil {
+		log.Printf("⚠️  Failed to enqueue file deletion: %v", err)
+	}
+}
+
+// deleteFilesJob - ลบไฟล์ตาม path (เฉพาะไฟล์ที่อยู่ใน ./uploads เท่านั้น)
+func deleteFilesJob(ctx context.Context, payload deleteFilesPayload) error {
+	failed := []string{}
+	for _, path := range payload.Paths {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		localPath, ok := storage.LocalPath(path)
+		if !ok {
+			log.Printf("⚠️  Refusing to delete file outside uploads: %q", path)
+			continue
+		}
+		if err := os.Remove(localPath); err != nil && !errors.Is(err, os.ErrNotExist) {
+			failed = append(failed, localPath)
+		}
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("failed to delete %d file(s): %s", len(failed), strings.Join(failed, ", "))
+	}
+	return nil
+}
+
+// maxLoggedOrphans - จำนวน path ของไฟล์ขยะที่ log ไว้ให้ตรวจในแต่ละรอบ
+const maxLoggedOrphans = 50
+
+// uploadsGCPayload - payload ของ job uploads.gc (ค่าเริ่มต้นเป็น dry run ที่รายงานไฟล์ขยะโดยไม่ลบ)
+type uploadsGCPayload struct {
+	Delete bool `json:"delete"`
+}
+
+// uploadsGCDeleteEnabled - ให้รอบที่ตั้งเวลาไว้ลบไฟล์ขยะจริง ต้องเปิดเองผ่าน UPLOADS_GC_DELETE=true
+// (ควรตรวจรายงานจาก dry run หรือ ./main gc-uploads ก่อนเปิด)
+func uploadsGCDeleteEnabled() bool {
+	enabled, _ := strconv.ParseBool(os.Getenv("UPLOADS_GC_DELETE"))
+	return enabled
+}
+
+// uploadsGCJob - รายงาน (และลบถ้า payload.Delete) ไฟล์ขยะใน ./uploads (ไฟล์ที่ไม่มีข้อมูลใน database อ้างถึง)
+func uploadsGCJob(ctx context.Context, payload uploadsGCPayload) error {
+	report, err := storage.CollectGarbage(config.DB, storage.DefaultMinAge, !payload.Delete)
+	if err != nil {
+		return err
+	}
+	if report.DryRun {
+		for i, orphan := range report.Orphans {
+			if i == maxLoggedOrphans {
+				log.Printf("🧹 Upload GC (dry run): ... and %d more", len(report.Orphans)-maxLoggedOrphans)
+				break
+			}
+			log.Printf("🧹 Upload GC (dry run): orphaned %s (%d bytes)", orphan.Path, orphan.Size)
+		}
+		log.Printf("🧹 Upload GC (dry run): scanned %d files, %d orphaned (%d bytes), nothing deleted (set UPLOADS_GC_DELETE=true to delete)",
+			report.Scanned, len(report.Orphans), report.OrphanSize)
+		return nil
+	}
+	log.Printf("🧹 Upload GC: scanned %d files, %d orphaned (%d bytes), %d deleted",
+		report.Scanned, len(report.Orphans), report.OrphanSize, report.Deleted)
+	if len(report.Errors) > 0 {
+		return fmt.Errorf("failed to delete %d orphaned file(s): %s", len(report.Errors), strings.Join(report.Errors, "; "))
+	}
+	return nil
+}
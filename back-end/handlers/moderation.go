@@ -0,0 +1,508 @@
+package handlers
+
+import (
+	"back-end/config"
+	"back-end/permissions"
+	"database/sql"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// AssignReportInput - ผู้ตรวจที่รับเรื่อง (ต้องมีสิทธิ์ reports.manage, ไม่ส่ง = ตัวเอง)
+type AssignReportInput struct {
+	AssigneeID *int `json:"assignee_id" example:"1"`
+}
+
+// CloseReportInput - ผลการตรวจรายงาน
+type CloseReportInput struct {
+	Resolution  string `json:"resolution" binding:"required" example:"ยืนยันว่าคัดลอกมา เอาออกจากการขายแล้ว"`
+	Action      string `json:"action" example:"delist_note"` // none (ค่าเริ่มต้น), delist_note, suspend_user (ใช้ตอน resolve เท่านั้น)
+	SuspendDays int    `json:"suspend_days" example:"7"`     // ใช้กับ suspend_user (ค่าเริ่มต้น 7 วัน)
+}
+
+// reportActionPermissions - สิทธิ์ที่ต้องมีเพิ่มจาก reports.manage สำหรับการจัดการแต่ละแบบ
+var reportActionPermissions = map[string]string{
+	ReportActionDelistNote:  permissions.NotesEdit,
+	ReportActionSuspendUser: permissions.UsersBan,
+}
+
+// respondReportError - ตอบกลับ error ของรายงาน
+func respondReportError(c *gin.Context, err error) {
+	if fieldErr, ok := err.(*NoteFieldError); ok {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid input",
+			"field":   fieldErr.Field,
+			"message": fieldErr.Message,
+		})
+		return
+	}
+	c.JSON(http.StatusInternalServerError, gin.H{
+		"error":   "Database error",
+		"message": err.Error(),
+	})
+}
+
+// GetReports godoc
+// @Summary Get moderation queue (Admin)
+// @Description Reports filtered by status, target and assignee, oldest open reports first, with counts per status
+// @Tags admin
+// @Produce json
+// @Security BearerAuth
+// @Param status query string false "Report status (open, in_review, resolved, dismissed)"
+// @Param target_type query string false "Target type (note, review, seller)"
+// @Param category query string false "Category"
+// @Param assigned_to query string false "Admin user ID, or 'me'"
+// @Param page query int false "Page number (default 1)"
+// @Param limit query int false "Items per page (default 50, max 200)"
+// @Success 200 {object} map[string]interface{} "Reports with total and counts per status"
+// @Failure 400 {object} map[string]string "Invalid filter"
+// @Failure 401 {object} map[string]string "Unauthorized"
+// @Failure 500 {object} map[string]string "Server error"
+// @Router /api/admin/reports [get]
+func GetReports(c *gin.Context) {
+	status := c.Query("status")
+	switch status {
+	case "", ReportOpen, ReportInReview, ReportResolved, ReportDismissed:
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid status"})
+		return
+	}
+	targetType := c.Query("target_type")
+	if _, ok := reportCategories[targetType]; targetType != "" && !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid target type"})
+		return
+	}
+	assignedTo := c.Query("assigned_to")
+	if assignedTo == "me" {
+		assignedTo = strconv.Itoa(c.GetInt("user_id"))
+	}
+
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "50"))
+	if page < 1 {
+		page = 1
+	}
+	if limit < 1 || limit > 200 {
+		limit = 50
+	}
+
+	args := []interface{}{status, targetType, c.Query("category"), assignedTo}
+	where := `($1 = '' OR r.status = $1) AND ($2 = '' OR r.target_type = $2)
+		AND ($3 = '' OR r.category = $3) AND ($4 = '' OR r.assigned_to::text = $4)`
+
+	var total int
+	if err := config.DB.QueryRow(`SELECT COUNT(*) FROM reports r WHERE `+where, args...).Scan(&total); err != nil {
+		respondReportError(c, err)
+		return
+	}
+
+	// รายงานที่ยังไม่ปิดขึ้นก่อน เรียงจากเก่าสุด (รอนานสุดได้ตรวจก่อน)
+	args = append(args, limit, (page-1)*limit)
+	rows, err := config.DB.Query(fmt.Sprintf(reportSelect+`
+		WHERE %s
+		ORDER BY r.status IN ('open', 'in_review') DESC, r.created_at, r.id
+		LIMIT $%d OFFSET $%d
+	`, where, len(args)-1, len(args)), args...)
+	if err != nil {
+		respondReportError(c, err)
+		return
+	}
+	defer rows.Close()
+
+	reports := []Report{}
+	for rows.Next() {
+		report, err := scanReport(rows)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error scanning report"})
+			return
+		}
+		reports = append(reports, *report)
+	}
+
+	counts := map[string]int{ReportOpen: 0, ReportInReview: 0, ReportResolved: 0, ReportDismissed: 0}
+	countRows, err := config.DB.Query(`SELECT status, COUNT(*) FROM reports GROUP BY status`)
+	if err != nil {
+		respondReportError(c, err)
+		return
+	}
+	defer countRows.Close()
+	for countRows.Next() {
+		var s string
+		var n int
+		if err := countRows.Scan(&s, &n); err == nil {
+			counts[s] = n
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    reports,
+		"total":   total,
+		"page":    page,
+		"limit":   limit,
+		"counts":  counts,
+	})
+}
+
+// GetReportByID godoc
+// @Summary Get a report (Admin)
+// @Description Report details with other reports on the same target
+// @Tags admin
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Report ID"
+// @Success 200 {object} map[string]interface{} "Report and related reports"
+// @Failure 400 {object} map[string]string "Invalid report ID"
+// @Failure 401 {object} map[string]string "Unauthorized"
+// @Failure 404 {object} map[string]string "Report not found"
+// @Failure 500 {object} map[string]string "Server error"
+// @Router /api/admin/reports/{id} [get]
+func GetReportByID(c *gin.Context) {
+	reportID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid report ID"})
+		return
+	}
+
+	report, err := getReport(config.DB, reportID)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Report not found"})
+		return
+	}
+	if err != nil {
+		respondReportError(c, err)
+		return
+	}
+
+	rows, err := config.DB.Query(reportSelect+`
+		WHERE r.target_type = $1 AND r.target_id = $2 AND r.id <> $3
+		ORDER BY r.created_at DESC
+	`, report.TargetType, report.TargetID, report.ID)
+	if err != nil {
+		respondReportError(c, err)
+		return
+	}
+	defer rows.Close()
+
+	related := []Report{}
+	for rows.Next() {
+		r, err := scanReport(rows)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error scanning report"})
+			return
+		}
+		related = append(related, *r)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    report,
+		"related": related,
+	})
+}
+
+// AssignReport godoc
+// @Summary Assign a report (Admin)
+// @Description Assign an open report to an admin (default: yourself) and mark it in review
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Report ID"
+// @Param request body AssignReportInput false "Assignee"
+// @Success 200 {object} map[string]interface{} "Assigned report"
+// @Failure 400 {object} map[string]string "Invalid input"
+// @Failure 401 {object} map[string]string "Unauthorized"
+// @Failure 404 {object} map[string]string "Report not found"
+// @Failure 409 {object} map[string]string "Report is already closed"
+// @Failure 500 {object} map[string]string "Server error"
+// @Router /api/admin/reports/{id}/assign [post]
+func AssignReport(c *gin.Context) {
+	reportID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid report ID"})
+		return
+	}
+
+	var input AssignReportInput
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&input); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format"})
+			return
+		}
+	}
+	assigneeID := c.GetInt("user_id")
+	if input.AssigneeID != nil {
+		assigneeID = *input.AssigneeID
+		canManage, err := permissions.UserHasAny(config.DB, assigneeID, permissions.ReportsManage)
+		if err != nil {
+			respondReportError(c, err)
+			return
+		}
+		if !canManage {
+			respondReportError(c, &NoteFieldError{Field: "assignee_id", Message: "must have the reports.manage permission"})
+			return
+		}
+	}
+
+	var status string
+	err = config.DB.QueryRow(`SELECT status FROM reports WHERE id = $1`, reportID).Scan(&status)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Report not found"})
+		return
+	}
+	if err != nil {
+		respondReportError(c, err)
+		return
+	}
+
+	result, err := config.DB.Exec(`
+		UPDATE reports SET assigned_to = $1, status = $2, updated_at = NOW()
+		WHERE id = $3 AND status IN ($4, $2)
+	`, assigneeID, ReportInReview, reportID, ReportOpen)
+	if err != nil {
+		respondReportError(c, err)
+		return
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "Report is already closed"})
+		return
+	}
+
+	report, err := getReport(config.DB, reportID)
+	if err != nil {
+		respondReportError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "Report assigned",
+		"data":    report,
+	})
+}
+
+// closeReport - ปิดรายงาน (resolve พร้อมจัดการเนื้อหาได้ หรือ dismiss) แล้วแจ้งผู้รายงาน
+func closeReport(c *gin.Context, status string) {
+	reportID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid report ID"})
+		return
+	}
+
+	var input CloseReportInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format"})
+		return
+	}
+	input.Resolution = strings.TrimSpace(input.Resolution)
+	if input.Resolution == "" {
+		respondReportError(c, &NoteFieldError{Field: "resolution", Message: "is required"})
+		return
+	}
+	if input.Action == "" {
+		input.Action = ReportActionNone
+	}
+	if status == ReportDismissed && input.Action != ReportActionNone {
+		respondReportError(c, &NoteFieldError{Field: "action", Message: "must be none when dismissing"})
+		return
+	}
+	switch input.Action {
+	case ReportActionNone, ReportActionDelistNote:
+	case ReportActionSuspendUser:
+		if input.SuspendDays == 0 {
+			input.SuspendDays = defaultSuspendDays
+		}
+		if input.SuspendDays < 1 || input.SuspendDays > maxSuspendDays {
+			respondReportError(c, &NoteFieldError{Field: "suspend_days", Message: fmt.Sprintf("must be between 1 and %d", maxSuspendDays)})
+			return
+		}
+	default:
+		respondReportError(c, &NoteFieldError{Field: "action", Message: "must be one of none, delist_note, suspend_user"})
+		return
+	}
+
+	tx, err := config.DB.Begin()
+	if err != nil {
+		respondReportError(c, err)
+		return
+	}
+	defer tx.Rollback()
+
+	var targetType, current string
+	var targetID, targetUserID int
+	err = tx.QueryRow(`
+		SELECT target_type, target_id, target_user_id, status FROM reports WHERE id = $1 FOR UPDATE
+	`, reportID).Scan(&targetType, &targetID, &targetUserID, &current)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Report not found"})
+		return
+	}
+	if err != nil {
+		respondReportError(c, err)
+		return
+	}
+	if current != ReportOpen && current != ReportInReview {
+		c.JSON(http.StatusConflict, gin.H{"error": "Report is already closed"})
+		return
+	}
+
+	// การจัดการต้องมีสิทธิ์ของการกระทำนั้นด้วย (reports.manage อย่างเดียวปิดรายงานได้แต่ delist/ระงับบัญชีไม่ได้)
+	if perm, ok := reportActionPermissions[input.Action]; ok {
+		allowed, err := permissions.UserHasAny(tx, c.GetInt("user_id"), perm)
+		if err != nil {
+			respondReportError(c, err)
+			return
+		}
+		if !allowed {
+			c.JSON(http.StatusForbidden, gin.H{
+				"error":   "Forbidden",
+				"message": fmt.Sprintf("The %s action requires the %s permission", input.Action, perm),
+			})
+			return
+		}
+	}
+
+	switch input.Action {
+	case ReportActionDelistNote:
+		if targetType != ReportTargetNote {
+			respondReportError(c, &NoteFieldError{Field: "action", Message: "delist_note only applies to note reports"})
+			return
+		}
+		// ถ้า note ถูก delist ไปแล้วก็ถือว่าสำเร็จ
+		if _, err := delistNoteAudited(tx, c, targetID, gin.H{"report_id": reportID}); err != nil {
+			respondReportError(c, err)
+			return
+		}
+	case ReportActionSuspendUser:
+		isAdmin, err := isAdminUser(targetUserID)
+		if err != nil {
+			respondReportError(c, err)
+			return
+		}
+		if isAdmin {
+			respondReportError(c, &NoteFieldError{Field: "action", Message: "admins cannot be suspended"})
+			return
+		}
+		before, err := loadAccountStatus(tx, targetUserID)
+		if err != nil {
+			respondReportError(c, err)
+			return
+		}
+		until := time.Now().AddDate(0, 0, input.SuspendDays)
+		if err := suspendUser(tx, targetUserID, until, input.Resolution); err != nil {
+			respondReportError(c, err)
+			return
+		}
+		after, err := loadAccountStatus(tx, targetUserID)
+		if err == nil {
+			err = recordAudit(tx, c, AuditUserSuspend, AuditTargetUser, targetUserID, before, after,
+				gin.H{"reason": input.Resolution, "days": input.SuspendDays, "report_id": reportID})
+		}
+		if err != nil {
+			respondReportError(c, err)
+			return
+		}
+	}
+
+	// resolve ปิดรายงานอื่นที่ยังค้างบนสิ่งเดียวกันไปด้วย ส่วน dismiss ปิดเฉพาะรายการนี้
+	query := `
+		UPDATE reports SET status = $1, resolution = $2, action = $3, resolved_by = $4,
+			resolved_at = NOW(), updated_at = NOW()
+		WHERE id = $5`
+	if status == ReportResolved {
+		query += ` OR (target_type = $6 AND target_id = $7 AND status IN ('open', 'in_review'))`
+	}
+	args := []interface{}{status, input.Resolution, input.Action, c.GetInt("user_id"), reportID}
+	if status == ReportResolved {
+		args = append(args, targetType, targetID)
+	}
+	rows, err := tx.Query(query+` RETURNING id, reporter_id`, args...)
+	if err != nil {
+		respondReportError(c, err)
+		return
+	}
+	closed := map[int]int{}
+	for rows.Next() {
+		var id, reporterID int
+		if err := rows.Scan(&id, &reporterID); err != nil {
+			rows.Close()
+			respondReportError(c, err)
+			return
+		}
+		closed[id] = reporterID
+	}
+	rows.Close()
+
+	for id, reporterID := range closed {
+		err := notify(tx, reporterID, NotifyReportClosed, "Your report has been reviewed",
+			fmt.Sprintf("Your report about a %s was %s", targetType, status),
+			map[string]interface{}{"report_id": id, "status": status, "resolution": input.Resolution})
+		if err != nil {
+			respondReportError(c, err)
+			return
+		}
+	}
+
+	report, err := getReport(tx, reportID)
+	if err != nil {
+		respondReportError(c, err)
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		respondReportError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "Report " + status,
+		"data":    report,
+		"closed":  len(closed),
+	})
+}
+
+// ResolveReport godoc
+// @Summary Resolve a report (Admin)
+// @Description Close a report as valid, optionally delisting the reported note (needs notes.edit) or suspending the content owner (needs users.ban). Other open reports on the same target are resolved too and every reporter is notified
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Report ID"
+// @Param request body CloseReportInput true "Resolution and action"
+// @Success 200 {object} map[string]interface{} "Resolved report"
+// @Failure 400 {object} map[string]string "Invalid input"
+// @Failure 401 {object} map[string]string "Unauthorized"
+// @Failure 403 {object} map[string]string "Missing permission for the action"
+// @Failure 404 {object} map[string]string "Report not found"
+// @Failure 409 {object} map[string]string "Report is already closed"
+// @Failure 500 {object} map[string]string "Server error"
+// @Router /api/admin/reports/{id}/resolve [post]
+func ResolveReport(c *gin.Context) {
+	closeReport(c, ReportResolved)
+}
+
+// DismissReport godoc
+// @Summary Dismiss a report (Admin)
+// @Description Close a report without action (nothing wrong found) and notify the reporter
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Report ID"
+// @Param request body CloseReportInput true "Resolution"
+// @Success 200 {object} map[string]interface{} "Dismissed report"
+// @Failure 400 {object} map[string]string "Invalid input"
+// @Failure 401 {object} map[string]string "Unauthorized"
+// @Failure 404 {object} map[string]string "Report not found"
+// @Failure 409 {object} map[string]string "Report is already closed"
+// @Failure 500 {object} map[string]string "Server error"
+// @Router /api/admin/reports/{id}/dismiss [post]
+func DismissReport(c *gin.Context) {
+	closeReport(c, ReportDismissed)
+}
@@ -0,0 +1,125 @@
+package handlers
+
+import (
+	"back-end/config"
+	"back-end/jobs"
+	"context"
+	"database/sql"
+	"log"
+	"os"
+	"strconv"
+)
+
+// defaultNotePurgeDays - จำนวนวันหลัง soft delete ที่ note ซึ่งไม่มีผู้ซื้อจะถูกลบถาวร
+const defaultNotePurgeDays = 90
+
+// notePurgeDays - ตั้งค่าได้ผ่าน NOTE_PURGE_AFTER_DAYS (0 = ไม่ลบถาวรเลย)
+func notePurgeDays() int {
+	if n, err := strconv.Atoi(os.Getenv("NOTE_PURGE_AFTER_DAYS")); err == nil && n >= 0 {
+		return n
+	}
+	return defaultNotePurgeDays
+}
+
+// noteFilePaths - path ของไฟล์ทั้งหมดของ note (PDF, รูป, รูปทุกขนาด, รูปตัวอย่างหน้า PDF)
+func noteFilePaths(tx *sql.Tx, noteID int) ([]string, error) {
+	rows, err := tx.Query(`
+		SELECT pdf_file FROM notes_for_sale WHERE id = $1 AND pdf_file IS NOT NULL
+		UNION ALL
+		SELECT path FROM note_images WHERE note_id = $1
+		UNION ALL
+		SELECT v.path FROM image_variants v JOIN note_images ni ON v.note_image_id = ni.id WHERE ni.note_id = $1
+		UNION ALL
+		SELECT path FROM note_previews WHERE note_id = $1
+	`, noteID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	paths := []string{}
+	for rows.Next() {
+		var path string
+		if err := rows.Scan(&path); err != nil {
+			return nil, err
+		}
+		paths = append(paths, path)
+	}
+	return paths, rows.Err()
+}
+
+// purgeDeletedNotesJob - ลบถาวร note ที่ถูก soft delete นานเกินกำหนดและไม่มีผู้ซื้อ
+// note ที่มีผู้ซื้อแล้วจะถูกเก็บไว้ตลอด เพื่อให้ผู้ซื้อยังดาวน์โหลดได้
+func purgeDeletedNotesJob(ctx context.Context, _ struct{}) error {
+	days := notePurgeDays()
+	if days == 0 {
+		return nil
+	}
+
+	rows, err := config.DB.QueryContext(ctx, `
+		SELECT n.id FROM notes_for_sale n
+		WHERE n.deleted_at < NOW() - $1 * INTERVAL '1 day'
+		AND NOT EXISTS (SELECT 1 FROM buyed_note bn WHERE bn.note_id = n.id)
+	`, days)
+	if err != nil {
+		return err
+	}
+	noteIDs := []int{}
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err == nil {
+			noteIDs = append(noteIDs, id)
+		}
+	}
+	rows.Close()
+
+	purged := 0
+	for _, noteID := range noteIDs {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if err := purgeNote(noteID); err != nil {
+			log.Printf("⚠️  Failed to purge note %d: %v", noteID, err)
+			continue
+		}
+		purged++
+	}
+	if purged > 0 {
+		log.Printf("🧹 Purged %d deleted notes", purged)
+	}
+	return nil
+}
+
+// purgeNote - ลบ note ออกจาก database และสั่งลบไฟล์ผ่าน job (ใน transaction เดียวกัน)
+func purgeNote(noteID int) error {
+	tx, err := config.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	paths, err := noteFilePaths(tx, noteID)
+	if err != nil {
+		return err
+	}
+
+	// ตรวจเงื่อนไขซ้ำตอนลบ เผื่อมีคน restore หรือซื้อไประหว่างนี้
+	result, err := tx.Exec(`
+		DELETE FROM notes_for_sale n
+		WHERE n.id = $1 AND n.deleted_at IS NOT NULL
+		AND NOT EXISTS (SELECT 1 FROM buyed_note bn WHERE bn.note_id = n.id)
+	`, noteID)
+	if err != nil {
+		return err
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return nil
+	}
+
+	if len(paths) > 0 {
+		if _, err := jobs.EnqueueTx(tx, JobDeleteFiles, deleteFilesPayload{Paths: paths}, jobs.EnqueueOptions{}); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
@@ -0,0 +1,231 @@
+package handlers
+
+import (
+	"back-end/config"
+	"back-end/jobs"
+	"back-end/storage"
+	"back-end/utils"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"image/jpeg"
+	"io"
+	"log"
+	"mime/multipart"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ขีดจำกัดของไฟล์ PDF ที่อัปโหลด
+const (
+	maxPDFSize          = 50 * 1024 * 1024 // 50MB
+	maxPDFPages         = 500
+	defaultPreviewPages = 3
+	previewMaxWidth     = 800
+	previewJPEGQuality  = 80
+)
+
+// ชนิดของรูปตัวอย่างของ note (preview_kind)
+const (
+	PreviewKindPages = "pages" // รูปจากหน้าจริงของ PDF
+	PreviewKindCover = "cover" // รูปปกที่สร้างขึ้น (PDF ไม่มีรูปฝังในหน้าแรกๆ)
+)
+
+// PDFValidationError - ไฟล์ PDF ไม่ผ่านการตรวจสอบ (ตอบกลับเป็น 400)
+type PDFValidationError struct {
+	Message string
+}
+
+func (e *PDFValidationError) Error() string {
+	return e.Message
+}
+
+// readAndValidatePDF - อ่านไฟล์ที่อัปโหลด ตรวจ magic bytes, parse โครงสร้าง, จำนวนหน้า และการเข้ารหัส
+func readAndValidatePDF(fileHeader *multipart.FileHeader) ([]byte, *utils.PDFDocument, error) {
+	if strings.ToLower(filepath.Ext(fileHeader.Filename)) != ".pdf" {
+		return nil, nil, &PDFValidationError{"Only PDF files are allowed"}
+	}
+	if fileHeader.Size > maxPDFSize {
+		return nil, nil, &PDFValidationError{fmt.Sprintf("PDF file is too large. Maximum %dMB allowed", maxPDFSize/(1024*1024))}
+	}
+
+	src, err := fileHeader.Open()
+	if err != nil {
+		return nil, nil, err
+	}
+	defer src.Close()
+
+	data, err := io.ReadAll(io.LimitReader(src, maxPDFSize+1))
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(data) > maxPDFSize {
+		return nil, nil, &PDFValidationError{fmt.Sprintf("PDF file is too large. Maximum %dMB allowed", maxPDFSize/(1024*1024))}
+	}
+
+	doc, err := utils.ParsePDF(data)
+	switch {
+	case errors.Is(err, utils.ErrNotPDF):
+		return nil, nil, &PDFValidationError{"File content is not a valid PDF"}
+	case errors.Is(err, utils.ErrPDFEncrypted):
+		return nil, nil, &PDFValidationError{"Encrypted or password-protected PDF files are not allowed"}
+	case err != nil:
+		return nil, nil, &PDFValidationError{"PDF file is corrupted or malformed"}
+	}
+
+	if doc.PageCount() > maxPDFPages {
+		return nil, nil, &PDFValidationError{fmt.Sprintf("PDF file has too many pages. Maximum %d pages allowed", maxPDFPages)}
+	}
+
+	return data, doc, nil
+}
+
+// previewPageLimit - จำนวนหน้าที่จะสร้างรูปตัวอย่าง (ตั้งค่าได้ผ่าน PDF_PREVIEW_PAGES)
+func previewPageLimit() int {
+	if n, err := strconv.Atoi(os.Getenv("PDF_PREVIEW_PAGES")); err == nil && n >= 0 {
+		return n
+	}
+	return defaultPreviewPages
+}
+
+// saveNotePreviews - บันทึกรูปตัวอย่างหน้า PDF เป็น JPEG และ insert ลง note_previews
+func saveNotePreviews(tx *sql.Tx, noteID int, timestamp int64, previews []utils.PDFPagePreview) ([]string, error) {
+	previewDir := filepath.Join("./uploads", "previews")
+	if err := os.MkdirAll(previewDir, 0755); err != nil {
+		return nil, err
+	}
+
+	paths := []string{}
+	for _, preview := range previews {
+		filename := fmt.Sprintf("%d_note_%d_page_%d.jpg", timestamp, noteID, preview.Page)
+		path := filepath.Join(previewDir, filename)
+
+		dst, err := os.Create(path)
+		if err != nil {
+			return nil, err
+		}
+		thumb := utils.ResizeToFit(preview.Image, previewMaxWidth, 0)
+		err = jpeg.Encode(dst, thumb, &jpeg.Options{Quality: previewJPEGQuality})
+		dst.Close()
+		if err != nil {
+			return nil, err
+		}
+
+		_, err = tx.Exec(`
+			INSERT INTO note_previews (note_id, page_number, path, is_fallback, created_at)
+			VALUES ($1, $2, $3, $4, NOW())
+		`, noteID, preview.Page, path, preview.Fallback)
+		if err != nil {
+			return nil, err
+		}
+		paths = append(paths, path)
+	}
+	return paths, nil
+}
+
+// notePreviewsPayload - payload ของ job notes.previews
+type notePreviewsPayload struct {
+	NoteID int `json:"note_id"`
+}
+
+// enqueueNotePreviews - สั่งสร้างรูปตัวอย่างของ note แบบ background (เรียกใน transaction ที่ insert note)
+func enqueueNotePreviews(tx *sql.Tx, noteID int) error {
+	_, err := jobs.EnqueueTx(tx, JobNotePreviews, notePreviewsPayload{NoteID: noteID}, jobs.EnqueueOptions{})
+	return err
+}
+
+// notePreviewsJob - สร้างรูปตัวอย่างหน้า PDF ของ note ลง note_previews
+// ถ้า note ยังไม่มีรูป (seller ไม่ได้อัปโหลดเอง) ใช้รูปตัวอย่างเป็นรูปของ note (รูปแรกเป็นหน้าปก)
+func notePreviewsJob(ctx context.Context, payload notePreviewsPayload) error {
+	var pdfPath, title string
+	var done bool
+	err := config.DB.QueryRowContext(ctx, `
+		SELECT pdf_file, book_title, EXISTS(SELECT 1 FROM note_previews p WHERE p.note_id = n.id)
+		FROM notes_for_sale n WHERE n.id = $1 AND n.deleted_at IS NULL
+	`, payload.NoteID).Scan(&pdfPath, &title, &done)
+	if err == sql.ErrNoRows || done {
+		// note ถูกลบไปแล้ว หรือมีรูปตัวอย่างแล้ว
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	localPath, ok := storage.LocalPath(pdfPath)
+	if !ok {
+		log.Printf("⚠️  Skipping previews of note %d: PDF outside uploads (%q)", payload.NoteID, pdfPath)
+		return nil
+	}
+	data, err := os.ReadFile(localPath)
+	if err != nil {
+		return fmt.Errorf("read PDF of note %d: %w", payload.NoteID, err)
+	}
+	doc, err := utils.ParsePDF(data)
+	if err != nil {
+		return fmt.Errorf("parse PDF of note %d: %w", payload.NoteID, err)
+	}
+	previews := doc.Previews(previewPageLimit(), title)
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+
+	tx, err := config.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	paths, err := saveNotePreviews(tx, payload.NoteID, time.Now().Unix(), previews)
+	if err != nil {
+		return err
+	}
+
+	var hasImages bool
+	if err := tx.QueryRow(`SELECT EXISTS(SELECT 1 FROM note_images WHERE note_id = $1)`, payload.NoteID).Scan(&hasImages); err != nil {
+		return err
+	}
+	if !hasImages {
+		for order, path := range paths {
+			_, err := tx.Exec(`
+				INSERT INTO note_images (note_id, image_order, path, created_at)
+				VALUES ($1, $2, $3, NOW())
+			`, payload.NoteID, order, path)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return tx.Commit()
+}
+
+// getNotePreviews - ดึง path ของรูปตัวอย่างหน้า PDF เรียงตามเลขหน้า พร้อมชนิด (ว่างถ้ายังไม่มีรูปตัวอย่าง)
+func getNotePreviews(noteID int) ([]string, string) {
+	previews := []string{}
+	rows, err := config.DB.Query(`
+		SELECT path, is_fallback FROM note_previews
+		WHERE note_id = $1
+		ORDER BY page_number ASC
+	`, noteID)
+	if err != nil {
+		return previews, ""
+	}
+	defer rows.Close()
+
+	kind := ""
+	for rows.Next() {
+		var path string
+		var fallback bool
+		if err := rows.Scan(&path, &fallback); err == nil {
+			previews = append(previews, path)
+			kind = PreviewKindPages
+			if fallback {
+				kind = PreviewKindCover
+			}
+		}
+	}
+	return previews, kind
+}
@@ -0,0 +1,386 @@
+package handlers
+
+import (
+	"back-end/config"
+	"back-end/jobs"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/gin-gonic/gin"
+)
+
+// สถานะของ note ที่ระบบรองรับ
+const (
+	NoteStatusPending   = "pending"
+	NoteStatusAvailable = "available"
+	NoteStatusRejected  = "rejected"
+	NoteStatusDelisted  = "delisted" // ถูกลบแบบ soft delete (ผู้ซื้อเดิมยังดาวน์โหลดได้)
+)
+
+// ขีดจำกัดของแต่ละ field (ตาม schema ของ notes_for_sale)
+const (
+	maxNoteTitleLength       = 255
+	maxNoteExamTermLength    = 20
+	maxNoteDescriptionLength = 5000
+	maxNotePrice             = 99999999.99 // DECIMAL(10,2)
+)
+
+// NotePatch - ข้อมูลที่อนุญาตให้แก้ไขใน notes_for_sale (field ที่เป็น nil จะไม่ถูกแก้ไข)
+type NotePatch struct {
+	Title       *string  `json:"title"`
+	Description *string  `json:"description"`
+	Price       *float64 `json:"price"`
+	CourseID    *int     `json:"course_id"`
+	ExamTerm    *string  `json:"exam_term"`
+	Status      *string  `json:"status"`
+}
+
+// UpdatedNote - ข้อมูล note หลังอัปเดต
+type UpdatedNote struct {
+	ID          int     `json:"id"`
+	SellerID    int     `json:"seller_id"`
+	Title       string  `json:"title"`
+	Description string  `json:"description"`
+	Price       float64 `json:"price"`
+	CourseID    *int    `json:"course_id"`
+	ExamTerm    string  `json:"exam_term"`
+	Status      string  `json:"status"`
+	CreatedAt   string  `json:"created_at"`
+}
+
+// NoteFieldError - error ของการ validate field ใดๆ
+type NoteFieldError struct {
+	Field   string
+	Message string
+}
+
+func (e *NoteFieldError) Error() string {
+	return e.Field + ": " + e.Message
+}
+
+// noteEditor - ผู้แก้ไข note (admin แก้ได้ทุก field, seller แก้ได้เฉพาะ note ของตัวเองและห้ามเปลี่ยน status)
+type noteEditor struct {
+	isAdmin  bool
+	sellerID int
+	// onUpdate - ถูกเรียกใน transaction หลังอัปเดต (เช่น บันทึก audit log) nil = ไม่ต้องทำอะไร
+	onUpdate func(tx *sql.Tx, before, after *UpdatedNote) error
+}
+
+// noteColumn - mapping ระหว่าง field ใน NotePatch กับ column ใน database (เรียงลำดับคงที่)
+type noteColumn struct {
+	field  string
+	column string
+	value  func(p *NotePatch) (interface{}, bool)
+}
+
+var noteColumns = []noteColumn{
+	{"title", "book_title", func(p *NotePatch) (interface{}, bool) { return derefString(p.Title) }},
+	{"description", "description", func(p *NotePatch) (interface{}, bool) { return derefString(p.Description) }},
+	{"price", "price", func(p *NotePatch) (interface{}, bool) {
+		if p.Price == nil {
+			return nil, false
+		}
+		return *p.Price, true
+	}},
+	{"course_id", "course_id", func(p *NotePatch) (interface{}, bool) {
+		if p.CourseID == nil {
+			return nil, false
+		}
+		return *p.CourseID, true
+	}},
+	{"exam_term", "exam_term", func(p *NotePatch) (interface{}, bool) { return derefString(p.ExamTerm) }},
+	{"status", "status", func(p *NotePatch) (interface{}, bool) { return derefString(p.Status) }},
+}
+
+func derefString(s *string) (interface{}, bool) {
+	if s == nil {
+		return nil, false
+	}
+	return *s, true
+}
+
+// isValidNoteStatus - ตรวจสอบว่า status อยู่ในรายการที่ตั้งค่าผ่านการแก้ไขได้
+// (delisted ตั้งได้ผ่าน DeleteNote เท่านั้น)
+func isValidNoteStatus(status string) bool {
+	switch status {
+	case NoteStatusPending, NoteStatusAvailable, NoteStatusRejected:
+		return true
+	}
+	return false
+}
+
+// normalize - ตัดช่องว่างหัวท้ายของ field ที่เป็นข้อความ
+func (p *NotePatch) normalize() {
+	for _, s := range []*string{p.Title, p.Description, p.ExamTerm, p.Status} {
+		if s != nil {
+			*s = strings.TrimSpace(*s)
+		}
+	}
+}
+
+// isEmpty - ไม่มี field ใดถูกส่งมาเลย
+func (p *NotePatch) isEmpty() bool {
+	for _, col := range noteColumns {
+		if _, ok := col.value(p); ok {
+			return false
+		}
+	}
+	return true
+}
+
+// validate - ตรวจสอบทุก field ที่ถูกส่งมา (ยกเว้นการมีอยู่ของ course ซึ่งตรวจใน transaction)
+func (p *NotePatch) validate(editor noteEditor) error {
+	if p.Title != nil {
+		if *p.Title == "" {
+			return &NoteFieldError{"title", "must not be empty"}
+		}
+		if utf8.RuneCountInString(*p.Title) > maxNoteTitleLength {
+			return &NoteFieldError{"title", fmt.Sprintf("must be at most %d characters", maxNoteTitleLength)}
+		}
+	}
+	if p.Description != nil && utf8.RuneCountInString(*p.Description) > maxNoteDescriptionLength {
+		return &NoteFieldError{"description", fmt.Sprintf("must be at most %d characters", maxNoteDescriptionLength)}
+	}
+	if p.Price != nil {
+		if *p.Price < 0 {
+			return &NoteFieldError{"price", "must not be negative"}
+		}
+		if *p.Price > maxNotePrice {
+			return &NoteFieldError{"price", "is too large"}
+		}
+	}
+	if p.CourseID != nil && *p.CourseID <= 0 {
+		return &NoteFieldError{"course_id", "must be a positive integer"}
+	}
+	if p.ExamTerm != nil {
+		if *p.ExamTerm == "" {
+			return &NoteFieldError{"exam_term", "must not be empty"}
+		}
+		if utf8.RuneCountInString(*p.ExamTerm) > maxNoteExamTermLength {
+			return &NoteFieldError{"exam_term", fmt.Sprintf("must be at most %d characters", maxNoteExamTermLength)}
+		}
+	}
+	if p.Status != nil {
+		if !editor.isAdmin {
+			return &NoteFieldError{"status", "cannot be changed by seller"}
+		}
+		if !isValidNoteStatus(*p.Status) {
+			return &NoteFieldError{"status", "is not a valid note status"}
+		}
+	}
+	return nil
+}
+
+// buildNoteUpdate - สร้าง UPDATE query จาก patch ตามลำดับของ noteColumns
+func buildNoteUpdate(noteID int, p *NotePatch, editor noteEditor) (string, []interface{}) {
+	sets := []string{}
+	args := []interface{}{}
+
+	for _, col := range noteColumns {
+		value, ok := col.value(p)
+		if !ok {
+			continue
+		}
+		args = append(args, value)
+		sets = append(sets, fmt.Sprintf("%s = $%d", col.column, len(args)))
+	}
+
+	args = append(args, noteID)
+	query := fmt.Sprintf("UPDATE notes_for_sale SET %s WHERE id = $%d AND deleted_at IS NULL", strings.Join(sets, ", "), len(args))
+
+	if !editor.isAdmin {
+		args = append(args, editor.sellerID)
+		query += fmt.Sprintf(" AND seller_id = $%d", len(args))
+	}
+
+	query += "\n\t\tRETURNING " + updatedNoteColumns
+
+	return query, args
+}
+
+// updatedNoteColumns - column ที่ scanUpdatedNote อ่าน
+const updatedNoteColumns = `id, seller_id, book_title, COALESCE(description, ''), price, course_id,
+		COALESCE(exam_term, ''), COALESCE(status, ''), TO_CHAR(created_at, 'YYYY-MM-DD HH24:MI')`
+
+func scanUpdatedNote(row *sql.Row) (*UpdatedNote, error) {
+	var note UpdatedNote
+	var courseID sql.NullInt64
+	err := row.Scan(
+		&note.ID, &note.SellerID, &note.Title, &note.Description, &note.Price, &courseID,
+		&note.ExamTerm, &note.Status, &note.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	if courseID.Valid {
+		id := int(courseID.Int64)
+		note.CourseID = &id
+	}
+	return &note, nil
+}
+
+// applyNotePatch - validate และอัปเดต note ใน transaction แล้วคืนค่า note ที่อัปเดตแล้ว
+// คืนค่า sql.ErrNoRows ถ้าไม่พบ note, note ถูกลบไปแล้ว หรือ seller ไม่ได้เป็นเจ้าของ
+func applyNotePatch(noteID int, p *NotePatch, editor noteEditor) (*UpdatedNote, error) {
+	p.normalize()
+	if err := p.validate(editor); err != nil {
+		return nil, err
+	}
+
+	tx, err := config.DB.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	if p.CourseID != nil {
+		var courseExists bool
+		err := tx.QueryRow(`SELECT EXISTS(SELECT 1 FROM courses WHERE id = $1)`, *p.CourseID).Scan(&courseExists)
+		if err != nil {
+			return nil, err
+		}
+		if !courseExists {
+			return nil, &NoteFieldError{"course_id", "course not found"}
+		}
+	}
+
+	// ค่าเดิม สำหรับแจ้งเตือนคนที่บันทึก note ไว้เมื่อราคาลดลง และสำหรับ onUpdate
+	var before *UpdatedNote
+	if p.Price != nil || editor.onUpdate != nil {
+		before, err = scanUpdatedNote(tx.QueryRow(`
+			SELECT `+updatedNoteColumns+` FROM notes_for_sale WHERE id = $1 AND deleted_at IS NULL FOR UPDATE
+		`, noteID))
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	query, args := buildNoteUpdate(noteID, p, editor)
+
+	note, err := scanUpdatedNote(tx.QueryRow(query, args...))
+	if err != nil {
+		return nil, err
+	}
+
+	if p.Price != nil && note.Price < before.Price && note.Status == NoteStatusAvailable {
+		payload := priceDropPayload{NoteID: note.ID, OldPrice: before.Price, NewPrice: note.Price}
+		if _, err := jobs.EnqueueTx(tx, JobWishlistPriceDrop, payload, jobs.EnqueueOptions{}); err != nil {
+			return nil, err
+		}
+	}
+
+	if editor.onUpdate != nil {
+		if err := editor.onUpdate(tx, before, note); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return note, nil
+}
+
+// bindNotePatch - อ่าน JSON body โดยไม่อนุญาต field ที่ไม่อยู่ใน NotePatch
+func bindNotePatch(c *gin.Context) (*NotePatch, error) {
+	var patch NotePatch
+	decoder := json.NewDecoder(c.Request.Body)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&patch); err != nil {
+		if err == io.EOF {
+			return nil, fmt.Errorf("request body is required")
+		}
+		return nil, err
+	}
+	return &patch, nil
+}
+
+// handleNotePatch - logic ร่วมของ admin UpdateNote และ seller UpdateMyNote
+func handleNotePatch(c *gin.Context, editor noteEditor) {
+	noteID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid note ID",
+		})
+		return
+	}
+
+	patch, err := bindNotePatch(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid input",
+			"message": err.Error(),
+		})
+		return
+	}
+
+	if patch.isEmpty() {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "No fields to update",
+		})
+		return
+	}
+
+	note, err := applyNotePatch(noteID, patch, editor)
+	if err != nil {
+		if fieldErr, ok := err.(*NoteFieldError); ok {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error":   "Invalid input",
+				"field":   fieldErr.Field,
+				"message": fieldErr.Message,
+			})
+			return
+		}
+		if err == sql.ErrNoRows {
+			c.JSON(http.StatusNotFound, gin.H{
+				"error": "Note not found",
+			})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Database error",
+			"message": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "Note updated successfully",
+		"data":    note,
+	})
+}
+
+// UpdateMyNote godoc
+// @Summary แก้ไขสรุปวิชาของตัวเอง (Seller)
+// @Description Seller แก้ไขชื่อ คำอธิบาย ราคา วิชา และเทอมสอบของสรุปที่ตัวเองขายได้ (แก้ไข status ไม่ได้)
+// @Tags notes
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Note ID"
+// @Param body body NotePatch true "ข้อมูลที่ต้องการอัปเดต"
+// @Success 200 {object} map[string]interface{} "อัปเดตสำเร็จ พร้อมข้อมูล note ล่าสุด"
+// @Failure 400 {object} map[string]interface{} "ข้อมูลไม่ถูกต้อง"
+// @Failure 401 {object} map[string]interface{} "Unauthorized"
+// @Failure 404 {object} map[string]interface{} "ไม่พบสรุปวิชา หรือไม่ใช่เจ้าของ"
+// @Failure 500 {object} map[string]interface{} "เกิดข้อผิดพลาด"
+// @Router /api/notes/{id} [put]
+func UpdateMyNote(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": "Unauthorized",
+		})
+		return
+	}
+
+	handleNotePatch(c, noteEditor{sellerID: userID.(int)})
+}
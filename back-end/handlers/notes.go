@@ -2,8 +2,9 @@ package handlers
 
 import (
 	"back-end/config"
+	"back-end/events"
+	"back-end/utils"
 	"fmt"
-	"io"
 	"net/http"
 	"os"
 	"path/filepath"
@@ -14,7 +15,7 @@ import (
 
 // CreateNote godoc
 // @Summary Create a new note for sale
-// @Description Create a new note/book for sale with images and PDF file. The note will be set to 'pending' status and requires admin approval.
+// @Description Create a new note/book for sale with images and PDF file. The PDF content is validated (max 50MB, not encrypted). Preview images of the first pages are generated afterwards by a background job (previews_pending) from the images embedded in them. Text and vector graphics are not rendered, so when no page has an embedded image a cover with the title and page count is generated instead (preview_kind = cover instead of pages). The previews become the note images when none are uploaded. The note will be set to 'pending' status and requires admin approval. Only users whose seller application has been approved can upload.
 // @Tags notes
 // @Accept multipart/form-data
 // @Produce json
@@ -25,10 +26,11 @@ import (
 // @Param course_id formData int true "Course ID"
 // @Param exam_term formData string true "Exam term (midterm, final, etc.)"
 // @Param pdf formData file true "PDF file"
-// @Param images formData file false "Image files (multiple allowed)"
+// @Param images formData file false "Image files (jpg, png, gif, webp; max 10MB each, multiple allowed). Optional unless PDF previews are disabled (PDF_PREVIEW_PAGES=0)"
 // @Success 201 {object} map[string]interface{} "Note created successfully"
 // @Failure 400 {object} map[string]string "Bad request"
 // @Failure 401 {object} map[string]string "Unauthorized"
+// @Failure 403 {object} map[string]string "Seller application required"
 // @Failure 500 {object} map[string]string "Server error"
 // @Router /api/notes [post]
 func CreateNote(c *gin.Context) {
@@ -41,6 +43,23 @@ func CreateNote(c *gin.Context) {
 		return
 	}
 
+	// รับ note เฉพาะผู้ขายที่ใบสมัครผ่านการอนุมัติแล้ว (role seller อย่างเดียวไม่พอ เพราะ admin มอบ role ได้โดยไม่ผ่านการยืนยันตัวตน)
+	isSeller, err := isVerifiedSeller(config.DB, c.GetInt("user_id"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Failed to check seller status",
+			"message": err.Error(),
+		})
+		return
+	}
+	if !isSeller {
+		c.JSON(http.StatusForbidden, gin.H{
+			"error":   "Seller application required",
+			"message": "Submit a seller application at /api/seller-applications and wait for approval before uploading notes",
+		})
+		return
+	}
+
 	// รับข้อมูลจาก form
 	bookTitle := c.PostForm("title")
 	description := c.PostForm("description")
@@ -84,10 +103,18 @@ func CreateNote(c *gin.Context) {
 		return
 	}
 
-	// ตรวจสอบว่าเป็นไฟล์ PDF
-	if filepath.Ext(pdfFile.Filename) != ".pdf" {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error": "Only PDF files are allowed",
+	// ตรวจสอบเนื้อหาไฟล์ PDF (magic bytes, โครงสร้าง, จำนวนหน้า, การเข้ารหัส)
+	pdfData, pdfDoc, err := readAndValidatePDF(pdfFile)
+	if err != nil {
+		if validationErr, ok := err.(*PDFValidationError); ok {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": validationErr.Message,
+			})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Failed to read PDF file",
+			"message": err.Error(),
 		})
 		return
 	}
@@ -101,28 +128,49 @@ func CreateNote(c *gin.Context) {
 		return
 	}
 
+	// รูปตัวอย่างจาก PDF (หรือรูปปก) จะถูกใช้เป็นรูปของ note ถ้าไม่ได้อัปโหลดรูปเอง จึงต้องมีรูปเฉพาะตอนปิดการสร้างรูปตัวอย่าง
 	images := form.File["images"]
-	if len(images) == 0 {
+	previewPages := previewPageLimit()
+	if len(images) == 0 && previewPages == 0 {
 		c.JSON(http.StatusBadRequest, gin.H{
 			"error": "At least one image is required",
 		})
 		return
 	}
 
+	// ตรวจสอบและสร้างรูปขนาด thumb/card/full ของทุกรูปก่อนบันทึกไฟล์ใดๆ
+	processedImages := make([][]utils.ImageVariant, 0, len(images))
+	for _, imageFile := range images {
+		variants, err := processUploadedImage(imageFile, maxImageUploadSize, utils.NoteImageVariants)
+		if err != nil {
+			if validationErr, ok := err.(*ImageValidationError); ok {
+				c.JSON(http.StatusBadRequest, gin.H{
+					"error": fmt.Sprintf("%s: %s", imageFile.Filename, validationErr.Message),
+				})
+				return
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error":   "Failed to process image file",
+				"message": err.Error(),
+			})
+			return
+		}
+		processedImages = append(processedImages, variants)
+	}
+
 	// สร้างโฟลเดอร์สำหรับเก็บไฟล์ (ถ้ายังไม่มี)
 	uploadsDir := "./uploads"
 	pdfDir := filepath.Join(uploadsDir, "pdfs")
 	imageDir := filepath.Join(uploadsDir, "images")
 
 	os.MkdirAll(pdfDir, 0755)
-	os.MkdirAll(imageDir, 0755)
 
 	// บันทึกไฟล์ PDF
 	timestamp := time.Now().Unix()
 	pdfFilename := fmt.Sprintf("%d_%s", timestamp, filepath.Base(pdfFile.Filename))
 	pdfPath := filepath.Join(pdfDir, pdfFilename)
 
-	if err := c.SaveUploadedFile(pdfFile, pdfPath); err != nil {
+	if err := os.WriteFile(pdfPath, pdfData, 0644); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"error":   "Failed to save PDF file",
 			"message": err.Error(),
@@ -155,8 +203,8 @@ func CreateNote(c *gin.Context) {
 	var noteID int
 	insertNoteQuery := `
 		INSERT INTO notes_for_sale 
-		(course_id, seller_id, book_title, price, exam_term, description, pdf_file, status, created_at)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, 'pending', NOW())
+		(course_id, seller_id, book_title, price, exam_term, description, pdf_file, page_count, status, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, 'pending', NOW())
 		RETURNING id
 	`
 	err = tx.QueryRow(
@@ -168,6 +216,7 @@ func CreateNote(c *gin.Context) {
 		examTerm,
 		description,
 		pdfPath,
+		pdfDoc.PageCount(),
 	).Scan(&noteID)
 
 	if err != nil {
@@ -178,34 +227,21 @@ func CreateNote(c *gin.Context) {
 		return
 	}
 
-	// บันทึกรูปภาพและ insert ลง note_images
-	for order, imageFile := range images {
-		// สร้างชื่อไฟล์ใหม่
-		imageFilename := fmt.Sprintf("%d_note_%d_img_%d%s", timestamp, noteID, order, filepath.Ext(imageFile.Filename))
-		imagePath := filepath.Join(imageDir, imageFilename)
-
-		// บันทึกไฟล์รูปภาพ
-		src, err := imageFile.Open()
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{
-				"error":   "Failed to open image file",
-				"message": err.Error(),
-			})
-			return
-		}
-		defer src.Close()
+	// เทียบ fingerprint ของไฟล์กับ note เดิม (ที่น่าจะซ้ำจะแสดงใน GetPendingNotes ให้ admin ตัดสิน)
+	duplicates, err := fingerprintNote(tx, noteID, pdfData, pdfDoc)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Failed to check for duplicate notes",
+			"message": err.Error(),
+		})
+		return
+	}
 
-		dst, err := os.Create(imagePath)
+	// บันทึกรูปภาพ (ทุกขนาด) และ insert ลง note_images / image_variants
+	for order, variants := range processedImages {
+		baseName := fmt.Sprintf("%d_note_%d_img_%d", timestamp, noteID, order)
+		saved, err := writeImageVariants(imageDir, baseName, variants)
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{
-				"error":   "Failed to create image file",
-				"message": err.Error(),
-			})
-			return
-		}
-		defer dst.Close()
-
-		if _, err = io.Copy(dst, src); err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{
 				"error":   "Failed to save image file",
 				"message": err.Error(),
@@ -213,13 +249,19 @@ func CreateNote(c *gin.Context) {
 			return
 		}
 
-		// Insert ข้อมูลรูปภาพลง database
+		// Insert ข้อมูลรูปภาพลง database (path หลักเป็นรูปขนาด full)
+		var noteImageID int
 		insertImageQuery := `
 			INSERT INTO note_images (note_id, image_order, path, created_at)
 			VALUES ($1, $2, $3, NOW())
+			RETURNING id
 		`
-		_, err = tx.Exec(insertImageQuery, noteID, order, imagePath)
+		err = tx.QueryRow(insertImageQuery, noteID, order, variantPath(saved, "full")).Scan(&noteImageID)
+		if err == nil {
+			err = insertImageVariants(tx, noteImageOwner(noteImageID), saved)
+		}
 		if err != nil {
+			removeVariantFiles(saved)
 			c.JSON(http.StatusInternalServerError, gin.H{
 				"error":   "Failed to save image data",
 				"message": err.Error(),
@@ -228,23 +270,15 @@ func CreateNote(c *gin.Context) {
 		}
 	}
 
-	// เพิ่ม role "seller" ให้ user อัตโนมัติ (ถ้ายังไม่มี)
-	addSellerRoleQuery := `
-		INSERT INTO user_roles (user_id, role_id)
-		SELECT $1, r.id FROM roles r
-		WHERE r.name = 'seller'
-		AND NOT EXISTS (
-			SELECT 1 FROM user_roles ur 
-			WHERE ur.user_id = $1 AND ur.role_id = r.id
-		)
-	`
-	_, err = tx.Exec(addSellerRoleQuery, userID)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error":   "Failed to assign seller role",
-			"message": err.Error(),
-		})
-		return
+	// สร้างรูปตัวอย่างหน้า PDF แบบ background (job จะใช้รูปตัวอย่างเป็นรูปของ note ถ้าไม่ได้อัปโหลดรูปเอง)
+	if previewPages > 0 {
+		if err := enqueueNotePreviews(tx, noteID); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error":   "Failed to schedule PDF previews",
+				"message": err.Error(),
+			})
+			return
+		}
 	}
 
 	// Commit transaction
@@ -256,10 +290,20 @@ func CreateNote(c *gin.Context) {
 		return
 	}
 
+	// แจ้ง admin ว่ามี note ใหม่รออนุมัติ
+	events.Publish(events.AdminTopic, events.NotePending, gin.H{
+		"note_id":             noteID,
+		"seller_id":           userID,
+		"book_title":          bookTitle,
+		"possible_duplicates": len(duplicates),
+	})
+
 	c.JSON(http.StatusCreated, gin.H{
-		"message":  "Note created successfully",
-		"note_id":  noteID,
-		"images":   len(images),
-		"pdf_path": pdfPath,
+		"message":          "Note created successfully",
+		"note_id":          noteID,
+		"images":           len(images),
+		"previews_pending": previewPages > 0,
+		"page_count":       pdfDoc.PageCount(),
+		"pdf_path":         pdfPath,
 	})
 }
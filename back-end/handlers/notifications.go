@@ -0,0 +1,441 @@
+package handlers
+
+import (
+	"back-end/config"
+	"back-end/jobs"
+	"back-end/utils"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ชนิดของการแจ้งเตือน
+const (
+	NotifyNoteApproved       = "note_approved"        // note ของ seller ผ่านการอนุมัติ
+	NotifyNoteRejected       = "note_rejected"        // note ของ seller ถูกปฏิเสธ
+	NotifyNoteSold           = "note_sold"            // มีคนซื้อ note หรือ bundle ของ seller
+	NotifyReviewReceived     = "review_received"      // มีรีวิวใหม่บน note ของ seller
+	NotifyReviewReply        = "review_reply"         // seller ตอบรีวิวของผู้ซื้อ
+	NotifyWishlistPriceDrop  = "wishlist_price_drop"  // note ที่บันทึกไว้ลดราคา
+	NotifyReportClosed       = "report_closed"        // admin ตรวจรายงานที่ user ส่งแล้ว
+	NotifySellerApplication  = "seller_application"   // ใบสมัครผู้ขายได้รับการอนุมัติหรือถูกปฏิเสธ
+	NotifyFollowedSellerNote = "followed_seller_note" // ผู้ขายที่ติดตามอยู่ลง note ใหม่
+)
+
+// notificationTypes - ชนิดการแจ้งเตือนที่ user ตั้งค่าได้ (ตามลำดับที่แสดงในหน้าตั้งค่า)
+var notificationTypes = []string{
+	NotifyNoteApproved,
+	NotifyNoteRejected,
+	NotifyNoteSold,
+	NotifyReviewReceived,
+	NotifyReviewReply,
+	NotifyWishlistPriceDrop,
+	NotifyReportClosed,
+	NotifySellerApplication,
+	NotifyFollowedSellerNote,
+}
+
+// defaultEmailNotify - ชนิดที่ส่ง email ด้วยถ้า user ยังไม่ได้ตั้งค่า (in-app เปิดเสมอเป็นค่าเริ่มต้น)
+var defaultEmailNotify = map[string]bool{
+	NotifyNoteApproved:      true,
+	NotifyNoteRejected:      true,
+	NotifyNoteSold:          true,
+	NotifySellerApplication: true,
+}
+
+// Notification - การแจ้งเตือนหนึ่งรายการ
+type Notification struct {
+	ID        int             `json:"id"`
+	Type      string          `json:"type"`
+	Title     string          `json:"title"`
+	Message   string          `json:"message"`
+	Data      json.RawMessage `json:"data" swaggertype:"object"`
+	IsRead    bool            `json:"is_read"`
+	ReadAt    *time.Time      `json:"read_at"`
+	CreatedAt time.Time       `json:"created_at"`
+}
+
+// NotificationPreference - ช่องทางที่ user ต้องการรับการแจ้งเตือนแต่ละชนิด
+type NotificationPreference struct {
+	Type  string `json:"type" binding:"required"`
+	InApp bool   `json:"in_app"`
+	Email bool   `json:"email"`
+}
+
+// notifyDB - ใช้ได้ทั้ง *sql.DB และ *sql.Tx
+type notifyDB interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+	QueryRow(query string, args ...interface{}) *sql.Row
+}
+
+// notifyEmailPayload - payload ของ job notifications.email
+type notifyEmailPayload struct {
+	UserID  int    `json:"user_id"`
+	Subject string `json:"subject"`
+	Body    string `json:"body"`
+}
+
+// isNotificationType - ตรวจว่าเป็นชนิดการแจ้งเตือนที่รู้จัก
+func isNotificationType(notifType string) bool {
+	for _, t := range notificationTypes {
+		if t == notifType {
+			return true
+		}
+	}
+	return false
+}
+
+// notificationPreference - ช่องทางที่ user เลือกไว้สำหรับชนิดนี้ (ยังไม่ได้ตั้งค่า = ค่าเริ่มต้น)
+func notificationPreference(db notifyDB, userID int, notifType string) (NotificationPreference, error) {
+	pref := NotificationPreference{Type: notifType, InApp: true, Email: defaultEmailNotify[notifType]}
+	err := db.QueryRow(`
+		SELECT in_app, email FROM notification_preferences WHERE user_id = $1 AND type = $2
+	`, userID, notifType).Scan(&pref.InApp, &pref.Email)
+	if err != nil && err != sql.ErrNoRows {
+		return pref, err
+	}
+	return pref, nil
+}
+
+// notify - แจ้งเตือน user ตามช่องทางที่ตั้งค่าไว้ (data เป็นข้อมูลประกอบสำหรับ frontend เช่น note_id)
+// ถ้าส่งผ่าน transaction การแจ้งเตือนจะเกิดขึ้นก็ต่อเมื่อ transaction commit
+// email ถูกส่งแบบ background job และจะข้ามไปถ้ายังไม่ได้ตั้งค่า SMTP
+func notify(db notifyDB, userID int, notifType, title, message string, data interface{}) error {
+	pref, err := notificationPreference(db, userID, notifType)
+	if err != nil {
+		return err
+	}
+
+	if pref.InApp {
+		payload, err := json.Marshal(data)
+		if err != nil {
+			return err
+		}
+		_, err = db.Exec(`
+			INSERT INTO notifications (user_id, type, title, message, data, created_at)
+			VALUES ($1, $2, $3, $4, $5, NOW())
+		`, userID, notifType, title, message, payload)
+		if err != nil {
+			return err
+		}
+	}
+
+	if pref.Email && utils.MailConfigured() {
+		payload := notifyEmailPayload{UserID: userID, Subject: title, Body: message}
+		if _, err := jobs.EnqueueTx(db, JobNotifyEmail, payload, jobs.EnqueueOptions{}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// notifyEmailJob - ส่ง email แจ้งเตือนไปยังที่อยู่ปัจจุบันของ user
+func notifyEmailJob(ctx context.Context, payload notifyEmailPayload) error {
+	var email string
+	err := config.DB.QueryRowContext(ctx, `SELECT email FROM users WHERE id = $1`, payload.UserID).Scan(&email)
+	if err == sql.ErrNoRows {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if err := utils.SendMail(email, payload.Subject, payload.Body); err == utils.ErrMailNotConfigured {
+		log.Printf("⚠️  Skipping notification email to user %d: %v", payload.UserID, err)
+		return nil
+	} else if err != nil {
+		return err
+	}
+	return nil
+}
+
+// GetNotifications godoc
+// @Summary ดึงการแจ้งเตือนของตัวเอง
+// @Description ดึงการแจ้งเตือนล่าสุดก่อน พร้อมจำนวนที่ยังไม่ได้อ่าน
+// @Tags notifications
+// @Produce json
+// @Security BearerAuth
+// @Param unread query bool false "ดึงเฉพาะที่ยังไม่ได้อ่าน"
+// @Param page query int false "Page number (default 1)"
+// @Param limit query int false "Items per page (default 20, max 100)"
+// @Success 200 {object} map[string]interface{} "รายการแจ้งเตือน total และ unread_count"
+// @Failure 401 {object} map[string]string "Unauthorized"
+// @Failure 500 {object} map[string]string "Database error"
+// @Router /api/notifications [get]
+func GetNotifications(c *gin.Context) {
+	userID := c.GetInt("user_id")
+	unreadOnly := c.Query("unread") == "true"
+
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
+	if page < 1 {
+		page = 1
+	}
+	if limit < 1 || limit > 100 {
+		limit = 20
+	}
+
+	var total, unread int
+	err := config.DB.QueryRow(`
+		SELECT COUNT(*) FILTER (WHERE NOT $2 OR read_at IS NULL), COUNT(*) FILTER (WHERE read_at IS NULL)
+		FROM notifications WHERE user_id = $1
+	`, userID, unreadOnly).Scan(&total, &unread)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+
+	rows, err := config.DB.Query(`
+		SELECT id, type, title, COALESCE(message, ''), COALESCE(data, 'null'), read_at, created_at
+		FROM notifications
+		WHERE user_id = $1 AND (NOT $2 OR read_at IS NULL)
+		ORDER BY created_at DESC, id DESC
+		LIMIT $3 OFFSET $4
+	`, userID, unreadOnly, limit, (page-1)*limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch notifications"})
+		return
+	}
+	defer rows.Close()
+
+	notifications := []Notification{}
+	for rows.Next() {
+		var n Notification
+		var data []byte
+		var readAt sql.NullTime
+		if err := rows.Scan(&n.ID, &n.Type, &n.Title, &n.Message, &data, &readAt, &n.CreatedAt); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to scan notification"})
+			return
+		}
+		n.Data = data
+		if readAt.Valid {
+			n.IsRead = true
+			n.ReadAt = &readAt.Time
+		}
+		notifications = append(notifications, n)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success":      true,
+		"data":         notifications,
+		"total":        total,
+		"unread_count": unread,
+		"page":         page,
+		"limit":        limit,
+	})
+}
+
+// GetUnreadNotificationCount godoc
+// @Summary จำนวนการแจ้งเตือนที่ยังไม่ได้อ่าน
+// @Description ใช้แสดง badge บนไอคอนแจ้งเตือน
+// @Tags notifications
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} map[string]interface{} "unread_count"
+// @Failure 401 {object} map[string]string "Unauthorized"
+// @Failure 500 {object} map[string]string "Database error"
+// @Router /api/notifications/unread-count [get]
+func GetUnreadNotificationCount(c *gin.Context) {
+	var count int
+	err := config.DB.QueryRow(`
+		SELECT COUNT(*) FROM notifications WHERE user_id = $1 AND read_at IS NULL
+	`, c.GetInt("user_id")).Scan(&count)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"unread_count": count})
+}
+
+// MarkNotificationRead godoc
+// @Summary อ่านการแจ้งเตือนแล้ว
+// @Description ทำเครื่องหมายว่าอ่านการแจ้งเตือนแล้ว (อ่านซ้ำไม่มีผล)
+// @Tags notifications
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Notification ID"
+// @Success 200 {object} map[string]interface{} "สำเร็จ พร้อมจำนวนที่ยังไม่ได้อ่าน"
+// @Failure 400 {object} map[string]string "Invalid notification ID"
+// @Failure 401 {object} map[string]string "Unauthorized"
+// @Failure 404 {object} map[string]string "Notification not found"
+// @Failure 500 {object} map[string]string "Database error"
+// @Router /api/notifications/{id}/read [put]
+func MarkNotificationRead(c *gin.Context) {
+	userID := c.GetInt("user_id")
+
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid notification ID"})
+		return
+	}
+
+	result, err := config.DB.Exec(`
+		UPDATE notifications SET read_at = COALESCE(read_at, NOW())
+		WHERE id = $1 AND user_id = $2
+	`, id, userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Notification not found"})
+		return
+	}
+
+	var unread int
+	config.DB.QueryRow(`SELECT COUNT(*) FROM notifications WHERE user_id = $1 AND read_at IS NULL`, userID).Scan(&unread)
+
+	c.JSON(http.StatusOK, gin.H{
+		"success":      true,
+		"unread_count": unread,
+	})
+}
+
+// MarkAllNotificationsRead godoc
+// @Summary อ่านการแจ้งเตือนทั้งหมดแล้ว
+// @Description ทำเครื่องหมายว่าอ่านการแจ้งเตือนที่ค้างอยู่ทั้งหมดแล้ว
+// @Tags notifications
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} map[string]interface{} "สำเร็จ พร้อมจำนวนที่ถูกอ่าน"
+// @Failure 401 {object} map[string]string "Unauthorized"
+// @Failure 500 {object} map[string]string "Database error"
+// @Router /api/notifications/read-all [put]
+func MarkAllNotificationsRead(c *gin.Context) {
+	result, err := config.DB.Exec(`
+		UPDATE notifications SET read_at = NOW() WHERE user_id = $1 AND read_at IS NULL
+	`, c.GetInt("user_id"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+	n, _ := result.RowsAffected()
+
+	c.JSON(http.StatusOK, gin.H{
+		"success":      true,
+		"marked_count": n,
+		"unread_count": 0,
+	})
+}
+
+// loadNotificationPreferences - การตั้งค่าของทุกชนิด (ชนิดที่ยังไม่ได้ตั้งค่าใช้ค่าเริ่มต้น)
+func loadNotificationPreferences(userID int) ([]NotificationPreference, error) {
+	rows, err := config.DB.Query(`
+		SELECT type, in_app, email FROM notification_preferences WHERE user_id = $1
+	`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	saved := map[string]NotificationPreference{}
+	for rows.Next() {
+		var pref NotificationPreference
+		if err := rows.Scan(&pref.Type, &pref.InApp, &pref.Email); err != nil {
+			return nil, err
+		}
+		saved[pref.Type] = pref
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	prefs := make([]NotificationPreference, 0, len(notificationTypes))
+	for _, notifType := range notificationTypes {
+		pref, ok := saved[notifType]
+		if !ok {
+			pref = NotificationPreference{Type: notifType, InApp: true, Email: defaultEmailNotify[notifType]}
+		}
+		prefs = append(prefs, pref)
+	}
+	return prefs, nil
+}
+
+// GetNotificationPreferences godoc
+// @Summary ดึงการตั้งค่าการแจ้งเตือน
+// @Description ดึงช่องทาง (in-app / email) ของการแจ้งเตือนแต่ละชนิด
+// @Tags notifications
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {array} NotificationPreference "การตั้งค่าทุกชนิด"
+// @Failure 401 {object} map[string]string "Unauthorized"
+// @Failure 500 {object} map[string]string "Database error"
+// @Router /api/notifications/preferences [get]
+func GetNotificationPreferences(c *gin.Context) {
+	prefs, err := loadNotificationPreferences(c.GetInt("user_id"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+
+	c.JSON(http.StatusOK, prefs)
+}
+
+// UpdateNotificationPreferences godoc
+// @Summary แก้ไขการตั้งค่าการแจ้งเตือน
+// @Description กำหนดช่องทางของการแจ้งเตือนแต่ละชนิด (ส่งมาเฉพาะชนิดที่ต้องการเปลี่ยน)
+// @Tags notifications
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body []NotificationPreference true "การตั้งค่าที่ต้องการเปลี่ยน"
+// @Success 200 {array} NotificationPreference "การตั้งค่าทุกชนิดหลังแก้ไข"
+// @Failure 400 {object} map[string]string "Invalid request or unknown notification type"
+// @Failure 401 {object} map[string]string "Unauthorized"
+// @Failure 500 {object} map[string]string "Database error"
+// @Router /api/notifications/preferences [put]
+func UpdateNotificationPreferences(c *gin.Context) {
+	userID := c.GetInt("user_id")
+
+	var request []NotificationPreference
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format"})
+		return
+	}
+	for _, pref := range request {
+		if !isNotificationType(pref.Type) {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": "Unknown notification type: " + pref.Type,
+				"types": notificationTypes,
+			})
+			return
+		}
+	}
+
+	tx, err := config.DB.Begin()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+	defer tx.Rollback()
+
+	for _, pref := range request {
+		_, err := tx.Exec(`
+			INSERT INTO notification_preferences (user_id, type, in_app, email, updated_at)
+			VALUES ($1, $2, $3, $4, NOW())
+			ON CONFLICT (user_id, type) DO UPDATE
+			SET in_app = EXCLUDED.in_app, email = EXCLUDED.email, updated_at = NOW()
+		`, userID, pref.Type, pref.InApp, pref.Email)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save preferences"})
+			return
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save preferences"})
+		return
+	}
+
+	prefs, err := loadNotificationPreferences(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+	c.JSON(http.StatusOK, prefs)
+}
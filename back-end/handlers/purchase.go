@@ -9,20 +9,25 @@ import (
 
 // PurchaseRequest represents the purchase request payload
 type PurchaseRequest struct {
-	NoteIDs []int `json:"note_ids" binding:"required"`
+	NoteIDs    []int  `json:"note_ids"`
+	BundleIDs  []int  `json:"bundle_ids"`  // optional
+	CouponCode string `json:"coupon_code"` // optional
 }
 
 // PurchaseNotes godoc
 // @Summary Purchase notes
-// @Description Complete a purchase of one or more notes and remove them from cart
+// @Deprecated
+// @Description Complete a purchase of one or more notes and/or bundles and remove them from cart. A bundle grants every member note the buyer does not own yet, priced down for notes already owned. Own notes, notes already owned and notes not available for sale are rejected with reason codes in issues. An optional coupon code is validated and redeemed in the same transaction. Prefer /api/checkout, which buys the server-side cart. An Idempotency-Key header makes retries return the original result
 // @Tags purchase
 // @Accept json
 // @Produce json
 // @Security BearerAuth
-// @Param request body PurchaseRequest true "Purchase request with note IDs"
+// @Param Idempotency-Key header string false "Unique key per purchase attempt"
+// @Param request body PurchaseRequest true "Purchase request with note IDs and bundle IDs"
 // @Success 200 {object} map[string]interface{} "Purchase completed successfully"
-// @Failure 400 {object} map[string]string "Invalid request"
+// @Failure 400 {object} map[string]interface{} "Invalid request or items that cannot be purchased"
 // @Failure 401 {object} map[string]string "Unauthorized"
+// @Failure 422 {object} map[string]string "Idempotency-Key reused with a different request"
 // @Failure 500 {object} map[string]string "Server error"
 // @Router /api/purchase [post]
 func PurchaseNotes(c *gin.Context) {
@@ -43,12 +48,18 @@ func PurchaseNotes(c *gin.Context) {
 		return
 	}
 
-	if len(req.NoteIDs) == 0 {
+	if len(req.NoteIDs) == 0 && len(req.BundleIDs) == 0 {
 		c.JSON(http.StatusBadRequest, gin.H{
-			"error": "Note IDs are required",
+			"error": "Note IDs or bundle IDs are required",
 		})
 		return
 	}
+	req.CouponCode = normalizeCouponCode(req.CouponCode)
+
+	key, ok := idempotencyKey(c)
+	if !ok {
+		return
+	}
 
 	// เริ่ม transaction
 	tx, err := config.DB.Begin()
@@ -60,37 +71,64 @@ func PurchaseNotes(c *gin.Context) {
 	}
 	defer tx.Rollback()
 
-	// Insert ข้อมูลลง buyed_note สำหรับแต่ละ note
-	insertQuery := `
-		INSERT INTO buyed_note (user_id, note_id, review, is_liked)
-		VALUES ($1, $2, '', NULL)
-		ON CONFLICT DO NOTHING
-	`
-
-	purchasedCount := 0
-	for _, noteID := range req.NoteIDs {
-		result, err := tx.Exec(insertQuery, userID, noteID)
+	if key != "" {
+		stored, err := claimIdempotencyKey(tx, userID.(int), key, "purchase", requestFingerprint("purchase", req))
+		if err == errIdempotencyKeyReused {
+			c.JSON(http.StatusUnprocessableEntity, gin.H{
+				"error": "Idempotency-Key was already used with a different request",
+			})
+			return
+		}
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{
-				"error":   "Failed to create purchase record",
-				"details": err.Error(),
+				"error": "Database error",
 			})
 			return
 		}
+		if stored != nil {
+			replayIdempotentResponse(c, stored)
+			return
+		}
+	}
 
-		rowsAffected, _ := result.RowsAffected()
-		purchasedCount += int(rowsAffected)
+	if err := lockBuyer(tx, userID.(int)); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Database error",
+		})
+		return
 	}
 
-	// ลบสินค้าออกจากตะกร้า
-	for _, noteID := range req.NoteIDs {
-		deleteCartQuery := `DELETE FROM cart WHERE user_id = $1 AND note_id = $2`
-		_, err = tx.Exec(deleteCartQuery, userID, noteID)
-		if err != nil {
+	// ตรวจสินค้า คำนวณยอด และบันทึกคำสั่งซื้อ (ราคาคำนวณที่ server เสมอ)
+	order, err := placeOrder(tx, userID.(int), req.NoteIDs, req.BundleIDs, req.CouponCode)
+	if err != nil {
+		if !respondOrderError(c, err) {
 			c.JSON(http.StatusInternalServerError, gin.H{
-				"error":   "Failed to clear cart",
+				"error":   "Failed to create purchase record",
 				"details": err.Error(),
 			})
+		}
+		return
+	}
+
+	response := gin.H{
+		"success":         true,
+		"message":         "Purchase completed successfully",
+		"purchased_count": order.granted,
+		"note_ids":        order.noteIDs,
+		"order":           order,
+	}
+	if len(order.bundles) > 0 {
+		response["bundles"] = order.bundles
+	}
+	if order.coupon != nil {
+		response["coupon"] = order.coupon
+	}
+
+	if key != "" {
+		if err := saveIdempotentResponse(tx, userID.(int), key, http.StatusOK, response); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error": "Failed to complete purchase",
+			})
 			return
 		}
 	}
@@ -102,11 +140,7 @@ func PurchaseNotes(c *gin.Context) {
 		})
 		return
 	}
+	publishOrderEvents(order)
 
-	c.JSON(http.StatusOK, gin.H{
-		"success":         true,
-		"message":         "Purchase completed successfully",
-		"purchased_count": purchasedCount,
-		"note_ids":        req.NoteIDs,
-	})
+	c.JSON(http.StatusOK, response)
 }
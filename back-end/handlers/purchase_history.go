@@ -3,7 +3,9 @@ package handlers
 import (
 	"back-end/config"
 	"database/sql"
+	"errors"
 	"net/http"
+	"strconv"
 
 	"github.com/gin-gonic/gin"
 )
@@ -21,8 +23,10 @@ type PurchaseHistoryResponse struct {
 	Images      []string `json:"images"`
 	Course      Course   `json:"course"`
 	Seller      Seller   `json:"seller"`
+	ReviewID    *int     `json:"review_id"`
+	Rating      *int     `json:"rating"`
 	Review      string   `json:"review"`
-	IsLiked     *bool    `json:"is_liked"`
+	IsLiked     *bool    `json:"is_liked"` // rating >= 4 (null = ยังไม่ได้รีวิว)
 }
 
 // GetMyPurchaseHistory godoc
@@ -47,12 +51,13 @@ func GetMyPurchaseHistory(c *gin.Context) {
 
 	query := `
 		SELECT 
-			bn.id, bn.review, bn.is_liked,
+			bn.id, r.id, r.rating, r.body,
 			n.id, n.book_title, n.price, n.exam_term, n.description, n.pdf_file,
 			c.id, c.code, c.name, c.year, c.major,
 			u.id, u.username, u.fullname
 		FROM buyed_note bn
 		INNER JOIN notes_for_sale n ON bn.note_id = n.id
+		LEFT JOIN reviews r ON r.buyed_note_id = bn.id
 		LEFT JOIN courses c ON n.course_id = c.id
 		LEFT JOIN users u ON n.seller_id = u.id
 		WHERE bn.user_id = $1
@@ -77,10 +82,10 @@ func GetMyPurchaseHistory(c *gin.Context) {
 		var courseCode, courseName, courseYear, courseMajor sql.NullString
 		var sellerUsername, sellerFullname sql.NullString
 		var examTerm, description, review sql.NullString
-		var isLiked sql.NullBool
+		var reviewID, rating sql.NullInt64
 
 		err := rows.Scan(
-			&purchase.BuyedNoteID, &review, &isLiked,
+			&purchase.BuyedNoteID, &reviewID, &rating, &review,
 			&purchase.NoteID, &purchase.BookTitle, &purchase.Price, &examTerm, &description, &purchase.PDFFile,
 			&courseID, &courseCode, &courseName, &courseYear, &courseMajor,
 			&sellerID, &sellerUsername, &sellerFullname,
@@ -90,13 +95,12 @@ func GetMyPurchaseHistory(c *gin.Context) {
 		}
 
 		// กำหนดค่า review
-		if review.Valid {
+		if reviewID.Valid {
+			id, stars := int(reviewID.Int64), int(rating.Int64)
+			liked := stars >= likedReviewRating
+			purchase.ReviewID = &id
+			purchase.Rating = &stars
 			purchase.Review = review.String
-		}
-
-		// กำหนดค่า is_liked
-		if isLiked.Valid {
-			liked := isLiked.Bool
 			purchase.IsLiked = &liked
 		}
 
@@ -132,9 +136,10 @@ func GetMyPurchaseHistory(c *gin.Context) {
 
 		// ดึงรูปปกเท่านั้น (รูปแรก)
 		imageQuery := `
-			SELECT path FROM note_images 
-			WHERE note_id = $1 
-			ORDER BY image_order ASC
+			SELECT COALESCE(v.path, ni.path) FROM note_images ni
+			LEFT JOIN image_variants v ON v.note_image_id = ni.id AND v.variant = 'card'
+			WHERE ni.note_id = $1
+			ORDER BY ni.image_order ASC
 			LIMIT 1
 		`
 		var coverImage string
@@ -153,11 +158,12 @@ func GetMyPurchaseHistory(c *gin.Context) {
 type UpdatePurchaseReviewRequest struct {
 	Review  string `json:"review" binding:"required"`
 	IsLiked bool   `json:"is_liked"`
+	Rating  *int   `json:"rating"` // 1-5 (ถ้าไม่ส่งมา: ชอบ = 5, ไม่ชอบ = 1)
 }
 
 // UpdatePurchaseReview godoc
-// @Summary Update purchase review and like status
-// @Description Update the review text and/or like status for a purchased note
+// @Summary Review a purchase
+// @Description Write the review for a purchased note. Deprecated: use POST /api/notes/{id}/reviews with a 1-5 star rating, and PUT /api/reviews/{id} to edit
 // @Tags Purchases
 // @Accept json
 // @Produce json
@@ -165,23 +171,21 @@ type UpdatePurchaseReviewRequest struct {
 // @Param id path int true "Buyed Note ID"
 // @Param review body UpdatePurchaseReviewRequest true "Review and like status"
 // @Success 200 {object} map[string]interface{} "Review updated successfully"
-// @Failure 400 {object} map[string]string "Invalid request"
+// @Failure 400 {object} map[string]string "Invalid request or review already submitted"
 // @Failure 401 {object} map[string]string "Unauthorized"
-// @Failure 403 {object} map[string]string "Not owner of this purchase"
 // @Failure 404 {object} map[string]string "Purchase not found"
 // @Failure 500 {object} map[string]string "Database error"
+// @Deprecated
 // @Router /api/my-purchases/{id} [put]
 func UpdatePurchaseReview(c *gin.Context) {
-	userID, exists := c.Get("user_id")
-	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{
-			"error": "Unauthorized",
+	buyedNoteID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "Purchase not found",
 		})
 		return
 	}
 
-	buyedNoteID := c.Param("id")
-
 	var req UpdatePurchaseReviewRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{
@@ -190,53 +194,39 @@ func UpdatePurchaseReview(c *gin.Context) {
 		return
 	}
 
-	// ตรวจสอบว่า buyed_note นี้เป็นของ user นี้หรือไม่
-	var existingReview string
-	var existingLiked sql.NullBool
-	checkQuery := `
-		SELECT review, is_liked 
-		FROM buyed_note 
-		WHERE id = $1 AND user_id = $2
-	`
-	err := config.DB.QueryRow(checkQuery, buyedNoteID, userID).Scan(&existingReview, &existingLiked)
-	if err == sql.ErrNoRows {
-		c.JSON(http.StatusNotFound, gin.H{
-			"error": "Purchase not found",
-		})
-		return
+	rating := minReviewRating
+	if req.IsLiked {
+		rating = maxReviewRating
 	}
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error": "Database error",
-		})
+	if req.Rating != nil {
+		rating = *req.Rating
+	}
+	input := ReviewInput{Rating: &rating, Review: &req.Review}
+	if err := input.validate(false); err != nil {
+		respondReviewError(c, err)
 		return
 	}
 
-	// ถ้ามี review หรือ is_liked แล้ว ห้ามแก้ไข
-	if existingReview != "" || existingLiked.Valid {
+	review, err := createReview(c.GetInt("user_id"), `bn.id = $2`, buyedNoteID, input)
+	switch {
+	case errors.Is(err, errReviewNotPurchased):
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "Purchase not found",
+		})
+		return
+	case errors.Is(err, errReviewExists):
 		c.JSON(http.StatusBadRequest, gin.H{
 			"error": "Review already submitted and cannot be modified",
 		})
 		return
-	}
-
-	// อัพเดท review และ is_liked
-	updateQuery := `
-		UPDATE buyed_note 
-		SET review = $1, is_liked = $2
-		WHERE id = $3 AND user_id = $4
-	`
-	_, err = config.DB.Exec(updateQuery, req.Review, req.IsLiked, buyedNoteID, userID)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error":   "Failed to update review",
-			"details": err.Error(),
-		})
+	case err != nil:
+		respondReviewError(c, err)
 		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
 		"message": "Review updated successfully",
+		"data":    review,
 	})
 }
@@ -0,0 +1,231 @@
+package handlers
+
+import (
+	"back-end/config"
+	"context"
+	"database/sql"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// เหตุผลที่แนะนำ note
+const (
+	RecommendCoPurchased = "co_purchased" // คนที่ซื้อเล่มนี้ซื้อเล่มนั้นด้วย
+	RecommendSameCourse  = "same_course"  // วิชาเดียวกัน
+	RecommendSameMajor   = "same_major"   // สาขาเดียวกัน
+)
+
+// น้ำหนักของคะแนนความคล้าย (ซื้อร่วมกันเป็น cosine similarity อยู่ในช่วง 0-1)
+const (
+	coPurchaseWeight = 1.0
+	sameCourseWeight = 0.3
+	sameMajorWeight  = 0.1
+)
+
+// จำนวน note ที่คล้ายกันที่เก็บไว้ต่อเล่ม และจำนวนที่คืนต่อ request
+const (
+	maxSimilarNotes            = 20
+	defaultRecommendationLimit = 6
+)
+
+// RecommendedNote - note ที่แนะนำพร้อมคะแนนและเหตุผล
+type RecommendedNote struct {
+	NoteResponse
+	Score  float64 `json:"score" example:"0.82"`
+	Reason string  `json:"reason" example:"co_purchased"`
+}
+
+// queryRecommendedNotes - ดึง note ที่แนะนำ (query ต้อง SELECT noteRowColumns ตามด้วย score, reason)
+func queryRecommendedNotes(c *gin.Context, query string, args ...interface{}) ([]RecommendedNote, error) {
+	rows, err := config.DB.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	items := []RecommendedNote{}
+	for rows.Next() {
+		var item RecommendedNote
+		if err := scanNoteRow(rows, &item.NoteResponse, &item.Score, &item.Reason); err != nil {
+			return nil, err
+		}
+		loadNoteImages(&item.NoteResponse)
+
+		items = append(items, item)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	notes := make([]NoteResponse, len(items))
+	for i := range items {
+		notes[i] = items[i].NoteResponse
+	}
+	applyWishlistInfo(c, notes)
+	applySellerBadges(notes)
+	for i := range items {
+		items[i].NoteResponse = notes[i]
+	}
+	return items, nil
+}
+
+// GetNoteRecommendations godoc
+// @Summary ดึง note ที่แนะนำ
+// @Description ดึง note ที่ "คนที่ซื้อเล่มนี้ซื้อด้วย" และ note วิชา/สาขาเดียวกัน จากตารางความคล้ายที่คำนวณไว้ล่วงหน้าโดย background job (note ใหม่ที่ยังไม่ถูกคำนวณจะแนะนำ note วิชาเดียวกันที่ขายดีแทน) ไม่รวม note ที่ viewer ซื้อแล้วหรือเป็นของตัวเอง
+// @Tags notes
+// @Produce json
+// @Param id path int true "Note ID"
+// @Param limit query int false "จำนวนที่ต้องการ (ค่าเริ่มต้น 6, สูงสุด 20)"
+// @Success 200 {array} RecommendedNote "note ที่แนะนำ"
+// @Failure 400 {object} map[string]string "Invalid note ID"
+// @Failure 404 {object} map[string]string "Note not found"
+// @Failure 500 {object} map[string]string "Database error"
+// @Router /api/notes/{id}/recommendations [get]
+func GetNoteRecommendations(c *gin.Context) {
+	noteID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid note ID"})
+		return
+	}
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", strconv.Itoa(defaultRecommendationLimit)))
+	if limit < 1 || limit > maxSimilarNotes {
+		limit = defaultRecommendationLimit
+	}
+
+	var courseID sql.NullInt64
+	err = config.DB.QueryRow(`
+		SELECT course_id FROM notes_for_sale
+		WHERE id = $1 AND status = 'available' AND deleted_at IS NULL
+	`, noteID).Scan(&courseID)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Note not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error", "message": err.Error()})
+		return
+	}
+
+	// เฉพาะ note ที่ยังขายอยู่ และ viewer ยังไม่ได้ซื้อหรือไม่ใช่ของตัวเอง
+	const available = `n.status = 'available' AND n.deleted_at IS NULL AND n.seller_id <> $2
+		AND NOT EXISTS (SELECT 1 FROM buyed_note b WHERE b.user_id = $2 AND b.note_id = n.id)`
+	viewer := viewerID(c)
+
+	notes, err := queryRecommendedNotes(c, `
+		SELECT `+noteRowColumns+`, s.score, s.reason
+		FROM note_similarities s
+		JOIN notes_for_sale n ON n.id = s.similar_note_id
+		LEFT JOIN courses c ON n.course_id = c.id
+		LEFT JOIN users u ON n.seller_id = u.id
+		WHERE s.note_id = $1 AND `+available+`
+		ORDER BY s.score DESC, n.id DESC
+		LIMIT $3
+	`, noteID, viewer, limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error", "message": err.Error()})
+		return
+	}
+
+	// ยังไม่มีผลที่คำนวณไว้ (เช่น note เพิ่งผ่านการอนุมัติ) ใช้ note วิชาเดียวกันที่ขายดีแทน
+	if len(notes) == 0 && courseID.Valid {
+		notes, err = queryRecommendedNotes(c, `
+			SELECT `+noteRowColumns+`, $4::float8, $5::text
+			FROM notes_for_sale n
+			LEFT JOIN courses c ON n.course_id = c.id
+			LEFT JOIN users u ON n.seller_id = u.id
+			WHERE n.course_id = $1 AND n.id <> $3 AND `+available+`
+			ORDER BY total_sales DESC, n.created_at DESC
+			LIMIT $6
+		`, courseID.Int64, viewer, noteID, sameCourseWeight, RecommendSameCourse, limit)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error", "message": err.Error()})
+			return
+		}
+	}
+
+	c.JSON(http.StatusOK, notes)
+}
+
+// recomputeRecommendationsJob - คำนวณตาราง note_similarities ใหม่ทั้งหมด
+// คะแนน = cosine similarity ของผู้ซื้อร่วมกัน + น้ำหนักถ้าวิชาเดียวกัน (หรือสาขาเดียวกัน) เก็บไว้เล่มละไม่เกิน maxSimilarNotes
+// note วิชา/สาขาเดียวกันใช้เป็นตัวเลือกเฉพาะเล่มที่ขายดีที่สุดไม่เกิน maxSimilarNotes+1 เล่มต่อวิชา/สาขา
+// (ไม่ต้องจับคู่ทุกเล่มในสาขาเดียวกัน ซึ่งโตแบบกำลังสอง)
+// แทนที่ของเดิมใน transaction เดียว ระหว่างคำนวณ request ยังอ่านผลรอบก่อนได้
+func recomputeRecommendationsJob(ctx context.Context, _ struct{}) error {
+	tx, err := config.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM note_similarities`); err != nil {
+		return err
+	}
+	result, err := tx.ExecContext(ctx, `
+		WITH buyers AS (
+			SELECT note_id, COUNT(*) AS total FROM buyed_note GROUP BY note_id
+		), live AS (
+			SELECT n.id, n.course_id, NULLIF(c.major, '') AS major, COALESCE(b.total, 0) AS sales
+			FROM notes_for_sale n
+			LEFT JOIN courses c ON n.course_id = c.id
+			LEFT JOIN buyers b ON b.note_id = n.id
+			WHERE n.status = 'available' AND n.deleted_at IS NULL
+		), top_by_course AS (
+			SELECT id, course_id FROM (
+				SELECT id, course_id, ROW_NUMBER() OVER (PARTITION BY course_id ORDER BY sales DESC, id DESC) AS rank
+				FROM live WHERE course_id IS NOT NULL
+			) t WHERE rank <= $8
+		), top_by_major AS (
+			SELECT id, major FROM (
+				SELECT id, major, ROW_NUMBER() OVER (PARTITION BY major ORDER BY sales DESC, id DESC) AS rank
+				FROM live WHERE major IS NOT NULL
+			) t WHERE rank <= $8
+		), co AS (
+			SELECT a.note_id, b.note_id AS similar_note_id, COUNT(*) AS co_purchases
+			FROM buyed_note a
+			JOIN buyed_note b ON a.user_id = b.user_id AND a.note_id <> b.note_id
+			GROUP BY a.note_id, b.note_id
+		), candidates AS (
+			SELECT note_id, similar_note_id FROM co
+			UNION
+			SELECT x.id, t.id FROM live x JOIN top_by_course t ON t.course_id = x.course_id AND t.id <> x.id
+			UNION
+			SELECT x.id, t.id FROM live x JOIN top_by_major t ON t.major = x.major AND t.id <> x.id
+		), scored AS (
+			SELECT p.note_id, p.similar_note_id, y.sales,
+				COALESCE(co.co_purchases, 0) AS co_purchases,
+				$1::float8 * COALESCE(co.co_purchases / SQRT(x.sales::float8 * y.sales), 0)
+					+ CASE WHEN x.course_id = y.course_id THEN $2::float8
+						WHEN x.major = y.major THEN $3::float8 ELSE 0 END AS score,
+				CASE WHEN co.co_purchases > 0 THEN $4
+					WHEN x.course_id = y.course_id THEN $5 ELSE $6 END AS reason
+			FROM candidates p
+			JOIN live x ON x.id = p.note_id
+			JOIN live y ON y.id = p.similar_note_id
+			LEFT JOIN co ON co.note_id = p.note_id AND co.similar_note_id = p.similar_note_id
+		), ranked AS (
+			SELECT *, ROW_NUMBER() OVER (
+				PARTITION BY note_id ORDER BY score DESC, co_purchases DESC, sales DESC, similar_note_id DESC
+			) AS rank
+			FROM scored
+		)
+		INSERT INTO note_similarities (note_id, similar_note_id, score, co_purchases, reason, computed_at)
+		SELECT note_id, similar_note_id, score, co_purchases, reason, NOW()
+		FROM ranked
+		WHERE rank <= $7
+	`, coPurchaseWeight, sameCourseWeight, sameMajorWeight,
+		RecommendCoPurchased, RecommendSameCourse, RecommendSameMajor, maxSimilarNotes, maxSimilarNotes+1)
+	if err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	n, _ := result.RowsAffected()
+	log.Printf("🧮 Recomputed note recommendations: %d similar-note pairs", n)
+	return nil
+}
@@ -26,6 +26,7 @@ type RefreshTokenRequest struct {
 // @Success 200 {object} map[string]interface{} "New tokens and user info"
 // @Failure 400 {object} map[string]string "Invalid request"
 // @Failure 401 {object} map[string]string "Invalid or expired refresh token"
+// @Failure 403 {object} map[string]string "Account suspended or banned"
 // @Failure 500 {object} map[string]string "Server error"
 // @Router /api/refresh-token [post]
 func RefreshToken(c *gin.Context) {
@@ -91,6 +92,19 @@ func RefreshToken(c *gin.Context) {
 		return
 	}
 
+	// บัญชีที่ถูกแบนหรือระงับต่อ token ไม่ได้
+	account, err := loadAccountStatus(config.DB, user.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Database error",
+			"message": err.Error(),
+		})
+		return
+	}
+	if respondAccountBlocked(c, account) {
+		return
+	}
+
 	// ดึง roles ของ user
 	roles, err := getUserRoles(user.ID)
 	if err != nil {
@@ -102,7 +116,7 @@ func RefreshToken(c *gin.Context) {
 	}
 
 	// สร้าง access token ใหม่
-	newAccessToken, err := utils.GenerateJWT(user.ID, user.Email, roles)
+	newAccessToken, err := utils.GenerateJWT(user.ID, user.Email, roles, account.TokenVersion)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"error":   "Failed to generate token",
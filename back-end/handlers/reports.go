@@ -0,0 +1,288 @@
+package handlers
+
+import (
+	"back-end/config"
+	"back-end/events"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lib/pq"
+)
+
+// ชนิดของสิ่งที่ถูกรายงาน
+const (
+	ReportTargetNote   = "note"
+	ReportTargetReview = "review"
+	ReportTargetSeller = "seller"
+)
+
+// สถานะของรายงาน
+const (
+	ReportOpen      = "open"      // รอ admin ตรวจ
+	ReportInReview  = "in_review" // มี admin รับเรื่องแล้ว
+	ReportResolved  = "resolved"  // จัดการแล้ว
+	ReportDismissed = "dismissed" // ตรวจแล้วไม่ผิด
+)
+
+// การจัดการตอนปิดรายงาน
+const (
+	ReportActionNone        = "none"
+	ReportActionDelistNote  = "delist_note"  // เอา note ออกจากการขาย (เหมือน DeleteNote)
+	ReportActionSuspendUser = "suspend_user" // ระงับบัญชีเจ้าของเนื้อหาชั่วคราว
+)
+
+const maxReportDetailsLength = 2000
+
+// reportCategories - หมวดที่เลือกได้ของแต่ละชนิด
+var reportCategories = map[string][]string{
+	ReportTargetNote:   {"plagiarism", "wrong_course", "low_quality", "copyright", "other"},
+	ReportTargetReview: {"spam", "offensive", "fake_review", "other"},
+	ReportTargetSeller: {"scam", "harassment", "impersonation", "other"},
+}
+
+// errReportTargetNotFound - ไม่พบสิ่งที่ถูกรายงาน
+var errReportTargetNotFound = errors.New("report target not found")
+
+// Report - รายงานเนื้อหา/ผู้ใช้หนึ่งรายการ
+type Report struct {
+	ID           int        `json:"id"`
+	ReporterID   int        `json:"reporter_id"`
+	ReporterName string     `json:"reporter_name"`
+	TargetType   string     `json:"target_type" example:"note"`
+	TargetID     int        `json:"target_id"`
+	TargetUserID int        `json:"target_user_id"` // เจ้าของเนื้อหา (seller ของ note, ผู้เขียนรีวิว หรือ seller ที่ถูกรายงาน)
+	Category     string     `json:"category" example:"plagiarism"`
+	Details      string     `json:"details"`
+	Status       string     `json:"status" example:"open"`
+	AssignedTo   *int       `json:"assigned_to"`
+	Resolution   string     `json:"resolution"`
+	Action       *string    `json:"action"`
+	ResolvedBy   *int       `json:"resolved_by"`
+	ResolvedAt   *time.Time `json:"resolved_at"`
+	CreatedAt    time.Time  `json:"created_at"`
+}
+
+// ReportInput - ข้อมูลสำหรับรายงาน
+type ReportInput struct {
+	TargetType string `json:"target_type" binding:"required" example:"note"`
+	TargetID   int    `json:"target_id" binding:"required" example:"12"`
+	Category   string `json:"category" binding:"required" example:"plagiarism"`
+	Details    string `json:"details" example:"คัดลอกมาจากชีทของอาจารย์ทั้งเล่ม"`
+}
+
+// reportSelect - คอลัมน์ของ Report (ใช้กับ scanReport) ต่อท้ายด้วย WHERE
+const reportSelect = `
+	SELECT r.id, r.reporter_id, u.username, r.target_type, r.target_id, r.target_user_id,
+		r.category, r.details, r.status, r.assigned_to, r.resolution, r.action,
+		r.resolved_by, r.resolved_at, r.created_at
+	FROM reports r
+	JOIN users u ON r.reporter_id = u.id
+`
+
+func scanReport(row interface{ Scan(...interface{}) error }) (*Report, error) {
+	var r Report
+	var assignedTo, resolvedBy sql.NullInt64
+	var action sql.NullString
+	var resolvedAt sql.NullTime
+	err := row.Scan(&r.ID, &r.ReporterID, &r.ReporterName, &r.TargetType, &r.TargetID, &r.TargetUserID,
+		&r.Category, &r.Details, &r.Status, &assignedTo, &r.Resolution, &action,
+		&resolvedBy, &resolvedAt, &r.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	if assignedTo.Valid {
+		id := int(assignedTo.Int64)
+		r.AssignedTo = &id
+	}
+	if resolvedBy.Valid {
+		id := int(resolvedBy.Int64)
+		r.ResolvedBy = &id
+	}
+	if action.Valid {
+		r.Action = &action.String
+	}
+	if resolvedAt.Valid {
+		r.ResolvedAt = &resolvedAt.Time
+	}
+	return &r, nil
+}
+
+// getReport - ดึงรายงานเดียวตาม ID
+func getReport(q sqlQuerier, reportID int) (*Report, error) {
+	return scanReport(q.QueryRow(reportSelect+` WHERE r.id = $1`, reportID))
+}
+
+// reportTargetOwner - หาเจ้าของสิ่งที่ถูกรายงาน (ใช้ตอนระงับบัญชีและกันการรายงานตัวเอง)
+func reportTargetOwner(q sqlQuerier, targetType string, targetID int) (int, error) {
+	var query string
+	switch targetType {
+	case ReportTargetNote:
+		query = `SELECT seller_id FROM notes_for_sale WHERE id = $1 AND deleted_at IS NULL`
+	case ReportTargetReview:
+		query = `SELECT buyer_id FROM reviews WHERE id = $1`
+	case ReportTargetSeller:
+		query = `SELECT u.id FROM users u JOIN user_roles ur ON ur.user_id = u.id
+			JOIN roles ro ON ur.role_id = ro.id WHERE u.id = $1 AND ro.name = 'seller'`
+	default:
+		return 0, errReportTargetNotFound
+	}
+
+	var ownerID int
+	err := q.QueryRow(query, targetID).Scan(&ownerID)
+	if err == sql.ErrNoRows {
+		return 0, errReportTargetNotFound
+	}
+	return ownerID, err
+}
+
+// validate - ตรวจชนิด หมวด และความยาวข้อความ
+func (in *ReportInput) validate() error {
+	categories, ok := reportCategories[in.TargetType]
+	if !ok {
+		return &NoteFieldError{Field: "target_type", Message: "must be one of note, review, seller"}
+	}
+	valid := false
+	for _, category := range categories {
+		if category == in.Category {
+			valid = true
+			break
+		}
+	}
+	if !valid {
+		return &NoteFieldError{Field: "category", Message: "must be one of " + strings.Join(categories, ", ")}
+	}
+	in.Details = strings.TrimSpace(in.Details)
+	if utf8.RuneCountInString(in.Details) > maxReportDetailsLength {
+		return &NoteFieldError{Field: "details", Message: fmt.Sprintf("must be at most %d characters", maxReportDetailsLength)}
+	}
+	return nil
+}
+
+// countOpenReports - จำนวนรายงานที่ยังไม่ปิด (ใช้ใน dashboard)
+func countOpenReports() (int, error) {
+	var count int
+	err := config.DB.QueryRow(`
+		SELECT COUNT(*) FROM reports WHERE status IN ($1, $2)
+	`, ReportOpen, ReportInReview).Scan(&count)
+	return count, err
+}
+
+// CreateReport godoc
+// @Summary รายงาน note รีวิว หรือ seller
+// @Description รายงานเนื้อหาที่ไม่เหมาะสมพร้อมหมวดและรายละเอียด (note: plagiarism, wrong_course, low_quality, copyright, other / review: spam, offensive, fake_review, other / seller: scam, harassment, impersonation, other) รายงานสิ่งเดิมซ้ำไม่ได้จนกว่ารายงานเดิมจะถูกปิด
+// @Tags reports
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body ReportInput true "สิ่งที่รายงานและเหตุผล"
+// @Success 201 {object} map[string]interface{} "รายงานที่สร้าง"
+// @Failure 400 {object} map[string]string "Invalid input"
+// @Failure 401 {object} map[string]string "Unauthorized"
+// @Failure 404 {object} map[string]string "Report target not found"
+// @Failure 409 {object} map[string]string "You have already reported this"
+// @Failure 500 {object} map[string]string "Database error"
+// @Router /api/reports [post]
+func CreateReport(c *gin.Context) {
+	var input ReportInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format"})
+		return
+	}
+	if err := input.validate(); err != nil {
+		respondReportError(c, err)
+		return
+	}
+
+	userID := c.GetInt("user_id")
+	ownerID, err := reportTargetOwner(config.DB, input.TargetType, input.TargetID)
+	if errors.Is(err, errReportTargetNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Report target not found"})
+		return
+	}
+	if err != nil {
+		respondReportError(c, err)
+		return
+	}
+	if ownerID == userID {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "You cannot report your own content"})
+		return
+	}
+
+	// unique index กันการรายงานสิ่งเดิมซ้ำระหว่างที่รายงานเดิมยังไม่ปิด
+	var reportID int
+	err = config.DB.QueryRow(`
+		INSERT INTO reports (reporter_id, target_type, target_id, target_user_id, category, details, status, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, NOW(), NOW())
+		RETURNING id
+	`, userID, input.TargetType, input.TargetID, ownerID, input.Category, input.Details, ReportOpen).Scan(&reportID)
+	if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23505" {
+		c.JSON(http.StatusConflict, gin.H{"error": "You have already reported this"})
+		return
+	}
+	if err != nil {
+		respondReportError(c, err)
+		return
+	}
+
+	report, err := getReport(config.DB, reportID)
+	if err != nil {
+		respondReportError(c, err)
+		return
+	}
+
+	events.Publish(events.AdminTopic, events.ReportCreated, gin.H{
+		"report_id":   report.ID,
+		"target_type": report.TargetType,
+		"target_id":   report.TargetID,
+		"category":    report.Category,
+	})
+
+	c.JSON(http.StatusCreated, gin.H{
+		"success": true,
+		"message": "Report submitted successfully",
+		"data":    report,
+	})
+}
+
+// GetMyReports godoc
+// @Summary ดึงรายงานที่ตัวเองส่ง
+// @Description ดึงรายงานที่ user ส่งพร้อมสถานะและผลการตรวจ (ใหม่สุดก่อน)
+// @Tags reports
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {array} Report "รายการรายงาน"
+// @Failure 401 {object} map[string]string "Unauthorized"
+// @Failure 500 {object} map[string]string "Database error"
+// @Router /api/reports/mine [get]
+func GetMyReports(c *gin.Context) {
+	rows, err := config.DB.Query(reportSelect+`
+		WHERE r.reporter_id = $1
+		ORDER BY r.created_at DESC, r.id DESC
+	`, c.GetInt("user_id"))
+	if err != nil {
+		respondReportError(c, err)
+		return
+	}
+	defer rows.Close()
+
+	reports := []Report{}
+	for rows.Next() {
+		report, err := scanReport(rows)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error scanning report"})
+			return
+		}
+		reports = append(reports, *report)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    reports,
+	})
+}
@@ -0,0 +1,339 @@
+package handlers
+
+import (
+	"back-end/config"
+	"back-end/events"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/gin-gonic/gin"
+)
+
+var (
+	// errReviewNotPurchased - รีวิวได้เฉพาะคนที่ซื้อ note แล้ว
+	errReviewNotPurchased = errors.New("only buyers of this note can review it")
+	// errReviewExists - รีวิวได้ครั้งเดียวต่อการซื้อ (แก้ไขได้ภายหลัง)
+	errReviewExists = errors.New("you have already reviewed this note")
+)
+
+// ReviewInput - ข้อมูลสำหรับเขียน/แก้ไขรีวิว (แก้ไขส่งมาเฉพาะ field ที่ต้องการเปลี่ยน)
+type ReviewInput struct {
+	Rating *int    `json:"rating" example:"5"`
+	Review *string `json:"review" example:"สรุปดีมาก อ่านแล้วเข้าใจง่าย"`
+}
+
+// ReviewEdit - ค่าเดิมของรีวิวก่อนถูกแก้ไขแต่ละครั้ง
+type ReviewEdit struct {
+	Rating     int       `json:"rating"`
+	Review     string    `json:"review"`
+	ReplacedAt time.Time `json:"replaced_at"` // เวลาที่ค่านี้ถูกแทนด้วยค่าใหม่
+}
+
+// validate - ตรวจคะแนนและความยาวข้อความ (partial = แก้ไข)
+func (in *ReviewInput) validate(partial bool) error {
+	if in.Rating == nil && !partial {
+		return &NoteFieldError{Field: "rating", Message: "is required"}
+	}
+	if in.Rating != nil && (*in.Rating < minReviewRating || *in.Rating > maxReviewRating) {
+		return &NoteFieldError{Field: "rating", Message: fmt.Sprintf("must be between %d and %d", minReviewRating, maxReviewRating)}
+	}
+	if in.Review != nil {
+		text := strings.TrimSpace(*in.Review)
+		if utf8.RuneCountInString(text) > maxReviewLength {
+			return &NoteFieldError{Field: "review", Message: fmt.Sprintf("must be at most %d characters", maxReviewLength)}
+		}
+		in.Review = &text
+	}
+	return nil
+}
+
+// createReview - บันทึกรีวิวของการซื้อหนึ่งครั้ง พร้อมแจ้ง seller (purchaseWhere ใช้ alias bn และ $2)
+func createReview(userID int, purchaseWhere string, arg interface{}, in ReviewInput) (*Review, error) {
+	tx, err := config.DB.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var buyedNoteID, noteID, sellerID int
+	var title string
+	err = tx.QueryRow(`
+		SELECT bn.id, n.id, n.seller_id, n.book_title
+		FROM buyed_note bn JOIN notes_for_sale n ON bn.note_id = n.id
+		WHERE bn.user_id = $1 AND `+purchaseWhere, userID, arg).Scan(&buyedNoteID, &noteID, &sellerID, &title)
+	if err == sql.ErrNoRows {
+		return nil, errReviewNotPurchased
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	body := ""
+	if in.Review != nil {
+		body = *in.Review
+	}
+	var reviewID int
+	err = tx.QueryRow(`
+		INSERT INTO reviews (buyed_note_id, buyer_id, note_id, rating, body, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, NOW(), NOW())
+		ON CONFLICT (buyed_note_id) DO NOTHING
+		RETURNING id
+	`, buyedNoteID, userID, noteID, *in.Rating, body).Scan(&reviewID)
+	if err == sql.ErrNoRows {
+		return nil, errReviewExists
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// แจ้ง seller และ webhook (ถูกบันทึกพร้อมรีวิว)
+	data := map[string]interface{}{"review_id": reviewID, "note_id": noteID, "rating": *in.Rating, "review": body}
+	if err := emitWebhook(tx, WebhookReviewCreated, sellerID, data); err != nil {
+		return nil, err
+	}
+	err = notify(tx, sellerID, NotifyReviewReceived, "New review on your note",
+		fmt.Sprintf("Someone rated %s %d/%d", title, *in.Rating, maxReviewRating),
+		map[string]interface{}{"review_id": reviewID, "note_id": noteID, "rating": *in.Rating})
+	if err != nil {
+		return nil, err
+	}
+
+	review, err := getReview(tx, reviewID)
+	if err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	events.PublishToUser(sellerID, events.ReviewCreated, gin.H{
+		"review_id":  reviewID,
+		"note_id":    noteID,
+		"book_title": title,
+		"rating":     review.Rating,
+	})
+	return review, nil
+}
+
+// respondReviewError - ตอบกลับ error จากการเขียน/แก้ไขรีวิว
+func respondReviewError(c *gin.Context, err error) {
+	var fieldErr *NoteFieldError
+	switch {
+	case errors.As(err, &fieldErr):
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid input",
+			"field":   fieldErr.Field,
+			"message": fieldErr.Message,
+		})
+	case errors.Is(err, errReviewNotPurchased):
+		c.JSON(http.StatusForbidden, gin.H{"error": "Only buyers of this note can review it"})
+	case errors.Is(err, errReviewExists):
+		c.JSON(http.StatusConflict, gin.H{"error": "You have already reviewed this note"})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Database error",
+			"message": err.Error(),
+		})
+	}
+}
+
+// CreateNoteReview godoc
+// @Summary เขียนรีวิวหนังสือ/โน้ต
+// @Description ให้คะแนน 1-5 ดาวพร้อมข้อความ (ไม่บังคับ) ได้เฉพาะคนที่ซื้อแล้ว และรีวิวได้ครั้งเดียวต่อการซื้อ (แก้ไขได้ภายหลัง)
+// @Tags reviews
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Note ID"
+// @Param request body ReviewInput true "คะแนนและข้อความรีวิว"
+// @Success 201 {object} map[string]interface{} "รีวิวที่สร้าง"
+// @Failure 400 {object} map[string]string "Invalid input"
+// @Failure 401 {object} map[string]string "Unauthorized"
+// @Failure 403 {object} map[string]string "Only buyers of this note can review it"
+// @Failure 409 {object} map[string]string "You have already reviewed this note"
+// @Failure 500 {object} map[string]string "Database error"
+// @Router /api/notes/{id}/reviews [post]
+func CreateNoteReview(c *gin.Context) {
+	noteID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid note ID"})
+		return
+	}
+
+	var input ReviewInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format"})
+		return
+	}
+	if err := input.validate(false); err != nil {
+		respondReviewError(c, err)
+		return
+	}
+
+	review, err := createReview(c.GetInt("user_id"), `bn.note_id = $2`, noteID, input)
+	if err != nil {
+		respondReviewError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"success": true,
+		"message": "Review created successfully",
+		"data":    review,
+	})
+}
+
+// UpdateMyReview godoc
+// @Summary แก้ไขรีวิวของตัวเอง
+// @Description แก้ไขคะแนนและ/หรือข้อความ ค่าเดิมจะถูกเก็บไว้ในประวัติการแก้ไข
+// @Tags reviews
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Review ID"
+// @Param request body ReviewInput true "field ที่ต้องการเปลี่ยน"
+// @Success 200 {object} map[string]interface{} "รีวิวหลังแก้ไข"
+// @Failure 400 {object} map[string]string "Invalid input"
+// @Failure 401 {object} map[string]string "Unauthorized"
+// @Failure 404 {object} map[string]string "Review not found"
+// @Failure 500 {object} map[string]string "Database error"
+// @Router /api/reviews/{id} [put]
+func UpdateMyReview(c *gin.Context) {
+	reviewID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid review ID"})
+		return
+	}
+
+	var input ReviewInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format"})
+		return
+	}
+	if err := input.validate(true); err != nil {
+		respondReviewError(c, err)
+		return
+	}
+
+	tx, err := config.DB.Begin()
+	if err != nil {
+		respondReviewError(c, err)
+		return
+	}
+	defer tx.Rollback()
+
+	var rating int
+	var body string
+	err = tx.QueryRow(`
+		SELECT rating, body FROM reviews WHERE id = $1 AND buyer_id = $2 FOR UPDATE
+	`, reviewID, c.GetInt("user_id")).Scan(&rating, &body)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Review not found"})
+		return
+	}
+	if err != nil {
+		respondReviewError(c, err)
+		return
+	}
+
+	newRating, newBody := rating, body
+	if input.Rating != nil {
+		newRating = *input.Rating
+	}
+	if input.Review != nil {
+		newBody = *input.Review
+	}
+
+	// บันทึกค่าเดิมไว้ในประวัติเฉพาะเมื่อมีการเปลี่ยนจริง
+	if newRating != rating || newBody != body {
+		_, err = tx.Exec(`
+			INSERT INTO review_edits (review_id, rating, body, replaced_at) VALUES ($1, $2, $3, NOW())
+		`, reviewID, rating, body)
+		if err != nil {
+			respondReviewError(c, err)
+			return
+		}
+		_, err = tx.Exec(`
+			UPDATE reviews SET rating = $1, body = $2, edited_at = NOW(), updated_at = NOW() WHERE id = $3
+		`, newRating, newBody, reviewID)
+		if err != nil {
+			respondReviewError(c, err)
+			return
+		}
+	}
+
+	review, err := getReview(tx, reviewID)
+	if err != nil {
+		respondReviewError(c, err)
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		respondReviewError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "Review updated successfully",
+		"data":    review,
+	})
+}
+
+// GetReviewHistory godoc
+// @Summary ดึงประวัติการแก้ไขรีวิว
+// @Description ดึงรีวิวปัจจุบันพร้อมค่าเดิมก่อนแก้ไขแต่ละครั้ง (เก่าสุดก่อน)
+// @Tags reviews
+// @Produce json
+// @Param id path int true "Review ID"
+// @Success 200 {object} map[string]interface{} "รีวิวปัจจุบันและประวัติการแก้ไข"
+// @Failure 400 {object} map[string]string "Invalid review ID"
+// @Failure 404 {object} map[string]string "Review not found"
+// @Failure 500 {object} map[string]string "Database error"
+// @Router /api/reviews/{id}/history [get]
+func GetReviewHistory(c *gin.Context) {
+	reviewID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid review ID"})
+		return
+	}
+
+	review, err := getReview(config.DB, reviewID)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Review not found"})
+		return
+	}
+	if err != nil {
+		respondReviewError(c, err)
+		return
+	}
+
+	rows, err := config.DB.Query(`
+		SELECT rating, body, replaced_at FROM review_edits WHERE review_id = $1 ORDER BY replaced_at, id
+	`, reviewID)
+	if err != nil {
+		respondReviewError(c, err)
+		return
+	}
+	defer rows.Close()
+
+	history := []ReviewEdit{}
+	for rows.Next() {
+		var edit ReviewEdit
+		if err := rows.Scan(&edit.Rating, &edit.Review, &edit.ReplacedAt); err != nil {
+			respondReviewError(c, err)
+			return
+		}
+		history = append(history, edit)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"review":  review,
+		"history": history,
+	})
+}
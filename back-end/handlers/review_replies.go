@@ -0,0 +1,277 @@
+package handlers
+
+import (
+	"back-end/config"
+	"back-end/events"
+	"database/sql"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ReviewReplyInput - ข้อมูลสำหรับตอบรีวิว
+type ReviewReplyInput struct {
+	Reply string `json:"reply" binding:"required" example:"ขอบคุณที่รีวิวครับ เล่มถัดไปจะเพิ่มแบบฝึกหัดให้"`
+}
+
+// ReplyToReview godoc
+// @Summary ตอบรีวิว (seller)
+// @Description seller ตอบรีวิวบน note ของตัวเองได้ 1 ครั้งต่อรีวิว เรียกซ้ำเพื่อแก้ไขคำตอบ (ผู้รีวิวจะได้รับแจ้งเตือนเมื่อตอบครั้งแรก)
+// @Tags reviews
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Review ID"
+// @Param request body ReviewReplyInput true "ข้อความตอบกลับ"
+// @Success 200 {object} map[string]interface{} "แก้ไขคำตอบแล้ว"
+// @Success 201 {object} map[string]interface{} "ตอบรีวิวแล้ว"
+// @Failure 400 {object} map[string]string "Invalid input"
+// @Failure 401 {object} map[string]string "Unauthorized"
+// @Failure 403 {object} map[string]string "You can only reply to reviews of your own notes"
+// @Failure 404 {object} map[string]string "Review not found"
+// @Failure 500 {object} map[string]string "Database error"
+// @Router /api/seller/reviews/{id}/reply [put]
+func ReplyToReview(c *gin.Context) {
+	reviewID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid review ID"})
+		return
+	}
+
+	var input ReviewReplyInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format"})
+		return
+	}
+	reply := strings.TrimSpace(input.Reply)
+	if reply == "" {
+		respondReviewError(c, &NoteFieldError{Field: "reply", Message: "is required"})
+		return
+	}
+	if utf8.RuneCountInString(reply) > maxReplyLength {
+		respondReviewError(c, &NoteFieldError{Field: "reply", Message: fmt.Sprintf("must be at most %d characters", maxReplyLength)})
+		return
+	}
+
+	userID := c.GetInt("user_id")
+	tx, err := config.DB.Begin()
+	if err != nil {
+		respondReviewError(c, err)
+		return
+	}
+	defer tx.Rollback()
+
+	// lock รีวิวไว้กันการตอบพร้อมกันสองครั้ง
+	var buyerID, noteID, sellerID int
+	var title string
+	err = tx.QueryRow(`
+		SELECT r.buyer_id, r.note_id, nfs.seller_id, nfs.book_title
+		FROM reviews r
+		JOIN notes_for_sale nfs ON r.note_id = nfs.id
+		WHERE r.id = $1
+		FOR UPDATE OF r
+	`, reviewID).Scan(&buyerID, &noteID, &sellerID, &title)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Review not found"})
+		return
+	}
+	if err != nil {
+		respondReviewError(c, err)
+		return
+	}
+	if sellerID != userID {
+		c.JSON(http.StatusForbidden, gin.H{"error": "You can only reply to reviews of your own notes"})
+		return
+	}
+
+	var current string
+	err = tx.QueryRow(`SELECT body FROM review_replies WHERE review_id = $1`, reviewID).Scan(&current)
+	created := err == sql.ErrNoRows
+	switch {
+	case created:
+		_, err = tx.Exec(`
+			INSERT INTO review_replies (review_id, seller_id, body, created_at, updated_at)
+			VALUES ($1, $2, $3, NOW(), NOW())
+		`, reviewID, userID, reply)
+		if err == nil {
+			err = notify(tx, buyerID, NotifyReviewReply, "The seller replied to your review",
+				fmt.Sprintf("The seller of %s replied to your review", title),
+				map[string]interface{}{"review_id": reviewID, "note_id": noteID})
+		}
+	case err == nil && current != reply:
+		_, err = tx.Exec(`
+			UPDATE review_replies SET body = $1, edited_at = NOW(), updated_at = NOW() WHERE review_id = $2
+		`, reply, reviewID)
+	}
+	if err != nil {
+		respondReviewError(c, err)
+		return
+	}
+
+	review, err := getReview(tx, reviewID)
+	if err != nil {
+		respondReviewError(c, err)
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		respondReviewError(c, err)
+		return
+	}
+
+	if created {
+		events.PublishToUser(buyerID, events.ReviewReplied, gin.H{
+			"review_id":  reviewID,
+			"note_id":    noteID,
+			"book_title": title,
+		})
+		c.JSON(http.StatusCreated, gin.H{
+			"success": true,
+			"message": "Reply posted successfully",
+			"data":    review,
+		})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "Reply updated successfully",
+		"data":    review,
+	})
+}
+
+// DeleteReviewReply godoc
+// @Summary ลบคำตอบรีวิว (seller)
+// @Description ลบคำตอบของตัวเองออกจากรีวิว (ตอบใหม่ได้ภายหลัง)
+// @Tags reviews
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Review ID"
+// @Success 200 {object} map[string]interface{} "ลบคำตอบแล้ว"
+// @Failure 400 {object} map[string]string "Invalid review ID"
+// @Failure 401 {object} map[string]string "Unauthorized"
+// @Failure 404 {object} map[string]string "Reply not found"
+// @Failure 500 {object} map[string]string "Database error"
+// @Router /api/seller/reviews/{id}/reply [delete]
+func DeleteReviewReply(c *gin.Context) {
+	reviewID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid review ID"})
+		return
+	}
+
+	result, err := config.DB.Exec(`
+		DELETE FROM review_replies WHERE review_id = $1 AND seller_id = $2
+	`, reviewID, c.GetInt("user_id"))
+	if err != nil {
+		respondReviewError(c, err)
+		return
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Reply not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "Reply deleted successfully",
+	})
+}
+
+// setHelpfulVote - กด/ยกเลิกว่ารีวิวมีประโยชน์ (1 เสียงต่อ user ต่อรีวิว กดซ้ำไม่นับเพิ่ม)
+func setHelpfulVote(c *gin.Context, helpful bool) {
+	reviewID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid review ID"})
+		return
+	}
+	userID := c.GetInt("user_id")
+
+	var buyerID, sellerID int
+	err = config.DB.QueryRow(`
+		SELECT r.buyer_id, nfs.seller_id
+		FROM reviews r
+		JOIN notes_for_sale nfs ON r.note_id = nfs.id
+		WHERE r.id = $1
+	`, reviewID).Scan(&buyerID, &sellerID)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Review not found"})
+		return
+	}
+	if err != nil {
+		respondReviewError(c, err)
+		return
+	}
+
+	if helpful {
+		// ผู้รีวิวและ seller ของ note โหวตรีวิวนี้ไม่ได้ กันการดันรีวิวตัวเอง
+		if buyerID == userID || sellerID == userID {
+			c.JSON(http.StatusForbidden, gin.H{"error": "You cannot vote on this review"})
+			return
+		}
+		_, err = config.DB.Exec(`
+			INSERT INTO review_votes (review_id, user_id, created_at) VALUES ($1, $2, NOW())
+			ON CONFLICT (review_id, user_id) DO NOTHING
+		`, reviewID, userID)
+	} else {
+		_, err = config.DB.Exec(`
+			DELETE FROM review_votes WHERE review_id = $1 AND user_id = $2
+		`, reviewID, userID)
+	}
+	if err != nil {
+		respondReviewError(c, err)
+		return
+	}
+
+	var count int
+	if err := config.DB.QueryRow(`
+		SELECT COUNT(*) FROM review_votes WHERE review_id = $1
+	`, reviewID).Scan(&count); err != nil {
+		respondReviewError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success":       true,
+		"review_id":     reviewID,
+		"helpful_count": count,
+		"voted_helpful": helpful,
+	})
+}
+
+// VoteReviewHelpful godoc
+// @Summary กดว่ารีวิวมีประโยชน์
+// @Description โหวตได้ 1 ครั้งต่อรีวิว (กดซ้ำไม่นับเพิ่ม) ผู้เขียนรีวิวและ seller ของ note โหวตไม่ได้
+// @Tags reviews
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Review ID"
+// @Success 200 {object} map[string]interface{} "จำนวนโหวตล่าสุด"
+// @Failure 400 {object} map[string]string "Invalid review ID"
+// @Failure 401 {object} map[string]string "Unauthorized"
+// @Failure 403 {object} map[string]string "You cannot vote on this review"
+// @Failure 404 {object} map[string]string "Review not found"
+// @Failure 500 {object} map[string]string "Database error"
+// @Router /api/reviews/{id}/helpful [post]
+func VoteReviewHelpful(c *gin.Context) {
+	setHelpfulVote(c, true)
+}
+
+// UnvoteReviewHelpful godoc
+// @Summary ยกเลิกโหวตว่ารีวิวมีประโยชน์
+// @Description ยกเลิกโหวตของตัวเอง (ถ้ายังไม่เคยโหวตก็สำเร็จเหมือนกัน)
+// @Tags reviews
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Review ID"
+// @Success 200 {object} map[string]interface{} "จำนวนโหวตล่าสุด"
+// @Failure 400 {object} map[string]string "Invalid review ID"
+// @Failure 401 {object} map[string]string "Unauthorized"
+// @Failure 404 {object} map[string]string "Review not found"
+// @Failure 500 {object} map[string]string "Database error"
+// @Router /api/reviews/{id}/helpful [delete]
+func UnvoteReviewHelpful(c *gin.Context) {
+	setHelpfulVote(c, false)
+}
@@ -2,79 +2,175 @@ package handlers
 
 import (
 	"back-end/config"
+	"database/sql"
+	"math"
 	"net/http"
 	"strconv"
 
 	"github.com/gin-gonic/gin"
+	"github.com/lib/pq"
 )
 
+// ช่วงคะแนนรีวิว
+const (
+	minReviewRating      = 1
+	maxReviewRating      = 5
+	likedReviewRating    = 4 // ตั้งแต่ 4 ดาวขึ้นไปนับเป็น "ชอบ" (ตรงกับ liked_count ใน get_notes.go)
+	dislikedReviewRating = 2 // ตั้งแต่ 2 ดาวลงมานับเป็น "ไม่ชอบ"
+	maxReviewLength      = 2000
+	maxReplyLength       = 2000
+)
+
+// ReviewReply - คำตอบของ seller ต่อรีวิว (1 คำตอบต่อรีวิว แก้ไขได้)
+type ReviewReply struct {
+	SellerID   int     `json:"seller_id"`
+	SellerName string  `json:"seller_name"`
+	Reply      string  `json:"reply"`
+	IsEdited   bool    `json:"is_edited"`
+	CreatedAt  string  `json:"created_at" example:"01/01/2025 12:00"`
+	EditedAt   *string `json:"edited_at" example:"02/01/2025 08:00"`
+}
+
 // Review - โครงสร้างข้อมูลรีวิว
 type Review struct {
-	ID            int     `json:"id"`
-	BuyerID       int     `json:"buyer_id"`
-	BuyerName     string  `json:"buyer_name"`
-	BuyerAvatar   *string `json:"buyer_avatar"`
-	NoteID        int     `json:"note_id"`
-	NoteTitle     string  `json:"note_title"`
-	CourseCode    *string `json:"course_code"`
-	CourseName    *string `json:"course_name"`
-	Review        string  `json:"review"`
-	IsLiked       *bool   `json:"is_liked"`
-	CreatedAt     string  `json:"created_at"`
+	ID               int          `json:"id"`
+	BuyerID          int          `json:"buyer_id"`
+	BuyerName        string       `json:"buyer_name"`
+	BuyerAvatar      string       `json:"buyer_avatar"`
+	NoteID           int          `json:"note_id"`
+	NoteTitle        string       `json:"note_title"`
+	CourseCode       string       `json:"course_code"`
+	CourseName       string       `json:"course_name"`
+	Rating           int          `json:"rating" example:"5"`
+	Review           string       `json:"review"`
+	IsLiked          bool         `json:"is_liked"`          // rating >= 4 (คงไว้ให้ client เดิม)
+	VerifiedPurchase bool         `json:"verified_purchase"` // รีวิวได้เฉพาะคนที่ซื้อแล้ว จึงเป็น true เสมอ
+	IsEdited         bool         `json:"is_edited"`
+	CreatedAt        string       `json:"created_at" example:"01/01/2025 10:30"`
+	EditedAt         *string      `json:"edited_at" example:"02/01/2025 08:00"`
+	HelpfulCount     int          `json:"helpful_count"`
+	VotedHelpful     bool         `json:"voted_helpful"` // user ที่ login อยู่กดว่ามีประโยชน์แล้ว
+	Reply            *ReviewReply `json:"reply"`         // null = seller ยังไม่ได้ตอบ
 }
 
 // ReviewStats - สถิติรีวิว
 type ReviewStats struct {
-	TotalReviews    int     `json:"total_reviews"`
-	LikedCount      int     `json:"liked_count"`
-	DislikedCount   int     `json:"disliked_count"`
-	LikedPercent    float64 `json:"liked_percent"`
-	DislikedPercent float64 `json:"disliked_percent"`
+	TotalReviews       int         `json:"total_reviews"`
+	AverageRating      float64     `json:"average_rating" example:"4.25"`
+	RatingDistribution map[int]int `json:"rating_distribution"` // จำนวนรีวิวของแต่ละดาว (1-5)
+	LikedCount         int         `json:"liked_count"`
+	DislikedCount      int         `json:"disliked_count"`
+	LikedPercent       float64     `json:"liked_percent"`
+	DislikedPercent    float64     `json:"disliked_percent"`
 }
 
-// GetSellerReviews godoc
-// @Summary ดึงรีวิวของ seller
-// @Description ดึงรายการรีวิวทั้งหมดของสินค้าที่ seller คนนั้นขาย
-// @Tags reviews
-// @Accept json
-// @Produce json
-// @Param id path int true "Seller ID"
-// @Success 200 {array} Review "รายการรีวิว"
-// @Failure 400 {object} map[string]string "Invalid seller ID"
-// @Failure 500 {object} map[string]string "Database error"
-// @Router /api/sellers/{id}/reviews [get]
-func GetSellerReviews(c *gin.Context) {
-	sellerIDStr := c.Param("id")
-	sellerID, err := strconv.Atoi(sellerIDStr)
+// reviewSelect - คอลัมน์ของ Review (ใช้กับ scanReview) ต่อท้ายด้วย WHERE
+const reviewSelect = `
+	SELECT
+		r.id, r.buyer_id, u.username, COALESCE(u.avatar_url, ''),
+		r.note_id, nfs.book_title, COALESCE(c.code, ''), COALESCE(c.name, ''),
+		r.rating, r.body,
+		TO_CHAR(r.created_at, 'DD/MM/YYYY HH24:MI'),
+		TO_CHAR(r.edited_at, 'DD/MM/YYYY HH24:MI'),
+		(SELECT COUNT(*) FROM review_votes rv WHERE rv.review_id = r.id) as helpful_count,
+		rr.seller_id, COALESCE(su.username, ''), rr.body,
+		TO_CHAR(rr.created_at, 'DD/MM/YYYY HH24:MI'),
+		TO_CHAR(rr.edited_at, 'DD/MM/YYYY HH24:MI')
+	FROM reviews r
+	INNER JOIN users u ON r.buyer_id = u.id
+	INNER JOIN notes_for_sale nfs ON r.note_id = nfs.id
+	LEFT JOIN courses c ON nfs.course_id = c.id
+	LEFT JOIN review_replies rr ON rr.review_id = r.id
+	LEFT JOIN users su ON rr.seller_id = su.id
+`
+
+func scanReview(row interface{ Scan(...interface{}) error }) (*Review, error) {
+	var review Review
+	var replySellerID sql.NullInt64
+	var replySellerName string
+	var replyBody, replyCreatedAt, replyEditedAt *string
+	err := row.Scan(
+		&review.ID, &review.BuyerID, &review.BuyerName, &review.BuyerAvatar,
+		&review.NoteID, &review.NoteTitle, &review.CourseCode, &review.CourseName,
+		&review.Rating, &review.Review,
+		&review.CreatedAt, &review.EditedAt,
+		&review.HelpfulCount,
+		&replySellerID, &replySellerName, &replyBody, &replyCreatedAt, &replyEditedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	if replySellerID.Valid {
+		review.Reply = &ReviewReply{
+			SellerID:   int(replySellerID.Int64),
+			SellerName: replySellerName,
+			Reply:      *replyBody,
+			IsEdited:   replyEditedAt != nil,
+			CreatedAt:  *replyCreatedAt,
+			EditedAt:   replyEditedAt,
+		}
+	}
+	review.IsLiked = review.Rating >= likedReviewRating
+	review.VerifiedPurchase = true
+	review.IsEdited = review.EditedAt != nil
+	return &review, nil
+}
+
+// getReview - ดึงรีวิวเดียวตาม ID
+func getReview(q sqlQuerier, reviewID int) (*Review, error) {
+	return scanReview(q.QueryRow(reviewSelect+` WHERE r.id = $1`, reviewID))
+}
+
+// applyReviewVotes - ใส่ว่า user ที่ login อยู่กดว่ามีประโยชน์รีวิวไหนไปแล้วบ้าง
+func applyReviewVotes(c *gin.Context, reviews []Review) {
+	viewer := viewerID(c)
+	if viewer == 0 || len(reviews) == 0 {
+		return
+	}
+	reviewIDs := make([]int, len(reviews))
+	for i := range reviews {
+		reviewIDs[i] = reviews[i].ID
+	}
+
+	rows, err := config.DB.Query(`
+		SELECT review_id FROM review_votes WHERE user_id = $1 AND review_id = ANY($2)
+	`, viewer, pq.Array(reviewIDs))
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid seller ID"})
 		return
 	}
+	defer rows.Close()
 
-	query := `
-		SELECT 
-			bn.id,
-			bn.user_id as buyer_id,
-			u.username as buyer_name,
-			u.avatar_url as buyer_avatar,
-			bn.note_id,
-			nfs.book_title as note_title,
-			c.code as course_code,
-			c.name as course_name,
-			bn.review,
-			bn.is_liked,
-			TO_CHAR(nfs.created_at, 'DD/MM/YYYY HH24:MI') as created_at
-		FROM buyed_note bn
-		INNER JOIN users u ON bn.user_id = u.id
-		INNER JOIN notes_for_sale nfs ON bn.note_id = nfs.id
-		LEFT JOIN courses c ON nfs.course_id = c.id
-		WHERE nfs.seller_id = $1
-		AND bn.review IS NOT NULL
-		AND bn.review != ''
-		ORDER BY nfs.created_at DESC
-	`
+	voted := map[int]bool{}
+	for rows.Next() {
+		var reviewID int
+		if err := rows.Scan(&reviewID); err == nil {
+			voted[reviewID] = true
+		}
+	}
+	for i := range reviews {
+		reviews[i].VotedHelpful = voted[reviews[i].ID]
+	}
+}
+
+// listReviews - ตอบกลับรายการรีวิวตามเงื่อนไข (filter ?rating= และเรียง ?sort=newest|helpful ได้)
+func listReviews(c *gin.Context, where string, id int) {
+	query := reviewSelect + ` WHERE ` + where
+	args := []interface{}{id}
+	if rating, err := strconv.Atoi(c.Query("rating")); err == nil {
+		query += ` AND r.rating = $2`
+		args = append(args, rating)
+	}
+	switch c.DefaultQuery("sort", "newest") {
+	case "newest":
+		query += ` ORDER BY r.created_at DESC, r.id DESC`
+	case "helpful":
+		query += ` ORDER BY helpful_count DESC, r.created_at DESC, r.id DESC`
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid sort, must be newest or helpful"})
+		return
+	}
 
-	rows, err := config.DB.Query(query, sellerID)
+	rows, err := config.DB.Query(query, args...)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"error":   "Database error",
@@ -84,22 +180,9 @@ func GetSellerReviews(c *gin.Context) {
 	}
 	defer rows.Close()
 
-	var reviews []map[string]interface{}
+	reviews := []Review{}
 	for rows.Next() {
-		var review Review
-		err := rows.Scan(
-			&review.ID,
-			&review.BuyerID,
-			&review.BuyerName,
-			&review.BuyerAvatar,
-			&review.NoteID,
-			&review.NoteTitle,
-			&review.CourseCode,
-			&review.CourseName,
-			&review.Review,
-			&review.IsLiked,
-			&review.CreatedAt,
-		)
+		review, err := scanReview(rows)
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{
 				"error":   "Error scanning review",
@@ -107,55 +190,92 @@ func GetSellerReviews(c *gin.Context) {
 			})
 			return
 		}
+		reviews = append(reviews, *review)
+	}
+	applyReviewVotes(c, reviews)
 
-		// แปลงข้อมูลเป็น map เพื่อจัดการ NULL values
-		reviewMap := map[string]interface{}{
-			"id":         review.ID,
-			"buyer_id":   review.BuyerID,
-			"buyer_name": review.BuyerName,
-			"note_id":    review.NoteID,
-			"note_title": review.NoteTitle,
-			"review":     review.Review,
-			"created_at": review.CreatedAt,
-		}
-
-		if review.BuyerAvatar != nil {
-			reviewMap["buyer_avatar"] = *review.BuyerAvatar
-		} else {
-			reviewMap["buyer_avatar"] = ""
-		}
-
-		if review.CourseCode != nil {
-			reviewMap["course_code"] = *review.CourseCode
-		} else {
-			reviewMap["course_code"] = ""
-		}
+	c.JSON(http.StatusOK, gin.H{"reviews": reviews})
+}
 
-		if review.CourseName != nil {
-			reviewMap["course_name"] = *review.CourseName
-		} else {
-			reviewMap["course_name"] = ""
-		}
+// loadReviewStats - คำนวณสถิติรีวิวตามเงื่อนไข (alias r = reviews, nfs = notes_for_sale)
+func loadReviewStats(where string, id int) (*ReviewStats, error) {
+	stats := ReviewStats{RatingDistribution: map[int]int{}}
+	var average float64
+	var stars [maxReviewRating + 1]int
+	err := config.DB.QueryRow(`
+		SELECT
+			COUNT(*),
+			COALESCE(AVG(r.rating), 0),
+			COUNT(*) FILTER (WHERE r.rating >= $2),
+			COUNT(*) FILTER (WHERE r.rating <= $3),
+			COUNT(*) FILTER (WHERE r.rating = 1),
+			COUNT(*) FILTER (WHERE r.rating = 2),
+			COUNT(*) FILTER (WHERE r.rating = 3),
+			COUNT(*) FILTER (WHERE r.rating = 4),
+			COUNT(*) FILTER (WHERE r.rating = 5)
+		FROM reviews r
+		INNER JOIN notes_for_sale nfs ON r.note_id = nfs.id
+		WHERE `+where,
+		id, likedReviewRating, dislikedReviewRating,
+	).Scan(
+		&stats.TotalReviews, &average, &stats.LikedCount, &stats.DislikedCount,
+		&stars[1], &stars[2], &stars[3], &stars[4], &stars[5],
+	)
+	if err != nil {
+		return nil, err
+	}
 
-		if review.IsLiked != nil {
-			reviewMap["is_liked"] = *review.IsLiked
-		} else {
-			reviewMap["is_liked"] = nil
-		}
+	for rating := minReviewRating; rating <= maxReviewRating; rating++ {
+		stats.RatingDistribution[rating] = stars[rating]
+	}
+	stats.AverageRating = math.Round(average*100) / 100
 
-		reviews = append(reviews, reviewMap)
+	// คำนวณเปอร์เซ็นต์
+	if stats.TotalReviews > 0 {
+		stats.LikedPercent = (float64(stats.LikedCount) / float64(stats.TotalReviews)) * 100
+		stats.DislikedPercent = (float64(stats.DislikedCount) / float64(stats.TotalReviews)) * 100
 	}
+	return &stats, nil
+}
 
-	if reviews == nil {
-		reviews = []map[string]interface{}{}
+// respondReviewStats - ตอบกลับสถิติรีวิว
+func respondReviewStats(c *gin.Context, where string, id int) {
+	stats, err := loadReviewStats(where, id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Database error",
+			"message": err.Error(),
+		})
+		return
 	}
+	c.JSON(http.StatusOK, stats)
+}
 
-	c.JSON(http.StatusOK, gin.H{"reviews": reviews})
+// GetSellerReviews godoc
+// @Summary ดึงรีวิวของ seller
+// @Description ดึงรายการรีวิวทั้งหมดของสินค้าที่ seller คนนั้นขาย (ใหม่สุดก่อน)
+// @Tags reviews
+// @Accept json
+// @Produce json
+// @Param id path int true "Seller ID"
+// @Param rating query int false "ดึงเฉพาะรีวิวที่ได้คะแนนนี้ (1-5)"
+// @Param sort query string false "เรียงตาม newest (ค่าเริ่มต้น) หรือ helpful (มีประโยชน์มากสุดก่อน)"
+// @Success 200 {array} Review "รายการรีวิว"
+// @Failure 400 {object} map[string]string "Invalid seller ID or sort"
+// @Failure 500 {object} map[string]string "Database error"
+// @Router /api/sellers/{id}/reviews [get]
+func GetSellerReviews(c *gin.Context) {
+	sellerID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid seller ID"})
+		return
+	}
+	listReviews(c, `nfs.seller_id = $1`, sellerID)
 }
 
 // GetSellerReviewStats godoc
 // @Summary ดึงสถิติรีวิวของ seller
-// @Description ดึงสถิติรีวิว (ชอบ/ไม่ชอบ) ของสินค้าที่ seller คนนั้นขาย
+// @Description ดึงสถิติรีวิว (คะแนนเฉลี่ย จำนวนแต่ละดาว และชอบ/ไม่ชอบ) ของสินค้าที่ seller คนนั้นขาย
 // @Tags reviews
 // @Accept json
 // @Produce json
@@ -165,173 +285,39 @@ func GetSellerReviews(c *gin.Context) {
 // @Failure 500 {object} map[string]string "Database error"
 // @Router /api/sellers/{id}/reviews/stats [get]
 func GetSellerReviewStats(c *gin.Context) {
-	sellerIDStr := c.Param("id")
-	sellerID, err := strconv.Atoi(sellerIDStr)
+	sellerID, err := strconv.Atoi(c.Param("id"))
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid seller ID"})
 		return
 	}
-
-	query := `
-		SELECT 
-			COUNT(*) as total_reviews,
-			COUNT(CASE WHEN bn.is_liked = true THEN 1 END) as liked_count,
-			COUNT(CASE WHEN bn.is_liked = false THEN 1 END) as disliked_count
-		FROM buyed_note bn
-		INNER JOIN notes_for_sale nfs ON bn.note_id = nfs.id
-		WHERE nfs.seller_id = $1
-		AND bn.review IS NOT NULL
-		AND bn.review != ''
-	`
-
-	var stats ReviewStats
-	err = config.DB.QueryRow(query, sellerID).Scan(
-		&stats.TotalReviews,
-		&stats.LikedCount,
-		&stats.DislikedCount,
-	)
-
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error":   "Database error",
-			"message": err.Error(),
-		})
-		return
-	}
-
-	// คำนวณเปอร์เซ็นต์
-	if stats.TotalReviews > 0 {
-		stats.LikedPercent = (float64(stats.LikedCount) / float64(stats.TotalReviews)) * 100
-		stats.DislikedPercent = (float64(stats.DislikedCount) / float64(stats.TotalReviews)) * 100
-	}
-
-	c.JSON(http.StatusOK, stats)
+	respondReviewStats(c, `nfs.seller_id = $1`, sellerID)
 }
 
 // GetNoteReviews godoc
 // @Summary ดึงรีวิวของหนังสือ/โน้ต
-// @Description ดึงรายการรีวิวทั้งหมดของหนังสือ/โน้ตเล่มนั้น
+// @Description ดึงรายการรีวิวทั้งหมดของหนังสือ/โน้ตเล่มนั้น (ใหม่สุดก่อน)
 // @Tags reviews
 // @Accept json
 // @Produce json
 // @Param id path int true "Note ID"
+// @Param rating query int false "ดึงเฉพาะรีวิวที่ได้คะแนนนี้ (1-5)"
+// @Param sort query string false "เรียงตาม newest (ค่าเริ่มต้น) หรือ helpful (มีประโยชน์มากสุดก่อน)"
 // @Success 200 {array} Review "รายการรีวิว"
-// @Failure 400 {object} map[string]string "Invalid note ID"
+// @Failure 400 {object} map[string]string "Invalid note ID or sort"
 // @Failure 500 {object} map[string]string "Database error"
 // @Router /api/notes/{id}/reviews [get]
 func GetNoteReviews(c *gin.Context) {
-	noteIDStr := c.Param("id")
-	noteID, err := strconv.Atoi(noteIDStr)
+	noteID, err := strconv.Atoi(c.Param("id"))
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid note ID"})
 		return
 	}
-
-	query := `
-		SELECT 
-			bn.id,
-			bn.user_id as buyer_id,
-			u.username as buyer_name,
-			u.avatar_url as buyer_avatar,
-			bn.note_id,
-			nfs.book_title as note_title,
-			c.code as course_code,
-			c.name as course_name,
-			bn.review,
-			bn.is_liked,
-			TO_CHAR(nfs.created_at, 'DD/MM/YYYY HH24:MI') as created_at
-		FROM buyed_note bn
-		INNER JOIN users u ON bn.user_id = u.id
-		INNER JOIN notes_for_sale nfs ON bn.note_id = nfs.id
-		LEFT JOIN courses c ON nfs.course_id = c.id
-		WHERE bn.note_id = $1
-		AND bn.review IS NOT NULL
-		AND bn.review != ''
-		ORDER BY nfs.created_at DESC
-	`
-
-	rows, err := config.DB.Query(query, noteID)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error":   "Database error",
-			"message": err.Error(),
-		})
-		return
-	}
-	defer rows.Close()
-
-	var reviews []map[string]interface{}
-	for rows.Next() {
-		var review Review
-		err := rows.Scan(
-			&review.ID,
-			&review.BuyerID,
-			&review.BuyerName,
-			&review.BuyerAvatar,
-			&review.NoteID,
-			&review.NoteTitle,
-			&review.CourseCode,
-			&review.CourseName,
-			&review.Review,
-			&review.IsLiked,
-			&review.CreatedAt,
-		)
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{
-				"error":   "Error scanning review",
-				"message": err.Error(),
-			})
-			return
-		}
-
-		// แปลงข้อมูลเป็น map เพื่อจัดการ NULL values
-		reviewMap := map[string]interface{}{
-			"id":         review.ID,
-			"buyer_id":   review.BuyerID,
-			"buyer_name": review.BuyerName,
-			"note_id":    review.NoteID,
-			"note_title": review.NoteTitle,
-			"review":     review.Review,
-			"created_at": review.CreatedAt,
-		}
-
-		if review.BuyerAvatar != nil {
-			reviewMap["buyer_avatar"] = *review.BuyerAvatar
-		} else {
-			reviewMap["buyer_avatar"] = ""
-		}
-
-		if review.CourseCode != nil {
-			reviewMap["course_code"] = *review.CourseCode
-		} else {
-			reviewMap["course_code"] = ""
-		}
-
-		if review.CourseName != nil {
-			reviewMap["course_name"] = *review.CourseName
-		} else {
-			reviewMap["course_name"] = ""
-		}
-
-		if review.IsLiked != nil {
-			reviewMap["is_liked"] = *review.IsLiked
-		} else {
-			reviewMap["is_liked"] = nil
-		}
-
-		reviews = append(reviews, reviewMap)
-	}
-
-	if reviews == nil {
-		reviews = []map[string]interface{}{}
-	}
-
-	c.JSON(http.StatusOK, gin.H{"reviews": reviews})
+	listReviews(c, `r.note_id = $1`, noteID)
 }
 
 // GetNoteReviewStats godoc
 // @Summary ดึงสถิติรีวิวของหนังสือ/โน้ต
-// @Description ดึงสถิติรีวิว (ชอบ/ไม่ชอบ) ของหนังสือ/โน้ตเล่มนั้น
+// @Description ดึงสถิติรีวิว (คะแนนเฉลี่ย จำนวนแต่ละดาว และชอบ/ไม่ชอบ) ของหนังสือ/โน้ตเล่มนั้น
 // @Tags reviews
 // @Accept json
 // @Produce json
@@ -341,44 +327,10 @@ func GetNoteReviews(c *gin.Context) {
 // @Failure 500 {object} map[string]string "Database error"
 // @Router /api/notes/{id}/reviews/stats [get]
 func GetNoteReviewStats(c *gin.Context) {
-	noteIDStr := c.Param("id")
-	noteID, err := strconv.Atoi(noteIDStr)
+	noteID, err := strconv.Atoi(c.Param("id"))
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid note ID"})
 		return
 	}
-
-	query := `
-		SELECT 
-			COUNT(*) as total_reviews,
-			COUNT(CASE WHEN bn.is_liked = true THEN 1 END) as liked_count,
-			COUNT(CASE WHEN bn.is_liked = false THEN 1 END) as disliked_count
-		FROM buyed_note bn
-		WHERE bn.note_id = $1
-		AND bn.review IS NOT NULL
-		AND bn.review != ''
-	`
-
-	var stats ReviewStats
-	err = config.DB.QueryRow(query, noteID).Scan(
-		&stats.TotalReviews,
-		&stats.LikedCount,
-		&stats.DislikedCount,
-	)
-
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error":   "Database error",
-			"message": err.Error(),
-		})
-		return
-	}
-
-	// คำนวณเปอร์เซ็นต์
-	if stats.TotalReviews > 0 {
-		stats.LikedPercent = (float64(stats.LikedCount) / float64(stats.TotalReviews)) * 100
-		stats.DislikedPercent = (float64(stats.DislikedCount) / float64(stats.TotalReviews)) * 100
-	}
-
-	c.JSON(http.StatusOK, stats)
+	respondReviewStats(c, `r.note_id = $1`, noteID)
 }
@@ -0,0 +1,982 @@
+package handlers
+
+import (
+	"back-end/config"
+	"back-end/permissions"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lib/pq"
+)
+
+// builtinRoles - role ที่โค้ดอ้างถึงโดยชื่อ (ลบหรือเปลี่ยนชื่อไม่ได้)
+var builtinRoles = map[string]bool{
+	"user":                true,
+	"seller":              true,
+	permissions.AdminRole: true,
+}
+
+var (
+	roleNamePattern       = regexp.MustCompile(`^[a-z][a-z0-9_]{1,49}$`)
+	permissionNamePattern = regexp.MustCompile(`^[a-z][a-z0-9_]*(\.[a-z][a-z0-9_]*)+$`)
+)
+
+const maxPermissionNameLength = 50
+
+var (
+	errRoleNotFound = errors.New("role not found")
+	errUserNotFound = errors.New("user not found")
+)
+
+// roleForbiddenError - ผู้ใช้ไม่มีสิทธิ์มอบหรือแก้ไขสิทธิ์นี้ (กันการเพิ่มสิทธิ์ให้ตัวเองเกินที่มี)
+type roleForbiddenError struct {
+	Message string
+}
+
+func (e *roleForbiddenError) Error() string {
+	return e.Message
+}
+
+// Role - role พร้อมสิทธิ์ (role admin มีทุกสิทธิ์เสมอ)
+type Role struct {
+	ID          int      `json:"id"`
+	Name        string   `json:"name" example:"moderator"`
+	Description string   `json:"description"`
+	Permissions []string `json:"permissions" example:"notes.view,notes.approve"`
+	UserCount   int      `json:"user_count"`
+	Builtin     bool     `json:"builtin"` // ลบหรือเปลี่ยนชื่อไม่ได้
+}
+
+// Permission - สิทธิ์และ role ที่มีสิทธิ์นี้
+type Permission struct {
+	ID          int      `json:"id"`
+	Name        string   `json:"name" example:"notes.approve"`
+	Description string   `json:"description"`
+	Roles       []string `json:"roles"`
+	Builtin     bool     `json:"builtin"` // สิทธิ์ที่โค้ดใช้ตรวจ (ลบไม่ได้)
+}
+
+// RoleInput - ข้อมูลสำหรับสร้าง/แก้ไข role (field ที่เป็น nil จะไม่ถูกแก้ไข)
+type RoleInput struct {
+	Name        *string   `json:"name" example:"moderator"`
+	Description *string   `json:"description"`
+	Permissions *[]string `json:"permissions" example:"notes.view,notes.approve"` // แทนที่สิทธิ์เดิมทั้งหมด
+}
+
+// PermissionInput - ข้อมูลสำหรับสร้าง/แก้ไขสิทธิ์ (แก้ไขได้เฉพาะคำอธิบาย)
+type PermissionInput struct {
+	Name        string `json:"name" example:"reviews.moderate"`
+	Description string `json:"description"`
+}
+
+// UserRoleInput - role ที่จะมอบให้ user
+type UserRoleInput struct {
+	Role string `json:"role" binding:"required" example:"moderator"`
+}
+
+const roleSelect = `
+	SELECT r.id, r.name, COALESCE(r.description, ''),
+		CASE WHEN r.name = '` + permissions.AdminRole + `'
+			THEN ARRAY(SELECT p.name FROM permissions p ORDER BY p.name)
+			ELSE ARRAY(
+				SELECT p.name FROM role_permissions rp JOIN permissions p ON p.id = rp.permission_id
+				WHERE rp.role_id = r.id ORDER BY p.name
+			)
+		END,
+		(SELECT COUNT(*) FROM user_roles ur WHERE ur.role_id = r.id)
+	FROM roles r`
+
+func scanRole(scan func(dest ...interface{}) error) (*Role, error) {
+	var r Role
+	if err := scan(&r.ID, &r.Name, &r.Description, pq.Array(&r.Permissions), &r.UserCount); err != nil {
+		return nil, err
+	}
+	if r.Permissions == nil {
+		r.Permissions = []string{}
+	}
+	r.Builtin = builtinRoles[r.Name]
+	return &r, nil
+}
+
+func loadRole(q sqlQuerier, roleID int) (*Role, error) {
+	role, err := scanRole(q.QueryRow(roleSelect+` WHERE r.id = $1`, roleID).Scan)
+	if err == sql.ErrNoRows {
+		return nil, errRoleNotFound
+	}
+	return role, err
+}
+
+// respondRoleError - แปลง error ของ role/permission เป็น response
+func respondRoleError(c *gin.Context, err error) {
+	var fieldErr *NoteFieldError
+	var forbidden *roleForbiddenError
+	switch {
+	case errors.As(err, &fieldErr):
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid input",
+			"field":   fieldErr.Field,
+			"message": fieldErr.Message,
+		})
+	case errors.As(err, &forbidden):
+		c.JSON(http.StatusForbidden, gin.H{"error": "Forbidden", "message": forbidden.Message})
+	case err == errRoleNotFound:
+		c.JSON(http.StatusNotFound, gin.H{"error": "Role not found"})
+	case err == errUserNotFound:
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+	default:
+		if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23505" {
+			c.JSON(http.StatusConflict, gin.H{"error": "Name already in use"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Database error",
+			"message": err.Error(),
+		})
+	}
+}
+
+// checkDelegation - admin มอบได้ทุกอย่าง ส่วนคนอื่น (ที่มี roles.manage) มอบหรือเอาออกได้เฉพาะสิทธิ์ที่ตัวเองมี
+// และแตะ role admin ไม่ได้
+func checkDelegation(c *gin.Context, roleName string, perms []string) error {
+	actorID := c.GetInt("user_id")
+	isAdmin, err := isAdminUser(actorID)
+	if err != nil || isAdmin {
+		return err
+	}
+	if roleName == permissions.AdminRole {
+		return &roleForbiddenError{"Only admins can change the admin role"}
+	}
+	held, err := permissions.ForUser(config.DB, actorID)
+	if err != nil {
+		return err
+	}
+	has := map[string]bool{}
+	for _, p := range held {
+		has[p] = true
+	}
+	for _, p := range perms {
+		if !has[p] {
+			return &roleForbiddenError{fmt.Sprintf("You cannot grant or revoke %s because you do not have it", p)}
+		}
+	}
+	return nil
+}
+
+// validate - ตรวจข้อมูล role (name จำเป็นตอนสร้างเท่านั้น)
+func (in *RoleInput) validate(creating bool) error {
+	if in.Name != nil {
+		*in.Name = strings.ToLower(strings.TrimSpace(*in.Name))
+		if !roleNamePattern.MatchString(*in.Name) {
+			return &NoteFieldError{"name", "must be 2-50 lowercase letters, digits or underscores, starting with a letter"}
+		}
+	} else if creating {
+		return &NoteFieldError{"name", "is required"}
+	}
+	if in.Description != nil {
+		*in.Description = strings.TrimSpace(*in.Description)
+	}
+	if in.Permissions != nil {
+		*in.Permissions = uniqueStrings(*in.Permissions)
+	}
+	return nil
+}
+
+// uniqueStrings - ตัดช่องว่าง ค่าว่าง และค่าซ้ำออก แล้วเรียงลำดับ
+func uniqueStrings(values []string) []string {
+	seen := map[string]bool{}
+	out := []string{}
+	for _, v := range values {
+		v = strings.TrimSpace(v)
+		if v != "" && !seen[v] {
+			seen[v] = true
+			out = append(out, v)
+		}
+	}
+	sort.Strings(out)
+	return out
+}
+
+// setRolePermissions - แทนที่สิทธิ์ทั้งหมดของ role (ทุกชื่อต้องมีอยู่ในตาราง permissions)
+func setRolePermissions(tx *sql.Tx, roleID int, names []string) error {
+	rows, err := tx.Query(`SELECT name FROM permissions WHERE name = ANY($1)`, pq.Array(names))
+	if err != nil {
+		return err
+	}
+	found := map[string]bool{}
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			rows.Close()
+			return err
+		}
+		found[name] = true
+	}
+	rows.Close()
+	for _, name := range names {
+		if !found[name] {
+			return &NoteFieldError{"permissions", fmt.Sprintf("unknown permission %q", name)}
+		}
+	}
+
+	if _, err := tx.Exec(`DELETE FROM role_permissions WHERE role_id = $1`, roleID); err != nil {
+		return err
+	}
+	_, err = tx.Exec(`
+		INSERT INTO role_permissions (role_id, permission_id)
+		SELECT $1, id FROM permissions WHERE name = ANY($2)
+	`, roleID, pq.Array(names))
+	return err
+}
+
+// GetRoles godoc
+// @Summary Get roles (Admin)
+// @Description All roles with their permissions and number of users. The admin role always has every permission
+// @Tags admin
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} map[string]interface{} "List of roles"
+// @Failure 401 {object} map[string]string "Unauthorized"
+// @Failure 403 {object} map[string]string "Missing roles.manage permission"
+// @Failure 500 {object} map[string]string "Database error"
+// @Router /api/admin/roles [get]
+func GetRoles(c *gin.Context) {
+	rows, err := config.DB.Query(roleSelect + ` ORDER BY r.id`)
+	if err != nil {
+		respondRoleError(c, err)
+		return
+	}
+	defer rows.Close()
+
+	roles := []Role{}
+	for rows.Next() {
+		role, err := scanRole(rows.Scan)
+		if err != nil {
+			respondRoleError(c, err)
+			return
+		}
+		roles = append(roles, *role)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    roles,
+	})
+}
+
+// CreateRole godoc
+// @Summary Create a role (Admin)
+// @Description Create a role with a set of permissions. Non-admins can only grant permissions they have themselves
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body RoleInput true "Role"
+// @Success 201 {object} map[string]interface{} "Created role"
+// @Failure 400 {object} map[string]string "Invalid input"
+// @Failure 401 {object} map[string]string "Unauthorized"
+// @Failure 403 {object} map[string]string "Cannot grant these permissions"
+// @Failure 409 {object} map[string]string "Name already in use"
+// @Failure 500 {object} map[string]string "Database error"
+// @Router /api/admin/roles [post]
+func CreateRole(c *gin.Context) {
+	var input RoleInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format"})
+		return
+	}
+	if err := input.validate(true); err != nil {
+		respondRoleError(c, err)
+		return
+	}
+	perms := []string{}
+	if input.Permissions != nil {
+		perms = *input.Permissions
+	}
+	if err := checkDelegation(c, *input.Name, perms); err != nil {
+		respondRoleError(c, err)
+		return
+	}
+
+	tx, err := config.DB.Begin()
+	if err != nil {
+		respondRoleError(c, err)
+		return
+	}
+	defer tx.Rollback()
+
+	var roleID int
+	err = tx.QueryRow(`
+		INSERT INTO roles (name, description) VALUES ($1, NULLIF($2, '')) RETURNING id
+	`, *input.Name, derefOr(input.Description, "")).Scan(&roleID)
+	if err == nil {
+		err = setRolePermissions(tx, roleID, perms)
+	}
+	var role *Role
+	if err == nil {
+		role, err = loadRole(tx, roleID)
+	}
+	if err == nil {
+		err = recordAudit(tx, c, AuditRoleCreate, AuditTargetRole, roleID, nil, role, nil)
+	}
+	if err == nil {
+		err = tx.Commit()
+	}
+	if err != nil {
+		respondRoleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"success": true,
+		"message": "Role created successfully",
+		"data":    role,
+	})
+}
+
+// derefOr - ค่าของ pointer หรือค่าเริ่มต้นถ้าเป็น nil
+func derefOr(s *string, fallback string) string {
+	if s == nil {
+		return fallback
+	}
+	return *s
+}
+
+// UpdateRole godoc
+// @Summary Update a role (Admin)
+// @Description Rename a role, change its description or replace its permissions. Built-in roles cannot be renamed and the admin role's permissions cannot be changed
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Role ID"
+// @Param request body RoleInput true "Fields to update"
+// @Success 200 {object} map[string]interface{} "Updated role"
+// @Failure 400 {object} map[string]string "Invalid input"
+// @Failure 401 {object} map[string]string "Unauthorized"
+// @Failure 403 {object} map[string]string "Cannot change these permissions"
+// @Failure 404 {object} map[string]string "Role not found"
+// @Failure 409 {object} map[string]string "Name already in use"
+// @Failure 500 {object} map[string]string "Database error"
+// @Router /api/admin/roles/{id} [put]
+func UpdateRole(c *gin.Context) {
+	roleID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid role ID"})
+		return
+	}
+	var input RoleInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format"})
+		return
+	}
+	if err := input.validate(false); err != nil {
+		respondRoleError(c, err)
+		return
+	}
+
+	tx, err := config.DB.Begin()
+	if err != nil {
+		respondRoleError(c, err)
+		return
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`SELECT 1 FROM roles WHERE id = $1 FOR UPDATE`, roleID); err != nil {
+		respondRoleError(c, err)
+		return
+	}
+	before, err := loadRole(tx, roleID)
+	if err != nil {
+		respondRoleError(c, err)
+		return
+	}
+	if before.Builtin && input.Name != nil && *input.Name != before.Name {
+		respondRoleError(c, &NoteFieldError{"name", "built-in roles cannot be renamed"})
+		return
+	}
+	if before.Name == permissions.AdminRole && input.Permissions != nil {
+		respondRoleError(c, &NoteFieldError{"permissions", "the admin role always has every permission"})
+		return
+	}
+
+	// ต้องมีสิทธิ์ทั้งชุดเดิมและชุดใหม่ (เอาสิทธิ์ที่ตัวเองไม่มีออกจาก role ก็ไม่ได้)
+	touched := before.Permissions
+	if input.Permissions != nil {
+		touched = append(append([]string{}, before.Permissions...), *input.Permissions...)
+	}
+	if err := checkDelegation(c, before.Name, touched); err != nil {
+		respondRoleError(c, err)
+		return
+	}
+
+	_, err = tx.Exec(`
+		UPDATE roles SET name = COALESCE($2, name),
+			description = CASE WHEN $4 THEN NULLIF($3, '') ELSE description END
+		WHERE id = $1
+	`, roleID, input.Name, derefOr(input.Description, ""), input.Description != nil)
+	if err == nil && input.Permissions != nil {
+		err = setRolePermissions(tx, roleID, *input.Permissions)
+	}
+	var after *Role
+	if err == nil {
+		after, err = loadRole(tx, roleID)
+	}
+	if err == nil {
+		err = recordAudit(tx, c, AuditRoleUpdate, AuditTargetRole, roleID, before, after, nil)
+	}
+	if err == nil {
+		err = tx.Commit()
+	}
+	if err != nil {
+		respondRoleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "Role updated successfully",
+		"data":    after,
+	})
+}
+
+// DeleteRole godoc
+// @Summary Delete a role (Admin)
+// @Description Delete a custom role. Users who had it lose its permissions immediately. Built-in roles cannot be deleted
+// @Tags admin
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Role ID"
+// @Success 200 {object} map[string]interface{} "Role deleted"
+// @Failure 400 {object} map[string]string "Built-in role"
+// @Failure 401 {object} map[string]string "Unauthorized"
+// @Failure 403 {object} map[string]string "Cannot revoke these permissions"
+// @Failure 404 {object} map[string]string "Role not found"
+// @Failure 500 {object} map[string]string "Database error"
+// @Router /api/admin/roles/{id} [delete]
+func DeleteRole(c *gin.Context) {
+	roleID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid role ID"})
+		return
+	}
+
+	tx, err := config.DB.Begin()
+	if err != nil {
+		respondRoleError(c, err)
+		return
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`SELECT 1 FROM roles WHERE id = $1 FOR UPDATE`, roleID); err != nil {
+		respondRoleError(c, err)
+		return
+	}
+	role, err := loadRole(tx, roleID)
+	if err != nil {
+		respondRoleError(c, err)
+		return
+	}
+	if role.Builtin {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Built-in roles cannot be deleted"})
+		return
+	}
+	if err := checkDelegation(c, role.Name, role.Permissions); err != nil {
+		respondRoleError(c, err)
+		return
+	}
+
+	_, err = tx.Exec(`DELETE FROM roles WHERE id = $1`, roleID)
+	if err == nil {
+		err = recordAudit(tx, c, AuditRoleDelete, AuditTargetRole, roleID, role, nil, nil)
+	}
+	if err == nil {
+		err = tx.Commit()
+	}
+	if err != nil {
+		respondRoleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "Role deleted successfully",
+	})
+}
+
+const permissionSelect = `
+	SELECT p.id, p.name, COALESCE(p.description, ''),
+		ARRAY(
+			SELECT r.name FROM roles r
+			WHERE r.name = '` + permissions.AdminRole + `'
+				OR r.id IN (SELECT rp.role_id FROM role_permissions rp WHERE rp.permission_id = p.id)
+			ORDER BY r.id
+		)
+	FROM permissions p`
+
+func scanPermission(scan func(dest ...interface{}) error) (*Permission, error) {
+	var p Permission
+	if err := scan(&p.ID, &p.Name, &p.Description, pq.Array(&p.Roles)); err != nil {
+		return nil, err
+	}
+	if p.Roles == nil {
+		p.Roles = []string{}
+	}
+	_, p.Builtin = permissions.Builtin[p.Name]
+	return &p, nil
+}
+
+// GetPermissions godoc
+// @Summary Get permissions (Admin)
+// @Description All permissions and the roles that have them
+// @Tags admin
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} map[string]interface{} "List of permissions"
+// @Failure 401 {object} map[string]string "Unauthorized"
+// @Failure 403 {object} map[string]string "Missing roles.manage permission"
+// @Failure 500 {object} map[string]string "Database error"
+// @Router /api/admin/permissions [get]
+func GetPermissions(c *gin.Context) {
+	rows, err := config.DB.Query(permissionSelect + ` ORDER BY p.name`)
+	if err != nil {
+		respondRoleError(c, err)
+		return
+	}
+	defer rows.Close()
+
+	perms := []Permission{}
+	for rows.Next() {
+		p, err := scanPermission(rows.Scan)
+		if err != nil {
+			respondRoleError(c, err)
+			return
+		}
+		perms = append(perms, *p)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    perms,
+	})
+}
+
+// CreatePermission godoc
+// @Summary Create a permission (Admin)
+// @Description Add a custom permission (e.g. for integrations). Only built-in permissions are checked by the API itself
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body PermissionInput true "Permission"
+// @Success 201 {object} map[string]interface{} "Created permission"
+// @Failure 400 {object} map[string]string "Invalid input"
+// @Failure 401 {object} map[string]string "Unauthorized"
+// @Failure 409 {object} map[string]string "Name already in use"
+// @Failure 500 {object} map[string]string "Database error"
+// @Router /api/admin/permissions [post]
+func CreatePermission(c *gin.Context) {
+	var input PermissionInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format"})
+		return
+	}
+	input.Name = strings.ToLower(strings.TrimSpace(input.Name))
+	input.Description = strings.TrimSpace(input.Description)
+	if len(input.Name) > maxPermissionNameLength || !permissionNamePattern.MatchString(input.Name) {
+		respondRoleError(c, &NoteFieldError{"name", "must look like resource.action (lowercase, at most 50 characters)"})
+		return
+	}
+
+	tx, err := config.DB.Begin()
+	if err != nil {
+		respondRoleError(c, err)
+		return
+	}
+	defer tx.Rollback()
+
+	var id int
+	err = tx.QueryRow(`
+		INSERT INTO permissions (name, description) VALUES ($1, NULLIF($2, '')) RETURNING id
+	`, input.Name, input.Description).Scan(&id)
+	var perm *Permission
+	if err == nil {
+		perm, err = scanPermission(tx.QueryRow(permissionSelect+` WHERE p.id = $1`, id).Scan)
+	}
+	if err == nil {
+		err = recordAudit(tx, c, AuditPermissionCreate, AuditTargetPermission, id, nil, perm, nil)
+	}
+	if err == nil {
+		err = tx.Commit()
+	}
+	if err != nil {
+		respondRoleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"success": true,
+		"message": "Permission created successfully",
+		"data":    perm,
+	})
+}
+
+// UpdatePermission godoc
+// @Summary Update a permission (Admin)
+// @Description Change a permission's description. Permission names cannot be changed
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Permission ID"
+// @Param request body object{description=string} true "New description"
+// @Success 200 {object} map[string]interface{} "Updated permission"
+// @Failure 400 {object} map[string]string "Invalid input"
+// @Failure 401 {object} map[string]string "Unauthorized"
+// @Failure 404 {object} map[string]string "Permission not found"
+// @Failure 500 {object} map[string]string "Database error"
+// @Router /api/admin/permissions/{id} [put]
+func UpdatePermission(c *gin.Context) {
+	permID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid permission ID"})
+		return
+	}
+	var input struct {
+		Description string `json:"description"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format"})
+		return
+	}
+
+	tx, err := config.DB.Begin()
+	if err != nil {
+		respondRoleError(c, err)
+		return
+	}
+	defer tx.Rollback()
+
+	before, err := scanPermission(tx.QueryRow(permissionSelect+` WHERE p.id = $1 FOR UPDATE OF p`, permID).Scan)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Permission not found"})
+		return
+	}
+	var after *Permission
+	if err == nil {
+		_, err = tx.Exec(`UPDATE permissions SET description = NULLIF($2, '') WHERE id = $1`,
+			permID, strings.TrimSpace(input.Description))
+	}
+	if err == nil {
+		after, err = scanPermission(tx.QueryRow(permissionSelect+` WHERE p.id = $1`, permID).Scan)
+	}
+	if err == nil {
+		err = recordAudit(tx, c, AuditPermissionUpdate, AuditTargetPermission, permID, before, after, nil)
+	}
+	if err == nil {
+		err = tx.Commit()
+	}
+	if err != nil {
+		respondRoleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "Permission updated successfully",
+		"data":    after,
+	})
+}
+
+// DeletePermission godoc
+// @Summary Delete a permission (Admin)
+// @Description Delete a custom permission and remove it from every role. Built-in permissions cannot be deleted
+// @Tags admin
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Permission ID"
+// @Success 200 {object} map[string]interface{} "Permission deleted"
+// @Failure 400 {object} map[string]string "Built-in permission"
+// @Failure 401 {object} map[string]string "Unauthorized"
+// @Failure 403 {object} map[string]string "Cannot revoke this permission"
+// @Failure 404 {object} map[string]string "Permission not found"
+// @Failure 500 {object} map[string]string "Database error"
+// @Router /api/admin/permissions/{id} [delete]
+func DeletePermission(c *gin.Context) {
+	permID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid permission ID"})
+		return
+	}
+
+	tx, err := config.DB.Begin()
+	if err != nil {
+		respondRoleError(c, err)
+		return
+	}
+	defer tx.Rollback()
+
+	perm, err := scanPermission(tx.QueryRow(permissionSelect+` WHERE p.id = $1 FOR UPDATE OF p`, permID).Scan)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Permission not found"})
+		return
+	}
+	if err != nil {
+		respondRoleError(c, err)
+		return
+	}
+	if perm.Builtin {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Built-in permissions cannot be deleted"})
+		return
+	}
+	if err := checkDelegation(c, "", []string{perm.Name}); err != nil {
+		respondRoleError(c, err)
+		return
+	}
+
+	_, err = tx.Exec(`DELETE FROM permissions WHERE id = $1`, permID)
+	if err == nil {
+		err = recordAudit(tx, c, AuditPermissionDelete, AuditTargetPermission, permID, perm, nil, nil)
+	}
+	if err == nil {
+		err = tx.Commit()
+	}
+	if err != nil {
+		respondRoleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "Permission deleted successfully",
+	})
+}
+
+// userRoleNames - ชื่อ role ของ user เรียงตามชื่อ (ต่างจาก getUserRoles ที่คืน "user" เมื่อไม่มี role)
+func userRoleNames(q sqlQuerier, userID int) ([]string, error) {
+	rows, err := q.Query(`
+		SELECT r.name FROM user_roles ur JOIN roles r ON r.id = ur.role_id
+		WHERE ur.user_id = $1 ORDER BY r.name
+	`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	names := []string{}
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, err
+		}
+		names = append(names, name)
+	}
+	return names, rows.Err()
+}
+
+// changeUserRole - มอบหรือเอา role ออกจาก user พร้อมบันทึก audit log
+// คืนค่า false ถ้าไม่มีอะไรเปลี่ยน (มี/ไม่มี role นั้นอยู่แล้ว)
+func changeUserRole(c *gin.Context, userID int, roleName string, grant bool) (bool, error) {
+	if !grant && roleName == permissions.AdminRole && userID == c.GetInt("user_id") {
+		return false, &NoteFieldError{"role", "you cannot remove your own admin role"}
+	}
+
+	tx, err := config.DB.Begin()
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	// lock แถวของ user ไว้กันการสั่งพร้อมกัน
+	var exists bool
+	err = tx.QueryRow(`SELECT true FROM users WHERE id = $1 FOR UPDATE`, userID).Scan(&exists)
+	if err == sql.ErrNoRows {
+		return false, errUserNotFound
+	}
+	if err != nil {
+		return false, err
+	}
+	var roleID int
+	err = tx.QueryRow(`SELECT id FROM roles WHERE name = $1`, roleName).Scan(&roleID)
+	if err == sql.ErrNoRows {
+		return false, errRoleNotFound
+	}
+	if err != nil {
+		return false, err
+	}
+	role, err := loadRole(tx, roleID)
+	if err != nil {
+		return false, err
+	}
+	if err := checkDelegation(c, role.Name, role.Permissions); err != nil {
+		return false, err
+	}
+
+	before, err := userRoleNames(tx, userID)
+	if err != nil {
+		return false, err
+	}
+	query, action := "DELETE FROM user_roles WHERE user_id = $1 AND role_id = $2", AuditRoleRevoke
+	if grant {
+		query, action = "INSERT INTO user_roles (user_id, role_id) VALUES ($1, $2) ON CONFLICT DO NOTHING", AuditRoleGrant
+	}
+	result, err := tx.Exec(query, userID, roleID)
+	if err != nil {
+		return false, err
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return false, nil
+	}
+	// เอา role seller ออก = ไม่ใช่ผู้ขายที่ยืนยันตัวตนแล้วอีกต่อไป (ต้องสมัครใหม่)
+	if !grant && roleName == "seller" {
+		if _, err := tx.Exec(`UPDATE users SET seller_verified_at = NULL WHERE id = $1`, userID); err != nil {
+			return false, err
+		}
+	}
+	after, err := userRoleNames(tx, userID)
+	if err != nil {
+		return false, err
+	}
+	err = recordAudit(tx, c, action, AuditTargetUser, userID,
+		gin.H{"roles": before}, gin.H{"roles": after}, gin.H{"role": roleName})
+	if err != nil {
+		return false, err
+	}
+	return true, tx.Commit()
+}
+
+// parseUserID - user ID จาก path (:id)
+func parseUserID(c *gin.Context) (int, bool) {
+	userID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return 0, false
+	}
+	return userID, true
+}
+
+// GetUserRoleAssignments godoc
+// @Summary Get a user's roles (Admin)
+// @Description Roles assigned to a user and the permissions they add up to
+// @Tags admin
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "User ID"
+// @Success 200 {object} map[string]interface{} "Roles and permissions"
+// @Failure 400 {object} map[string]string "Invalid user ID"
+// @Failure 401 {object} map[string]string "Unauthorized"
+// @Failure 404 {object} map[string]string "User not found"
+// @Failure 500 {object} map[string]string "Database error"
+// @Router /api/admin/users/{id}/roles [get]
+func GetUserRoleAssignments(c *gin.Context) {
+	userID, ok := parseUserID(c)
+	if !ok {
+		return
+	}
+
+	var exists bool
+	err := config.DB.QueryRow(`SELECT EXISTS(SELECT 1 FROM users WHERE id = $1)`, userID).Scan(&exists)
+	if err == nil && !exists {
+		err = errUserNotFound
+	}
+	var roles, perms []string
+	if err == nil {
+		roles, err = userRoleNames(config.DB, userID)
+	}
+	if err == nil {
+		perms, err = permissions.ForUser(config.DB, userID)
+	}
+	if err != nil {
+		respondRoleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data": gin.H{
+			"user_id":     userID,
+			"roles":       roles,
+			"permissions": perms,
+		},
+	})
+}
+
+// AssignUserRole godoc
+// @Summary Assign a role to a user (Admin)
+// @Description Give a user any role. Non-admins can only assign roles whose permissions they have, and never the admin role. Takes effect for permission checks immediately; role-name checks (e.g. seller routes) after the user's next token refresh. The seller role does not allow uploading notes without an approved seller application
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "User ID"
+// @Param request body UserRoleInput true "Role name"
+// @Success 200 {object} map[string]interface{} "Role assigned"
+// @Failure 400 {object} map[string]string "Invalid input"
+// @Failure 401 {object} map[string]string "Unauthorized"
+// @Failure 403 {object} map[string]string "Cannot assign this role"
+// @Failure 404 {object} map[string]string "User or role not found"
+// @Failure 500 {object} map[string]string "Database error"
+// @Router /api/admin/users/{id}/roles [post]
+func AssignUserRole(c *gin.Context) {
+	userID, ok := parseUserID(c)
+	if !ok {
+		return
+	}
+	var input UserRoleInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format"})
+		return
+	}
+
+	changed, err := changeUserRole(c, userID, strings.TrimSpace(input.Role), true)
+	if err != nil {
+		respondRoleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "Role assigned successfully",
+		"changed": changed,
+	})
+}
+
+// RevokeUserRole godoc
+// @Summary Remove a role from a user (Admin)
+// @Description Take a role away from a user. Admins cannot remove their own admin role
+// @Tags admin
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "User ID"
+// @Param role path string true "Role name"
+// @Success 200 {object} map[string]interface{} "Role removed"
+// @Failure 400 {object} map[string]string "Invalid input"
+// @Failure 401 {object} map[string]string "Unauthorized"
+// @Failure 403 {object} map[string]string "Cannot remove this role"
+// @Failure 404 {object} map[string]string "User or role not found"
+// @Failure 500 {object} map[string]string "Database error"
+// @Router /api/admin/users/{id}/roles/{role} [delete]
+func RevokeUserRole(c *gin.Context) {
+	userID, ok := parseUserID(c)
+	if !ok {
+		return
+	}
+
+	changed, err := changeUserRole(c, userID, c.Param("role"), false)
+	if err != nil {
+		respondRoleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "Role removed successfully",
+		"changed": changed,
+	})
+}
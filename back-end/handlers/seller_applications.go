@@ -0,0 +1,737 @@
+package handlers
+
+import (
+	"back-end/config"
+	"back-end/events"
+	"back-end/utils"
+	"database/sql"
+	"fmt"
+	"net/http"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lib/pq"
+)
+
+// สถานะของใบสมัครผู้ขาย
+const (
+	SellerApplicationPending  = "pending"  // รอ admin ตรวจ
+	SellerApplicationApproved = "approved" // ผ่านการตรวจ ได้ role seller และ badge ยืนยันตัวตน
+	SellerApplicationRejected = "rejected" // ไม่ผ่าน (ส่งใบสมัครใหม่ได้)
+)
+
+// ช่องทางรับเงินจากการขาย
+const (
+	PayoutBank      = "bank"
+	PayoutPromptPay = "promptpay"
+)
+
+// sellerDocumentsDir - โฟลเดอร์เก็บรูปบัตรนักศึกษา อยู่นอก ./uploads เพื่อไม่ให้เปิดดูผ่าน /uploads ได้
+const sellerDocumentsDir = "./private/seller_documents"
+
+const maxSellerApplicationFieldLength = 100
+
+var (
+	studentIDPattern     = regexp.MustCompile(`^[0-9A-Za-z-]{5,20}$`)
+	accountNumberPattern = regexp.MustCompile(`^[0-9]{10,15}$`)
+)
+
+// SellerApplication - ใบสมัครเป็นผู้ขาย
+type SellerApplication struct {
+	ID                  int        `json:"id"`
+	UserID              int        `json:"user_id"`
+	Username            string     `json:"username"`
+	Fullname            string     `json:"fullname"`
+	StudentID           string     `json:"student_id" example:"6501234567"`
+	StudentIDCardURL    string     `json:"student_id_card_url,omitempty"` // เฉพาะ admin
+	Faculty             string     `json:"faculty" example:"วิศวกรรมศาสตร์"`
+	Major               string     `json:"major" example:"วิศวกรรมคอมพิวเตอร์"`
+	PayoutMethod        string     `json:"payout_method" example:"bank"`
+	PayoutBank          string     `json:"payout_bank" example:"กสิกรไทย"`
+	PayoutAccountName   string     `json:"payout_account_name" example:"สมชาย ใจดี"`
+	PayoutAccountNumber string     `json:"payout_account_number" example:"******7890"` // ผู้สมัครเห็นแบบปิดบางส่วน
+	Status              string     `json:"status" example:"pending"`
+	RejectReason        string     `json:"reject_reason"`
+	ReviewedBy          *int       `json:"reviewed_by"`
+	ReviewedAt          *time.Time `json:"reviewed_at"`
+	CreatedAt           time.Time  `json:"created_at"`
+	UpdatedAt           time.Time  `json:"updated_at"`
+
+	studentIDCardPath string
+}
+
+// SellerApplicationInput - ข้อมูลใบสมัคร (ส่งเป็น multipart form พร้อมรูปบัตรนักศึกษา)
+type SellerApplicationInput struct {
+	StudentID           string
+	Faculty             string
+	Major               string
+	PayoutMethod        string
+	PayoutBank          string
+	PayoutAccountName   string
+	PayoutAccountNumber string
+}
+
+// RejectSellerApplicationInput - เหตุผลที่ไม่อนุมัติ (ส่งถึงผู้สมัคร)
+type RejectSellerApplicationInput struct {
+	Reason string `json:"reason" binding:"required" example:"รูปบัตรนักศึกษาไม่ชัด กรุณาถ่ายใหม่"`
+}
+
+// sellerApplicationSelect - คอลัมน์ของ SellerApplication (ใช้กับ scanSellerApplication) ต่อท้ายด้วย WHERE
+const sellerApplicationSelect = `
+	SELECT a.id, a.user_id, u.username, COALESCE(u.fullname, ''), a.student_id, a.student_id_card_path,
+		a.faculty, a.major, a.payout_method, COALESCE(a.payout_bank, ''), a.payout_account_name,
+		a.payout_account_number, a.status, COALESCE(a.reject_reason, ''), a.reviewed_by, a.reviewed_at,
+		a.created_at, a.updated_at
+	FROM seller_applications a
+	JOIN users u ON a.user_id = u.id
+`
+
+func scanSellerApplication(row interface{ Scan(...interface{}) error }) (*SellerApplication, error) {
+	var a SellerApplication
+	var reviewedBy sql.NullInt64
+	var reviewedAt sql.NullTime
+	err := row.Scan(&a.ID, &a.UserID, &a.Username, &a.Fullname, &a.StudentID, &a.studentIDCardPath,
+		&a.Faculty, &a.Major, &a.PayoutMethod, &a.PayoutBank, &a.PayoutAccountName,
+		&a.PayoutAccountNumber, &a.Status, &a.RejectReason, &reviewedBy, &reviewedAt,
+		&a.CreatedAt, &a.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	if reviewedBy.Valid {
+		id := int(reviewedBy.Int64)
+		a.ReviewedBy = &id
+	}
+	if reviewedAt.Valid {
+		a.ReviewedAt = &reviewedAt.Time
+	}
+	return &a, nil
+}
+
+// getSellerApplication - ดึงใบสมัครเดียวตาม ID
+func getSellerApplication(q sqlQuerier, applicationID int) (*SellerApplication, error) {
+	return scanSellerApplication(q.QueryRow(sellerApplicationSelect+` WHERE a.id = $1`, applicationID))
+}
+
+// forApplicant - ปิดเลขบัญชีเหลือ 4 หลักท้ายสำหรับแสดงให้ผู้สมัครดู
+func (a *SellerApplication) forApplicant() {
+	n := len(a.PayoutAccountNumber)
+	if n > 4 {
+		a.PayoutAccountNumber = strings.Repeat("*", n-4) + a.PayoutAccountNumber[n-4:]
+	}
+}
+
+// forAdmin - เพิ่มลิงก์รูปบัตรนักศึกษา (เปิดได้เฉพาะผู้มีสิทธิ์ sellers.verify)
+func (a *SellerApplication) forAdmin() {
+	a.StudentIDCardURL = fmt.Sprintf("/api/admin/seller-applications/%d/student-id-card", a.ID)
+}
+
+func (in *SellerApplicationInput) validate() error {
+	in.StudentID = strings.TrimSpace(in.StudentID)
+	in.Faculty = strings.TrimSpace(in.Faculty)
+	in.Major = strings.TrimSpace(in.Major)
+	in.PayoutBank = strings.TrimSpace(in.PayoutBank)
+	in.PayoutAccountName = strings.TrimSpace(in.PayoutAccountName)
+	in.PayoutAccountNumber = strings.NewReplacer(" ", "", "-", "").Replace(in.PayoutAccountNumber)
+
+	if !studentIDPattern.MatchString(in.StudentID) {
+		return &NoteFieldError{Field: "student_id", Message: "must be 5-20 letters, digits or hyphens"}
+	}
+	for _, f := range []struct{ name, value string }{
+		{"faculty", in.Faculty},
+		{"major", in.Major},
+		{"payout_account_name", in.PayoutAccountName},
+	} {
+		if f.value == "" {
+			return &NoteFieldError{Field: f.name, Message: "is required"}
+		}
+		if utf8.RuneCountInString(f.value) > maxSellerApplicationFieldLength {
+			return &NoteFieldError{Field: f.name, Message: fmt.Sprintf("must be at most %d characters", maxSellerApplicationFieldLength)}
+		}
+	}
+
+	switch in.PayoutMethod {
+	case PayoutBank:
+		if in.PayoutBank == "" {
+			return &NoteFieldError{Field: "payout_bank", Message: "is required for bank transfers"}
+		}
+		if utf8.RuneCountInString(in.PayoutBank) > maxSellerApplicationFieldLength {
+			return &NoteFieldError{Field: "payout_bank", Message: fmt.Sprintf("must be at most %d characters", maxSellerApplicationFieldLength)}
+		}
+		if !accountNumberPattern.MatchString(in.PayoutAccountNumber) {
+			return &NoteFieldError{Field: "payout_account_number", Message: "must be a 10-15 digit bank account number"}
+		}
+	case PayoutPromptPay:
+		// PromptPay ผูกกับเบอร์มือถือ (10 หลัก) หรือเลขบัตรประชาชน (13 หลัก)
+		in.PayoutBank = ""
+		n := len(in.PayoutAccountNumber)
+		if !accountNumberPattern.MatchString(in.PayoutAccountNumber) || (n != 10 && n != 13) {
+			return &NoteFieldError{Field: "payout_account_number", Message: "must be a 10-digit phone number or 13-digit national ID"}
+		}
+	default:
+		return &NoteFieldError{Field: "payout_method", Message: "must be one of bank, promptpay"}
+	}
+	return nil
+}
+
+// isVerifiedSeller - ใบสมัครผู้ขายของ user ผ่านการอนุมัติแล้ว (ลง note ขายได้) หรือไม่
+func isVerifiedSeller(q sqlQuerier, userID int) (bool, error) {
+	var ok bool
+	err := q.QueryRow(`SELECT seller_verified_at IS NOT NULL FROM users WHERE id = $1`, userID).Scan(&ok)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	return ok, err
+}
+
+// hasSellerRole - user มี role seller หรือไม่ (มอบโดย admin ได้ จึงไม่ได้แปลว่าผ่านการยืนยันตัวตน)
+func hasSellerRole(q sqlQuerier, userID int) (bool, error) {
+	var ok bool
+	err := q.QueryRow(`
+		SELECT EXISTS (
+			SELECT 1 FROM user_roles ur JOIN roles r ON r.id = ur.role_id
+			WHERE ur.user_id = $1 AND r.name = 'seller'
+		)
+	`, userID).Scan(&ok)
+	return ok, err
+}
+
+// verifiedSellers - seller ใน sellerIDs ที่ผ่านการยืนยันตัวตนแล้ว
+func verifiedSellers(q sqlQuerier, sellerIDs []int) (map[int]bool, error) {
+	verified := map[int]bool{}
+	if len(sellerIDs) == 0 {
+		return verified, nil
+	}
+	rows, err := q.Query(`
+		SELECT id FROM users WHERE id = ANY($1) AND seller_verified_at IS NOT NULL
+	`, pq.Array(sellerIDs))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		verified[id] = true
+	}
+	return verified, rows.Err()
+}
+
+// applySellerBadges - เติม badge ยืนยันตัวตนให้ seller ของ note ทุกเล่ม (ถ้าดึงไม่ได้จะปล่อยเป็น false)
+func applySellerBadges(notes []NoteResponse) {
+	sellerIDs := make([]int, len(notes))
+	for i := range notes {
+		sellerIDs[i] = notes[i].Seller.ID
+	}
+	verified, err := verifiedSellers(config.DB, sellerIDs)
+	if err != nil {
+		return
+	}
+	for i := range notes {
+		notes[i].Seller.Verified = verified[notes[i].Seller.ID]
+	}
+}
+
+// respondSellerApplicationError - ตอบกลับ error ของใบสมัครผู้ขาย
+func respondSellerApplicationError(c *gin.Context, err error) {
+	if fieldErr, ok := err.(*NoteFieldError); ok {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid input",
+			"field":   fieldErr.Field,
+			"message": fieldErr.Message,
+		})
+		return
+	}
+	if imageErr, ok := err.(*ImageValidationError); ok {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": imageErr.Message,
+		})
+		return
+	}
+	c.JSON(http.StatusInternalServerError, gin.H{
+		"error":   "Database error",
+		"message": err.Error(),
+	})
+}
+
+// SubmitSellerApplication godoc
+// @Summary สมัครเป็นผู้ขาย
+// @Description ส่งใบสมัครพร้อมรูปบัตรนักศึกษา คณะ/สาขา และบัญชีรับเงิน เพื่อให้ admin ตรวจ เมื่ออนุมัติแล้วจึงอัปโหลด note ได้และได้ badge ยืนยันตัวตน (มีใบสมัครที่รอตรวจได้ครั้งละใบ)
+// @Tags sellers
+// @Accept multipart/form-data
+// @Produce json
+// @Security BearerAuth
+// @Param student_id formData string true "รหัสนักศึกษา"
+// @Param student_id_card formData file true "รูปบัตรนักศึกษา (jpg, png, gif, webp; ไม่เกิน 10MB)"
+// @Param faculty formData string true "คณะ"
+// @Param major formData string true "สาขา"
+// @Param payout_method formData string true "ช่องทางรับเงิน (bank, promptpay)"
+// @Param payout_bank formData string false "ชื่อธนาคาร (จำเป็นเมื่อเป็น bank)"
+// @Param payout_account_name formData string true "ชื่อบัญชี"
+// @Param payout_account_number formData string true "เลขบัญชี หรือเบอร์/เลขบัตรประชาชนที่ผูก PromptPay"
+// @Success 201 {object} map[string]interface{} "ใบสมัครที่ส่ง"
+// @Failure 400 {object} map[string]string "Invalid input"
+// @Failure 401 {object} map[string]string "Unauthorized"
+// @Failure 409 {object} map[string]string "Already verified or an application is pending"
+// @Failure 500 {object} map[string]string "Server error"
+// @Router /api/seller-applications [post]
+func SubmitSellerApplication(c *gin.Context) {
+	userID := c.GetInt("user_id")
+	input := SellerApplicationInput{
+		StudentID:           c.PostForm("student_id"),
+		Faculty:             c.PostForm("faculty"),
+		Major:               c.PostForm("major"),
+		PayoutMethod:        c.PostForm("payout_method"),
+		PayoutBank:          c.PostForm("payout_bank"),
+		PayoutAccountName:   c.PostForm("payout_account_name"),
+		PayoutAccountNumber: c.PostForm("payout_account_number"),
+	}
+	if err := input.validate(); err != nil {
+		respondSellerApplicationError(c, err)
+		return
+	}
+
+	var verified, pending bool
+	err := config.DB.QueryRow(`
+		SELECT u.seller_verified_at IS NOT NULL,
+			EXISTS (SELECT 1 FROM seller_applications a WHERE a.user_id = u.id AND a.status = $2)
+		FROM users u WHERE u.id = $1
+	`, userID, SellerApplicationPending).Scan(&verified, &pending)
+	if err != nil {
+		respondSellerApplicationError(c, err)
+		return
+	}
+	if verified {
+		c.JSON(http.StatusConflict, gin.H{"error": "You are already a verified seller"})
+		return
+	}
+	if pending {
+		c.JSON(http.StatusConflict, gin.H{"error": "You already have a pending seller application"})
+		return
+	}
+
+	cardFile, err := c.FormFile("student_id_card")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Student ID card image is required"})
+		return
+	}
+	variants, err := processUploadedImage(cardFile, maxImageUploadSize, utils.StudentIDVariants)
+	if err != nil {
+		respondSellerApplicationError(c, err)
+		return
+	}
+	saved, err := writeImageVariants(sellerDocumentsDir, fmt.Sprintf("student_id_%d_%d", userID, time.Now().UnixNano()), variants)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save student ID card"})
+		return
+	}
+
+	// unique index กันการส่งซ้อนระหว่างที่ใบสมัครเดิมยังรอตรวจ
+	var applicationID int
+	err = config.DB.QueryRow(`
+		INSERT INTO seller_applications (user_id, student_id, student_id_card_path, faculty, major,
+			payout_method, payout_bank, payout_account_name, payout_account_number, status, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, NULLIF($7, ''), $8, $9, $10, NOW(), NOW())
+		RETURNING id
+	`, userID, input.StudentID, variantPath(saved, "full"), input.Faculty, input.Major,
+		input.PayoutMethod, input.PayoutBank, input.PayoutAccountName, input.PayoutAccountNumber,
+		SellerApplicationPending).Scan(&applicationID)
+	if err != nil {
+		removeVariantFiles(saved)
+		if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23505" {
+			c.JSON(http.StatusConflict, gin.H{"error": "You already have a pending seller application"})
+			return
+		}
+		respondSellerApplicationError(c, err)
+		return
+	}
+
+	application, err := getSellerApplication(config.DB, applicationID)
+	if err != nil {
+		respondSellerApplicationError(c, err)
+		return
+	}
+	application.forApplicant()
+
+	events.Publish(events.AdminTopic, events.SellerApplicationCreated, gin.H{
+		"application_id": application.ID,
+		"user_id":        userID,
+		"faculty":        application.Faculty,
+		"major":          application.Major,
+	})
+
+	c.JSON(http.StatusCreated, gin.H{
+		"success": true,
+		"message": "Seller application submitted successfully",
+		"data":    application,
+	})
+}
+
+// GetMySellerApplications godoc
+// @Summary ดึงใบสมัครผู้ขายของตัวเอง
+// @Description ดึงใบสมัครทั้งหมดของ user พร้อมสถานะและเหตุผลที่ไม่อนุมัติ (ใหม่สุดก่อน) และสถานะการยืนยันตัวตน เลขบัญชีแสดงเฉพาะ 4 หลักท้าย
+// @Tags sellers
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} map[string]interface{} "ใบสมัครและสถานะ"
+// @Failure 401 {object} map[string]string "Unauthorized"
+// @Failure 500 {object} map[string]string "Server error"
+// @Router /api/seller-applications/mine [get]
+func GetMySellerApplications(c *gin.Context) {
+	userID := c.GetInt("user_id")
+
+	verified, err := isVerifiedSeller(config.DB, userID)
+	if err != nil {
+		respondSellerApplicationError(c, err)
+		return
+	}
+	isSeller, err := hasSellerRole(config.DB, userID)
+	if err != nil {
+		respondSellerApplicationError(c, err)
+		return
+	}
+
+	rows, err := config.DB.Query(sellerApplicationSelect+`
+		WHERE a.user_id = $1
+		ORDER BY a.created_at DESC, a.id DESC
+	`, userID)
+	if err != nil {
+		respondSellerApplicationError(c, err)
+		return
+	}
+	defer rows.Close()
+
+	applications := []SellerApplication{}
+	for rows.Next() {
+		application, err := scanSellerApplication(rows)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error scanning seller application"})
+			return
+		}
+		application.forApplicant()
+		applications = append(applications, *application)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success":      true,
+		"is_seller":    isSeller,
+		"verified":     verified,
+		"applications": applications,
+	})
+}
+
+// GetSellerApplications godoc
+// @Summary Get seller applications (Admin)
+// @Description Seller applications filtered by status, oldest pending applications first, with counts per status
+// @Tags admin
+// @Produce json
+// @Security BearerAuth
+// @Param status query string false "Application status (pending, approved, rejected)"
+// @Param page query int false "Page number (default 1)"
+// @Param limit query int false "Items per page (default 50, max 200)"
+// @Success 200 {object} map[string]interface{} "Applications with total and counts per status"
+// @Failure 400 {object} map[string]string "Invalid status"
+// @Failure 401 {object} map[string]string "Unauthorized"
+// @Failure 500 {object} map[string]string "Server error"
+// @Router /api/admin/seller-applications [get]
+func GetSellerApplications(c *gin.Context) {
+	status := c.Query("status")
+	switch status {
+	case "", SellerApplicationPending, SellerApplicationApproved, SellerApplicationRejected:
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid status"})
+		return
+	}
+
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "50"))
+	if page < 1 {
+		page = 1
+	}
+	if limit < 1 || limit > 200 {
+		limit = 50
+	}
+
+	var total int
+	err := config.DB.QueryRow(`SELECT COUNT(*) FROM seller_applications a WHERE $1 = '' OR a.status = $1`, status).Scan(&total)
+	if err != nil {
+		respondSellerApplicationError(c, err)
+		return
+	}
+
+	// ใบสมัครที่รอตรวจขึ้นก่อน เรียงจากเก่าสุด
+	rows, err := config.DB.Query(sellerApplicationSelect+`
+		WHERE $1 = '' OR a.status = $1
+		ORDER BY a.status = 'pending' DESC, a.created_at, a.id
+		LIMIT $2 OFFSET $3
+	`, status, limit, (page-1)*limit)
+	if err != nil {
+		respondSellerApplicationError(c, err)
+		return
+	}
+	defer rows.Close()
+
+	applications := []SellerApplication{}
+	for rows.Next() {
+		application, err := scanSellerApplication(rows)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error scanning seller application"})
+			return
+		}
+		application.forAdmin()
+		applications = append(applications, *application)
+	}
+
+	counts := map[string]int{SellerApplicationPending: 0, SellerApplicationApproved: 0, SellerApplicationRejected: 0}
+	countRows, err := config.DB.Query(`SELECT status, COUNT(*) FROM seller_applications GROUP BY status`)
+	if err != nil {
+		respondSellerApplicationError(c, err)
+		return
+	}
+	defer countRows.Close()
+	for countRows.Next() {
+		var s string
+		var n int
+		if err := countRows.Scan(&s, &n); err == nil {
+			counts[s] = n
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    applications,
+		"total":   total,
+		"page":    page,
+		"limit":   limit,
+		"counts":  counts,
+	})
+}
+
+// parseSellerApplicationID - ID ใบสมัครจาก path (:id)
+func parseSellerApplicationID(c *gin.Context) (int, bool) {
+	applicationID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid application ID"})
+		return 0, false
+	}
+	return applicationID, true
+}
+
+// GetSellerApplicationByID godoc
+// @Summary Get a seller application (Admin)
+// @Description Full application details, including the payout account number and a link to the student ID card image
+// @Tags admin
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Application ID"
+// @Success 200 {object} map[string]interface{} "Seller application"
+// @Failure 400 {object} map[string]string "Invalid application ID"
+// @Failure 401 {object} map[string]string "Unauthorized"
+// @Failure 404 {object} map[string]string "Application not found"
+// @Failure 500 {object} map[string]string "Server error"
+// @Router /api/admin/seller-applications/{id} [get]
+func GetSellerApplicationByID(c *gin.Context) {
+	applicationID, ok := parseSellerApplicationID(c)
+	if !ok {
+		return
+	}
+	application, err := getSellerApplication(config.DB, applicationID)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Application not found"})
+		return
+	}
+	if err != nil {
+		respondSellerApplicationError(c, err)
+		return
+	}
+	application.forAdmin()
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    application,
+	})
+}
+
+// GetSellerApplicationStudentCard godoc
+// @Summary Get the student ID card of an application (Admin)
+// @Description The uploaded student ID card image. It is stored outside the public uploads directory and only served here
+// @Tags admin
+// @Produce jpeg
+// @Security BearerAuth
+// @Param id path int true "Application ID"
+// @Success 200 {file} binary "Student ID card image"
+// @Failure 400 {object} map[string]string "Invalid application ID"
+// @Failure 401 {object} map[string]string "Unauthorized"
+// @Failure 404 {object} map[string]string "Application not found"
+// @Failure 500 {object} map[string]string "Server error"
+// @Router /api/admin/seller-applications/{id}/student-id-card [get]
+func GetSellerApplicationStudentCard(c *gin.Context) {
+	applicationID, ok := parseSellerApplicationID(c)
+	if !ok {
+		return
+	}
+	var path string
+	err := config.DB.QueryRow(`SELECT student_id_card_path FROM seller_applications WHERE id = $1`, applicationID).Scan(&path)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Application not found"})
+		return
+	}
+	if err != nil {
+		respondSellerApplicationError(c, err)
+		return
+	}
+	if _, err := os.Stat(path); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Student ID card file not found"})
+		return
+	}
+
+	c.Header("Cache-Control", "private, no-store")
+	c.Header("Content-Type", "image/jpeg")
+	c.File(path)
+}
+
+// reviewSellerApplication - อนุมัติหรือปฏิเสธใบสมัครที่รอตรวจ บันทึก audit log และแจ้งผู้สมัคร
+// อนุมัติ = ได้ role seller และ badge ยืนยันตัวตน
+func reviewSellerApplication(c *gin.Context, status, reason string) {
+	applicationID, ok := parseSellerApplicationID(c)
+	if !ok {
+		return
+	}
+
+	tx, err := config.DB.Begin()
+	if err != nil {
+		respondSellerApplicationError(c, err)
+		return
+	}
+	defer tx.Rollback()
+
+	application, err := scanSellerApplication(tx.QueryRow(sellerApplicationSelect+` WHERE a.id = $1 FOR UPDATE OF a`, applicationID))
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Application not found"})
+		return
+	}
+	if err != nil {
+		respondSellerApplicationError(c, err)
+		return
+	}
+	if application.Status != SellerApplicationPending {
+		c.JSON(http.StatusConflict, gin.H{"error": "Application has already been reviewed"})
+		return
+	}
+
+	_, err = tx.Exec(`
+		UPDATE seller_applications
+		SET status = $1, reject_reason = NULLIF($2, ''), reviewed_by = $3, reviewed_at = NOW(), updated_at = NOW()
+		WHERE id = $4
+	`, status, reason, c.GetInt("user_id"), applicationID)
+	if err != nil {
+		respondSellerApplicationError(c, err)
+		return
+	}
+
+	action := AuditSellerApplicationReject
+	title, message := "Your seller application was not approved", reason
+	if status == SellerApplicationApproved {
+		action = AuditSellerApplicationApprove
+		title, message = "You are now a verified seller", "Your seller application was approved. You can start uploading notes."
+		if _, err := tx.Exec(`UPDATE users SET seller_verified_at = NOW() WHERE id = $1`, application.UserID); err != nil {
+			respondSellerApplicationError(c, err)
+			return
+		}
+		_, err = tx.Exec(`
+			INSERT INTO user_roles (user_id, role_id)
+			SELECT $1, r.id FROM roles r WHERE r.name = 'seller'
+			ON CONFLICT DO NOTHING
+		`, application.UserID)
+		if err != nil {
+			respondSellerApplicationError(c, err)
+			return
+		}
+	}
+
+	details := gin.H{"user_id": application.UserID}
+	if reason != "" {
+		details["reason"] = reason
+	}
+	err = recordAudit(tx, c, action, AuditTargetSellerApplication, applicationID,
+		gin.H{"status": application.Status}, gin.H{"status": status}, details)
+	if err != nil {
+		respondSellerApplicationError(c, err)
+		return
+	}
+
+	eventData := gin.H{"application_id": applicationID, "status": status, "reason": reason}
+	if err := notify(tx, application.UserID, NotifySellerApplication, title, message, eventData); err != nil {
+		respondSellerApplicationError(c, err)
+		return
+	}
+
+	application, err = getSellerApplication(tx, applicationID)
+	if err != nil {
+		respondSellerApplicationError(c, err)
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		respondSellerApplicationError(c, err)
+		return
+	}
+	application.forAdmin()
+
+	events.PublishToUser(application.UserID, events.SellerApplicationReviewed, eventData)
+	events.Publish(events.AdminTopic, events.SellerApplicationReviewed, eventData)
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "Seller application " + status,
+		"data":    application,
+	})
+}
+
+// ApproveSellerApplication godoc
+// @Summary Approve a seller application (Admin)
+// @Description Grant the seller role and the verified seller badge, and notify the applicant
+// @Tags admin
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Application ID"
+// @Success 200 {object} map[string]interface{} "Approved application"
+// @Failure 400 {object} map[string]string "Invalid application ID"
+// @Failure 401 {object} map[string]string "Unauthorized"
+// @Failure 404 {object} map[string]string "Application not found"
+// @Failure 409 {object} map[string]string "Application has already been reviewed"
+// @Failure 500 {object} map[string]string "Server error"
+// @Router /api/admin/seller-applications/{id}/approve [post]
+func ApproveSellerApplication(c *gin.Context) {
+	reviewSellerApplication(c, SellerApplicationApproved, "")
+}
+
+// RejectSellerApplication godoc
+// @Summary Reject a seller application (Admin)
+// @Description Reject an application with a reason that is sent to the applicant. The applicant can submit a new application
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Application ID"
+// @Param request body RejectSellerApplicationInput true "Rejection reason"
+// @Success 200 {object} map[string]interface{} "Rejected application"
+// @Failure 400 {object} map[string]string "Invalid input"
+// @Failure 401 {object} map[string]string "Unauthorized"
+// @Failure 404 {object} map[string]string "Application not found"
+// @Failure 409 {object} map[string]string "Application has already been reviewed"
+// @Failure 500 {object} map[string]string "Server error"
+// @Router /api/admin/seller-applications/{id}/reject [post]
+func RejectSellerApplication(c *gin.Context) {
+	var input RejectSellerApplicationInput
+	if err := c.ShouldBindJSON(&input); err != nil || strings.TrimSpace(input.Reason) == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Rejection reason is required"})
+		return
+	}
+	reviewSellerApplication(c, SellerApplicationRejected, strings.TrimSpace(input.Reason))
+}
@@ -0,0 +1,124 @@
+package handlers
+
+import (
+	"back-end/config"
+	"back-end/permissions"
+	"database/sql"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SellerProfile - โปรไฟล์สาธารณะของผู้ขาย
+type SellerProfile struct {
+	ID             int            `json:"id" example:"2"`
+	Username       string         `json:"username" example:"seller1"`
+	DisplayName    string         `json:"display_name" example:"ผู้ขายคนที่ 1"`
+	AvatarURL      string         `json:"avatar_url"`
+	Bio            string         `json:"bio"`
+	Verified       bool           `json:"verified"` // ผ่านการยืนยันตัวตนผู้ขายแล้ว
+	JoinedAt       time.Time      `json:"joined_at"`
+	NotesCount     int            `json:"notes_count"` // จำนวน note ที่วางขายอยู่
+	TotalSales     int            `json:"total_sales"`
+	FollowersCount int            `json:"followers_count"`
+	IsFollowing    bool           `json:"is_following"` // user ที่ login อยู่ติดตามผู้ขายคนนี้แล้ว
+	Rating         *ReviewStats   `json:"rating"`
+	Notes          []NoteResponse `json:"notes"`           // note ที่วางขายอยู่ (ใหม่สุดก่อน)
+	Email          string         `json:"email,omitempty"` // เฉพาะเจ้าของบัญชีและ admin
+	Phone          string         `json:"phone,omitempty"` // เฉพาะเจ้าของบัญชีและ admin
+}
+
+// canViewContact - viewer เห็นข้อมูลติดต่อ (email, เบอร์โทร) ของ user ได้หรือไม่ (เจ้าของบัญชีหรือผู้มีสิทธิ์ users.view)
+func canViewContact(c *gin.Context, userID int) (bool, error) {
+	viewer := viewerID(c)
+	if viewer == 0 {
+		return false, nil
+	}
+	if viewer == userID {
+		return true, nil
+	}
+	return permissions.UserHasAny(config.DB, viewer, permissions.UsersView)
+}
+
+// GetSellerProfile godoc
+// @Summary ดึงโปรไฟล์ผู้ขาย
+// @Description ดึงโปรไฟล์สาธารณะของผู้ขาย: ชื่อที่แสดง รูป bio badge ยืนยันตัวตน วันที่สมัคร จำนวน note ยอดขาย จำนวนผู้ติดตาม สรุปรีวิว และ note ที่วางขายอยู่ (email และเบอร์โทรแสดงเฉพาะเจ้าของบัญชีและ admin)
+// @Tags sellers
+// @Produce json
+// @Param id path int true "Seller ID"
+// @Success 200 {object} SellerProfile "โปรไฟล์ผู้ขาย"
+// @Failure 400 {object} map[string]string "Invalid seller ID"
+// @Failure 404 {object} map[string]string "Seller not found"
+// @Failure 500 {object} map[string]string "Database error"
+// @Router /api/sellers/{id} [get]
+func GetSellerProfile(c *gin.Context) {
+	sellerID, ok := parseSellerID(c)
+	if !ok {
+		return
+	}
+
+	canView, err := canViewContact(c, sellerID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error", "message": err.Error()})
+		return
+	}
+
+	// เป็นผู้ขาย = มี role seller หรือเคยลง note (บัญชีที่ถูกแบนไม่แสดงต่อสาธารณะ)
+	var profile SellerProfile
+	var fullname, avatarURL sql.NullString
+	var isSeller, banned bool
+	err = config.DB.QueryRow(`
+		SELECT u.id, u.username, u.fullname, u.avatar_url, COALESCE(u.bio, ''),
+			u.seller_verified_at IS NOT NULL, u.created_at, u.banned_at IS NOT NULL,
+			COALESCE(u.email, ''), COALESCE(u.phone, ''),
+			EXISTS (
+				SELECT 1 FROM user_roles ur JOIN roles r ON r.id = ur.role_id
+				WHERE ur.user_id = u.id AND r.name = 'seller'
+			) OR EXISTS (SELECT 1 FROM notes_for_sale n WHERE n.seller_id = u.id),
+			(SELECT COUNT(*) FROM notes_for_sale n WHERE n.seller_id = u.id AND n.status = 'available'),
+			(SELECT COUNT(*) FROM buyed_note b JOIN notes_for_sale n ON b.note_id = n.id WHERE n.seller_id = u.id),
+			(SELECT COUNT(*) FROM seller_follows f WHERE f.seller_id = u.id),
+			EXISTS (SELECT 1 FROM seller_follows f WHERE f.seller_id = u.id AND f.follower_id = $2)
+		FROM users u
+		WHERE u.id = $1
+	`, sellerID, viewerID(c)).Scan(
+		&profile.ID, &profile.Username, &fullname, &avatarURL, &profile.Bio,
+		&profile.Verified, &profile.JoinedAt, &banned,
+		&profile.Email, &profile.Phone,
+		&isSeller, &profile.NotesCount, &profile.TotalSales,
+		&profile.FollowersCount, &profile.IsFollowing,
+	)
+	if err == sql.ErrNoRows || (err == nil && (!isSeller || (banned && !canView))) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Seller not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error", "message": err.Error()})
+		return
+	}
+
+	profile.DisplayName = profile.Username
+	if fullname.Valid && fullname.String != "" {
+		profile.DisplayName = fullname.String
+	}
+	if avatarURL.Valid {
+		profile.AvatarURL = avatarURL.String
+	}
+	if !canView {
+		profile.Email, profile.Phone = "", ""
+	}
+
+	profile.Rating, err = loadReviewStats(`nfs.seller_id = $1`, sellerID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error", "message": err.Error()})
+		return
+	}
+	profile.Notes, err = sellerNotes(c, sellerID, false)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error", "message": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, profile)
+}
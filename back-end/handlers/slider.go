@@ -5,11 +5,11 @@ import (
 	"fmt"
 	"net/http"
 	"os"
-	"path/filepath"
+	"reflect"
 	"strconv"
-	"strings"
 
 	"back-end/config"
+	"back-end/utils"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
@@ -26,9 +26,12 @@ import (
 // @Router /api/slider [get]
 func GetSliderImages(c *gin.Context) {
 	rows, err := config.DB.Query(`
-		SELECT id, image_path, display_order, link_url
-		FROM slider_images
-		ORDER BY display_order ASC
+		SELECT s.id, s.image_path, COALESCE(card.path, s.image_path), COALESCE(thumb.path, s.image_path),
+			s.display_order, s.link_url
+		FROM slider_images s
+		LEFT JOIN image_variants card ON card.slider_image_id = s.id AND card.variant = 'card'
+		LEFT JOIN image_variants thumb ON thumb.slider_image_id = s.id AND thumb.variant = 'thumb'
+		ORDER BY s.display_order ASC
 	`)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
@@ -41,16 +44,18 @@ func GetSliderImages(c *gin.Context) {
 	defer rows.Close()
 
 	type SliderImage struct {
-		ID           int            `json:"id"`
-		ImagePath    string         `json:"image_path"`
-		DisplayOrder int            `json:"display_order"`
-		LinkURL      sql.NullString `json:"link_url"`
+		ID            int            `json:"id"`
+		ImagePath     string         `json:"image_path"`
+		CardPath      string         `json:"card_path"`
+		ThumbnailPath string         `json:"thumbnail_path"`
+		DisplayOrder  int            `json:"display_order"`
+		LinkURL       sql.NullString `json:"link_url"`
 	}
 
 	var images []SliderImage
 	for rows.Next() {
 		var img SliderImage
-		if err := rows.Scan(&img.ID, &img.ImagePath, &img.DisplayOrder, &img.LinkURL); err != nil {
+		if err := rows.Scan(&img.ID, &img.ImagePath, &img.CardPath, &img.ThumbnailPath, &img.DisplayOrder, &img.LinkURL); err != nil {
 			continue
 		}
 		images = append(images, img)
@@ -69,7 +74,7 @@ func GetSliderImages(c *gin.Context) {
 // @Accept multipart/form-data
 // @Produce json
 // @Security BearerAuth
-// @Param image formData file true "Image file (jpg, jpeg, png, gif, webp)"
+// @Param image formData file true "Image file (jpg, png, gif, webp; max 10MB). Re-encoded as JPEG in thumb/card/full sizes"
 // @Param link_url formData string false "Link URL for navigation"
 // @Success 200 {object} map[string]interface{} "Upload successful with image info"
 // @Failure 400 {object} map[string]string "Invalid file"
@@ -91,35 +96,27 @@ func UploadSliderImage(c *gin.Context) {
 	// รับ link_url จาก form (optional)
 	linkURL := c.PostForm("link_url")
 
-	// ตรวจสอบประเภทไฟล์
-	ext := strings.ToLower(filepath.Ext(file.Filename))
-	allowedExts := map[string]bool{".jpg": true, ".jpeg": true, ".png": true, ".gif": true, ".webp": true}
-	if !allowedExts[ext] {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"success": false,
-			"message": "Invalid file type. Only jpg, jpeg, png, gif, webp allowed",
-		})
-		return
-	}
-
-	// สร้างชื่อไฟล์ unique
-	uniqueFilename := fmt.Sprintf("slider_%s%s", uuid.New().String(), ext)
-	uploadDir := "./uploads/images"
-	
-	// สร้างโฟลเดอร์ถ้ายังไม่มี
-	if err := os.MkdirAll(uploadDir, 0755); err != nil {
+	// ตรวจสอบเนื้อหาไฟล์และสร้างรูปขนาด thumb/card/full
+	variants, err := processUploadedImage(file, maxImageUploadSize, utils.SliderVariants)
+	if err != nil {
+		if validationErr, ok := err.(*ImageValidationError); ok {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"success": false,
+				"message": validationErr.Message,
+			})
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"success": false,
-			"message": "Failed to create upload directory",
+			"message": "Failed to process image",
 			"error":   err.Error(),
 		})
 		return
 	}
 
-	filePath := filepath.Join(uploadDir, uniqueFilename)
-
-	// บันทึกไฟล์
-	if err := c.SaveUploadedFile(file, filePath); err != nil {
+	// บันทึกไฟล์ (ชื่อ unique)
+	saved, err := writeImageVariants("./uploads/images", fmt.Sprintf("slider_%s", uuid.New().String()), variants)
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"success": false,
 			"message": "Failed to save image file",
@@ -127,6 +124,7 @@ func UploadSliderImage(c *gin.Context) {
 		})
 		return
 	}
+	filePath := variantPath(saved, "full")
 
 	// หา display_order ล่าสุด
 	var maxOrder sql.NullInt64
@@ -166,11 +164,25 @@ func UploadSliderImage(c *gin.Context) {
 		args = []interface{}{filePath, nextOrder}
 	}
 
-	err = config.DB.QueryRow(query, args...).Scan(&imageID)
+	tx, err := config.DB.Begin()
+	if err == nil {
+		defer tx.Rollback()
+		err = tx.QueryRow(query, args...).Scan(&imageID)
+	}
+	if err == nil {
+		err = insertImageVariants(tx, sliderOwner(imageID), saved)
+	}
+	if err == nil {
+		err = recordAudit(tx, c, AuditSliderCreate, AuditTargetSlider, imageID, nil,
+			gin.H{"image_path": filePath, "display_order": nextOrder, "link_url": linkURL}, nil)
+	}
+	if err == nil {
+		err = tx.Commit()
+	}
 
 	if err != nil {
 		// ลบไฟล์ถ้าบันทึก DB ไม่สำเร็จ
-		os.Remove(filePath)
+		removeVariantFiles(saved)
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"success": false,
 			"message": "Failed to save image info to database",
@@ -183,10 +195,12 @@ func UploadSliderImage(c *gin.Context) {
 		"success": true,
 		"message": "Image uploaded successfully",
 		"data": gin.H{
-			"id":            imageID,
-			"image_path":    filePath,
-			"display_order": nextOrder,
-			"link_url":      linkURL,
+			"id":             imageID,
+			"image_path":     filePath,
+			"card_path":      variantPath(saved, "card"),
+			"thumbnail_path": variantPath(saved, "thumb"),
+			"display_order":  nextOrder,
+			"link_url":       linkURL,
 		},
 	})
 }
@@ -241,9 +255,30 @@ func UpdateSliderOrder(c *gin.Context) {
 		return
 	}
 
-	// อัปเดตลำดับแต่ละรายการ
+	// อัปเดตลำดับแต่ละรายการ (บันทึกค่าเดิมไว้ใน audit log)
 	for _, update := range updates {
-		var err error
+		var before struct {
+			DisplayOrder int     `json:"display_order"`
+			LinkURL      *string `json:"link_url"`
+		}
+		err := tx.QueryRow(`
+			SELECT display_order, link_url FROM slider_images WHERE id = $1 FOR UPDATE
+		`, update.ID).Scan(&before.DisplayOrder, &before.LinkURL)
+		if err == sql.ErrNoRows {
+			continue
+		}
+		if err != nil {
+			tx.Rollback()
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"success": false,
+				"message": "Failed to update order",
+				"error":   err.Error(),
+			})
+			return
+		}
+
+		after := before
+		after.DisplayOrder = update.Order
 		if update.LinkURL != nil {
 			// Update both order and link
 			_, err = tx.Exec(`
@@ -259,7 +294,13 @@ func UpdateSliderOrder(c *gin.Context) {
 				WHERE id = $2
 			`, update.Order, update.ID)
 		}
-		
+		if update.LinkURL != nil {
+			after.LinkURL = update.LinkURL
+		}
+		if err == nil && !reflect.DeepEqual(before, after) {
+			err = recordAudit(tx, c, AuditSliderUpdate, AuditTargetSlider, update.ID, before, after, nil)
+		}
+
 		if err != nil {
 			tx.Rollback()
 			c.JSON(http.StatusInternalServerError, gin.H{
@@ -312,9 +353,24 @@ func DeleteSliderImage(c *gin.Context) {
 		return
 	}
 
-	// ดึงข้อมูลรูปเพื่อลบไฟล์
+	tx, err := config.DB.Begin()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"message": "Failed to start transaction",
+			"error":   err.Error(),
+		})
+		return
+	}
+	defer tx.Rollback()
+
+	// ดึงข้อมูลรูปเพื่อลบไฟล์ (และเก็บไว้ใน audit log)
 	var imagePath string
-	err = config.DB.QueryRow("SELECT image_path FROM slider_images WHERE id = $1", imageID).Scan(&imagePath)
+	var displayOrder int
+	var linkURL sql.NullString
+	err = tx.QueryRow(`
+		SELECT image_path, display_order, link_url FROM slider_images WHERE id = $1 FOR UPDATE
+	`, imageID).Scan(&imagePath, &displayOrder, &linkURL)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			c.JSON(http.StatusNotFound, gin.H{
@@ -331,8 +387,18 @@ func DeleteSliderImage(c *gin.Context) {
 		return
 	}
 
-	// ลบข้อมูลจากฐานข้อมูล
-	_, err = config.DB.Exec("DELETE FROM slider_images WHERE id = $1", imageID)
+	// ลบข้อมูลรูปทุกขนาดและข้อมูลรูปจากฐานข้อมูล
+	variantPaths, err := deleteImageVariants(tx, sliderOwner(imageID))
+	if err == nil {
+		_, err = tx.Exec("DELETE FROM slider_images WHERE id = $1", imageID)
+	}
+	if err == nil {
+		err = recordAudit(tx, c, AuditSliderDelete, AuditTargetSlider, imageID,
+			gin.H{"image_path": imagePath, "display_order": displayOrder, "link_url": linkURL.String}, nil, nil)
+	}
+	if err == nil {
+		err = tx.Commit()
+	}
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"success": false,
@@ -347,6 +413,11 @@ func DeleteSliderImage(c *gin.Context) {
 		// ไม่ return error ถ้าลบไฟล์ไม่สำเร็จ (อาจถูกลบไปแล้ว)
 		fmt.Printf("Warning: Failed to delete image file: %v\n", err)
 	}
+	for _, path := range variantPaths {
+		if path != imagePath {
+			os.Remove(path)
+		}
+	}
 
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
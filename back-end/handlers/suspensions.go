@@ -0,0 +1,349 @@
+package handlers
+
+import (
+	"back-end/config"
+	"back-end/permissions"
+	"database/sql"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lib/pq"
+)
+
+// สถานะบัญชี
+const (
+	AccountActive    = "active"
+	AccountSuspended = "suspended" // ระงับชั่วคราวจนถึง suspended_until
+	AccountBanned    = "banned"    // แบนถาวรจนกว่า admin จะยกเลิก
+)
+
+// accountStatusSQL - สถานะบัญชีคำนวณจากคอลัมน์ของ users (alias u)
+const accountStatusSQL = `CASE WHEN u.banned_at IS NOT NULL THEN 'banned'
+	WHEN u.suspended_until > NOW() THEN 'suspended' ELSE 'active' END`
+
+// ระยะเวลาระงับบัญชี (วัน)
+const (
+	defaultSuspendDays = 7
+	maxSuspendDays     = 365
+)
+
+// AccountStatus - สถานะบัญชีของ user
+type AccountStatus struct {
+	Status         string     `json:"status" example:"suspended"`
+	Reason         string     `json:"reason"`
+	SuspendedUntil *time.Time `json:"suspended_until"`
+	BannedAt       *time.Time `json:"banned_at"`
+	TokenVersion   int        `json:"-"`
+}
+
+// SuspendUserInput - ข้อมูลสำหรับระงับบัญชี
+type SuspendUserInput struct {
+	Reason string `json:"reason" binding:"required" example:"สแปมรีวิว"`
+	Days   int    `json:"days" example:"7"` // 1-365 (ค่าเริ่มต้น 7 วัน)
+}
+
+// BanUserInput - ข้อมูลสำหรับแบนหรือยกเลิกการระงับ
+type BanUserInput struct {
+	Reason string `json:"reason" binding:"required" example:"ขายชีทที่คัดลอกมาซ้ำหลายครั้ง"`
+}
+
+// loadAccountStatus - ดึงสถานะบัญชีและ token version ปัจจุบัน
+func loadAccountStatus(q sqlQuerier, userID int) (*AccountStatus, error) {
+	var status AccountStatus
+	var suspendedUntil, bannedAt sql.NullTime
+	var suspensionReason, banReason string
+	err := q.QueryRow(`
+		SELECT `+accountStatusSQL+`, u.suspended_until, COALESCE(u.suspension_reason, ''),
+			u.banned_at, COALESCE(u.ban_reason, ''), u.token_version
+		FROM users u WHERE u.id = $1
+	`, userID).Scan(&status.Status, &suspendedUntil, &suspensionReason, &bannedAt, &banReason, &status.TokenVersion)
+	if err != nil {
+		return nil, err
+	}
+
+	switch status.Status {
+	case AccountBanned:
+		status.Reason = banReason
+		status.BannedAt = &bannedAt.Time
+	case AccountSuspended:
+		status.Reason = suspensionReason
+		status.SuspendedUntil = &suspendedUntil.Time
+	}
+	return &status, nil
+}
+
+// respondAccountBlocked - ตอบกลับ 403 ถ้าบัญชีถูกระงับหรือแบน (คืน true ถ้าตอบไปแล้ว)
+func respondAccountBlocked(c *gin.Context, status *AccountStatus) bool {
+	switch status.Status {
+	case AccountBanned:
+		c.JSON(http.StatusForbidden, gin.H{
+			"error":   "Account banned",
+			"message": status.Reason,
+		})
+	case AccountSuspended:
+		c.JSON(http.StatusForbidden, gin.H{
+			"error":           "Account suspended",
+			"message":         status.Reason,
+			"suspended_until": status.SuspendedUntil,
+		})
+	default:
+		return false
+	}
+	return true
+}
+
+// revokeUserSessions - เพิ่ม token version (access token ที่ออกไปแล้วใช้ไม่ได้ทันที) และยกเลิก refresh token ทั้งหมด
+func revokeUserSessions(db notifyDB, userID int) error {
+	if _, err := db.Exec(`UPDATE users SET token_version = token_version + 1 WHERE id = $1`, userID); err != nil {
+		return err
+	}
+	_, err := db.Exec(`UPDATE refresh_tokens SET is_revoked = true WHERE user_id = $1 AND is_revoked = false`, userID)
+	return err
+}
+
+// suspendUser - ระงับบัญชีถึงเวลาที่กำหนด และให้หลุดจากระบบทันที
+func suspendUser(db notifyDB, userID int, until time.Time, reason string) error {
+	_, err := db.Exec(`
+		UPDATE users SET suspended_until = $1, suspension_reason = $2 WHERE id = $3
+	`, until, reason, userID)
+	if err != nil {
+		return err
+	}
+	return revokeUserSessions(db, userID)
+}
+
+// banUser - แบนบัญชี ให้หลุดจากระบบทันที และเอา note/bundle ของ seller ออกจากการขาย (คืน ID ของ note ที่ถูก delist)
+// note ที่ถูก delist กู้คืนได้ทีละรายการด้วย RestoreNote
+func banUser(tx *sql.Tx, userID int, reason string) ([]int, error) {
+	_, err := tx.Exec(`UPDATE users SET banned_at = NOW(), ban_reason = $1 WHERE id = $2`, reason, userID)
+	if err != nil {
+		return nil, err
+	}
+	if err := revokeUserSessions(tx, userID); err != nil {
+		return nil, err
+	}
+
+	rows, err := tx.Query(`
+		UPDATE notes_for_sale
+		SET status_before_delete = status, status = 'delisted', deleted_at = NOW()
+		WHERE seller_id = $1 AND deleted_at IS NULL
+		RETURNING id
+	`, userID)
+	if err != nil {
+		return nil, err
+	}
+	noteIDs := []int{}
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		noteIDs = append(noteIDs, id)
+	}
+	rows.Close()
+
+	if len(noteIDs) > 0 {
+		if _, err := tx.Exec(`DELETE FROM cart WHERE note_id = ANY($1)`, pq.Array(noteIDs)); err != nil {
+			return nil, err
+		}
+	}
+	if _, err := tx.Exec(`UPDATE bundles SET status = 'inactive', updated_at = NOW() WHERE seller_id = $1 AND status = 'available'`, userID); err != nil {
+		return nil, err
+	}
+	return noteIDs, nil
+}
+
+// isAdminUser - ใช้กันไม่ให้ระงับ/แบน admin และตรวจสิทธิ์การมอบ role
+func isAdminUser(userID int) (bool, error) {
+	roles, err := getUserRoles(userID)
+	if err != nil {
+		return false, err
+	}
+	for _, role := range roles {
+		if role == permissions.AdminRole {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// moderateUser - โครงของ endpoint ระงับ/แบน/ยกเลิก: ตรวจ user เป้าหมาย ทำ apply ใน transaction แล้วบันทึก audit log
+func moderateUser(c *gin.Context, action string, apply func(tx *sql.Tx, userID int) (gin.H, error)) {
+	userID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+	if userID == c.GetInt("user_id") {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "You cannot moderate your own account"})
+		return
+	}
+	isAdmin, err := isAdminUser(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+	if isAdmin {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Admins cannot be suspended or banned"})
+		return
+	}
+
+	tx, err := config.DB.Begin()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+	defer tx.Rollback()
+
+	// lock แถวของ user ไว้กันการสั่งพร้อมกัน
+	if _, err := tx.Exec(`SELECT 1 FROM users WHERE id = $1 FOR UPDATE`, userID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+	before, err := loadAccountStatus(tx, userID)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+
+	extra, err := apply(tx, userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Database error",
+			"message": err.Error(),
+		})
+		return
+	}
+	after, err := loadAccountStatus(tx, userID)
+	if err == nil {
+		err = recordAudit(tx, c, action, AuditTargetUser, userID, before, after, extra)
+	}
+	if err == nil {
+		err = tx.Commit()
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Database error",
+			"message": err.Error(),
+		})
+		return
+	}
+
+	response := gin.H{"success": true, "user_id": userID, "account": after}
+	for k, v := range extra {
+		response[k] = v
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// SuspendUser godoc
+// @Summary Suspend a user (Admin)
+// @Description Suspend an account for a number of days. The user is logged out immediately and cannot log in or refresh tokens until the suspension ends
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "User ID"
+// @Param request body SuspendUserInput true "Reason and duration"
+// @Success 200 {object} map[string]interface{} "New account status"
+// @Failure 400 {object} map[string]string "Invalid input"
+// @Failure 401 {object} map[string]string "Unauthorized"
+// @Failure 403 {object} map[string]string "Admins cannot be suspended or banned"
+// @Failure 404 {object} map[string]string "User not found"
+// @Failure 500 {object} map[string]string "Database error"
+// @Router /api/admin/users/{id}/suspend [post]
+func SuspendUser(c *gin.Context) {
+	var input SuspendUserInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format"})
+		return
+	}
+	input.Reason = strings.TrimSpace(input.Reason)
+	if input.Days == 0 {
+		input.Days = defaultSuspendDays
+	}
+	if input.Reason == "" || input.Days < 1 || input.Days > maxSuspendDays {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid input",
+			"message": fmt.Sprintf("reason is required and days must be between 1 and %d", maxSuspendDays),
+		})
+		return
+	}
+
+	moderateUser(c, AuditUserSuspend, func(tx *sql.Tx, userID int) (gin.H, error) {
+		until := time.Now().AddDate(0, 0, input.Days)
+		return gin.H{"reason": input.Reason, "days": input.Days}, suspendUser(tx, userID, until, input.Reason)
+	})
+}
+
+// BanUser godoc
+// @Summary Ban a user (Admin)
+// @Description Ban an account until reinstated. The user is logged out immediately, and all of their notes and bundles are taken off sale (notes can be restored one by one)
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "User ID"
+// @Param request body BanUserInput true "Reason"
+// @Success 200 {object} map[string]interface{} "New account status and delisted note IDs"
+// @Failure 400 {object} map[string]string "Invalid input"
+// @Failure 401 {object} map[string]string "Unauthorized"
+// @Failure 403 {object} map[string]string "Admins cannot be suspended or banned"
+// @Failure 404 {object} map[string]string "User not found"
+// @Failure 500 {object} map[string]string "Database error"
+// @Router /api/admin/users/{id}/ban [post]
+func BanUser(c *gin.Context) {
+	var input BanUserInput
+	if err := c.ShouldBindJSON(&input); err != nil || strings.TrimSpace(input.Reason) == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format", "message": "reason is required"})
+		return
+	}
+	reason := strings.TrimSpace(input.Reason)
+
+	moderateUser(c, AuditUserBan, func(tx *sql.Tx, userID int) (gin.H, error) {
+		noteIDs, err := banUser(tx, userID, reason)
+		return gin.H{"reason": reason, "delisted_note_ids": noteIDs}, err
+	})
+}
+
+// ReinstateUser godoc
+// @Summary Lift a suspension or ban (Admin)
+// @Description Clear both the suspension and the ban so the user can log in again. Delisted notes are not restored automatically
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "User ID"
+// @Param request body BanUserInput true "Reason"
+// @Success 200 {object} map[string]interface{} "New account status"
+// @Failure 400 {object} map[string]string "Invalid input"
+// @Failure 401 {object} map[string]string "Unauthorized"
+// @Failure 403 {object} map[string]string "Admins cannot be suspended or banned"
+// @Failure 404 {object} map[string]string "User not found"
+// @Failure 500 {object} map[string]string "Database error"
+// @Router /api/admin/users/{id}/reinstate [post]
+func ReinstateUser(c *gin.Context) {
+	var input BanUserInput
+	if err := c.ShouldBindJSON(&input); err != nil || strings.TrimSpace(input.Reason) == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format", "message": "reason is required"})
+		return
+	}
+	reason := strings.TrimSpace(input.Reason)
+
+	moderateUser(c, AuditUserReinstate, func(tx *sql.Tx, userID int) (gin.H, error) {
+		_, err := tx.Exec(`
+			UPDATE users SET suspended_until = NULL, suspension_reason = NULL, banned_at = NULL, ban_reason = NULL
+			WHERE id = $1
+		`, userID)
+		return gin.H{"reason": reason}, err
+	})
+}
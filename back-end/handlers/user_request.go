@@ -35,7 +35,8 @@ func GetMe(c *gin.Context){
     var avatarURL sql.NullString
 
     query := `
-		SELECT id, username, email, fullname, phone, avatar_url, created_at 
+		SELECT id, username, email, fullname, phone, avatar_url, COALESCE(bio, ''), created_at,
+			seller_verified_at IS NOT NULL
 		FROM users 
 		WHERE id = $1
 	`
@@ -49,7 +50,9 @@ func GetMe(c *gin.Context){
         &user.FullName,
         &user.Phone,
         &avatarURL,
+        &user.Bio,
         &user.CreatedAt,
+        &user.SellerVerified,
     )
 
     if err != nil {
@@ -72,7 +75,7 @@ func GetMe(c *gin.Context){
 
 // GetUserByID godoc
 // @Summary Get user by ID
-// @Description Get a user's public profile by their ID
+// @Description Get a user's profile by their ID. Email and phone are empty unless the viewer is the user or an admin
 // @Tags users
 // @Accept json
 // @Produce json
@@ -95,7 +98,8 @@ func GetUserByID(c *gin.Context) {
     var avatarURL sql.NullString
 
 	query := `
-		SELECT id, username, email, fullname, phone, avatar_url, created_at
+		SELECT id, username, email, fullname, phone, avatar_url, COALESCE(bio, ''), created_at,
+			seller_verified_at IS NOT NULL
 		FROM users
 		WHERE id = $1
 	`
@@ -109,7 +113,9 @@ func GetUserByID(c *gin.Context) {
 		&user.FullName,
 		&user.Phone,
 		&avatarURL,
+		&user.Bio,
 		&user.CreatedAt,
+		&user.SellerVerified,
 	)
 
 	if err != nil {
@@ -126,5 +132,15 @@ func GetUserByID(c *gin.Context) {
 		user.AvatarURL = avatarURL.String
 	}
 
+	// email และเบอร์โทรเห็นได้เฉพาะเจ้าของบัญชีและ admin
+	canView, err := canViewContact(c, userId)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "db error"})
+		return
+	}
+	if !canView {
+		user.Email, user.Phone = "", ""
+	}
+
 	c.JSON(http.StatusOK, user)
 }
\ No newline at end of file
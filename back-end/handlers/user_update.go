@@ -3,19 +3,26 @@ package handlers
 import (
 	"back-end/config"
 	"database/sql"
+	"fmt"
 	"net/http"
+	"strings"
+	"unicode/utf8"
 
 	"github.com/gin-gonic/gin"
 )
 
 // UpdateUserRequest - ข้อมูลสำหรับอัปเดตโปรไฟล์
 type UpdateUserRequest struct {
-	Username string `json:"username"`
-	FullName string `json:"fullname"`
-	Email    string `json:"email"`
-	Phone    string `json:"phone"`
+	Username string  `json:"username"`
+	FullName string  `json:"fullname"`
+	Email    string  `json:"email"`
+	Phone    string  `json:"phone"`
+	Bio      *string `json:"bio"` // แนะนำตัวในหน้าโปรไฟล์ผู้ขาย (ไม่ส่ง = ไม่เปลี่ยน, "" = ลบ)
 }
 
+// maxBioLength - ความยาวสูงสุดของ bio (ตัวอักษร)
+const maxBioLength = 500
+
 // UpdateUserProfile godoc
 // @Summary อัปเดตข้อมูลโปรไฟล์ผู้ใช้
 // @Description อัปเดตข้อมูลส่วนตัวของผู้ใช้ที่ล็อกอินอยู่
@@ -48,6 +55,16 @@ func UpdateUserProfile(c *gin.Context) {
 		})
 		return
 	}
+	if req.Bio != nil {
+		bio := strings.TrimSpace(*req.Bio)
+		if utf8.RuneCountInString(bio) > maxBioLength {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": fmt.Sprintf("Bio must be at most %d characters", maxBioLength),
+			})
+			return
+		}
+		req.Bio = &bio
+	}
 
 	// ตรวจสอบว่า username ไม่ซ้ำกับผู้อื่น
 	if req.Username != "" {
@@ -99,9 +116,10 @@ func UpdateUserProfile(c *gin.Context) {
 		SET username = COALESCE(NULLIF($1, ''), username),
 		    fullname = COALESCE(NULLIF($2, ''), fullname),
 		    email = COALESCE(NULLIF($3, ''), email),
-		    phone = COALESCE(NULLIF($4, ''), phone)
-		WHERE id = $5
-		RETURNING id, username, email, fullname, phone, avatar_url, created_at
+		    phone = COALESCE(NULLIF($4, ''), phone),
+		    bio = COALESCE($5, bio)
+		WHERE id = $6
+		RETURNING id, username, email, fullname, phone, avatar_url, COALESCE(bio, ''), created_at
 	`
 
 	var user struct {
@@ -111,6 +129,7 @@ func UpdateUserProfile(c *gin.Context) {
 		FullName  string         `json:"fullname"`
 		Phone     string         `json:"phone"`
 		AvatarURL sql.NullString `json:"avatar_url"`
+		Bio       string         `json:"bio"`
 		CreatedAt string         `json:"created_at"`
 	}
 
@@ -121,6 +140,7 @@ func UpdateUserProfile(c *gin.Context) {
 		req.FullName,
 		req.Email,
 		req.Phone,
+		req.Bio,
 		userID,
 	).Scan(
 		&user.ID,
@@ -129,6 +149,7 @@ func UpdateUserProfile(c *gin.Context) {
 		&user.FullName,
 		&user.Phone,
 		&avatarURL,
+		&user.Bio,
 		&user.CreatedAt,
 	)
 
@@ -148,6 +169,7 @@ func UpdateUserProfile(c *gin.Context) {
 		"fullname":   user.FullName,
 		"phone":      user.Phone,
 		"avatar_url": "",
+		"bio":        user.Bio,
 		"created_at": user.CreatedAt,
 	}
 
@@ -0,0 +1,643 @@
+package handlers
+
+import (
+	"back-end/config"
+	"back-end/jobs"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lib/pq"
+)
+
+const (
+	maxWebhookURLLength      = 2048
+	maxWebhookEndpointsOwned = 10 // จำนวน endpoint ต่อ seller (หรือของระบบ)
+)
+
+// WebhookEndpoint - ปลายทางที่สมัครรับ webhook
+type WebhookEndpoint struct {
+	ID         int       `json:"id"`
+	UserID     *int      `json:"user_id"` // null = endpoint ของระบบ (รับ event ทั้งหมด)
+	URL        string    `json:"url"`
+	Events     []string  `json:"events"`
+	IsActive   bool      `json:"is_active"`
+	Secret     string    `json:"secret,omitempty"` // แสดงครั้งเดียวตอนสร้าง
+	SecretHint string    `json:"secret_hint"`      // 4 ตัวท้ายของ secret
+	CreatedAt  time.Time `json:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
+
+// WebhookInput - ข้อมูลสำหรับสร้าง/แก้ไข endpoint
+type WebhookInput struct {
+	URL      string   `json:"url" example:"https://example.com/webhooks/noteshop"`
+	Events   []string `json:"events" example:"order.paid,review.created"`
+	IsActive *bool    `json:"is_active"`
+}
+
+// WebhookDelivery - การส่ง webhook หนึ่งรายการใน delivery log
+type WebhookDelivery struct {
+	ID             int64           `json:"id"`
+	EndpointID     int             `json:"endpoint_id"`
+	URL            string          `json:"url"`
+	EventType      string          `json:"event_type"`
+	Status         string          `json:"status"`
+	Attempts       int             `json:"attempts"`
+	ResponseStatus *int            `json:"response_status"`
+	ResponseBody   string          `json:"response_body"`
+	LastError      *string         `json:"last_error"`
+	JobStatus      *string         `json:"job_status"`
+	Payload        json.RawMessage `json:"payload" swaggertype:"object"`
+	CreatedAt      time.Time       `json:"created_at"`
+	LastAttemptAt  *time.Time      `json:"last_attempt_at"`
+	DeliveredAt    *time.Time      `json:"delivered_at"`
+}
+
+// webhookEditor - ผู้จัดการ webhook (admin จัดการได้ทุก endpoint และสร้าง endpoint ของระบบ, seller จัดการได้เฉพาะของตัวเอง)
+type webhookEditor struct {
+	isAdmin bool
+	userID  int
+}
+
+// validate - ตรวจสอบ url และ event (partial = แก้ไข ส่งมาเฉพาะ field ที่ต้องการเปลี่ยน)
+func (in *WebhookInput) validate(partial bool) error {
+	in.URL = strings.TrimSpace(in.URL)
+	if in.URL != "" || !partial {
+		u, err := url.Parse(in.URL)
+		if err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+			return &NoteFieldError{Field: "url", Message: "must be an absolute http(s) URL"}
+		}
+		if len(in.URL) > maxWebhookURLLength {
+			return &NoteFieldError{Field: "url", Message: fmt.Sprintf("must be at most %d characters", maxWebhookURLLength)}
+		}
+		if u.User != nil {
+			return &NoteFieldError{Field: "url", Message: "must not contain credentials"}
+		}
+	}
+
+	if in.Events != nil || !partial {
+		if len(in.Events) == 0 {
+			return &NoteFieldError{Field: "events", Message: "at least one event is required"}
+		}
+		seen := map[string]bool{}
+		events := []string{}
+		for _, event := range in.Events {
+			if !isWebhookEvent(event) {
+				return &NoteFieldError{Field: "events", Message: "unknown event: " + event}
+			}
+			if !seen[event] {
+				seen[event] = true
+				events = append(events, event)
+			}
+		}
+		in.Events = events
+	}
+	return nil
+}
+
+// ownerFilter - เงื่อนไขเจ้าของ endpoint (alias e) ต่อท้าย args
+func (editor webhookEditor) ownerFilter(args []interface{}) (string, []interface{}) {
+	if editor.isAdmin {
+		return "TRUE", args
+	}
+	args = append(args, editor.userID)
+	return fmt.Sprintf("e.user_id = $%d", len(args)), args
+}
+
+const webhookEndpointColumns = `e.id, e.user_id, e.url, e.events, e.is_active, RIGHT(e.secret, 4), e.created_at, e.updated_at`
+
+func scanWebhookEndpoint(row interface{ Scan(...interface{}) error }) (*WebhookEndpoint, error) {
+	var endpoint WebhookEndpoint
+	var userID sql.NullInt64
+	err := row.Scan(&endpoint.ID, &userID, &endpoint.URL, pq.Array(&endpoint.Events), &endpoint.IsActive,
+		&endpoint.SecretHint, &endpoint.CreatedAt, &endpoint.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	if userID.Valid {
+		id := int(userID.Int64)
+		endpoint.UserID = &id
+	}
+	return &endpoint, nil
+}
+
+// respondWebhookInputError - ตอบกลับ error จากการตรวจข้อมูล (คืน false ถ้าไม่ใช่ error ของข้อมูล)
+func respondWebhookInputError(c *gin.Context, err error) bool {
+	fieldErr, ok := err.(*NoteFieldError)
+	if !ok {
+		return false
+	}
+	c.JSON(http.StatusBadRequest, gin.H{
+		"error":   "Invalid input",
+		"field":   fieldErr.Field,
+		"message": fieldErr.Message,
+	})
+	return true
+}
+
+func handleCreateWebhook(c *gin.Context, editor webhookEditor) {
+	var input WebhookInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid input",
+			"message": err.Error(),
+		})
+		return
+	}
+	if err := input.validate(false); err != nil {
+		respondWebhookInputError(c, err)
+		return
+	}
+
+	// admin สร้าง endpoint ของระบบ (ไม่มีเจ้าของ), seller สร้าง endpoint ของตัวเอง
+	var ownerID sql.NullInt64
+	if !editor.isAdmin {
+		ownerID = sql.NullInt64{Int64: int64(editor.userID), Valid: true}
+	}
+
+	var count int
+	err := config.DB.QueryRow(`
+		SELECT COUNT(*) FROM webhook_endpoints WHERE user_id IS NOT DISTINCT FROM $1
+	`, ownerID).Scan(&count)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+	if count >= maxWebhookEndpointsOwned {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": fmt.Sprintf("You can have at most %d webhook endpoints", maxWebhookEndpointsOwned),
+		})
+		return
+	}
+
+	secret, err := newWebhookSecret()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate secret"})
+		return
+	}
+	active := input.IsActive == nil || *input.IsActive
+
+	endpoint, err := scanWebhookEndpoint(config.DB.QueryRow(`
+		INSERT INTO webhook_endpoints AS e (user_id, url, secret, events, is_active, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, NOW(), NOW())
+		RETURNING `+webhookEndpointColumns,
+		ownerID, input.URL, secret, pq.Array(input.Events), active))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Database error",
+			"message": err.Error(),
+		})
+		return
+	}
+	endpoint.Secret = secret
+
+	c.JSON(http.StatusCreated, gin.H{
+		"success": true,
+		"message": "Webhook endpoint created. Store the secret now; it will not be shown again",
+		"data":    endpoint,
+	})
+}
+
+func handleListWebhooks(c *gin.Context, editor webhookEditor) {
+	where, args := editor.ownerFilter(nil)
+	rows, err := config.DB.Query(`
+		SELECT `+webhookEndpointColumns+` FROM webhook_endpoints e
+		WHERE `+where+`
+		ORDER BY e.created_at DESC
+	`, args...)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Database error",
+			"message": err.Error(),
+		})
+		return
+	}
+	defer rows.Close()
+
+	endpoints := []WebhookEndpoint{}
+	for rows.Next() {
+		endpoint, err := scanWebhookEndpoint(rows)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error":   "Error scanning webhook data",
+				"message": err.Error(),
+			})
+			return
+		}
+		endpoints = append(endpoints, *endpoint)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    endpoints,
+		"count":   len(endpoints),
+	})
+}
+
+func handleUpdateWebhook(c *gin.Context, editor webhookEditor) {
+	var input WebhookInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid input",
+			"message": err.Error(),
+		})
+		return
+	}
+	if err := input.validate(true); err != nil {
+		respondWebhookInputError(c, err)
+		return
+	}
+
+	var events interface{}
+	if input.Events != nil {
+		events = pq.Array(input.Events)
+	}
+	args := []interface{}{c.Param("id"), input.URL, events, input.IsActive}
+	where, args := editor.ownerFilter(args)
+
+	endpoint, err := scanWebhookEndpoint(config.DB.QueryRow(`
+		UPDATE webhook_endpoints e
+		SET url = COALESCE(NULLIF($2, ''), e.url),
+			events = COALESCE($3, e.events),
+			is_active = COALESCE($4, e.is_active),
+			updated_at = NOW()
+		WHERE e.id = $1 AND `+where+`
+		RETURNING `+webhookEndpointColumns, args...))
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Webhook endpoint not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Database error",
+			"message": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "Webhook endpoint updated successfully",
+		"data":    endpoint,
+	})
+}
+
+func handleDeleteWebhook(c *gin.Context, editor webhookEditor) {
+	where, args := editor.ownerFilter([]interface{}{c.Param("id")})
+	result, err := config.DB.Exec(`DELETE FROM webhook_endpoints e WHERE e.id = $1 AND `+where, args...)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Database error",
+			"message": err.Error(),
+		})
+		return
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Webhook endpoint not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "Webhook endpoint deleted successfully",
+	})
+}
+
+func handleListWebhookDeliveries(c *gin.Context, editor webhookEditor, endpointID string) {
+	status := c.Query("status")
+	switch status {
+	case "", DeliveryPending, DeliverySucceeded, DeliveryFailed:
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid status"})
+		return
+	}
+
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "50"))
+	if page < 1 {
+		page = 1
+	}
+	if limit < 1 || limit > 200 {
+		limit = 50
+	}
+
+	args := []interface{}{endpointID, status, c.Query("event")}
+	where, args := editor.ownerFilter(args)
+	where = `($1 = '' OR d.endpoint_id::text = $1) AND ($2 = '' OR d.status = $2)
+		AND ($3 = '' OR d.event_type = $3) AND ` + where
+
+	var total int
+	if err := config.DB.QueryRow(`
+		SELECT COUNT(*) FROM webhook_deliveries d JOIN webhook_endpoints e ON d.endpoint_id = e.id
+		WHERE `+where, args...).Scan(&total); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+
+	args = append(args, limit, (page-1)*limit)
+	rows, err := config.DB.Query(fmt.Sprintf(`
+		SELECT d.id, d.endpoint_id, e.url, d.event_type, d.status, d.attempts, d.response_status,
+			COALESCE(d.response_body, ''), d.last_error, j.status, d.payload, d.created_at, d.last_attempt_at, d.delivered_at
+		FROM webhook_deliveries d
+		JOIN webhook_endpoints e ON d.endpoint_id = e.id
+		LEFT JOIN background_jobs j ON d.job_id = j.id
+		WHERE %s
+		ORDER BY d.created_at DESC, d.id DESC
+		LIMIT $%d OFFSET $%d
+	`, where, len(args)-1, len(args)), args...)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Database error",
+			"message": err.Error(),
+		})
+		return
+	}
+	defer rows.Close()
+
+	deliveries := []WebhookDelivery{}
+	for rows.Next() {
+		var d WebhookDelivery
+		var responseStatus sql.NullInt64
+		var lastError, jobStatus sql.NullString
+		var lastAttemptAt, deliveredAt sql.NullTime
+		var payload []byte
+		err := rows.Scan(&d.ID, &d.EndpointID, &d.URL, &d.EventType, &d.Status, &d.Attempts, &responseStatus,
+			&d.ResponseBody, &lastError, &jobStatus, &payload, &d.CreatedAt, &lastAttemptAt, &deliveredAt)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error scanning delivery data"})
+			return
+		}
+		d.Payload = payload
+		if responseStatus.Valid {
+			code := int(responseStatus.Int64)
+			d.ResponseStatus = &code
+		}
+		if lastError.Valid {
+			d.LastError = &lastError.String
+		}
+		if jobStatus.Valid {
+			d.JobStatus = &jobStatus.String
+		}
+		if lastAttemptAt.Valid {
+			d.LastAttemptAt = &lastAttemptAt.Time
+		}
+		if deliveredAt.Valid {
+			d.DeliveredAt = &deliveredAt.Time
+		}
+		deliveries = append(deliveries, d)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    deliveries,
+		"total":   total,
+		"page":    page,
+		"limit":   limit,
+	})
+}
+
+// CreateMyWebhook godoc
+// @Summary Create a webhook endpoint (Seller)
+// @Description Subscribe a URL to events about the current seller's notes (order.paid, note.approved, note.rejected, review.created). Payloads are signed with HMAC-SHA256 using the returned secret: X-Webhook-Signature = "sha256=" + hex(HMAC(secret, X-Webhook-Timestamp + "." + body))
+// @Tags webhooks
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body WebhookInput true "Webhook endpoint"
+// @Success 201 {object} map[string]interface{} "Endpoint created (includes the secret, shown only once)"
+// @Failure 400 {object} map[string]string "Invalid input"
+// @Failure 401 {object} map[string]string "Unauthorized"
+// @Failure 500 {object} map[string]string "Server error"
+// @Router /api/seller/webhooks [post]
+func CreateMyWebhook(c *gin.Context) {
+	handleCreateWebhook(c, webhookEditor{userID: c.GetInt("user_id")})
+}
+
+// GetMyWebhooks godoc
+// @Summary Get my webhook endpoints (Seller)
+// @Description List webhook endpoints of the current seller
+// @Tags webhooks
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} map[string]interface{} "List of endpoints"
+// @Failure 401 {object} map[string]string "Unauthorized"
+// @Failure 500 {object} map[string]string "Server error"
+// @Router /api/seller/webhooks [get]
+func GetMyWebhooks(c *gin.Context) {
+	handleListWebhooks(c, webhookEditor{userID: c.GetInt("user_id")})
+}
+
+// UpdateMyWebhook godoc
+// @Summary Update my webhook endpoint (Seller)
+// @Description Change the URL, events or active state of an endpoint (only the fields sent are changed)
+// @Tags webhooks
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Endpoint ID"
+// @Param request body WebhookInput true "Fields to change"
+// @Success 200 {object} map[string]interface{} "Endpoint updated"
+// @Failure 400 {object} map[string]string "Invalid input"
+// @Failure 401 {object} map[string]string "Unauthorized"
+// @Failure 404 {object} map[string]string "Webhook endpoint not found"
+// @Failure 500 {object} map[string]string "Server error"
+// @Router /api/seller/webhooks/{id} [put]
+func UpdateMyWebhook(c *gin.Context) {
+	handleUpdateWebhook(c, webhookEditor{userID: c.GetInt("user_id")})
+}
+
+// DeleteMyWebhook godoc
+// @Summary Delete my webhook endpoint (Seller)
+// @Description Delete an endpoint and its delivery log
+// @Tags webhooks
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Endpoint ID"
+// @Success 200 {object} map[string]interface{} "Endpoint deleted"
+// @Failure 401 {object} map[string]string "Unauthorized"
+// @Failure 404 {object} map[string]string "Webhook endpoint not found"
+// @Failure 500 {object} map[string]string "Server error"
+// @Router /api/seller/webhooks/{id} [delete]
+func DeleteMyWebhook(c *gin.Context) {
+	handleDeleteWebhook(c, webhookEditor{userID: c.GetInt("user_id")})
+}
+
+// GetMyWebhookDeliveries godoc
+// @Summary Get deliveries of my webhook endpoint (Seller)
+// @Description Delivery log of an endpoint, newest first
+// @Tags webhooks
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Endpoint ID"
+// @Param status query string false "Delivery status (pending, succeeded, failed)"
+// @Param event query string false "Event type"
+// @Param page query int false "Page number (default 1)"
+// @Param limit query int false "Items per page (default 50, max 200)"
+// @Success 200 {object} map[string]interface{} "Delivery log with total"
+// @Failure 400 {object} map[string]string "Invalid status"
+// @Failure 401 {object} map[string]string "Unauthorized"
+// @Failure 500 {object} map[string]string "Server error"
+// @Router /api/seller/webhooks/{id}/deliveries [get]
+func GetMyWebhookDeliveries(c *gin.Context) {
+	handleListWebhookDeliveries(c, webhookEditor{userID: c.GetInt("user_id")}, c.Param("id"))
+}
+
+// CreateWebhook godoc
+// @Summary Create a system webhook endpoint (Admin)
+// @Description Subscribe a URL to events across the whole shop (e.g. for bookkeeping integrations)
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body WebhookInput true "Webhook endpoint"
+// @Success 201 {object} map[string]interface{} "Endpoint created (includes the secret, shown only once)"
+// @Failure 400 {object} map[string]string "Invalid input"
+// @Failure 401 {object} map[string]string "Unauthorized"
+// @Failure 500 {object} map[string]string "Server error"
+// @Router /api/admin/webhooks [post]
+func CreateWebhook(c *gin.Context) {
+	handleCreateWebhook(c, webhookEditor{isAdmin: true})
+}
+
+// GetAllWebhooks godoc
+// @Summary Get all webhook endpoints (Admin)
+// @Description List system endpoints and every seller's endpoints
+// @Tags admin
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} map[string]interface{} "List of endpoints"
+// @Failure 401 {object} map[string]string "Unauthorized"
+// @Failure 500 {object} map[string]string "Server error"
+// @Router /api/admin/webhooks [get]
+func GetAllWebhooks(c *gin.Context) {
+	handleListWebhooks(c, webhookEditor{isAdmin: true})
+}
+
+// UpdateWebhook godoc
+// @Summary Update a webhook endpoint (Admin)
+// @Description Change the URL, events or active state of any endpoint (only the fields sent are changed)
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Endpoint ID"
+// @Param request body WebhookInput true "Fields to change"
+// @Success 200 {object} map[string]interface{} "Endpoint updated"
+// @Failure 400 {object} map[string]string "Invalid input"
+// @Failure 401 {object} map[string]string "Unauthorized"
+// @Failure 404 {object} map[string]string "Webhook endpoint not found"
+// @Failure 500 {object} map[string]string "Server error"
+// @Router /api/admin/webhooks/{id} [put]
+func UpdateWebhook(c *gin.Context) {
+	handleUpdateWebhook(c, webhookEditor{isAdmin: true})
+}
+
+// DeleteWebhook godoc
+// @Summary Delete a webhook endpoint (Admin)
+// @Description Delete any endpoint and its delivery log
+// @Tags admin
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Endpoint ID"
+// @Success 200 {object} map[string]interface{} "Endpoint deleted"
+// @Failure 401 {object} map[string]string "Unauthorized"
+// @Failure 404 {object} map[string]string "Webhook endpoint not found"
+// @Failure 500 {object} map[string]string "Server error"
+// @Router /api/admin/webhooks/{id} [delete]
+func DeleteWebhook(c *gin.Context) {
+	handleDeleteWebhook(c, webhookEditor{isAdmin: true})
+}
+
+// GetWebhookDeliveries godoc
+// @Summary Get webhook delivery log (Admin)
+// @Description Deliveries to all endpoints, newest first, with the state of the retry job
+// @Tags admin
+// @Produce json
+// @Security BearerAuth
+// @Param endpoint_id query int false "Endpoint ID"
+// @Param status query string false "Delivery status (pending, succeeded, failed)"
+// @Param event query string false "Event type"
+// @Param page query int false "Page number (default 1)"
+// @Param limit query int false "Items per page (default 50, max 200)"
+// @Success 200 {object} map[string]interface{} "Delivery log with total"
+// @Failure 400 {object} map[string]string "Invalid status"
+// @Failure 401 {object} map[string]string "Unauthorized"
+// @Failure 500 {object} map[string]string "Server error"
+// @Router /api/admin/webhooks/deliveries [get]
+func GetWebhookDeliveries(c *gin.Context) {
+	handleListWebhookDeliveries(c, webhookEditor{isAdmin: true}, c.Query("endpoint_id"))
+}
+
+// RedeliverWebhook godoc
+// @Summary Redeliver a webhook (Admin)
+// @Description Queue a delivery again with the same payload and event id (e.g. after the receiver fixed an outage)
+// @Tags admin
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Delivery ID"
+// @Success 202 {object} map[string]interface{} "Delivery queued"
+// @Failure 400 {object} map[string]string "Invalid delivery ID"
+// @Failure 401 {object} map[string]string "Unauthorized"
+// @Failure 404 {object} map[string]string "Delivery not found"
+// @Failure 409 {object} map[string]string "Delivery is already queued"
+// @Failure 500 {object} map[string]string "Server error"
+// @Router /api/admin/webhooks/deliveries/{id}/redeliver [post]
+func RedeliverWebhook(c *gin.Context) {
+	deliveryID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid delivery ID"})
+		return
+	}
+
+	tx, err := config.DB.Begin()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+	defer tx.Rollback()
+
+	// ล็อกรายการไว้กันการกดส่งซ้ำพร้อมกัน
+	var jobStatus sql.NullString
+	err = tx.QueryRow(`
+		SELECT j.status FROM webhook_deliveries d
+		LEFT JOIN background_jobs j ON d.job_id = j.id
+		WHERE d.id = $1
+		FOR UPDATE OF d
+	`, deliveryID).Scan(&jobStatus)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Delivery not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+	if jobStatus.String == jobs.StatusPending || jobStatus.String == jobs.StatusRunning {
+		c.JSON(http.StatusConflict, gin.H{"error": "Delivery is already queued"})
+		return
+	}
+
+	if _, err := tx.Exec(`UPDATE webhook_deliveries SET status = 'pending' WHERE id = $1`, deliveryID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+	if err := enqueueWebhookDelivery(tx, deliveryID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to queue delivery"})
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to queue delivery"})
+		return
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{
+		"success": true,
+		"message": "Delivery queued",
+	})
+}
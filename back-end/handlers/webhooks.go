@@ -0,0 +1,336 @@
+package handlers
+
+import (
+	"back-end/config"
+	"back-end/jobs"
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"syscall"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ชนิดของ webhook event
+const (
+	WebhookOrderPaid     = "order.paid"
+	WebhookNoteApproved  = "note.approved"
+	WebhookNoteRejected  = "note.rejected"
+	WebhookReviewCreated = "review.created"
+)
+
+// webhookEvents - event ที่สมัครรับได้
+var webhookEvents = []string{WebhookOrderPaid, WebhookNoteApproved, WebhookNoteRejected, WebhookReviewCreated}
+
+// สถานะการส่ง webhook
+const (
+	DeliveryPending   = "pending"   // รอส่ง / รอ retry
+	DeliverySucceeded = "succeeded" // ปลายทางตอบ 2xx
+	DeliveryFailed    = "failed"    // ส่งไม่สำเร็จครบจำนวนครั้งแล้ว (admin สั่งส่งใหม่ได้)
+)
+
+// header ที่แนบไปกับ webhook
+const (
+	WebhookIDHeader        = "X-Webhook-Id"
+	WebhookEventHeader     = "X-Webhook-Event"
+	WebhookTimestampHeader = "X-Webhook-Timestamp"
+	WebhookSignatureHeader = "X-Webhook-Signature" // "sha256=" + HMAC-SHA256(secret, timestamp + "." + body)
+)
+
+const (
+	webhookTimeout          = 10 * time.Second
+	webhookMaxAttempts      = 8
+	webhookResponseBodySize = 1024 // เก็บ response body ไว้ใน log เท่านี้
+)
+
+// WebhookPayload - body ที่ส่งไปยังปลายทาง
+type WebhookPayload struct {
+	ID        string      `json:"id"`
+	Type      string      `json:"type"`
+	CreatedAt time.Time   `json:"created_at"`
+	Data      interface{} `json:"data"`
+}
+
+// webhookDB - ใช้ได้ทั้ง *sql.DB และ *sql.Tx
+type webhookDB interface {
+	sqlQuerier
+	Exec(query string, args ...interface{}) (sql.Result, error)
+}
+
+// webhookDeliverPayload - payload ของ job webhooks.deliver
+type webhookDeliverPayload struct {
+	DeliveryID int64 `json:"delivery_id"`
+}
+
+// isWebhookEvent - ตรวจว่าเป็น event ที่รู้จัก
+func isWebhookEvent(event string) bool {
+	for _, e := range webhookEvents {
+		if e == event {
+			return true
+		}
+	}
+	return false
+}
+
+// newWebhookSecret - สร้าง secret สำหรับ sign payload ของ endpoint
+func newWebhookSecret() (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return "whsec_" + hex.EncodeToString(buf), nil
+}
+
+// signWebhook - ลายเซ็นของ payload (ปลายทางคำนวณซ้ำด้วย secret เดียวกันแล้วเทียบ)
+func signWebhook(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10) + "."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// queueWebhooks - สร้างรายการส่งและ job ให้ทุก endpoint ที่สมัคร event นี้
+// ownerID > 0 = endpoint ของ user คนนั้น, ownerID = 0 = endpoint ของระบบ (admin สร้าง รับทุก event)
+// ถ้าส่งผ่าน transaction webhook จะถูกส่งก็ต่อเมื่อ transaction commit
+func queueWebhooks(db webhookDB, eventType string, ownerID int, data interface{}) error {
+	query := `SELECT id FROM webhook_endpoints WHERE is_active = true AND $1 = ANY(events) AND user_id IS NULL`
+	args := []interface{}{eventType}
+	if ownerID > 0 {
+		query = `SELECT id FROM webhook_endpoints WHERE is_active = true AND $1 = ANY(events) AND user_id = $2`
+		args = append(args, ownerID)
+	}
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return err
+	}
+	endpointIDs := []int{}
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return err
+		}
+		endpointIDs = append(endpointIDs, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for _, endpointID := range endpointIDs {
+		// แต่ละ endpoint ได้ event id ของตัวเอง ปลายทางใช้กันการประมวลผลซ้ำได้
+		body, err := json.Marshal(WebhookPayload{
+			ID:        uuid.NewString(),
+			Type:      eventType,
+			CreatedAt: time.Now().UTC(),
+			Data:      data,
+		})
+		if err != nil {
+			return err
+		}
+
+		var deliveryID int64
+		err = db.QueryRow(`
+			INSERT INTO webhook_deliveries (endpoint_id, event_type, payload, status, created_at)
+			VALUES ($1, $2, $3, 'pending', NOW())
+			RETURNING id
+		`, endpointID, eventType, body).Scan(&deliveryID)
+		if err != nil {
+			return err
+		}
+		if err := enqueueWebhookDelivery(db, deliveryID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// enqueueWebhookDelivery - สร้าง job ส่ง webhook และผูกไว้กับรายการส่ง
+func enqueueWebhookDelivery(db webhookDB, deliveryID int64) error {
+	jobID, err := jobs.EnqueueTx(db, JobWebhookDeliver, webhookDeliverPayload{DeliveryID: deliveryID},
+		jobs.EnqueueOptions{MaxAttempts: webhookMaxAttempts})
+	if err != nil {
+		return err
+	}
+	_, err = db.Exec(`UPDATE webhook_deliveries SET job_id = $1 WHERE id = $2`, jobID, deliveryID)
+	return err
+}
+
+// emitWebhook - ส่ง event ให้ endpoint ของเจ้าของ (เช่น seller ของ note) และ endpoint ของระบบ
+func emitWebhook(db webhookDB, eventType string, ownerID int, data interface{}) error {
+	if err := queueWebhooks(db, eventType, ownerID, data); err != nil {
+		return err
+	}
+	return queueWebhooks(db, eventType, 0, data)
+}
+
+// emitOrderWebhooks - order.paid: seller แต่ละคนได้เฉพาะรายการของตัวเอง, endpoint ของระบบได้ทั้ง order
+func emitOrderWebhooks(db webhookDB, buyerID int, order *Order) error {
+	sellerItems := map[int][]OrderItem{}
+	sellers := []int{}
+	for _, item := range order.Items {
+		if _, ok := sellerItems[item.SellerID]; !ok {
+			sellers = append(sellers, item.SellerID)
+		}
+		sellerItems[item.SellerID] = append(sellerItems[item.SellerID], item)
+	}
+
+	for _, sellerID := range sellers {
+		var total float64
+		for _, item := range sellerItems[sellerID] {
+			total += item.Price - item.Discount
+		}
+		data := map[string]interface{}{
+			"order_id":   order.ID,
+			"status":     order.Status,
+			"items":      sellerItems[sellerID],
+			"amount":     roundMoney(total),
+			"created_at": order.CreatedAt,
+		}
+		if err := queueWebhooks(db, WebhookOrderPaid, sellerID, data); err != nil {
+			return err
+		}
+	}
+
+	return queueWebhooks(db, WebhookOrderPaid, 0, map[string]interface{}{
+		"order":    order,
+		"buyer_id": buyerID,
+	})
+}
+
+// webhookAllowPrivate - อนุญาตให้ส่งไปยัง IP ภายใน (สำหรับทดสอบในเครื่อง: WEBHOOK_ALLOW_PRIVATE=true)
+func webhookAllowPrivate() bool {
+	return os.Getenv("WEBHOOK_ALLOW_PRIVATE") == "true"
+}
+
+// webhookClient - http client ที่ไม่ยอมต่อไปยัง IP ภายใน (กันการใช้ webhook ยิงเข้า service หลังบ้าน) และไม่ผ่าน proxy
+// ตรวจตอนเชื่อมต่อจริง จึงกันกรณี DNS ชี้ไปยัง IP ภายในภายหลังได้ด้วย
+var webhookClient = &http.Client{
+	Timeout: webhookTimeout,
+	Transport: &http.Transport{
+		DialContext: (&net.Dialer{
+			Timeout: 5 * time.Second,
+			Control: func(network, address string, _ syscall.RawConn) error {
+				if webhookAllowPrivate() {
+					return nil
+				}
+				host, _, err := net.SplitHostPort(address)
+				if err != nil {
+					return err
+				}
+				ip := net.ParseIP(host)
+				if ip == nil || ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() ||
+					ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() {
+					return fmt.Errorf("webhook target %s is not allowed", host)
+				}
+				return nil
+			},
+		}).DialContext,
+		ResponseHeaderTimeout: webhookTimeout,
+	},
+	// ไม่ตาม redirect ปลายทางต้องตอบ 2xx เอง
+	CheckRedirect: func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	},
+}
+
+// deliverWebhookJob - ส่ง webhook หนึ่งรายการ (คืน error เพื่อให้คิว retry แบบ backoff)
+func deliverWebhookJob(ctx context.Context, payload webhookDeliverPayload) error {
+	var endpointID int
+	var url, secret, eventType, status string
+	var active bool
+	var body []byte
+	var attempts int
+	var jobID sql.NullInt64
+	err := config.DB.QueryRowContext(ctx, `
+		SELECT d.endpoint_id, e.url, e.secret, e.is_active, d.event_type, d.payload, d.status, d.attempts, d.job_id
+		FROM webhook_deliveries d
+		JOIN webhook_endpoints e ON d.endpoint_id = e.id
+		WHERE d.id = $1
+	`, payload.DeliveryID).Scan(&endpointID, &url, &secret, &active, &eventType, &body, &status, &attempts, &jobID)
+	if err == sql.ErrNoRows {
+		return nil // endpoint ถูกลบไปแล้ว
+	}
+	if err != nil {
+		return err
+	}
+	if status == DeliverySucceeded || !active {
+		return nil
+	}
+
+	var event WebhookPayload
+	json.Unmarshal(body, &event)
+
+	timestamp := time.Now().Unix()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return recordWebhookAttempt(payload.DeliveryID, attempts+1, jobID, 0, "", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "NoteShop-Webhooks/1.0")
+	req.Header.Set(WebhookIDHeader, event.ID)
+	req.Header.Set(WebhookEventHeader, eventType)
+	req.Header.Set(WebhookTimestampHeader, strconv.FormatInt(timestamp, 10))
+	req.Header.Set(WebhookSignatureHeader, signWebhook(secret, timestamp, body))
+
+	resp, err := webhookClient.Do(req)
+	if err != nil {
+		return recordWebhookAttempt(payload.DeliveryID, attempts+1, jobID, 0, "", err)
+	}
+	defer resp.Body.Close()
+	respBody, _ := io.ReadAll(io.LimitReader(resp.Body, webhookResponseBodySize))
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		err = fmt.Errorf("endpoint responded with status %d", resp.StatusCode)
+	}
+	return recordWebhookAttempt(payload.DeliveryID, attempts+1, jobID, resp.StatusCode, string(respBody), err)
+}
+
+// recordWebhookAttempt - บันทึกผลการส่งแต่ละครั้งลง delivery log แล้วคืน error เดิมให้คิวตัดสินใจ retry
+// ครั้งสุดท้ายที่ยังไม่สำเร็จจะถูกบันทึกเป็น failed
+func recordWebhookAttempt(deliveryID int64, attempts int, jobID sql.NullInt64, statusCode int, respBody string, deliveryErr error) error {
+	status := DeliverySucceeded
+	var errMsg sql.NullString
+	if deliveryErr != nil {
+		status = DeliveryPending
+		errMsg = sql.NullString{String: deliveryErr.Error(), Valid: true}
+
+		// job นี้ลองครบแล้วหรือยัง (attempts ของ job นับรวมครั้งที่กำลังทำอยู่)
+		if jobID.Valid {
+			var jobAttempts, maxAttempts int
+			err := config.DB.QueryRow(`SELECT attempts, max_attempts FROM background_jobs WHERE id = $1`, jobID.Int64).
+				Scan(&jobAttempts, &maxAttempts)
+			if err == nil && jobAttempts >= maxAttempts {
+				status = DeliveryFailed
+			}
+		}
+	}
+
+	var code sql.NullInt64
+	if statusCode > 0 {
+		code = sql.NullInt64{Int64: int64(statusCode), Valid: true}
+	}
+	_, err := config.DB.Exec(`
+		UPDATE webhook_deliveries
+		SET status = $1, attempts = $2, response_status = $3, response_body = $4, last_error = $5,
+			last_attempt_at = NOW(),
+			delivered_at = CASE WHEN $1 = 'succeeded' THEN NOW() ELSE delivered_at END
+		WHERE id = $6
+	`, status, attempts, code, respBody, errMsg, deliveryID)
+	if err != nil && deliveryErr == nil {
+		return err
+	}
+	return deliveryErr
+}
@@ -0,0 +1,322 @@
+package handlers
+
+import (
+	"back-end/config"
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lib/pq"
+)
+
+// WishlistItem - note ที่ user บันทึกไว้
+type WishlistItem struct {
+	NoteID        int       `json:"note_id"`
+	BookTitle     string    `json:"book_title"`
+	Price         float64   `json:"price"`       // ราคาปัจจุบัน
+	SavedPrice    float64   `json:"saved_price"` // ราคาตอนบันทึก
+	PriceDropped  bool      `json:"price_dropped"`
+	ExamTerm      string    `json:"exam_term"`
+	Status        string    `json:"status"`
+	CoverImage    string    `json:"cover_image"`
+	Course        *Course   `json:"course"`
+	Seller        *Seller   `json:"seller"`
+	WishlistCount int       `json:"wishlist_count"`
+	SavedAt       time.Time `json:"saved_at"`
+}
+
+// priceDropPayload - payload ของ job wishlist.price_drop
+type priceDropPayload struct {
+	NoteID   int     `json:"note_id"`
+	OldPrice float64 `json:"old_price"`
+	NewPrice float64 `json:"new_price"`
+}
+
+// viewerID - user ที่ดูอยู่ (0 = ไม่ได้ login)
+func viewerID(c *gin.Context) int {
+	return c.GetInt("user_id")
+}
+
+// wishlistInfo - จำนวนคนที่บันทึกแต่ละ note และ note ที่ viewer บันทึกไว้
+func wishlistInfo(viewer int, noteIDs []int) (map[int]int, map[int]bool, error) {
+	counts := map[int]int{}
+	saved := map[int]bool{}
+	if len(noteIDs) == 0 {
+		return counts, saved, nil
+	}
+
+	rows, err := config.DB.Query(`
+		SELECT note_id, COUNT(*), BOOL_OR(user_id = $2)
+		FROM wishlists WHERE note_id = ANY($1)
+		GROUP BY note_id
+	`, pq.Array(noteIDs), viewer)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var noteID, count int
+		var inWishlist bool
+		if err := rows.Scan(&noteID, &count, &inWishlist); err != nil {
+			return nil, nil, err
+		}
+		counts[noteID] = count
+		saved[noteID] = inWishlist
+	}
+	return counts, saved, rows.Err()
+}
+
+// applyWishlistInfo - เติม wishlist_count และ in_wishlist ให้ note ทุกเล่ม (ถ้าดึงไม่ได้จะปล่อยเป็นค่าเริ่มต้น)
+func applyWishlistInfo(c *gin.Context, notes []NoteResponse) {
+	noteIDs := make([]int, len(notes))
+	for i := range notes {
+		noteIDs[i] = notes[i].ID
+	}
+	counts, saved, err := wishlistInfo(viewerID(c), noteIDs)
+	if err != nil {
+		return
+	}
+	for i := range notes {
+		notes[i].WishlistCount = counts[notes[i].ID]
+		notes[i].InWishlist = saved[notes[i].ID]
+	}
+}
+
+// GetMyWishlist godoc
+// @Summary ดึงรายการ note ที่บันทึกไว้
+// @Description ดึง note ที่ user บันทึกไว้ พร้อมราคาปัจจุบัน ราคาตอนบันทึก และจำนวนคนที่บันทึก
+// @Tags wishlist
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {array} WishlistItem "รายการ note ที่บันทึกไว้"
+// @Failure 401 {object} map[string]string "Unauthorized"
+// @Failure 500 {object} map[string]string "Server error"
+// @Router /api/wishlist [get]
+func GetMyWishlist(c *gin.Context) {
+	userID := c.GetInt("user_id")
+
+	rows, err := config.DB.Query(`
+		SELECT
+			n.id, n.book_title, n.price, w.saved_price, COALESCE(n.exam_term, ''), n.status,
+			COALESCE(`+coverImageSQL("thumb")+`, '') as cover_image,
+			c.id, c.code, c.name, c.year, c.major,
+			u.id, u.username, u.fullname,
+			(SELECT COUNT(*) FROM wishlists w2 WHERE w2.note_id = n.id),
+			w.created_at
+		FROM wishlists w
+		JOIN notes_for_sale n ON w.note_id = n.id
+		LEFT JOIN courses c ON n.course_id = c.id
+		LEFT JOIN users u ON n.seller_id = u.id
+		WHERE w.user_id = $1
+		ORDER BY w.created_at DESC
+	`, userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch wishlist"})
+		return
+	}
+	defer rows.Close()
+
+	items := []WishlistItem{}
+	for rows.Next() {
+		var item WishlistItem
+		var courseID, sellerID sql.NullInt64
+		var courseCode, courseName, courseYear, courseMajor sql.NullString
+		var sellerUsername, sellerFullname sql.NullString
+
+		err := rows.Scan(
+			&item.NoteID, &item.BookTitle, &item.Price, &item.SavedPrice, &item.ExamTerm, &item.Status, &item.CoverImage,
+			&courseID, &courseCode, &courseName, &courseYear, &courseMajor,
+			&sellerID, &sellerUsername, &sellerFullname,
+			&item.WishlistCount, &item.SavedAt,
+		)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to scan wishlist item"})
+			return
+		}
+
+		if courseID.Valid {
+			item.Course = &Course{
+				ID:    int(courseID.Int64),
+				Code:  courseCode.String,
+				Name:  courseName.String,
+				Year:  courseYear.String,
+				Major: courseMajor.String,
+			}
+		}
+		if sellerID.Valid {
+			item.Seller = &Seller{
+				ID:       int(sellerID.Int64),
+				Username: sellerUsername.String,
+				Fullname: sellerFullname.String,
+			}
+		}
+		item.PriceDropped = item.Price < item.SavedPrice
+
+		items = append(items, item)
+	}
+
+	c.JSON(http.StatusOK, items)
+}
+
+// AddToWishlist godoc
+// @Summary บันทึก note ไว้ดูภายหลัง
+// @Description เพิ่ม note ที่พร้อมขายลงใน wishlist (บันทึกซ้ำจะไม่มีผล)
+// @Tags wishlist
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body object{note_id=int} true "Note ID"
+// @Success 200 {object} map[string]interface{} "บันทึกสำเร็จ พร้อมจำนวนคนที่บันทึก"
+// @Failure 400 {object} map[string]string "Invalid request"
+// @Failure 401 {object} map[string]string "Unauthorized"
+// @Failure 404 {object} map[string]string "Note not found"
+// @Failure 500 {object} map[string]string "Server error"
+// @Router /api/wishlist [post]
+func AddToWishlist(c *gin.Context) {
+	userID := c.GetInt("user_id")
+
+	var request struct {
+		NoteID int `json:"note_id" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// บันทึกได้เฉพาะ note ที่พร้อมขาย (เก็บราคาตอนบันทึกไว้เทียบภายหลัง)
+	result, err := config.DB.Exec(`
+		INSERT INTO wishlists (user_id, note_id, saved_price, created_at)
+		SELECT $1, id, price, NOW() FROM notes_for_sale
+		WHERE id = $2 AND status = 'available' AND deleted_at IS NULL
+		ON CONFLICT (user_id, note_id) DO NOTHING
+	`, userID, request.NoteID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save note"})
+		return
+	}
+
+	var inWishlist bool
+	var count int
+	err = config.DB.QueryRow(`
+		SELECT COALESCE(BOOL_OR(user_id = $2), false), COUNT(*) FROM wishlists WHERE note_id = $1
+	`, request.NoteID, userID).Scan(&inWishlist, &count)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+	if n, _ := result.RowsAffected(); n == 0 && !inWishlist {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Note not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success":        true,
+		"message":        "Note saved to wishlist",
+		"in_wishlist":    true,
+		"wishlist_count": count,
+	})
+}
+
+// RemoveFromWishlist godoc
+// @Summary เอา note ออกจาก wishlist
+// @Description ลบ note ออกจากรายการที่บันทึกไว้
+// @Tags wishlist
+// @Produce json
+// @Security BearerAuth
+// @Param note_id path int true "Note ID"
+// @Success 200 {object} map[string]interface{} "ลบสำเร็จ"
+// @Failure 400 {object} map[string]string "Invalid note ID"
+// @Failure 401 {object} map[string]string "Unauthorized"
+// @Failure 404 {object} map[string]string "Note is not in wishlist"
+// @Failure 500 {object} map[string]string "Server error"
+// @Router /api/wishlist/{note_id} [delete]
+func RemoveFromWishlist(c *gin.Context) {
+	userID := c.GetInt("user_id")
+
+	noteID, err := strconv.Atoi(c.Param("note_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid note ID"})
+		return
+	}
+
+	result, err := config.DB.Exec(`DELETE FROM wishlists WHERE user_id = $1 AND note_id = $2`, userID, noteID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to remove note from wishlist"})
+		return
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Note is not in wishlist"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "Note removed from wishlist",
+	})
+}
+
+// wishlistPriceDropJob - แจ้งเตือนคนที่บันทึก note ไว้เมื่อราคาลดลง
+// ใช้ราคาปัจจุบันตอนที่ job ทำงาน (ถ้าราคากลับขึ้นไปแล้วหรือ note ไม่พร้อมขายจะไม่แจ้ง)
+func wishlistPriceDropJob(ctx context.Context, payload priceDropPayload) error {
+	var title string
+	var price float64
+	err := config.DB.QueryRowContext(ctx, `
+		SELECT book_title, price FROM notes_for_sale
+		WHERE id = $1 AND status = 'available' AND deleted_at IS NULL
+	`, payload.NoteID).Scan(&title, &price)
+	if err == sql.ErrNoRows {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if price >= payload.OldPrice {
+		return nil
+	}
+
+	// ไม่แจ้งคนที่ซื้อไปแล้ว
+	rows, err := config.DB.QueryContext(ctx, `
+		SELECT w.user_id FROM wishlists w
+		WHERE w.note_id = $1
+		AND NOT EXISTS (SELECT 1 FROM buyed_note bn WHERE bn.user_id = w.user_id AND bn.note_id = w.note_id)
+	`, payload.NoteID)
+	if err != nil {
+		return err
+	}
+	userIDs := []int{}
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err == nil {
+			userIDs = append(userIDs, id)
+		}
+	}
+	rows.Close()
+
+	// บันทึกทั้งหมดใน transaction เดียว ถ้า job ถูก retry จะไม่มีคนได้แจ้งเตือนซ้ำ
+	tx, err := config.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	data := priceDropPayload{NoteID: payload.NoteID, OldPrice: payload.OldPrice, NewPrice: price}
+	message := fmt.Sprintf("%s is now %.2f (was %.2f)", title, price, payload.OldPrice)
+	for _, userID := range userIDs {
+		if err := notify(tx, userID, NotifyWishlistPriceDrop, "Price drop on a saved note", message, data); err != nil {
+			return err
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	if len(userIDs) > 0 {
+		log.Printf("🔔 Notified %d users of price drop on note %d", len(userIDs), payload.NoteID)
+	}
+	return nil
+}
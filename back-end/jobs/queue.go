@@ -0,0 +1,246 @@
+package jobs
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"back-end/config"
+)
+
+// สถานะของ job
+const (
+	StatusPending   = "pending"   // รอทำงาน (รวมถึง job ที่รอ retry)
+	StatusRunning   = "running"   // worker กำลังทำงาน
+	StatusCompleted = "completed" // ทำงานสำเร็จ
+	StatusDead      = "dead"      // ล้มเหลวครบจำนวนครั้งแล้ว (dead-letter) รอ admin retry
+)
+
+const defaultMaxAttempts = 5
+
+// ErrDuplicateJob - มี job ที่ใช้ unique key เดียวกันอยู่แล้ว
+var ErrDuplicateJob = errors.New("job with the same unique key already exists")
+
+// ErrJobNotFound - ไม่พบ job
+var ErrJobNotFound = errors.New("job not found")
+
+// ErrJobNotRetryable - retry ได้เฉพาะ job ที่ยังไม่ได้ทำงานหรืออยู่ใน dead-letter
+var ErrJobNotRetryable = errors.New("only pending or dead jobs can be retried")
+
+// Job - งานใน background_jobs
+type Job struct {
+	ID          int64           `json:"id"`
+	Type        string          `json:"type"`
+	Payload     json.RawMessage `json:"payload"`
+	Status      string          `json:"status"`
+	Attempts    int             `json:"attempts"`
+	MaxAttempts int             `json:"max_attempts"`
+	RunAt       time.Time       `json:"run_at"`
+	UniqueKey   *string         `json:"unique_key,omitempty"`
+	LastError   *string         `json:"last_error,omitempty"`
+	LockedBy    *string         `json:"locked_by,omitempty"`
+	LockedAt    *time.Time      `json:"locked_at,omitempty"`
+	CompletedAt *time.Time      `json:"completed_at,omitempty"`
+	CreatedAt   time.Time       `json:"created_at"`
+	UpdatedAt   time.Time       `json:"updated_at"`
+}
+
+// Decode - แปลง payload ของ job เป็น struct
+func (j *Job) Decode(v interface{}) error {
+	return json.Unmarshal(j.Payload, v)
+}
+
+// EnqueueOptions - ตัวเลือกเพิ่มเติมตอนสร้าง job
+type EnqueueOptions struct {
+	RunAt       time.Time // เวลาที่เริ่มทำงานได้ (ค่าเริ่มต้น = ทันที)
+	MaxAttempts int       // จำนวนครั้งที่ลองได้สูงสุด (ค่าเริ่มต้น 5)
+	UniqueKey   string    // ถ้ามี job ที่ใช้ key นี้อยู่แล้วจะไม่สร้างซ้ำ (คืน ErrDuplicateJob)
+}
+
+// queryRower - ใช้ได้ทั้ง *sql.DB และ *sql.Tx
+type queryRower interface {
+	QueryRow(query string, args ...interface{}) *sql.Row
+}
+
+// Enqueue - เพิ่ม job ลงคิว
+func Enqueue(jobType string, payload interface{}, opts EnqueueOptions) (int64, error) {
+	return EnqueueTx(config.DB, jobType, payload, opts)
+}
+
+// EnqueueTx - เพิ่ม job ลงคิวภายใน transaction (job จะถูกเห็นโดย worker หลัง commit เท่านั้น)
+func EnqueueTx(q queryRower, jobType string, payload interface{}, opts EnqueueOptions) (int64, error) {
+	if payload == nil {
+		payload = struct{}{}
+	}
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return 0, fmt.Errorf("encode job payload: %w", err)
+	}
+
+	runAt := opts.RunAt
+	if runAt.IsZero() {
+		runAt = time.Now()
+	}
+	maxAttempts := opts.MaxAttempts
+	if maxAttempts <= 0 {
+		maxAttempts = defaultMaxAttempts
+	}
+	var uniqueKey sql.NullString
+	if opts.UniqueKey != "" {
+		uniqueKey = sql.NullString{String: opts.UniqueKey, Valid: true}
+	}
+
+	var id int64
+	err = q.QueryRow(`
+		INSERT INTO background_jobs (job_type, payload, status, max_attempts, run_at, unique_key, created_at, updated_at)
+		VALUES ($1, $2, 'pending', $3, $4, $5, NOW(), NOW())
+		ON CONFLICT (unique_key) DO NOTHING
+		RETURNING id
+	`, jobType, data, maxAttempts, runAt, uniqueKey).Scan(&id)
+	if err == sql.ErrNoRows {
+		return 0, ErrDuplicateJob
+	}
+	return id, err
+}
+
+const jobColumns = `id, job_type, payload, status, attempts, max_attempts, run_at, unique_key,
+	last_error, locked_by, locked_at, completed_at, created_at, updated_at`
+
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanJob(row rowScanner) (*Job, error) {
+	var job Job
+	var payload []byte
+	var uniqueKey, lastError, lockedBy sql.NullString
+	var lockedAt, completedAt sql.NullTime
+
+	err := row.Scan(&job.ID, &job.Type, &payload, &job.Status, &job.Attempts, &job.MaxAttempts, &job.RunAt,
+		&uniqueKey, &lastError, &lockedBy, &lockedAt, &completedAt, &job.CreatedAt, &job.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	job.Payload = json.RawMessage(payload)
+	if uniqueKey.Valid {
+		job.UniqueKey = &uniqueKey.String
+	}
+	if lastError.Valid {
+		job.LastError = &lastError.String
+	}
+	if lockedBy.Valid {
+		job.LockedBy = &lockedBy.String
+	}
+	if lockedAt.Valid {
+		job.LockedAt = &lockedAt.Time
+	}
+	if completedAt.Valid {
+		job.CompletedAt = &completedAt.Time
+	}
+	return &job, nil
+}
+
+// Get - ดึง job ตาม ID
+func Get(id int64) (*Job, error) {
+	job, err := scanJob(config.DB.QueryRow(`SELECT `+jobColumns+` FROM background_jobs WHERE id = $1`, id))
+	if err == sql.ErrNoRows {
+		return nil, ErrJobNotFound
+	}
+	return job, err
+}
+
+// ListFilter - เงื่อนไขการค้นหา job
+type ListFilter struct {
+	Status string
+	Type   string
+	Limit  int
+	Offset int
+}
+
+// List - ดึงรายการ job ล่าสุดตามเงื่อนไข พร้อมจำนวนทั้งหมด
+func List(filter ListFilter) ([]Job, int, error) {
+	conditions := []string{}
+	args := []interface{}{}
+	if filter.Status != "" {
+		args = append(args, filter.Status)
+		conditions = append(conditions, fmt.Sprintf("status = $%d", len(args)))
+	}
+	if filter.Type != "" {
+		args = append(args, filter.Type)
+		conditions = append(conditions, fmt.Sprintf("job_type = $%d", len(args)))
+	}
+	where := ""
+	if len(conditions) > 0 {
+		where = "WHERE " + strings.Join(conditions, " AND ")
+	}
+
+	var total int
+	if err := config.DB.QueryRow(`SELECT COUNT(*) FROM background_jobs `+where, args...).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	limit := filter.Limit
+	if limit <= 0 || limit > 200 {
+		limit = 50
+	}
+	args = append(args, limit, max(filter.Offset, 0))
+	rows, err := config.DB.Query(fmt.Sprintf(`
+		SELECT %s FROM background_jobs %s
+		ORDER BY id DESC
+		LIMIT $%d OFFSET $%d
+	`, jobColumns, where, len(args)-1, len(args)), args...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	jobs := []Job{}
+	for rows.Next() {
+		job, err := scanJob(rows)
+		if err != nil {
+			return nil, 0, err
+		}
+		jobs = append(jobs, *job)
+	}
+	return jobs, total, rows.Err()
+}
+
+// CountByStatus - จำนวน job แยกตามสถานะ
+func CountByStatus() (map[string]int, error) {
+	counts := map[string]int{StatusPending: 0, StatusRunning: 0, StatusCompleted: 0, StatusDead: 0}
+	rows, err := config.DB.Query(`SELECT status, COUNT(*) FROM background_jobs GROUP BY status`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var status string
+		var count int
+		if err := rows.Scan(&status, &count); err != nil {
+			return nil, err
+		}
+		counts[status] = count
+	}
+	return counts, rows.Err()
+}
+
+// Retry - นำ job ที่อยู่ใน dead-letter (หรือที่รอ retry) กลับมาทำงานทันทีและนับจำนวนครั้งใหม่
+func Retry(id int64) (*Job, error) {
+	job, err := scanJob(config.DB.QueryRow(`
+		UPDATE background_jobs
+		SET status = 'pending', attempts = 0, run_at = NOW(), locked_by = NULL, locked_at = NULL, updated_at = NOW()
+		WHERE id = $1 AND status IN ('pending', 'dead')
+		RETURNING `+jobColumns, id))
+	if err == sql.ErrNoRows {
+		if _, getErr := Get(id); getErr != nil {
+			return nil, getErr
+		}
+		return nil, ErrJobNotRetryable
+	}
+	return job, err
+}
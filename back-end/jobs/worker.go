@@ -0,0 +1,351 @@
+package jobs
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"math/rand"
+	"os"
+	"runtime/debug"
+	"sync"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// ค่าเริ่มต้นของ worker pool
+const (
+	defaultPollInterval = time.Second
+	defaultJobTimeout   = 5 * time.Minute
+	lockTimeout         = 15 * time.Minute   // job ที่ running นานกว่านี้ถือว่า worker ตายไปแล้ว
+	maintenanceInterval = 30 * time.Second   // รอบการคืน job ค้าง / สร้าง scheduled job / ลบ job เก่า
+	completedRetention  = 7 * 24 * time.Hour // เก็บ job ที่สำเร็จแล้วไว้ 7 วัน
+	backoffBase         = 10 * time.Second
+	backoffMax          = time.Hour
+)
+
+// Handler - ฟังก์ชันที่ทำงานของ job (คืน error เพื่อให้ retry, คืน Permanent(err) เพื่อไม่ต้อง retry)
+type Handler func(ctx context.Context, job *Job) error
+
+// Typed - สร้าง Handler ที่ decode payload เป็น type T ให้อัตโนมัติ
+func Typed[T any](fn func(ctx context.Context, payload T) error) Handler {
+	return func(ctx context.Context, job *Job) error {
+		var payload T
+		if err := job.Decode(&payload); err != nil {
+			return Permanent(fmt.Errorf("invalid payload: %w", err))
+		}
+		return fn(ctx, payload)
+	}
+}
+
+type permanentError struct {
+	err error
+}
+
+func (e *permanentError) Error() string { return e.err.Error() }
+func (e *permanentError) Unwrap() error { return e.err }
+
+// Permanent - ระบุว่า error นี้ retry ไปก็ไม่สำเร็จ (job จะเข้า dead-letter ทันที)
+func Permanent(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &permanentError{err}
+}
+
+// schedule - job ที่สร้างอัตโนมัติทุกๆ interval
+type schedule struct {
+	name     string
+	jobType  string
+	interval time.Duration
+	payload  interface{}
+}
+
+// Pool - กลุ่ม worker ที่ดึง job จาก background_jobs ด้วย FOR UPDATE SKIP LOCKED
+// (รันได้หลาย instance พร้อมกันโดยไม่ทำ job ซ้ำ)
+type Pool struct {
+	db           *sql.DB
+	workers      int
+	workerID     string
+	pollInterval time.Duration
+	jobTimeout   time.Duration
+
+	mu        sync.RWMutex
+	handlers  map[string]Handler
+	schedules []schedule
+
+	stop     chan struct{}
+	jobCtx   context.Context // ยกเลิกเมื่อ Stop หมดเวลารอ
+	abortJob context.CancelFunc
+	wg       sync.WaitGroup
+	started  bool
+}
+
+// NewPool - สร้าง worker pool (workers = จำนวน job ที่ทำพร้อมกันได้)
+func NewPool(db *sql.DB, workers int) *Pool {
+	hostname, _ := os.Hostname()
+	jobCtx, abort := context.WithCancel(context.Background())
+	return &Pool{
+		db:           db,
+		workers:      workers,
+		workerID:     fmt.Sprintf("%s-%d", hostname, os.Getpid()),
+		pollInterval: defaultPollInterval,
+		jobTimeout:   defaultJobTimeout,
+		handlers:     map[string]Handler{},
+		stop:         make(chan struct{}),
+		jobCtx:       jobCtx,
+		abortJob:     abort,
+	}
+}
+
+// Register - ผูก job type กับ handler (ต้องเรียกก่อน Start)
+func (p *Pool) Register(jobType string, handler Handler) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.handlers[jobType] = handler
+}
+
+// Schedule - สร้าง job ชนิด jobType อัตโนมัติทุกๆ interval
+// ใช้ unique key ตามช่วงเวลา จึงถูกสร้างเพียงครั้งเดียวต่อรอบแม้จะรันหลาย instance
+func (p *Pool) Schedule(name, jobType string, interval time.Duration, payload interface{}) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.schedules = append(p.schedules, schedule{name, jobType, interval, payload})
+}
+
+// Start - เริ่ม worker และงานดูแลคิว
+func (p *Pool) Start() {
+	if p.started || p.workers <= 0 {
+		return
+	}
+	p.started = true
+
+	for i := 0; i < p.workers; i++ {
+		p.wg.Add(1)
+		go p.work()
+	}
+	p.wg.Add(1)
+	go p.maintain()
+
+	log.Printf("⚙️  Job worker pool started (%d workers)", p.workers)
+}
+
+// Stop - หยุดรับ job ใหม่และรอ job ที่กำลังทำงานให้เสร็จ
+// ถ้า ctx หมดเวลาก่อน job จะถูกยกเลิก (context ของ handler ถูก cancel) และจะถูก retry ภายหลัง
+func (p *Pool) Stop(ctx context.Context) error {
+	if !p.started {
+		return nil
+	}
+	p.started = false
+	close(p.stop)
+
+	done := make(chan struct{})
+	go func() {
+		p.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		log.Println("⚙️  Job worker pool stopped")
+		return nil
+	case <-ctx.Done():
+		p.abortJob()
+		<-done
+		return ctx.Err()
+	}
+}
+
+func (p *Pool) jobTypes() []string {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	types := make([]string, 0, len(p.handlers))
+	for jobType := range p.handlers {
+		types = append(types, jobType)
+	}
+	return types
+}
+
+func (p *Pool) handler(jobType string) Handler {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.handlers[jobType]
+}
+
+// work - loop ของ worker แต่ละตัว
+func (p *Pool) work() {
+	defer p.wg.Done()
+	for {
+		select {
+		case <-p.stop:
+			return
+		default:
+		}
+
+		job, err := p.claim()
+		if err != nil {
+			log.Printf("⚠️  Failed to claim job: %v", err)
+		}
+		if job != nil {
+			p.run(job)
+			continue
+		}
+
+		select {
+		case <-p.stop:
+			return
+		case <-time.After(p.pollInterval):
+		}
+	}
+}
+
+// claim - จอง job ที่ถึงเวลาทำงาน (เฉพาะ type ที่มี handler) โดยข้ามแถวที่ worker อื่นล็อกอยู่
+func (p *Pool) claim() (*Job, error) {
+	types := p.jobTypes()
+	if len(types) == 0 {
+		return nil, nil
+	}
+
+	job, err := scanJob(p.db.QueryRow(`
+		UPDATE background_jobs
+		SET status = 'running', attempts = attempts + 1, locked_by = $1, locked_at = NOW(), updated_at = NOW()
+		WHERE id = (
+			SELECT id FROM background_jobs
+			WHERE status = 'pending' AND run_at <= NOW() AND job_type = ANY($2)
+			ORDER BY run_at, id
+			FOR UPDATE SKIP LOCKED
+			LIMIT 1
+		)
+		RETURNING `+jobColumns, p.workerID, pq.Array(types)))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return job, err
+}
+
+// run - เรียก handler และบันทึกผล
+func (p *Pool) run(job *Job) {
+	ctx, cancel := context.WithTimeout(p.jobCtx, p.jobTimeout)
+	defer cancel()
+
+	err := p.call(ctx, job)
+	if err == nil {
+		_, err = p.db.Exec(`
+			UPDATE background_jobs
+			SET status = 'completed', completed_at = NOW(), last_error = NULL, locked_by = NULL, locked_at = NULL, updated_at = NOW()
+			WHERE id = $1
+		`, job.ID)
+		if err != nil {
+			log.Printf("⚠️  Failed to mark job %d as completed: %v", job.ID, err)
+		}
+		return
+	}
+
+	var permanent *permanentError
+	if errors.As(err, &permanent) || job.Attempts >= job.MaxAttempts {
+		log.Printf("❌ Job %d (%s) moved to dead-letter after %d attempts: %v", job.ID, job.Type, job.Attempts, err)
+		_, err = p.db.Exec(`
+			UPDATE background_jobs
+			SET status = 'dead', last_error = $2, locked_by = NULL, locked_at = NULL, updated_at = NOW()
+			WHERE id = $1
+		`, job.ID, err.Error())
+	} else {
+		delay := backoff(job.Attempts)
+		log.Printf("⚠️  Job %d (%s) failed (attempt %d/%d), retrying in %s: %v", job.ID, job.Type, job.Attempts, job.MaxAttempts, delay, err)
+		_, err = p.db.Exec(`
+			UPDATE background_jobs
+			SET status = 'pending', run_at = NOW() + $2 * INTERVAL '1 millisecond', last_error = $3,
+				locked_by = NULL, locked_at = NULL, updated_at = NOW()
+			WHERE id = $1
+		`, job.ID, delay.Milliseconds(), err.Error())
+	}
+	if err != nil {
+		log.Printf("⚠️  Failed to record failure of job %d: %v", job.ID, err)
+	}
+}
+
+// call - เรียก handler โดยแปลง panic เป็น error
+func (p *Pool) call(ctx context.Context, job *Job) (err error) {
+	handler := p.handler(job.Type)
+	if handler == nil {
+		return Permanent(fmt.Errorf("no handler registered for job type %q", job.Type))
+	}
+
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v\n%s", r, debug.Stack())
+		}
+	}()
+	return handler(ctx, job)
+}
+
+// backoff - เวลารอก่อน retry แบบ exponential (10s, 20s, 40s, ... ไม่เกิน 1 ชั่วโมง) บวก jitter ไม่เกิน 20%
+func backoff(attempt int) time.Duration {
+	delay := backoffBase
+	for i := 1; i < attempt && delay < backoffMax; i++ {
+		delay *= 2
+	}
+	delay = min(delay, backoffMax)
+	return delay + time.Duration(rand.Int63n(int64(delay)/5+1))
+}
+
+// maintain - คืน job ที่ค้าง, สร้าง scheduled job และลบ job เก่าเป็นระยะ
+func (p *Pool) maintain() {
+	defer p.wg.Done()
+
+	ticker := time.NewTicker(maintenanceInterval)
+	defer ticker.Stop()
+	for {
+		p.requeueStale()
+		p.enqueueScheduled(time.Now())
+		p.purgeCompleted()
+
+		select {
+		case <-p.stop:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// requeueStale - job ที่ running นานเกิน lockTimeout (worker ตายระหว่างทำงาน) จะถูกนำกลับเข้าคิว
+func (p *Pool) requeueStale() {
+	_, err := p.db.Exec(`
+		UPDATE background_jobs
+		SET status = CASE WHEN attempts >= max_attempts THEN 'dead' ELSE 'pending' END,
+			last_error = 'worker lock expired', locked_by = NULL, locked_at = NULL, run_at = NOW(), updated_at = NOW()
+		WHERE status = 'running' AND locked_at < NOW() - $1 * INTERVAL '1 second'
+	`, int(lockTimeout.Seconds()))
+	if err != nil {
+		log.Printf("⚠️  Failed to requeue stale jobs: %v", err)
+	}
+}
+
+func (p *Pool) enqueueScheduled(now time.Time) {
+	p.mu.RLock()
+	schedules := append([]schedule(nil), p.schedules...)
+	p.mu.RUnlock()
+
+	for _, s := range schedules {
+		slot := now.Truncate(s.interval)
+		_, err := EnqueueTx(p.db, s.jobType, s.payload, EnqueueOptions{
+			RunAt:     slot,
+			UniqueKey: fmt.Sprintf("schedule:%s:%d", s.name, slot.Unix()),
+		})
+		if err != nil && !errors.Is(err, ErrDuplicateJob) {
+			log.Printf("⚠️  Failed to enqueue scheduled job %s: %v", s.name, err)
+		}
+	}
+}
+
+func (p *Pool) purgeCompleted() {
+	_, err := p.db.Exec(`
+		DELETE FROM background_jobs
+		WHERE status = 'completed' AND completed_at < NOW() - $1 * INTERVAL '1 second'
+	`, int(completedRetention.Seconds()))
+	if err != nil {
+		log.Printf("⚠️  Failed to purge completed jobs: %v", err)
+	}
+}
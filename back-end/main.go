@@ -3,10 +3,20 @@ package main
 import (
 	"back-end/config"
 	_ "back-end/docs" // Swagger docs
+	"back-end/events"
 	"back-end/handlers"
+	"back-end/jobs"
 	"back-end/middleware"
+	"back-end/permissions"
+	"context"
+	"errors"
 	"log"
+	"net/http"
 	"os"
+	"os/signal"
+	"strconv"
+	"syscall"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/joho/godotenv"
@@ -43,8 +53,16 @@ func main() {
 	config.ConnectDB()
 	defer config.CloseDB()
 
-	// สร้าง Gin router
-	r := gin.Default()
+	// คำสั่งดูแลระบบ (เช่น ./main gc-uploads) รันแล้วจบโดยไม่เปิด server
+	if len(os.Args) > 1 {
+		code := runCommand(os.Args[1:])
+		config.CloseDB()
+		os.Exit(code)
+	}
+
+	// สร้าง Gin router (เหมือน gin.Default แต่ไม่เขียน /api/events ลง access log เพราะ query มี stream ticket)
+	r := gin.New()
+	r.Use(gin.LoggerWithConfig(gin.LoggerConfig{SkipPaths: []string{"/api/events"}}), gin.Recovery())
 
 	// CORS middleware (อนุญาตให้ frontend เข้าถึง API)
 	r.Use(func(c *gin.Context) {
@@ -78,6 +96,7 @@ func main() {
 
 	// Public routes (ไม่ต้อง login)
 	public := r.Group("/api")
+	public.Use(middleware.OptionalAuth()) // รู้จัก user ที่ login อยู่ (เช่น in_wishlist) แต่ไม่บังคับ
 	{
 		public.POST("/register", handlers.Register)
 		public.POST("/login", handlers.Login)
@@ -91,17 +110,28 @@ func main() {
 		public.GET("/notes/most-liked", handlers.GetMostLikedNotes)     // ดึงสรุปที่ถูกใจมากที่สุด
 		public.GET("/notes/:id", handlers.GetNoteByID)                  // ดึง note เดียวตาม ID
 
+		// Bundles - ดูได้โดยไม่ต้อง login
+		public.GET("/bundles", handlers.GetBundles)        // ดึงรายการ bundle ที่พร้อมขาย
+		public.GET("/bundles/:id", handlers.GetBundleByID) // ดึง bundle เดียวตาม ID
+
 		// Courses - ดูได้โดยไม่ต้อง login
 		public.GET("/courses", handlers.GetAllCourses)          // ดึงรายการ courses ทั้งหมด
 		public.GET("/courses/majors", handlers.GetCourseMajors) // ดึงรายการสาขาทั้งหมด
 		public.GET("/courses/years", handlers.GetCourseYears)   // ดึงรายการชั้นปีทั้งหมด
 
+		// Sellers - โปรไฟล์ผู้ขาย (ข้อมูลติดต่อแสดงเฉพาะเจ้าของบัญชีและ admin)
+		public.GET("/sellers/:id", handlers.GetSellerProfile) // ดึงโปรไฟล์สาธารณะของผู้ขาย
+
 		// Reviews - ดูได้โดยไม่ต้อง login
 		public.GET("/sellers/:id/reviews", handlers.GetSellerReviews)           // ดึงรีวิวของ seller
 		public.GET("/sellers/:id/reviews/stats", handlers.GetSellerReviewStats) // ดึงสถิติรีวิว
 		public.GET("/notes/:id/reviews", handlers.GetNoteReviews)          // ดึงรีวิวของหนังสือ
 		public.GET("/notes/:id/reviews/stats", handlers.GetNoteReviewStats) // ดึงสถิติรีวิวของหนังสือ
 
+		// Recommendations - คนที่ซื้อเล่มนี้ซื้ออะไรอีก (คำนวณล่วงหน้าโดย background job)
+		public.GET("/notes/:id/recommendations", handlers.GetNoteRecommendations) // ดึง note ที่แนะนำ
+		public.GET("/reviews/:id/history", handlers.GetReviewHistory)           // ดึงประวัติการแก้ไขรีวิว
+
 		// Slider - ดูได้โดยไม่ต้อง login
 		public.GET("/slider", handlers.GetSliderImages) // ดึงรูปภาพ slider ที่ active
 	}
@@ -131,56 +161,219 @@ func main() {
 		protected.DELETE("/delete-avatar", handlers.DeleteAvatar) // ลบ avatar
 
 		// Notes endpoints
-		protected.POST("/notes", handlers.CreateNote) // สร้างโน้ตขาย
+		protected.POST("/notes", handlers.CreateNote)      // สร้างโน้ตขาย
+		protected.PUT("/notes/:id", handlers.UpdateMyNote) // แก้ไขโน้ตของตัวเอง (seller)
 		protected.GET("/users/:id/notes", handlers.GetNotesByUserID)
 		
 		// Purchase endpoints
+		protected.POST("/checkout", handlers.Checkout)                    // สั่งซื้อสินค้าทั้งหมดในตะกร้า (รองรับ Idempotency-Key)
 		protected.POST("/purchase", handlers.PurchaseNotes)               // ซื้อหนังสือ
 		protected.GET("/my-purchases", handlers.GetMyPurchaseHistory)     // ดึงประวัติการซื้อ
 		protected.PUT("/my-purchases/:id", handlers.UpdatePurchaseReview) // อัพเดทรีวิว
 		protected.GET("/download/:id", handlers.DownloadPurchasedNote)    // ดาวน์โหลด PDF
 
+		// Reviews - เขียนได้เฉพาะคนที่ซื้อแล้ว
+		protected.POST("/notes/:id/reviews", handlers.CreateNoteReview)        // เขียนรีวิว (1-5 ดาว)
+		protected.PUT("/reviews/:id", handlers.UpdateMyReview)                 // แก้ไขรีวิวของตัวเอง
+		protected.POST("/reviews/:id/helpful", handlers.VoteReviewHelpful)     // กดว่ารีวิวมีประโยชน์ (1 เสียงต่อคน)
+		protected.DELETE("/reviews/:id/helpful", handlers.UnvoteReviewHelpful) // ยกเลิกโหวต
+
+		// Reports - รายงานเนื้อหาที่ไม่เหมาะสม
+		protected.POST("/reports", handlers.CreateReport)     // รายงาน note, รีวิว หรือ seller
+		protected.GET("/reports/mine", handlers.GetMyReports) // ดึงรายงานที่ตัวเองส่ง
+
+		// Seller applications - สมัครเป็นผู้ขาย (ต้องได้รับอนุมัติก่อนอัปโหลด note)
+		protected.POST("/seller-applications", handlers.SubmitSellerApplication)     // ส่งใบสมัครพร้อมรูปบัตรนักศึกษา
+		protected.GET("/seller-applications/mine", handlers.GetMySellerApplications) // ดูใบสมัครและสถานะของตัวเอง
+
+		// Follows - ติดตามผู้ขายและ feed ส่วนตัว
+		protected.POST("/sellers/:id/follow", handlers.FollowSeller)     // ติดตามผู้ขาย
+		protected.DELETE("/sellers/:id/follow", handlers.UnfollowSeller) // เลิกติดตามผู้ขาย
+		protected.GET("/following", handlers.GetFollowing)               // ดึงผู้ขายที่ติดตามอยู่
+		protected.GET("/feed", handlers.GetFeed)                         // note ใหม่จากผู้ขายที่ติดตามและวิชาที่เคยซื้อ
+
 		// Cart endpoints
-		protected.POST("/cart", handlers.AddToCart)            // เพิ่มสินค้าลงตะกร้า
-		protected.GET("/cart", handlers.GetCart)               // ดูสินค้าในตะกร้า
-		protected.PUT("/cart/:id", handlers.UpdateCartItem)    // อัพเดทจำนวนสินค้า
-		protected.DELETE("/cart/:id", handlers.RemoveFromCart) // ลบสินค้าออกจากตะกร้า
-		protected.DELETE("/cart", handlers.ClearCart)          // ล้างตะกร้าทั้งหมด
+		protected.POST("/cart", handlers.AddToCart)                // เพิ่มสินค้าลงตะกร้า
+		protected.GET("/cart", handlers.GetCart)                   // ดูสินค้าในตะกร้า
+		protected.PUT("/cart/:id", handlers.UpdateCartItem)        // อัพเดทจำนวนสินค้า
+		protected.DELETE("/cart/:id", handlers.RemoveFromCart)     // ลบสินค้าออกจากตะกร้า
+		protected.DELETE("/cart", handlers.ClearCart)              // ล้างตะกร้าทั้งหมด
+		protected.POST("/cart/apply-coupon", handlers.ApplyCoupon) // ดูส่วนลดจากคูปอง (ยังไม่ใช้คูปองจริง)
+
+		// Wishlist routes
+		protected.GET("/wishlist", handlers.GetMyWishlist)                  // ดึง note ที่บันทึกไว้
+		protected.POST("/wishlist", handlers.AddToWishlist)                 // บันทึก note ไว้ดูภายหลัง
+		protected.DELETE("/wishlist/:note_id", handlers.RemoveFromWishlist) // เอา note ออกจาก wishlist
+
+		// Notification routes
+		protected.GET("/notifications", handlers.GetNotifications)                          // ดึงการแจ้งเตือนของตัวเอง
+		protected.GET("/notifications/unread-count", handlers.GetUnreadNotificationCount)   // จำนวนที่ยังไม่ได้อ่าน
+		protected.PUT("/notifications/read-all", handlers.MarkAllNotificationsRead)         // อ่านทั้งหมดแล้ว
+		protected.PUT("/notifications/:id/read", handlers.MarkNotificationRead)             // อ่านแล้ว
+		protected.GET("/notifications/preferences", handlers.GetNotificationPreferences)    // ดึงการตั้งค่าการแจ้งเตือน
+		protected.PUT("/notifications/preferences", handlers.UpdateNotificationPreferences) // แก้ไขการตั้งค่าการแจ้งเตือน
+
+		// Real-time events
+		protected.POST("/events/ticket", handlers.CreateEventsTicket) // ขอ ticket สำหรับเปิด /api/events
+	}
+
+	// Real-time events (Server-Sent Events) รับ ticket ผ่าน query ได้เพราะ EventSource ส่ง header ไม่ได้
+	stream := r.Group("/api")
+	stream.Use(middleware.StreamTicketAuth())
+	{
+		stream.GET("/events", handlers.StreamEvents) // รับ event แบบ real-time
 	}
 
-	// Protected routes สำหรับ admin เท่านั้น
+	// Protected routes สำหรับ seller
+	seller := r.Group("/api/seller")
+	seller.Use(middleware.AuthMiddleware())
+	seller.Use(middleware.RequireRole("seller"))
+	{
+		seller.POST("/coupons", handlers.CreateMyCoupon)           // สร้างคูปองสำหรับ note ของตัวเอง
+		seller.GET("/coupons", handlers.GetMyCoupons)              // ดึงคูปองที่ตัวเองสร้าง
+		seller.DELETE("/coupons/:id", handlers.DeactivateMyCoupon) // ปิดใช้งานคูปอง
+
+		// Review replies - ตอบรีวิวบน note ของตัวเอง
+		seller.PUT("/reviews/:id/reply", handlers.ReplyToReview)        // ตอบ/แก้ไขคำตอบรีวิว
+		seller.DELETE("/reviews/:id/reply", handlers.DeleteReviewReply) // ลบคำตอบรีวิว
+
+		// Webhooks
+		seller.POST("/webhooks", handlers.CreateMyWebhook)                      // สมัครรับ webhook
+		seller.GET("/webhooks", handlers.GetMyWebhooks)                         // ดึง webhook ของตัวเอง
+		seller.PUT("/webhooks/:id", handlers.UpdateMyWebhook)                   // แก้ไข webhook
+		seller.DELETE("/webhooks/:id", handlers.DeleteMyWebhook)                // ลบ webhook
+		seller.GET("/webhooks/:id/deliveries", handlers.GetMyWebhookDeliveries) // ดูประวัติการส่ง webhook
+
+		// Bundles
+		seller.POST("/bundles", handlers.CreateBundle)             // รวม note ของตัวเองขายเป็นชุด
+		seller.GET("/bundles", handlers.GetMyBundles)              // ดึง bundle ของตัวเอง
+		seller.PUT("/bundles/:id", handlers.UpdateMyBundle)        // แก้ไข bundle
+		seller.DELETE("/bundles/:id", handlers.DeactivateMyBundle) // ปิดการขาย bundle
+	}
+
+	// Protected routes สำหรับ admin และผู้ดูแลที่ได้รับสิทธิ์ (ตรวจ permission ของ role ราย route, role admin มีทุกสิทธิ์)
 	admin := r.Group("/api/admin")
 	admin.Use(middleware.AuthMiddleware())
-	admin.Use(middleware.RequireRole("admin"))
 	{
-		admin.GET("/users", handlers.GetAllUsers)                       // ดึงรายการ Users ทั้งหมด
-		admin.GET("/sellers", handlers.GetAllSellers)                   // ดึงรายการ Sellers ทั้งหมด
-		admin.GET("/stats", handlers.GetDashboardStats)                 // ดึงสถิติ Dashboard
-		admin.GET("/notes", handlers.GetAllNotesAdmin)                  // ดึงรายการ Notes ทั้งหมด
-		admin.GET("/notes/pending", handlers.GetPendingNotes)           // ดึงรายการ Notes ที่รออนุมัติ
-		admin.GET("/notes/:id/download", handlers.DownloadNoteForAdmin) // ดาวน์โหลด PDF (Admin)
-		admin.POST("/notes/:id/approve", handlers.ApproveNote)          // อนุมัติ Note
-		admin.POST("/notes/:id/reject", handlers.RejectNote)            // ปฏิเสธ Note
-		admin.PUT("/notes/:id", handlers.UpdateNote)                    // อัปเดต Note (ราคา, ชื่อ, คำอธิบาย)
-		admin.DELETE("/notes/:id", handlers.DeleteNote)                 // ลบ Note
-		admin.POST("/seller/add", handlers.AddSellerRole)               // เพิ่ม role seller
-		admin.POST("/seller/remove", handlers.RemoveSellerRole)         // ลบ role seller
+		can := middleware.RequirePermission
+
+		admin.GET("/stats", can(permissions.StatsView), handlers.GetDashboardStats) // ดึงสถิติ Dashboard
+
+		// Users
+		admin.GET("/users", can(permissions.UsersView), handlers.GetAllUsers)                         // ดึงรายการ Users ทั้งหมด
+		admin.GET("/sellers", can(permissions.UsersView), handlers.GetAllSellers)                     // ดึงรายการ Sellers ทั้งหมด
+		admin.POST("/users/:id/suspend", can(permissions.UsersBan), handlers.SuspendUser)             // ระงับบัญชีชั่วคราว
+		admin.POST("/users/:id/ban", can(permissions.UsersBan), handlers.BanUser)                     // แบนบัญชี (เอา note ออกจากการขายทั้งหมด)
+		admin.POST("/users/:id/reinstate", can(permissions.UsersBan), handlers.ReinstateUser)         // ยกเลิกการระงับ/แบน
+		admin.POST("/seller/add", can(permissions.RolesManage), handlers.AddSellerRole)               // เพิ่ม role seller
+		admin.POST("/seller/remove", can(permissions.RolesManage), handlers.RemoveSellerRole)         // ลบ role seller
+		admin.GET("/users/:id/roles", can(permissions.RolesManage), handlers.GetUserRoleAssignments)  // ดู role และสิทธิ์ของ user
+		admin.POST("/users/:id/roles", can(permissions.RolesManage), handlers.AssignUserRole)         // มอบ role ให้ user
+		admin.DELETE("/users/:id/roles/:role", can(permissions.RolesManage), handlers.RevokeUserRole) // เอา role ออกจาก user
+
+		// Roles & permissions
+		admin.GET("/roles", can(permissions.RolesManage), handlers.GetRoles)                      // ดึง role ทั้งหมดพร้อมสิทธิ์
+		admin.POST("/roles", can(permissions.RolesManage), handlers.CreateRole)                   // สร้าง role
+		admin.PUT("/roles/:id", can(permissions.RolesManage), handlers.UpdateRole)                // แก้ไข role / สิทธิ์ของ role
+		admin.DELETE("/roles/:id", can(permissions.RolesManage), handlers.DeleteRole)             // ลบ role
+		admin.GET("/permissions", can(permissions.RolesManage), handlers.GetPermissions)          // ดึงสิทธิ์ทั้งหมด
+		admin.POST("/permissions", can(permissions.RolesManage), handlers.CreatePermission)       // สร้างสิทธิ์
+		admin.PUT("/permissions/:id", can(permissions.RolesManage), handlers.UpdatePermission)    // แก้ไขคำอธิบายสิทธิ์
+		admin.DELETE("/permissions/:id", can(permissions.RolesManage), handlers.DeletePermission) // ลบสิทธิ์
+
+		// Notes
+		admin.GET("/notes", can(permissions.NotesView), handlers.GetAllNotesAdmin)                  // ดึงรายการ Notes ทั้งหมด
+		admin.GET("/notes/pending", can(permissions.NotesView), handlers.GetPendingNotes)           // ดึงรายการ Notes ที่รออนุมัติ
+		admin.GET("/notes/:id/download", can(permissions.NotesView), handlers.DownloadNoteForAdmin) // ดาวน์โหลด PDF (Admin)
+		admin.POST("/notes/:id/approve", can(permissions.NotesApprove), handlers.ApproveNote)       // อนุมัติ Note
+		admin.POST("/notes/:id/reject", can(permissions.NotesApprove), handlers.RejectNote)         // ปฏิเสธ Note
+		admin.PUT("/notes/:id", can(permissions.NotesEdit), handlers.UpdateNote)                    // อัปเดต Note (ราคา, ชื่อ, คำอธิบาย, วิชา, เทอม, สถานะ)
+		admin.DELETE("/notes/:id", can(permissions.NotesEdit), handlers.DeleteNote)                 // ลบ Note (soft delete)
+		admin.POST("/notes/:id/restore", can(permissions.NotesEdit), handlers.RestoreNote)          // กู้คืน Note ที่ถูกลบ
 
 		// Slider management
-		admin.GET("/slider", handlers.GetSliderImages)           // ดึงรูปภาพ slider ทั้งหมด
-		admin.POST("/slider/upload", handlers.UploadSliderImage) // อัปโหลดรูป slider
-		admin.PUT("/slider/order", handlers.UpdateSliderOrder)   // อัปเดตลำดับการแสดง
-		admin.DELETE("/slider/:id", handlers.DeleteSliderImage)  // ลบรูป slider
+		admin.GET("/slider", can(permissions.SliderManage), handlers.GetSliderImages)           // ดึงรูปภาพ slider ทั้งหมด
+		admin.POST("/slider/upload", can(permissions.SliderManage), handlers.UploadSliderImage) // อัปโหลดรูป slider
+		admin.PUT("/slider/order", can(permissions.SliderManage), handlers.UpdateSliderOrder)   // อัปเดตลำดับการแสดง
+		admin.DELETE("/slider/:id", can(permissions.SliderManage), handlers.DeleteSliderImage)  // ลบรูป slider
+
+		// Background jobs
+		admin.GET("/jobs", can(permissions.JobsManage), handlers.GetJobs)             // ดึงรายการ jobs และจำนวนตามสถานะ
+		admin.GET("/jobs/:id", can(permissions.JobsManage), handlers.GetJobByID)      // ดูรายละเอียด job
+		admin.POST("/jobs/:id/retry", can(permissions.JobsManage), handlers.RetryJob) // retry job ที่อยู่ใน dead-letter
+
+		// Coupons
+		admin.POST("/coupons", can(permissions.CouponsManage), handlers.CreateCoupon)           // สร้างคูปอง
+		admin.GET("/coupons", can(permissions.CouponsManage), handlers.GetAllCoupons)           // ดึงคูปองทั้งหมด
+		admin.DELETE("/coupons/:id", can(permissions.CouponsManage), handlers.DeactivateCoupon) // ปิดใช้งานคูปอง
+
+		// Moderation - คิวตรวจรายงาน
+		admin.GET("/reports", can(permissions.ReportsManage), handlers.GetReports)                 // ดึงคิวรายงาน
+		admin.GET("/reports/:id", can(permissions.ReportsManage), handlers.GetReportByID)          // ดูรายละเอียดรายงาน
+		admin.POST("/reports/:id/assign", can(permissions.ReportsManage), handlers.AssignReport)   // รับเรื่อง/มอบหมายรายงาน
+		admin.POST("/reports/:id/resolve", can(permissions.ReportsManage), handlers.ResolveReport) // ปิดรายงานพร้อมจัดการ (delist/ระงับบัญชี)
+		admin.POST("/reports/:id/dismiss", can(permissions.ReportsManage), handlers.DismissReport) // ปิดรายงานโดยไม่ดำเนินการ
+
+		// Seller applications - ตรวจใบสมัครผู้ขาย
+		admin.GET("/seller-applications", can(permissions.SellersVerify), handlers.GetSellerApplications)                               // ดึงใบสมัคร (กรองตามสถานะ)
+		admin.GET("/seller-applications/:id", can(permissions.SellersVerify), handlers.GetSellerApplicationByID)                        // ดูรายละเอียดใบสมัคร
+		admin.GET("/seller-applications/:id/student-id-card", can(permissions.SellersVerify), handlers.GetSellerApplicationStudentCard) // ดูรูปบัตรนักศึกษา
+		admin.POST("/seller-applications/:id/approve", can(permissions.SellersVerify), handlers.ApproveSellerApplication)               // อนุมัติ (ได้ role seller และ badge)
+		admin.POST("/seller-applications/:id/reject", can(permissions.SellersVerify), handlers.RejectSellerApplication)                 // ไม่อนุมัติพร้อมเหตุผล
+
+		// Webhooks
+		admin.POST("/webhooks", can(permissions.WebhooksManage), handlers.CreateWebhook)                             // สร้าง webhook ของระบบ
+		admin.GET("/webhooks", can(permissions.WebhooksManage), handlers.GetAllWebhooks)                             // ดึง webhook ทั้งหมด
+		admin.GET("/webhooks/deliveries", can(permissions.WebhooksManage), handlers.GetWebhookDeliveries)            // ดูประวัติการส่ง webhook
+		admin.POST("/webhooks/deliveries/:id/redeliver", can(permissions.WebhooksManage), handlers.RedeliverWebhook) // ส่ง webhook ซ้ำ
+		admin.PUT("/webhooks/:id", can(permissions.WebhooksManage), handlers.UpdateWebhook)                          // แก้ไข webhook
+		admin.DELETE("/webhooks/:id", can(permissions.WebhooksManage), handlers.DeleteWebhook)                       // ลบ webhook
+
+		// Audit log
+		admin.GET("/audit-log", can(permissions.AuditView), handlers.GetAuditLog)           // ดู audit log (กรองตาม admin, action, เป้าหมาย, ช่วงวันที่)
+		admin.GET("/audit-log/export", can(permissions.AuditView), handlers.ExportAuditLog) // export audit log เป็น CSV
 	}
 
+	// เริ่ม worker pool สำหรับ background jobs
+	jobPool := jobs.NewPool(config.DB, jobWorkerCount())
+	handlers.RegisterJobs(jobPool)
+	jobPool.Start()
+
 	// เริ่ม server
 	port := os.Getenv("PORT")
 	if port == "" {
 		port = "8080"
 	}
 
-	log.Printf("🚀 Server is running on port %s", port)
-	if err := r.Run(":" + port); err != nil {
-		log.Fatal("Failed to start server:", err)
+	srv := &http.Server{Addr: ":" + port, Handler: r}
+	srv.RegisterOnShutdown(events.Close) // ปิด SSE stream ที่ค้างอยู่ ไม่ให้ Shutdown ต้องรอจน timeout
+	go func() {
+		log.Printf("🚀 Server is running on port %s", port)
+		if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Fatal("Failed to start server:", err)
+		}
+	}()
+
+	// รอสัญญาณปิดโปรแกรม แล้วปิด server และรอ job ที่กำลังทำงานให้เสร็จ
+	quit := make(chan os.Signal, 1)
+	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
+	<-quit
+	log.Println("🛑 Shutting down...")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	if err := srv.Shutdown(ctx); err != nil {
+		log.Println("Server shutdown error:", err)
+	}
+	if err := jobPool.Stop(ctx); err != nil {
+		log.Println("Job worker pool shutdown error:", err)
+	}
+}
+
+// jobWorkerCount - จำนวน worker ของ background jobs (JOB_WORKERS, ค่าเริ่มต้น 4, ตั้งเป็น 0 เพื่อปิด)
+func jobWorkerCount() int {
+	if n, err := strconv.Atoi(os.Getenv("JOB_WORKERS")); err == nil && n >= 0 {
+		return n
 	}
+	return 4
 }
@@ -1,7 +1,10 @@
 package middleware
 
 import (
+	"back-end/config"
+	"back-end/permissions"
 	"back-end/utils"
+	"database/sql"
 	"net/http"
 	"strings"
 
@@ -46,15 +49,72 @@ func AuthMiddleware() gin.HandlerFunc {
 			return
 		}
 
+		// ตรวจว่าบัญชียังใช้งานได้ และ token ยังไม่ถูกยกเลิก (token version ตรงกับ database)
+		state, err := loadTokenState(claims.UserID)
+		if err == sql.ErrNoRows {
+			c.JSON(http.StatusUnauthorized, gin.H{
+				"error":   "Unauthorized",
+				"message": "User not found",
+			})
+			c.Abort()
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error":   "Server error",
+				"message": "Failed to check account status",
+			})
+			c.Abort()
+			return
+		}
+		if state.banned || state.suspended {
+			message := "Account suspended"
+			if state.banned {
+				message = "Account banned"
+			}
+			c.JSON(http.StatusForbidden, gin.H{
+				"error":   "Forbidden",
+				"message": message,
+			})
+			c.Abort()
+			return
+		}
+		if state.version != claims.TokenVersion {
+			c.JSON(http.StatusUnauthorized, gin.H{
+				"error":   "Unauthorized",
+				"message": "Token has been revoked, please log in again",
+			})
+			c.Abort()
+			return
+		}
+
 		// เก็บข้อมูล user ใน context
-		c.Set("user_id", claims.UserID)
-		c.Set("email", claims.Email)
-		c.Set("roles", claims.Roles)
+		setClaims(c, claims)
 
 		c.Next()
 	}
 }
 
+// tokenState - ข้อมูลบัญชีที่ใช้ตรวจ token ทุก request
+type tokenState struct {
+	version   int
+	banned    bool
+	suspended bool
+}
+
+// loadTokenState - ดึง token version และสถานะการระงับ/แบนของ user
+func loadTokenState(userID int) (*tokenState, error) {
+	var state tokenState
+	err := config.DB.QueryRow(`
+		SELECT token_version, banned_at IS NOT NULL, COALESCE(suspended_until > NOW(), false)
+		FROM users WHERE id = $1
+	`, userID).Scan(&state.version, &state.banned, &state.suspended)
+	if err != nil {
+		return nil, err
+	}
+	return &state, nil
+}
+
 // RequireRole - middleware สำหรับตรวจสอบว่ามี role ที่ต้องการหรือไม่
 func RequireRole(requiredRoles ...string) gin.HandlerFunc {
 	return func(c *gin.Context) {
@@ -104,3 +164,107 @@ func RequireRole(requiredRoles ...string) gin.HandlerFunc {
 		c.Next()
 	}
 }
+
+// OptionalAuth - ถ้ามี JWT token ที่ถูกต้องจะเก็บข้อมูล user ใน context เหมือน AuthMiddleware
+// ถ้าไม่มี token ไม่ถูกต้อง ถูกยกเลิก หรือบัญชีถูกระงับ จะทำงานต่อแบบไม่ login (ใช้กับ route สาธารณะที่แสดงข้อมูลเฉพาะคนได้)
+func OptionalAuth() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		parts := strings.SplitN(c.GetHeader("Authorization"), " ", 2)
+		if len(parts) == 2 && parts[0] == "Bearer" {
+			if claims, err := utils.ValidateJWT(parts[1]); err == nil && tokenUsable(claims) {
+				setClaims(c, claims)
+			}
+		}
+		c.Next()
+	}
+}
+
+// tokenUsable - token ยังไม่ถูกยกเลิกและบัญชีใช้งานได้
+func tokenUsable(claims *utils.JWTClaims) bool {
+	state, err := loadTokenState(claims.UserID)
+	return err == nil && !state.banned && !state.suspended && state.version == claims.TokenVersion
+}
+
+// setClaims - เก็บข้อมูล user จาก token ใน context
+func setClaims(c *gin.Context, claims *utils.JWTClaims) {
+	c.Set("user_id", claims.UserID)
+	c.Set("email", claims.Email)
+	c.Set("roles", claims.Roles)
+	c.Set("token_version", claims.TokenVersion)
+}
+
+// SessionValid - ตรวจซ้ำว่า token ของ request ยังไม่ถูกยกเลิกและบัญชียังใช้งานได้
+// ใช้กับ connection ที่เปิดค้างนาน (เช่น SSE) ที่ผ่าน AuthMiddleware ไปตั้งแต่ตอนเชื่อมต่อ
+func SessionValid(c *gin.Context) (bool, error) {
+	state, err := loadTokenState(c.GetInt("user_id"))
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return !state.banned && !state.suspended && state.version == c.GetInt("token_version"), nil
+}
+
+// StreamTicketAuth - ใช้แทน AuthMiddleware กับ endpoint แบบ stream
+// EventSource ของ browser ส่ง header เองไม่ได้ จึงรับ ?ticket= ที่ขอจาก POST /api/events/ticket (ใช้ได้ครั้งเดียว อายุสั้น)
+// แทนการส่ง access token ใน URL ซึ่งจะถูกเขียนลง access log ถ้าไม่มี ticket จะตรวจ Authorization header ตามปกติ
+func StreamTicketAuth() gin.HandlerFunc {
+	auth := AuthMiddleware()
+	return func(c *gin.Context) {
+		ticket := c.Query("ticket")
+		if ticket == "" {
+			auth(c)
+			return
+		}
+
+		claims, ok := utils.RedeemStreamTicket(ticket)
+		if !ok || !tokenUsable(claims) {
+			c.JSON(http.StatusUnauthorized, gin.H{
+				"error":   "Unauthorized",
+				"message": "Invalid or expired stream ticket",
+			})
+			c.Abort()
+			return
+		}
+		setClaims(c, claims)
+		c.Next()
+	}
+}
+
+// RequirePermission - middleware สำหรับตรวจสอบว่า role ของ user มีสิทธิ์อย่างน้อยหนึ่งสิทธิ์ที่ระบุหรือไม่
+// ตรวจจาก database ทุก request (การเปลี่ยนสิทธิ์ของ role มีผลทันทีโดยไม่ต้อง login ใหม่)
+// ต้องใช้หลัง AuthMiddleware
+func RequirePermission(perms ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID := c.GetInt("user_id")
+		if userID == 0 {
+			c.JSON(http.StatusUnauthorized, gin.H{
+				"error":   "Unauthorized",
+				"message": "User not authenticated",
+			})
+			c.Abort()
+			return
+		}
+
+		ok, err := permissions.UserHasAny(config.DB, userID, perms...)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error":   "Server error",
+				"message": "Failed to check permissions",
+			})
+			c.Abort()
+			return
+		}
+		if !ok {
+			c.JSON(http.StatusForbidden, gin.H{
+				"error":   "Forbidden",
+				"message": "You don't have permission to access this resource",
+			})
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
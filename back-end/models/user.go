@@ -11,7 +11,10 @@ type User struct {
 	FullName     string    `json:"fullname"`
 	Phone        string    `json:"phone"`
 	AvatarURL    string    `json:"avatar_url"`
+	Bio          string    `json:"bio"`
 	CreatedAt    time.Time `json:"created_at"`
+
+	SellerVerified bool `json:"seller_verified"` // ผู้ขายที่ผ่านการยืนยันตัวตน (badge)
 }
 
 // Role model
@@ -33,7 +36,8 @@ type UserRole struct {
 // UserWithRoles - User พร้อม roles
 type UserWithRoles struct {
 	User
-	Roles []string `json:"roles"` // ["user", "seller", "admin"]
+	Roles       []string `json:"roles"`       // ["user", "seller", "admin"]
+	Permissions []string `json:"permissions"` // ["notes.view", "notes.approve"]
 }
 
 // LoginRequest - ข้อมูลสำหรับ login
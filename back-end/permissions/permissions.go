@@ -0,0 +1,97 @@
+package permissions
+
+import (
+	"database/sql"
+
+	"github.com/lib/pq"
+)
+
+// สิทธิ์ที่โค้ดใช้ตรวจ (ผูกกับ role ผ่านตาราง role_permissions)
+const (
+	StatsView      = "stats.view"
+	UsersView      = "users.view"
+	UsersBan       = "users.ban"
+	RolesManage    = "roles.manage"
+	NotesView      = "notes.view"
+	NotesApprove   = "notes.approve"
+	NotesEdit      = "notes.edit"
+	SliderManage   = "slider.manage"
+	JobsManage     = "jobs.manage"
+	CouponsManage  = "coupons.manage"
+	ReportsManage  = "reports.manage"
+	WebhooksManage = "webhooks.manage"
+	AuditView      = "audit.view"
+	SellersVerify  = "sellers.verify"
+)
+
+// AdminRole - role ที่มีทุกสิทธิ์เสมอ (ไม่ต้องผูกสิทธิ์ใน role_permissions)
+const AdminRole = "admin"
+
+// Builtin - สิทธิ์ที่โค้ดใช้ตรวจ พร้อมคำอธิบาย (ลบหรือเปลี่ยนชื่อผ่าน API ไม่ได้)
+var Builtin = map[string]string{
+	StatsView:      "View dashboard statistics",
+	UsersView:      "View users and sellers",
+	UsersBan:       "Suspend, ban and reinstate users",
+	RolesManage:    "Manage roles, permissions and role assignments",
+	NotesView:      "View all notes, including pending ones",
+	NotesApprove:   "Approve or reject pending notes",
+	NotesEdit:      "Edit, delete and restore notes",
+	SliderManage:   "Manage homepage slider images",
+	JobsManage:     "View and retry background jobs",
+	CouponsManage:  "Manage site-wide coupons",
+	ReportsManage:  "Work the content report queue",
+	WebhooksManage: "Manage site webhooks",
+	AuditView:      "View and export the audit log",
+	SellersVerify:  "Review seller applications and student ID documents",
+}
+
+// Querier - ใช้ได้ทั้ง *sql.DB และ *sql.Tx
+type Querier interface {
+	QueryRow(query string, args ...interface{}) *sql.Row
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+}
+
+// UserHasAny - user มีสิทธิ์อย่างน้อยหนึ่งสิทธิ์ที่ระบุ (role admin มีทุกสิทธิ์)
+func UserHasAny(db Querier, userID int, perms ...string) (bool, error) {
+	var ok bool
+	err := db.QueryRow(`
+		SELECT EXISTS (
+			SELECT 1 FROM user_roles ur
+			JOIN roles r ON r.id = ur.role_id
+			LEFT JOIN role_permissions rp ON rp.role_id = r.id
+			LEFT JOIN permissions p ON p.id = rp.permission_id
+			WHERE ur.user_id = $1 AND (r.name = $2 OR p.name = ANY($3))
+		)
+	`, userID, AdminRole, pq.Array(perms)).Scan(&ok)
+	return ok, err
+}
+
+// ForUser - สิทธิ์ทั้งหมดของ user เรียงตามชื่อ (role admin ได้ทุกสิทธิ์ในตาราง permissions)
+func ForUser(db Querier, userID int) ([]string, error) {
+	rows, err := db.Query(`
+		SELECT p.name FROM permissions p
+		WHERE EXISTS (
+			SELECT 1 FROM user_roles ur JOIN roles r ON r.id = ur.role_id
+			WHERE ur.user_id = $1 AND r.name = $2
+		) OR p.id IN (
+			SELECT rp.permission_id FROM user_roles ur
+			JOIN role_permissions rp ON rp.role_id = ur.role_id
+			WHERE ur.user_id = $1
+		)
+		ORDER BY p.name
+	`, userID, AdminRole)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	perms := []string{}
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, err
+		}
+		perms = append(perms, name)
+	}
+	return perms, rows.Err()
+}
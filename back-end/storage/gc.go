@@ -0,0 +1,141 @@
+package storage
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// UploadsDir - โฟลเดอร์เก็บไฟล์ที่ผู้ใช้อัปโหลด (relative กับ working directory ของ server)
+const UploadsDir = "uploads"
+
+// DefaultMinAge - ไฟล์ที่ใหม่กว่านี้จะไม่ถูกนับเป็นไฟล์ขยะ
+// (CreateNote บันทึกไฟล์ก่อน commit transaction จึงต้องเผื่อเวลาให้ request ที่กำลังทำงานอยู่)
+const DefaultMinAge = 24 * time.Hour
+
+// referenceQueries - ทุกคอลัมน์ที่อ้างถึงไฟล์ใน ./uploads
+var referenceQueries = []string{
+	`SELECT pdf_file FROM notes_for_sale WHERE pdf_file IS NOT NULL`,
+	`SELECT path FROM note_images`,
+	`SELECT path FROM note_previews`,
+	`SELECT path FROM image_variants`,
+	`SELECT avatar_url FROM users WHERE avatar_url IS NOT NULL`,
+	`SELECT image_path FROM slider_images`,
+}
+
+// OrphanFile - ไฟล์ใน ./uploads ที่ไม่มีข้อมูลใน database อ้างถึง
+type OrphanFile struct {
+	Path    string    `json:"path"`
+	Size    int64     `json:"size"`
+	ModTime time.Time `json:"mod_time"`
+}
+
+// GCReport - ผลการตรวจสอบ/ลบไฟล์ขยะ
+type GCReport struct {
+	DryRun     bool         `json:"dry_run"`
+	Scanned    int          `json:"scanned"`
+	Referenced int          `json:"referenced"`
+	Orphans    []OrphanFile `json:"orphans"`
+	OrphanSize int64        `json:"orphan_size"`
+	Deleted    int          `json:"deleted"`
+	Errors     []string     `json:"errors,omitempty"`
+}
+
+// LocalPath - แปลง path หรือ URL ที่เก็บใน database ("./uploads/..", "uploads/..", "/uploads/..")
+// เป็น path ในเครื่อง และตรวจว่าอยู่ใน ./uploads จริง
+func LocalPath(path string) (string, bool) {
+	cleaned := filepath.Clean(strings.TrimPrefix(filepath.FromSlash(path), string(filepath.Separator)))
+	if strings.HasPrefix(cleaned, UploadsDir+string(filepath.Separator)) {
+		return cleaned, true
+	}
+	return "", false
+}
+
+// referencedFiles - รวม path ของไฟล์ทั้งหมดที่ database อ้างถึง
+func referencedFiles(db *sql.DB) (map[string]bool, error) {
+	referenced := map[string]bool{}
+	for _, query := range referenceQueries {
+		rows, err := db.Query(query)
+		if err != nil {
+			return nil, err
+		}
+		for rows.Next() {
+			var path string
+			if err := rows.Scan(&path); err != nil {
+				rows.Close()
+				return nil, err
+			}
+			if local, ok := LocalPath(path); ok {
+				referenced[local] = true
+			}
+		}
+		err = rows.Err()
+		rows.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+	return referenced, nil
+}
+
+// CollectGarbage - เทียบไฟล์ใน ./uploads กับข้อมูลใน database
+// dryRun = true จะรายงานไฟล์ขยะอย่างเดียว, false จะลบไฟล์เหล่านั้นด้วย
+func CollectGarbage(db *sql.DB, minAge time.Duration, dryRun bool) (*GCReport, error) {
+	// ดึงรายการอ้างอิงก่อนสแกนไฟล์ ไฟล์ที่ถูกสร้างระหว่างนี้จะยังใหม่กว่า minAge จึงไม่ถูกลบ
+	referenced, err := referencedFiles(db)
+	if err != nil {
+		return nil, fmt.Errorf("load file references: %w", err)
+	}
+
+	report := &GCReport{DryRun: dryRun, Orphans: []OrphanFile{}}
+	cutoff := time.Now().Add(-minAge)
+
+	err = filepath.WalkDir(UploadsDir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) && path == UploadsDir {
+				return fs.SkipDir
+			}
+			return err
+		}
+		if entry.IsDir() || strings.HasPrefix(entry.Name(), ".") {
+			return nil
+		}
+
+		report.Scanned++
+		if referenced[filepath.Clean(path)] {
+			report.Referenced++
+			return nil
+		}
+
+		info, err := entry.Info()
+		if err != nil || info.ModTime().After(cutoff) {
+			return nil
+		}
+		report.Orphans = append(report.Orphans, OrphanFile{Path: path, Size: info.Size(), ModTime: info.ModTime()})
+		report.OrphanSize += info.Size()
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("scan uploads: %w", err)
+	}
+
+	sort.Slice(report.Orphans, func(i, j int) bool { return report.Orphans[i].Path < report.Orphans[j].Path })
+	if dryRun {
+		return report, nil
+	}
+
+	for _, orphan := range report.Orphans {
+		if err := os.Remove(orphan.Path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			report.Errors = append(report.Errors, err.Error())
+			continue
+		}
+		report.Deleted++
+	}
+	return report, nil
+}
@@ -0,0 +1,116 @@
+package utils
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"hash/fnv"
+	"strings"
+	"unicode"
+)
+
+// ขนาดของ text fingerprint
+const (
+	MinHashSize     = 128 // จำนวนค่าใน MinHash signature
+	MinHashBands    = 32  // LSH: แบ่ง signature เป็น 32 band (band ละ 4 ค่า) ใช้หา note ที่น่าจะคล้ายจาก index
+	MinTextShingles = 50  // ถ้ามี shingle น้อยกว่านี้ถือว่าข้อความไม่พอเทียบ (เช่น PDF ที่สแกนมา)
+	shingleSize     = 8   // จำนวนตัวอักษรต่อ shingle (ใช้ตัวอักษรแทนคำเพราะภาษาไทยไม่เว้นวรรค)
+)
+
+// TextFingerprint - MinHash ของ shingle ในข้อความ (เทียบความคล้ายแบบ Jaccard ได้โดยไม่ต้องเก็บข้อความ)
+type TextFingerprint struct {
+	MinHash  []int64
+	Shingles int // จำนวน shingle ที่ไม่ซ้ำกัน
+}
+
+// FileSHA256 - hash ของไฟล์ทั้งไฟล์ (hex) ใช้หาไฟล์ที่เหมือนกันทุก byte
+func FileSHA256(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// normalizeText - เก็บเฉพาะตัวอักษร ตัวเลข และสระ/วรรณยุกต์ (ตัดช่องว่างและเครื่องหมายออก ไม่สนตัวพิมพ์เล็ก/ใหญ่)
+func normalizeText(text string) []rune {
+	out := make([]rune, 0, len(text))
+	for _, r := range strings.ToLower(text) {
+		if unicode.IsLetter(r) || unicode.IsNumber(r) || unicode.Is(unicode.Mn, r) {
+			out = append(out, r)
+		}
+	}
+	return out
+}
+
+// splitmix64 - กระจายบิตของ hash (ใช้สร้าง hash function หลายตัวจาก seed ต่างกัน)
+func splitmix64(x uint64) uint64 {
+	x += 0x9e3779b97f4a7c15
+	x = (x ^ (x >> 30)) * 0xbf58476d1ce4e5b9
+	x = (x ^ (x >> 27)) * 0x94d049bb133111eb
+	return x ^ (x >> 31)
+}
+
+// FingerprintText - สร้าง MinHash จาก shingle ของข้อความ
+func FingerprintText(text string) TextFingerprint {
+	runes := normalizeText(text)
+	shingles := map[uint64]struct{}{}
+	for i := 0; i+shingleSize <= len(runes); i++ {
+		h := fnv.New64a()
+		h.Write([]byte(string(runes[i : i+shingleSize])))
+		shingles[h.Sum64()] = struct{}{}
+	}
+
+	fp := TextFingerprint{Shingles: len(shingles)}
+	if len(shingles) == 0 {
+		return fp
+	}
+
+	mins := make([]uint64, MinHashSize)
+	for i := range mins {
+		mins[i] = ^uint64(0)
+	}
+	for shingle := range shingles {
+		for i := range mins {
+			if h := splitmix64(shingle ^ uint64(i)*0x9e3779b97f4a7c15); h < mins[i] {
+				mins[i] = h
+			}
+		}
+	}
+
+	fp.MinHash = make([]int64, MinHashSize)
+	for i, v := range mins {
+		fp.MinHash[i] = int64(v) // เก็บเป็น BIGINT ใน Postgres
+	}
+	return fp
+}
+
+// Bands - hash ของแต่ละ band (note ที่มี band ตรงกันอย่างน้อย 1 band เป็นผู้สมัครที่ต้องเทียบต่อ)
+func (f TextFingerprint) Bands() []int64 {
+	if len(f.MinHash) != MinHashSize {
+		return nil
+	}
+	rows := MinHashSize / MinHashBands
+	bands := make([]int64, MinHashBands)
+	buf := make([]byte, 8)
+	for b := range bands {
+		h := fnv.New64a()
+		for _, v := range f.MinHash[b*rows : (b+1)*rows] {
+			binary.BigEndian.PutUint64(buf, uint64(v))
+			h.Write(buf)
+		}
+		bands[b] = int64(h.Sum64())
+	}
+	return bands
+}
+
+// MinHashSimilarity - ประมาณความคล้าย (Jaccard) ของข้อความสองชุดจาก MinHash (0-1)
+func MinHashSimilarity(a, b []int64) float64 {
+	if len(a) == 0 || len(a) != len(b) {
+		return 0
+	}
+	same := 0
+	for i := range a {
+		if a[i] == b[i] {
+			same++
+		}
+	}
+	return float64(same) / float64(len(a))
+}
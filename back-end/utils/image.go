@@ -0,0 +1,321 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"image"
+	"image/draw"
+	_ "image/gif" // ลงทะเบียน decoder
+	"image/jpeg"
+	_ "image/png" // ลงทะเบียน decoder
+	"net/http"
+
+	_ "golang.org/x/image/webp" // ลงทะเบียน decoder
+)
+
+// ResizeToFit - ย่อรูปให้อยู่ในกรอบ maxWidth x maxHeight โดยรักษาสัดส่วน (ไม่ขยายรูปที่เล็กกว่ากรอบ)
+// ใช้ box filter (เฉลี่ยพิกเซลในพื้นที่ที่ถูกย่อ) ซึ่งให้ผลดีสำหรับการย่อรูป
+func ResizeToFit(src image.Image, maxWidth, maxHeight int) image.Image {
+	bounds := src.Bounds()
+	w, h := bounds.Dx(), bounds.Dy()
+	if w <= 0 || h <= 0 {
+		return src
+	}
+
+	scale := 1.0
+	if maxWidth > 0 && w > maxWidth {
+		scale = float64(maxWidth) / float64(w)
+	}
+	if maxHeight > 0 && float64(h)*scale > float64(maxHeight) {
+		scale = float64(maxHeight) / float64(h)
+	}
+	if scale >= 1.0 {
+		return toRGBA(src)
+	}
+
+	dstW := max(1, int(float64(w)*scale+0.5))
+	dstH := max(1, int(float64(h)*scale+0.5))
+	return resizeBox(toRGBA(src), dstW, dstH)
+}
+
+// toRGBA - แปลงรูปเป็น *image.RGBA ที่เริ่มจากจุด (0,0)
+func toRGBA(src image.Image) *image.RGBA {
+	if rgba, ok := src.(*image.RGBA); ok && rgba.Bounds().Min == (image.Point{}) {
+		return rgba
+	}
+	bounds := src.Bounds()
+	dst := image.NewRGBA(image.Rect(0, 0, bounds.Dx(), bounds.Dy()))
+	draw.Draw(dst, dst.Bounds(), src, bounds.Min, draw.Src)
+	return dst
+}
+
+func resizeBox(src *image.RGBA, dstW, dstH int) *image.RGBA {
+	srcW, srcH := src.Bounds().Dx(), src.Bounds().Dy()
+	dst := image.NewRGBA(image.Rect(0, 0, dstW, dstH))
+
+	for y := 0; y < dstH; y++ {
+		y0 := y * srcH / dstH
+		y1 := max(y0+1, (y+1)*srcH/dstH)
+		for x := 0; x < dstW; x++ {
+			x0 := x * srcW / dstW
+			x1 := max(x0+1, (x+1)*srcW/dstW)
+
+			var r, g, b, a, n uint64
+			for sy := y0; sy < y1; sy++ {
+				row := src.Pix[sy*src.Stride:]
+				for sx := x0; sx < x1; sx++ {
+					p := row[sx*4 : sx*4+4]
+					r += uint64(p[0])
+					g += uint64(p[1])
+					b += uint64(p[2])
+					a += uint64(p[3])
+					n++
+				}
+			}
+
+			i := y*dst.Stride + x*4
+			dst.Pix[i] = uint8(r / n)
+			dst.Pix[i+1] = uint8(g / n)
+			dst.Pix[i+2] = uint8(b / n)
+			dst.Pix[i+3] = uint8(a / n)
+		}
+	}
+	return dst
+}
+
+// ImageVariantSpec - ขนาดมาตรฐานของรูปแต่ละแบบ (รูปจะถูกย่อให้อยู่ในกรอบ MaxWidth x MaxHeight)
+type ImageVariantSpec struct {
+	Name      string
+	MaxWidth  int
+	MaxHeight int
+}
+
+// ImageVariant - รูปที่ถูกย่อและ encode ใหม่แล้ว
+type ImageVariant struct {
+	Name     string
+	Data     []byte
+	Width    int
+	Height   int
+	MimeType string
+}
+
+// ขนาดมาตรฐาน (thumb, card, full) ของรูปแต่ละประเภท
+var (
+	NoteImageVariants = []ImageVariantSpec{
+		{"thumb", 200, 200},
+		{"card", 600, 600},
+		{"full", 1600, 1600},
+	}
+	AvatarVariants = []ImageVariantSpec{
+		{"thumb", 96, 96},
+		{"card", 256, 256},
+		{"full", 512, 512},
+	}
+	SliderVariants = []ImageVariantSpec{
+		{"thumb", 320, 180},
+		{"card", 960, 540},
+		{"full", 1920, 1080},
+	}
+	// บัตรนักศึกษาเก็บขนาดเดียว (encode ใหม่เพื่อตัด EXIF เช่นพิกัด GPS ออก)
+	StudentIDVariants = []ImageVariantSpec{
+		{"full", 1600, 1600},
+	}
+)
+
+// Image errors - รูปภาพไม่ผ่านการตรวจสอบ
+var (
+	ErrImageType     = errors.New("unsupported image type. Only jpg, png, gif and webp are allowed")
+	ErrImageCorrupt  = errors.New("file is not a valid image")
+	ErrImageTooLarge = errors.New("image dimensions are too large")
+)
+
+const (
+	maxImagePixels     = 50_000_000
+	maxImageDimension  = 12000
+	imageJPEGQuality   = 85
+	imageSniffLength   = 512
+	exifOrientationTag = 0x0112
+)
+
+// allowedImageTypes - MIME type ที่รับได้ (ตรวจจากเนื้อหาไฟล์ ไม่ใช่นามสกุล)
+var allowedImageTypes = map[string]bool{
+	"image/jpeg": true,
+	"image/png":  true,
+	"image/gif":  true,
+	"image/webp": true,
+}
+
+// SniffImageType - ตรวจ MIME type จาก magic bytes ของไฟล์
+func SniffImageType(data []byte) (string, error) {
+	head := data
+	if len(head) > imageSniffLength {
+		head = head[:imageSniffLength]
+	}
+	mimeType := http.DetectContentType(head)
+	if !allowedImageTypes[mimeType] {
+		return "", ErrImageType
+	}
+	return mimeType, nil
+}
+
+// ProcessImage - ตรวจสอบว่าเป็นรูปจริง, หมุนตาม EXIF orientation, ลบ metadata (EXIF) โดยการ decode/encode ใหม่
+// แล้วสร้างรูปตามขนาดมาตรฐานที่กำหนด (encode เป็น JPEG)
+func ProcessImage(data []byte, specs []ImageVariantSpec) ([]ImageVariant, error) {
+	mimeType, err := SniffImageType(data)
+	if err != nil {
+		return nil, err
+	}
+
+	// ตรวจขนาดก่อน decode ทั้งรูป (กัน decompression bomb)
+	config, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, ErrImageCorrupt
+	}
+	if config.Width <= 0 || config.Height <= 0 {
+		return nil, ErrImageCorrupt
+	}
+	if config.Width > maxImageDimension || config.Height > maxImageDimension || config.Width*config.Height > maxImagePixels {
+		return nil, ErrImageTooLarge
+	}
+
+	src, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, ErrImageCorrupt
+	}
+
+	if mimeType == "image/jpeg" {
+		src = applyOrientation(src, jpegOrientation(data))
+	}
+	flat := flattenOnWhite(src)
+
+	variants := make([]ImageVariant, 0, len(specs))
+	for _, spec := range specs {
+		resized := ResizeToFit(flat, spec.MaxWidth, spec.MaxHeight)
+
+		var buf bytes.Buffer
+		if err := jpeg.Encode(&buf, resized, &jpeg.Options{Quality: imageJPEGQuality}); err != nil {
+			return nil, err
+		}
+		variants = append(variants, ImageVariant{
+			Name:     spec.Name,
+			Data:     buf.Bytes(),
+			Width:    resized.Bounds().Dx(),
+			Height:   resized.Bounds().Dy(),
+			MimeType: "image/jpeg",
+		})
+	}
+	return variants, nil
+}
+
+// flattenOnWhite - วางรูปบนพื้นขาว (JPEG ไม่รองรับความโปร่งใส)
+func flattenOnWhite(src image.Image) *image.RGBA {
+	bounds := src.Bounds()
+	dst := image.NewRGBA(image.Rect(0, 0, bounds.Dx(), bounds.Dy()))
+	draw.Draw(dst, dst.Bounds(), image.White, image.Point{}, draw.Src)
+	draw.Draw(dst, dst.Bounds(), src, bounds.Min, draw.Over)
+	return dst
+}
+
+// jpegOrientation - อ่านค่า EXIF Orientation (1-8) จาก segment APP1 ของ JPEG (คืน 1 ถ้าไม่มี)
+func jpegOrientation(data []byte) int {
+	if len(data) < 4 || data[0] != 0xFF || data[1] != 0xD8 {
+		return 1
+	}
+	pos := 2
+	for pos+4 <= len(data) {
+		if data[pos] != 0xFF {
+			return 1
+		}
+		marker := data[pos+1]
+		if marker == 0xDA || marker == 0xD9 { // start of scan / end of image
+			return 1
+		}
+		length := int(binary.BigEndian.Uint16(data[pos+2:]))
+		if length < 2 || pos+2+length > len(data) {
+			return 1
+		}
+		segment := data[pos+4 : pos+2+length]
+		if marker == 0xE1 && bytes.HasPrefix(segment, []byte("Exif\x00\x00")) {
+			return exifOrientation(segment[6:])
+		}
+		pos += 2 + length
+	}
+	return 1
+}
+
+func exifOrientation(tiff []byte) int {
+	if len(tiff) < 8 {
+		return 1
+	}
+	var order binary.ByteOrder
+	switch string(tiff[:2]) {
+	case "II":
+		order = binary.LittleEndian
+	case "MM":
+		order = binary.BigEndian
+	default:
+		return 1
+	}
+
+	ifd := int(order.Uint32(tiff[4:]))
+	if ifd+2 > len(tiff) {
+		return 1
+	}
+	entries := int(order.Uint16(tiff[ifd:]))
+	for i := 0; i < entries; i++ {
+		entry := ifd + 2 + i*12
+		if entry+12 > len(tiff) {
+			return 1
+		}
+		if order.Uint16(tiff[entry:]) == exifOrientationTag {
+			value := int(order.Uint16(tiff[entry+8:]))
+			if value >= 1 && value <= 8 {
+				return value
+			}
+			return 1
+		}
+	}
+	return 1
+}
+
+// applyOrientation - หมุน/กลับรูปตามค่า EXIF Orientation เพื่อให้แสดงผลถูกต้องหลังลบ EXIF
+func applyOrientation(src image.Image, orientation int) image.Image {
+	if orientation <= 1 || orientation > 8 {
+		return src
+	}
+
+	img := toRGBA(src)
+	w, h := img.Bounds().Dx(), img.Bounds().Dy()
+	dstW, dstH := w, h
+	if orientation >= 5 {
+		dstW, dstH = h, w
+	}
+	dst := image.NewRGBA(image.Rect(0, 0, dstW, dstH))
+
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			var dx, dy int
+			switch orientation {
+			case 2: // กลับซ้ายขวา
+				dx, dy = w-1-x, y
+			case 3: // หมุน 180
+				dx, dy = w-1-x, h-1-y
+			case 4: // กลับบนล่าง
+				dx, dy = x, h-1-y
+			case 5: // transpose
+				dx, dy = y, x
+			case 6: // หมุน 90 ตามเข็ม
+				dx, dy = h-1-y, x
+			case 7: // transverse
+				dx, dy = h-1-y, w-1-x
+			case 8: // หมุน 90 ทวนเข็ม
+				dx, dy = y, w-1-x
+			}
+			si := y*img.Stride + x*4
+			di := dy*dst.Stride + dx*4
+			copy(dst.Pix[di:di+4], img.Pix[si:si+4])
+		}
+	}
+	return dst
+}
@@ -10,14 +10,15 @@ import (
 )
 
 type JWTClaims struct {
-	UserID int      `json:"user_id"`
-	Email  string   `json:"email"`
-	Roles  []string `json:"roles"`
+	UserID       int      `json:"user_id"`
+	Email        string   `json:"email"`
+	Roles        []string `json:"roles"`
+	TokenVersion int      `json:"ver"` // ต้องตรงกับ users.token_version (เพิ่มค่าเมื่อต้องการยกเลิก token ที่ออกไปแล้วทั้งหมด)
 	jwt.RegisteredClaims
 }
 
 // GenerateJWT - สร้าง JWT Access token (อายุสั้น 15 นาที)
-func GenerateJWT(userID int, email string, roles []string) (string, error) {
+func GenerateJWT(userID int, email string, roles []string, tokenVersion int) (string, error) {
 	secret := os.Getenv("JWT_SECRET")
 	if secret == "" {
 		secret = "default-secret-key"
@@ -25,9 +26,10 @@ func GenerateJWT(userID int, email string, roles []string) (string, error) {
 
 	expiryMinutes := 15 // Access token อายุ 15 นาที
 	claims := JWTClaims{
-		UserID: userID,
-		Email:  email,
-		Roles:  roles,
+		UserID:       userID,
+		Email:        email,
+		Roles:        roles,
+		TokenVersion: tokenVersion,
 		RegisteredClaims: jwt.RegisteredClaims{
 			ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Minute * time.Duration(expiryMinutes))),
 			IssuedAt:  jwt.NewNumericDate(time.Now()),
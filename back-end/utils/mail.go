@@ -0,0 +1,48 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"net/smtp"
+	"os"
+	"strings"
+)
+
+// ErrMailNotConfigured - ยังไม่ได้ตั้งค่า SMTP_HOST (ส่ง email ไม่ได้)
+var ErrMailNotConfigured = errors.New("mail is not configured")
+
+// MailConfigured - ตั้งค่า SMTP ไว้หรือไม่
+func MailConfigured() bool {
+	return os.Getenv("SMTP_HOST") != ""
+}
+
+// SendMail - ส่ง email แบบข้อความธรรมดาผ่าน SMTP
+// ตั้งค่าด้วย SMTP_HOST, SMTP_PORT (default 587), SMTP_USER, SMTP_PASSWORD และ SMTP_FROM
+func SendMail(to, subject, body string) error {
+	host := os.Getenv("SMTP_HOST")
+	if host == "" {
+		return ErrMailNotConfigured
+	}
+	port := os.Getenv("SMTP_PORT")
+	if port == "" {
+		port = "587"
+	}
+	from := os.Getenv("SMTP_FROM")
+	if from == "" {
+		from = os.Getenv("SMTP_USER")
+	}
+
+	// กัน header injection จาก subject หรือที่อยู่ที่มีขึ้นบรรทัดใหม่
+	if strings.ContainsAny(to+subject+from, "\r\n") {
+		return errors.New("invalid mail header")
+	}
+
+	var auth smtp.Auth
+	if user := os.Getenv("SMTP_USER"); user != "" {
+		auth = smtp.PlainAuth("", user, os.Getenv("SMTP_PASSWORD"), host)
+	}
+
+	msg := fmt.Sprintf("From: %s\r\nTo: %s\r\nSubject: %s\r\nMIME-Version: 1.0\r\nContent-Type: text/plain; charset=UTF-8\r\n\r\n%s\r\n",
+		from, to, subject, body)
+	return smtp.SendMail(host+":"+port, auth, from, []string{to}, []byte(msg))
+}
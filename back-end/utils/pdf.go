@@ -0,0 +1,832 @@
+package utils
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/ascii85"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+)
+
+// PDF errors - ใช้แยกประเภทของไฟล์ที่ไม่ผ่านการตรวจสอบ
+var (
+	ErrNotPDF          = errors.New("file is not a PDF")
+	ErrPDFEncrypted    = errors.New("encrypted PDF files are not supported")
+	ErrPDFMalformed    = errors.New("PDF file is malformed")
+	ErrPDFUnsupported  = errors.New("unsupported PDF feature")
+	errPDFStreamTooBig = errors.New("PDF streams are too large")
+	errPDFTooDeep      = errors.New("objects are nested too deeply")
+)
+
+// ขีดจำกัดในการ parse (กันไฟล์ที่จงใจทำให้ server ใช้หน่วยความจำมากเกินไป)
+const (
+	pdfMaxDecodedTotal  = 128 * 1024 * 1024 // ขนาดรวมของ stream ที่ถอดแล้วทั้งเอกสาร
+	pdfMaxTreeDepth     = 64
+	pdfMaxNestingDepth  = 64 // array/dictionary ซ้อนกันได้ไม่เกินนี้ (กัน stack overflow)
+	pdfHeaderSearchSize = 1024
+)
+
+// ชนิดข้อมูลของ object ใน PDF
+type (
+	pdfName   string
+	pdfString []byte
+	pdfArray  []interface{}
+	pdfDict   map[pdfName]interface{}
+	pdfRef    struct{ Num, Gen int }
+	pdfStream struct {
+		Dict pdfDict
+		Raw  []byte
+	}
+)
+
+// PDFDocument - เอกสาร PDF ที่ parse แล้ว
+type PDFDocument struct {
+	Version   string
+	Encrypted bool
+
+	data     []byte
+	objects  map[int]interface{}
+	xrefs    []pdfDict
+	trailers []pdfDict
+	catalog  pdfDict
+	pages    []pdfPage
+	decoded  int // ขนาดรวมของ stream ที่ inflate ไปแล้ว (เทียบกับ pdfMaxDecodedTotal)
+}
+
+// pdfPage - หน้าของเอกสาร พร้อม resources ที่สืบทอดมาจาก page tree
+type pdfPage struct {
+	dict      pdfDict
+	resources pdfDict
+}
+
+var pdfObjHeader = regexp.MustCompile(`(\d+)[\x00\t\n\f\r ]+(\d+)[\x00\t\n\f\r ]+obj`)
+
+// HasPDFMagic - ตรวจสอบ magic bytes "%PDF-" ในส่วนหัวของไฟล์
+func HasPDFMagic(data []byte) bool {
+	head := data
+	if len(head) > pdfHeaderSearchSize {
+		head = head[:pdfHeaderSearchSize]
+	}
+	return bytes.Contains(head, []byte("%PDF-"))
+}
+
+// ParsePDF - parse ไฟล์ PDF ทั้งไฟล์ ตรวจสอบโครงสร้าง page tree และการเข้ารหัส
+func ParsePDF(data []byte) (*PDFDocument, error) {
+	if !HasPDFMagic(data) {
+		return nil, ErrNotPDF
+	}
+
+	doc := &PDFDocument{
+		data:    data,
+		objects: map[int]interface{}{},
+	}
+
+	start := bytes.Index(data, []byte("%PDF-"))
+	version := data[start+5:]
+	if len(version) > 3 {
+		version = version[:3]
+	}
+	doc.Version = string(version)
+
+	if !bytes.Contains(tail(data, 2048), []byte("%%EOF")) {
+		return nil, fmt.Errorf("%w: missing %%%%EOF marker", ErrPDFMalformed)
+	}
+
+	if err := doc.scanObjects(); err != nil {
+		return nil, err
+	}
+	doc.scanTrailers()
+
+	for _, trailer := range doc.trailers {
+		if _, ok := trailer["Encrypt"]; ok {
+			doc.Encrypted = true
+		}
+	}
+	if doc.Encrypted {
+		return doc, ErrPDFEncrypted
+	}
+
+	doc.expandObjectStreams()
+
+	if err := doc.loadCatalog(); err != nil {
+		return nil, err
+	}
+	if err := doc.loadPages(); err != nil {
+		return nil, err
+	}
+
+	return doc, nil
+}
+
+// PageCount - จำนวนหน้าของเอกสาร
+func (d *PDFDocument) PageCount() int {
+	return len(d.pages)
+}
+
+func tail(data []byte, n int) []byte {
+	if len(data) > n {
+		return data[len(data)-n:]
+	}
+	return data
+}
+
+// scanObjects - ไล่อ่าน "N G obj" ทั้งไฟล์ตามลำดับ (object ที่อยู่ท้ายไฟล์แทนที่ของเดิม ตามแบบ incremental update)
+func (d *PDFDocument) scanObjects() error {
+	pos := 0
+	for pos < len(d.data) {
+		loc := pdfObjHeader.FindSubmatchIndex(d.data[pos:])
+		if loc == nil {
+			break
+		}
+		begin := pos + loc[0]
+		if begin > 0 && isPDFRegular(d.data[begin-1]) {
+			pos = pos + loc[1]
+			continue
+		}
+
+		num, _ := strconv.Atoi(string(d.data[pos+loc[2] : pos+loc[3]]))
+		p := &pdfParser{data: d.data, pos: pos + loc[1], doc: d}
+		obj, err := p.parseIndirectBody()
+		if err != nil {
+			return fmt.Errorf("%w: object %d: %v", ErrPDFMalformed, num, err)
+		}
+		d.objects[num] = obj
+		if stream, ok := obj.(*pdfStream); ok && stream.Dict["Type"] == pdfName("XRef") {
+			d.xrefs = append(d.xrefs, stream.Dict)
+		}
+		pos = p.pos
+	}
+
+	if len(d.objects) == 0 {
+		return fmt.Errorf("%w: no objects found", ErrPDFMalformed)
+	}
+	return nil
+}
+
+// scanTrailers - เก็บ trailer dictionary ทุกอัน (ทั้งแบบ "trailer" และ cross-reference stream)
+func (d *PDFDocument) scanTrailers() {
+	keyword := []byte("trailer")
+	pos := 0
+	for {
+		idx := bytes.Index(d.data[pos:], keyword)
+		if idx < 0 {
+			break
+		}
+		p := &pdfParser{data: d.data, pos: pos + idx + len(keyword), doc: d}
+		if obj, err := p.parseObject(); err == nil {
+			if dict, ok := obj.(pdfDict); ok {
+				d.trailers = append(d.trailers, dict)
+			}
+		}
+		pos += idx + len(keyword)
+	}
+
+	// cross-reference stream (PDF 1.5+) ทำหน้าที่เป็น trailer ด้วย
+	d.trailers = append(d.trailers, d.xrefs...)
+}
+
+// expandObjectStreams - แตก object ที่ถูกบีบอัดอยู่ใน /Type /ObjStm
+func (d *PDFDocument) expandObjectStreams() {
+	for _, obj := range d.objects {
+		stream, ok := obj.(*pdfStream)
+		if !ok || stream.Dict["Type"] != pdfName("ObjStm") {
+			continue
+		}
+		data, filter, err := d.decodeStream(stream)
+		if err != nil || filter != "" {
+			continue
+		}
+		n, _ := pdfInt(stream.Dict["N"])
+		first, _ := pdfInt(stream.Dict["First"])
+		if first <= 0 || first > len(data) {
+			continue
+		}
+
+		header := &pdfParser{data: data[:first], doc: d}
+		for i := 0; i < n; i++ {
+			numObj, err1 := header.parseObject()
+			offObj, err2 := header.parseObject()
+			num, ok1 := pdfInt(numObj)
+			off, ok2 := pdfInt(offObj)
+			if err1 != nil || err2 != nil || !ok1 || !ok2 {
+				break
+			}
+			if _, exists := d.objects[num]; exists {
+				continue
+			}
+			if first+off >= len(data) {
+				continue
+			}
+			p := &pdfParser{data: data, pos: first + off, doc: d}
+			if value, err := p.parseObject(); err == nil {
+				d.objects[num] = value
+			}
+		}
+	}
+}
+
+// loadCatalog - หา document catalog จาก /Root ของ trailer (หรือค้นหา /Type /Catalog ถ้าไม่มี)
+func (d *PDFDocument) loadCatalog() error {
+	for i := len(d.trailers) - 1; i >= 0; i-- {
+		if catalog, ok := d.resolve(d.trailers[i]["Root"]).(pdfDict); ok {
+			d.catalog = catalog
+			return nil
+		}
+	}
+	for _, obj := range d.objects {
+		if dict, ok := obj.(pdfDict); ok && dict["Type"] == pdfName("Catalog") {
+			d.catalog = dict
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: document catalog not found", ErrPDFMalformed)
+}
+
+// loadPages - ไล่ page tree และเก็บทุกหน้าตามลำดับ
+func (d *PDFDocument) loadPages() error {
+	root := d.catalog["Pages"]
+	if _, ok := d.resolve(root).(pdfDict); !ok {
+		return fmt.Errorf("%w: page tree not found", ErrPDFMalformed)
+	}
+
+	visited := map[int]bool{}
+	var walk func(nodeObj interface{}, inherited pdfDict, depth int) error
+	walk = func(nodeObj interface{}, inherited pdfDict, depth int) error {
+		if ref, ok := nodeObj.(pdfRef); ok {
+			if visited[ref.Num] {
+				return fmt.Errorf("%w: page tree is cyclic", ErrPDFMalformed)
+			}
+			visited[ref.Num] = true
+		}
+		if depth > pdfMaxTreeDepth {
+			return fmt.Errorf("%w: page tree is too deep", ErrPDFMalformed)
+		}
+		node, ok := d.resolve(nodeObj).(pdfDict)
+		if !ok {
+			return fmt.Errorf("%w: invalid page tree node", ErrPDFMalformed)
+		}
+
+		resources := inherited
+		if res, ok := d.resolve(node["Resources"]).(pdfDict); ok {
+			resources = res
+		}
+
+		kids, isTree := d.resolve(node["Kids"]).(pdfArray)
+		if !isTree {
+			d.pages = append(d.pages, pdfPage{dict: node, resources: resources})
+			return nil
+		}
+		for _, kid := range kids {
+			if err := walk(kid, resources, depth+1); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	if err := walk(root, nil, 0); err != nil {
+		return err
+	}
+	if len(d.pages) == 0 {
+		return fmt.Errorf("%w: document has no pages", ErrPDFMalformed)
+	}
+	return nil
+}
+
+// resolve - แปลง indirect reference เป็น object จริง
+func (d *PDFDocument) resolve(obj interface{}) interface{} {
+	for i := 0; i < 32; i++ {
+		ref, ok := obj.(pdfRef)
+		if !ok {
+			return obj
+		}
+		obj = d.objects[ref.Num]
+	}
+	return nil
+}
+
+// decodeStream - ถอด filter ของ stream (คืน filter ของรูปภาพ เช่น DCTDecode ถ้าเจอ โดยไม่ถอดต่อ)
+func (d *PDFDocument) decodeStream(s *pdfStream) ([]byte, pdfName, error) {
+	data := s.Raw
+	filters := []pdfName{}
+	params := []pdfDict{}
+
+	switch f := d.resolve(s.Dict["Filter"]).(type) {
+	case pdfName:
+		filters = append(filters, f)
+	case pdfArray:
+		for _, item := range f {
+			if name, ok := d.resolve(item).(pdfName); ok {
+				filters = append(filters, name)
+			}
+		}
+	}
+	switch p := d.resolve(s.Dict["DecodeParms"]).(type) {
+	case pdfDict:
+		params = append(params, p)
+	case pdfArray:
+		for _, item := range p {
+			dict, _ := d.resolve(item).(pdfDict)
+			params = append(params, dict)
+		}
+	}
+
+	for i, filter := range filters {
+		var param pdfDict
+		if i < len(params) {
+			param = params[i]
+		}
+
+		var err error
+		switch filter {
+		case "FlateDecode", "Fl":
+			data, err = inflate(data, pdfMaxDecodedTotal-d.decoded)
+			d.decoded += len(data)
+			if err == nil {
+				data, err = applyPredictor(data, param)
+			}
+		case "ASCIIHexDecode", "AHx":
+			data, err = decodeASCIIHex(data)
+		case "ASCII85Decode", "A85":
+			data, err = decodeASCII85(data)
+		case "DCTDecode", "DCT", "JPXDecode", "CCITTFaxDecode", "CCF", "JBIG2Decode":
+			return data, filter, nil
+		default:
+			return nil, "", fmt.Errorf("%w: filter %s", ErrPDFUnsupported, filter)
+		}
+		if err != nil {
+			return nil, "", err
+		}
+	}
+	return data, "", nil
+}
+
+// inflate - ถอด zlib ได้ไม่เกิน limit byte
+func inflate(data []byte, limit int) ([]byte, error) {
+	r, err := zlib.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+
+	out, err := io.ReadAll(io.LimitReader(r, int64(limit)+1))
+	if len(out) > limit {
+		return nil, errPDFStreamTooBig
+	}
+	// stream ที่ถูกตัดท้าย (unexpected EOF) ยังใช้ข้อมูลที่อ่านได้
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) {
+		return nil, err
+	}
+	return out, nil
+}
+
+// applyPredictor - ถอด PNG predictor (Predictor >= 10) ที่ใช้คู่กับ FlateDecode
+func applyPredictor(data []byte, param pdfDict) ([]byte, error) {
+	predictor, _ := pdfInt(param["Predictor"])
+	if predictor < 10 {
+		if predictor == 2 {
+			return nil, fmt.Errorf("%w: TIFF predictor", ErrPDFUnsupported)
+		}
+		return data, nil
+	}
+
+	colors := pdfIntDefault(param["Colors"], 1)
+	bpc := pdfIntDefault(param["BitsPerComponent"], 8)
+	columns := pdfIntDefault(param["Columns"], 1)
+	if colors < 1 || colors > 4 {
+		return nil, fmt.Errorf("%w: predictor /Colors %d", ErrPDFMalformed, colors)
+	}
+	switch bpc {
+	case 1, 2, 4, 8, 16:
+	default:
+		return nil, fmt.Errorf("%w: predictor /BitsPerComponent %d", ErrPDFMalformed, bpc)
+	}
+	// แถวหนึ่งต้องไม่ยาวกว่าข้อมูลทั้งหมด (ตรวจ columns ก่อนคูณเพื่อไม่ให้ overflow)
+	if columns < 1 || columns > len(data)*8 {
+		return nil, fmt.Errorf("%w: predictor /Columns %d", ErrPDFMalformed, columns)
+	}
+
+	bpp := (colors*bpc + 7) / 8
+	rowLen := (colors*bpc*columns + 7) / 8
+	if rowLen > len(data) {
+		return nil, fmt.Errorf("%w: predictor row is longer than the stream", ErrPDFMalformed)
+	}
+
+	out := make([]byte, 0, len(data))
+	prev := make([]byte, rowLen)
+	for len(data) > rowLen {
+		filterType := data[0]
+		row := make([]byte, rowLen)
+		copy(row, data[1:rowLen+1])
+		data = data[rowLen+1:]
+
+		for i := 0; i < rowLen; i++ {
+			var left, upLeft byte
+			if i >= bpp {
+				left = row[i-bpp]
+				upLeft = prev[i-bpp]
+			}
+			up := prev[i]
+			switch filterType {
+			case 0:
+			case 1:
+				row[i] += left
+			case 2:
+				row[i] += up
+			case 3:
+				row[i] += byte((int(left) + int(up)) / 2)
+			case 4:
+				row[i] += paeth(left, up, upLeft)
+			default:
+				return nil, fmt.Errorf("%w: invalid PNG predictor row", ErrPDFMalformed)
+			}
+		}
+		out = append(out, row...)
+		prev = row
+	}
+	return out, nil
+}
+
+func paeth(a, b, c byte) byte {
+	p := int(a) + int(b) - int(c)
+	pa, pb, pc := abs(p-int(a)), abs(p-int(b)), abs(p-int(c))
+	if pa <= pb && pa <= pc {
+		return a
+	}
+	if pb <= pc {
+		return b
+	}
+	return c
+}
+
+func abs(x int) int {
+	if x < 0 {
+		return -x
+	}
+	return x
+}
+
+func decodeASCIIHex(data []byte) ([]byte, error) {
+	clean := make([]byte, 0, len(data))
+	for _, b := range data {
+		if b == '>' {
+			break
+		}
+		if !isPDFSpace(b) {
+			clean = append(clean, b)
+		}
+	}
+	if len(clean)%2 == 1 {
+		clean = append(clean, '0')
+	}
+	out := make([]byte, len(clean)/2)
+	_, err := hex.Decode(out, clean)
+	return out, err
+}
+
+func decodeASCII85(data []byte) ([]byte, error) {
+	data = bytes.TrimPrefix(bytes.TrimSpace(data), []byte("<~"))
+	if idx := bytes.Index(data, []byte("~>")); idx >= 0 {
+		data = data[:idx]
+	}
+	out := make([]byte, len(data))
+	n, _, err := ascii85.Decode(out, data, true)
+	return out[:n], err
+}
+
+// pdfInt - แปลงตัวเลขใน PDF เป็น int
+func pdfInt(obj interface{}) (int, bool) {
+	switch v := obj.(type) {
+	case int64:
+		return int(v), true
+	case float64:
+		return int(v), true
+	}
+	return 0, false
+}
+
+func pdfIntDefault(obj interface{}, def int) int {
+	if v, ok := pdfInt(obj); ok {
+		return v
+	}
+	return def
+}
+
+// ---------- lexer / parser ----------
+
+type pdfParser struct {
+	data  []byte
+	pos   int
+	doc   *PDFDocument
+	depth int // ความลึกของ array/dictionary ที่กำลัง parse
+}
+
+// enter - เข้าไปใน array/dictionary ชั้นถัดไป (ต้องเรียก p.depth-- เมื่อออก)
+func (p *pdfParser) enter() error {
+	p.depth++
+	if p.depth > pdfMaxNestingDepth {
+		return errPDFTooDeep
+	}
+	return nil
+}
+
+func isPDFSpace(b byte) bool {
+	switch b {
+	case 0, '\t', '\n', '\f', '\r', ' ':
+		return true
+	}
+	return false
+}
+
+func isPDFDelimiter(b byte) bool {
+	switch b {
+	case '(', ')', '<', '>', '[', ']', '{', '}', '/', '%':
+		return true
+	}
+	return false
+}
+
+func isPDFRegular(b byte) bool {
+	return !isPDFSpace(b) && !isPDFDelimiter(b)
+}
+
+func (p *pdfParser) skipSpace() {
+	for p.pos < len(p.data) {
+		b := p.data[p.pos]
+		if isPDFSpace(b) {
+			p.pos++
+			continue
+		}
+		if b == '%' {
+			for p.pos < len(p.data) && p.data[p.pos] != '\n' && p.data[p.pos] != '\r' {
+				p.pos++
+			}
+			continue
+		}
+		break
+	}
+}
+
+func (p *pdfParser) readToken() string {
+	start := p.pos
+	for p.pos < len(p.data) && isPDFRegular(p.data[p.pos]) {
+		p.pos++
+	}
+	return string(p.data[start:p.pos])
+}
+
+func (p *pdfParser) hasPrefix(s string) bool {
+	return bytes.HasPrefix(p.data[p.pos:], []byte(s))
+}
+
+// parseIndirectBody - parse object หลัง "N G obj" รวมถึง stream (ถ้ามี) จนถึง "endobj"
+func (p *pdfParser) parseIndirectBody() (interface{}, error) {
+	obj, err := p.parseObject()
+	if err != nil {
+		return nil, err
+	}
+
+	p.skipSpace()
+	if dict, ok := obj.(pdfDict); ok && p.hasPrefix("stream") {
+		p.pos += len("stream")
+		if p.hasPrefix("\r\n") {
+			p.pos += 2
+		} else if p.hasPrefix("\n") || p.hasPrefix("\r") {
+			p.pos++
+		}
+
+		start := p.pos
+		end := -1
+		if length, ok := pdfInt(p.doc.resolve(dict["Length"])); ok && length >= 0 && start+length <= len(p.data) {
+			after := &pdfParser{data: p.data, pos: start + length}
+			after.skipSpace()
+			if after.hasPrefix("endstream") {
+				end = start + length
+				p.pos = after.pos + len("endstream")
+			}
+		}
+		if end < 0 {
+			// /Length ไม่ถูกต้อง ให้หา endstream แทน
+			idx := bytes.Index(p.data[start:], []byte("endstream"))
+			if idx < 0 {
+				return nil, errors.New("unterminated stream")
+			}
+			end = start + idx
+			p.pos = end + len("endstream")
+			for end > start && (p.data[end-1] == '\n' || p.data[end-1] == '\r') {
+				end--
+			}
+		}
+		obj = &pdfStream{Dict: dict, Raw: p.data[start:end]}
+		p.skipSpace()
+	}
+
+	if p.hasPrefix("endobj") {
+		p.pos += len("endobj")
+	}
+	return obj, nil
+}
+
+// parseObject - parse object ตรงตำแหน่งปัจจุบัน
+func (p *pdfParser) parseObject() (interface{}, error) {
+	p.skipSpace()
+	if p.pos >= len(p.data) {
+		return nil, io.ErrUnexpectedEOF
+	}
+
+	switch b := p.data[p.pos]; {
+	case b == '/':
+		p.pos++
+		return pdfName(decodeName(p.readToken())), nil
+	case b == '(':
+		return p.parseLiteralString()
+	case b == '<' && p.hasPrefix("<<"):
+		return p.parseDict()
+	case b == '<':
+		p.pos++
+		end := bytes.IndexByte(p.data[p.pos:], '>')
+		if end < 0 {
+			return nil, errors.New("unterminated hex string")
+		}
+		raw := p.data[p.pos : p.pos+end]
+		p.pos += end + 1
+		s, err := decodeASCIIHex(raw)
+		return pdfString(s), err
+	case b == '[':
+		return p.parseArray()
+	case b == '+' || b == '-' || b == '.' || (b >= '0' && b <= '9'):
+		return p.parseNumberOrRef()
+	default:
+		token := p.readToken()
+		switch token {
+		case "true":
+			return true, nil
+		case "false":
+			return false, nil
+		case "null":
+			return nil, nil
+		case "":
+			p.pos++
+			return nil, fmt.Errorf("unexpected character %q", b)
+		}
+		return nil, fmt.Errorf("unexpected keyword %q", token)
+	}
+}
+
+func decodeName(raw string) string {
+	if !bytes.ContainsRune([]byte(raw), '#') {
+		return raw
+	}
+	out := []byte{}
+	for i := 0; i < len(raw); i++ {
+		if raw[i] == '#' && i+2 < len(raw) {
+			if v, err := strconv.ParseUint(raw[i+1:i+3], 16, 8); err == nil {
+				out = append(out, byte(v))
+				i += 2
+				continue
+			}
+		}
+		out = append(out, raw[i])
+	}
+	return string(out)
+}
+
+func (p *pdfParser) parseNumberOrRef() (interface{}, error) {
+	token := p.readToken()
+	if i, err := strconv.ParseInt(token, 10, 64); err == nil {
+		// ตรวจสอบรูปแบบ "num gen R"
+		save := p.pos
+		p.skipSpace()
+		genToken := p.readToken()
+		if gen, err := strconv.Atoi(genToken); err == nil && genToken != "" {
+			p.skipSpace()
+			if p.hasPrefix("R") && (p.pos+1 >= len(p.data) || !isPDFRegular(p.data[p.pos+1])) {
+				p.pos++
+				return pdfRef{Num: int(i), Gen: gen}, nil
+			}
+		}
+		p.pos = save
+		return i, nil
+	}
+	f, err := strconv.ParseFloat(token, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid number %q", token)
+	}
+	return f, nil
+}
+
+func (p *pdfParser) parseLiteralString() (interface{}, error) {
+	p.pos++ // '('
+	out := []byte{}
+	depth := 1
+	for p.pos < len(p.data) {
+		b := p.data[p.pos]
+		p.pos++
+		switch b {
+		case '(':
+			depth++
+		case ')':
+			depth--
+			if depth == 0 {
+				return pdfString(out), nil
+			}
+		case '\\':
+			if p.pos >= len(p.data) {
+				break
+			}
+			e := p.data[p.pos]
+			p.pos++
+			switch e {
+			case 'n':
+				out = append(out, '\n')
+			case 'r':
+				out = append(out, '\r')
+			case 't':
+				out = append(out, '\t')
+			case 'b':
+				out = append(out, '\b')
+			case 'f':
+				out = append(out, '\f')
+			case '\r':
+				if p.pos < len(p.data) && p.data[p.pos] == '\n' {
+					p.pos++
+				}
+			case '\n':
+			default:
+				if e >= '0' && e <= '7' {
+					v := int(e - '0')
+					for k := 0; k < 2 && p.pos < len(p.data) && p.data[p.pos] >= '0' && p.data[p.pos] <= '7'; k++ {
+						v = v*8 + int(p.data[p.pos]-'0')
+						p.pos++
+					}
+					out = append(out, byte(v))
+				} else {
+					out = append(out, e)
+				}
+			}
+			continue
+		}
+		out = append(out, b)
+	}
+	return nil, errors.New("unterminated string")
+}
+
+func (p *pdfParser) parseArray() (interface{}, error) {
+	defer func() { p.depth-- }()
+	if err := p.enter(); err != nil {
+		return nil, err
+	}
+	p.pos++ // '['
+	arr := pdfArray{}
+	for {
+		p.skipSpace()
+		if p.pos >= len(p.data) {
+			return nil, errors.New("unterminated array")
+		}
+		if p.data[p.pos] == ']' {
+			p.pos++
+			return arr, nil
+		}
+		item, err := p.parseObject()
+		if err != nil {
+			return nil, err
+		}
+		arr = append(arr, item)
+	}
+}
+
+func (p *pdfParser) parseDict() (interface{}, error) {
+	defer func() { p.depth-- }()
+	if err := p.enter(); err != nil {
+		return nil, err
+	}
+	p.pos += 2 // '<<'
+	dict := pdfDict{}
+	for {
+		p.skipSpace()
+		if p.pos >= len(p.data) {
+			return nil, errors.New("unterminated dictionary")
+		}
+		if p.hasPrefix(">>") {
+			p.pos += 2
+			return dict, nil
+		}
+		key, err := p.parseObject()
+		if err != nil {
+			return nil, err
+		}
+		name, ok := key.(pdfName)
+		if !ok {
+			return nil, errors.New("dictionary key is not a name")
+		}
+		value, err := p.parseObject()
+		if err != nil {
+			return nil, err
+		}
+		dict[name] = value
+	}
+}
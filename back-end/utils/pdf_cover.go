@@ -0,0 +1,123 @@
+package utils
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"strings"
+
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/basicfont"
+	"golang.org/x/image/math/fixed"
+)
+
+// ขนาดของรูปปกที่สร้างแทนรูปตัวอย่าง (วาดที่ coverWidth x coverHeight แล้วขยาย coverScale เท่า ให้ฟอนต์ 7x13 อ่านได้)
+const (
+	coverWidth     = 200
+	coverHeight    = 283 // สัดส่วน A4
+	coverScale     = 3
+	coverMargin    = 12
+	coverMaxLines  = 8
+	coverLineSpace = 16
+)
+
+var (
+	coverBackground = color.RGBA{0xfa, 0xfa, 0xf7, 0xff}
+	coverBand       = color.RGBA{0x2f, 0x4f, 0x7f, 0xff}
+	coverText       = color.RGBA{0x22, 0x22, 0x22, 0xff}
+	coverMuted      = color.RGBA{0x77, 0x77, 0x77, 0xff}
+)
+
+// CoverPreview - สร้างรูปปก (ชื่อ note และจำนวนหน้า) ใช้แทนรูปตัวอย่างเมื่อดึงรูปจากหน้า PDF ไม่ได้
+// ฟอนต์ basicfont มีเฉพาะอักษรละติน ตัวอักษรอื่น (เช่นภาษาไทย) ในชื่อจะถูกข้าม
+func CoverPreview(title string, pageCount int) image.Image {
+	img := image.NewRGBA(image.Rect(0, 0, coverWidth, coverHeight))
+	draw.Draw(img, img.Bounds(), image.NewUniform(coverBackground), image.Point{}, draw.Src)
+	draw.Draw(img, image.Rect(0, 0, coverWidth, 8), image.NewUniform(coverBand), image.Point{}, draw.Src)
+	draw.Draw(img, image.Rect(0, coverHeight-40, coverWidth, coverHeight), image.NewUniform(coverBand), image.Point{}, draw.Src)
+
+	y := 40
+	for _, line := range coverTitleLines(title) {
+		drawCoverText(img, line, coverMargin, y, coverText)
+		y += coverLineSpace
+	}
+
+	pages := fmt.Sprintf("%d PAGES", pageCount)
+	if pageCount == 1 {
+		pages = "1 PAGE"
+	}
+	drawCoverText(img, "PDF", coverMargin, coverHeight-60, coverMuted)
+	drawCoverText(img, pages, coverMargin, coverHeight-16, color.White)
+
+	return scaleNearest(img, coverScale)
+}
+
+// coverTitleLines - ตัดชื่อเป็นบรรทัดตามความกว้างของรูป (เฉพาะตัวอักษรที่ฟอนต์มี)
+func coverTitleLines(title string) []string {
+	face := basicfont.Face7x13
+	title = strings.Map(func(r rune) rune {
+		if _, ok := face.GlyphAdvance(r); !ok {
+			return ' '
+		}
+		return r
+	}, title)
+
+	maxChars := (coverWidth - 2*coverMargin) / face.Advance
+	lines := []string{}
+	line := []rune{}
+	for _, field := range strings.Fields(title) {
+		word := []rune(field)
+		for len(word) > maxChars {
+			if len(line) > 0 {
+				lines = append(lines, string(line))
+				line = nil
+			}
+			lines = append(lines, string(word[:maxChars]))
+			word = word[maxChars:]
+		}
+		switch {
+		case len(line) == 0:
+			line = word
+		case len(line)+1+len(word) <= maxChars:
+			line = append(append(line, ' '), word...)
+		default:
+			lines = append(lines, string(line))
+			line = word
+		}
+	}
+	if len(line) > 0 {
+		lines = append(lines, string(line))
+	}
+	if len(lines) > coverMaxLines {
+		lines = lines[:coverMaxLines]
+		last := []rune(lines[coverMaxLines-1])
+		if len(last) > maxChars-3 {
+			last = last[:maxChars-3]
+		}
+		lines[coverMaxLines-1] = string(last) + "..."
+	}
+	return lines
+}
+
+func drawCoverText(img draw.Image, text string, x, y int, c color.Color) {
+	d := font.Drawer{
+		Dst:  img,
+		Src:  image.NewUniform(c),
+		Face: basicfont.Face7x13,
+		Dot:  fixed.P(x, y),
+	}
+	d.DrawString(text)
+}
+
+// scaleNearest - ขยายรูป factor เท่าแบบ nearest neighbor (ตัวอักษรคมไม่เบลอ)
+func scaleNearest(src *image.RGBA, factor int) *image.RGBA {
+	b := src.Bounds()
+	dst := image.NewRGBA(image.Rect(0, 0, b.Dx()*factor, b.Dy()*factor))
+	for y := 0; y < dst.Bounds().Dy(); y++ {
+		for x := 0; x < dst.Bounds().Dx(); x++ {
+			dst.SetRGBA(x, y, src.RGBAAt(b.Min.X+x/factor, b.Min.Y+y/factor))
+		}
+	}
+	return dst
+}
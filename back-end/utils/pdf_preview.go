@@ -0,0 +1,266 @@
+package utils
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"image/jpeg"
+)
+
+// ขีดจำกัดของรูปภาพที่ดึงออกมาจาก PDF
+const (
+	pdfMaxImagePixels  = 40_000_000
+	pdfMaxFormDepth    = 3
+	pdfMinPreviewPixel = 64
+)
+
+// PDFPagePreview - รูปตัวอย่างของหน้าใน PDF
+type PDFPagePreview struct {
+	Page     int // เลขหน้า (เริ่มจาก 1)
+	Image    image.Image
+	Fallback bool // เป็นรูปปกที่สร้างขึ้น (CoverPreview) ไม่ใช่รูปจากหน้าจริง
+}
+
+// Previews - รูปตัวอย่างของ maxPages หน้าแรก (PagePreviews)
+// ถ้าไม่มีหน้าไหนมีรูปฝังอยู่ (เช่นเอกสารที่พิมพ์ด้วยข้อความล้วน) คืนรูปปกจาก title และจำนวนหน้าแทนหนึ่งรูป
+func (d *PDFDocument) Previews(maxPages int, title string) []PDFPagePreview {
+	if maxPages <= 0 {
+		return []PDFPagePreview{}
+	}
+	previews := d.PagePreviews(maxPages)
+	if len(previews) == 0 {
+		previews = append(previews, PDFPagePreview{Page: 1, Image: CoverPreview(title, d.PageCount()), Fallback: true})
+	}
+	return previews
+}
+
+// PagePreviews - สร้างรูปตัวอย่างของ maxPages หน้าแรกด้วย Go ล้วน
+// ใช้รูปภาพที่ใหญ่ที่สุดที่ฝังอยู่ในแต่ละหน้า (เช่นไฟล์ที่สแกนมา) ไม่ได้ render ข้อความหรือกราฟิกเวกเตอร์
+// หน้าที่ไม่มีรูปภาพจึงไม่มีรูปตัวอย่าง (Previews ใช้รูปปกแทนเมื่อไม่มีเลยสักหน้า)
+func (d *PDFDocument) PagePreviews(maxPages int) []PDFPagePreview {
+	previews := []PDFPagePreview{}
+	for i, page := range d.pages {
+		if i >= maxPages {
+			break
+		}
+		img, err := d.largestPageImage(page.resources, 0)
+		if err != nil || img == nil {
+			continue
+		}
+		previews = append(previews, PDFPagePreview{Page: i + 1, Image: img})
+	}
+	return previews
+}
+
+// largestPageImage - หารูปที่ใหญ่ที่สุดใน /XObject ของหน้า (รวมถึงใน Form XObject)
+func (d *PDFDocument) largestPageImage(resources pdfDict, depth int) (image.Image, error) {
+	if resources == nil || depth > pdfMaxFormDepth {
+		return nil, nil
+	}
+	xobjects, ok := d.resolve(resources["XObject"]).(pdfDict)
+	if !ok {
+		return nil, nil
+	}
+
+	var best *pdfStream
+	bestArea := 0
+	var bestNested image.Image
+
+	for _, ref := range xobjects {
+		stream, ok := d.resolve(ref).(*pdfStream)
+		if !ok {
+			continue
+		}
+		switch d.resolve(stream.Dict["Subtype"]) {
+		case pdfName("Image"):
+			w, _ := pdfInt(d.resolve(stream.Dict["Width"]))
+			h, _ := pdfInt(d.resolve(stream.Dict["Height"]))
+			if w < pdfMinPreviewPixel || h < pdfMinPreviewPixel || !pdfImageSizeOK(w, h) {
+				continue
+			}
+			if w*h > bestArea {
+				best, bestArea, bestNested = stream, w*h, nil
+			}
+		case pdfName("Form"):
+			formResources, _ := d.resolve(stream.Dict["Resources"]).(pdfDict)
+			nested, err := d.largestPageImage(formResources, depth+1)
+			if err != nil || nested == nil {
+				continue
+			}
+			if area := nested.Bounds().Dx() * nested.Bounds().Dy(); area > bestArea {
+				best, bestArea, bestNested = nil, area, nested
+			}
+		}
+	}
+
+	if bestNested != nil {
+		return bestNested, nil
+	}
+	if best == nil {
+		return nil, nil
+	}
+	return d.decodeImage(best)
+}
+
+// pdfImageSizeOK - ขนาดรูปอยู่ในช่วงที่ยอมให้ถอดได้ (ตรวจทีละด้านก่อนคูณเพื่อไม่ให้ overflow)
+func pdfImageSizeOK(width, height int) bool {
+	return width > 0 && height > 0 && width <= pdfMaxImagePixels && height <= pdfMaxImagePixels &&
+		width*height <= pdfMaxImagePixels
+}
+
+// decodeImage - แปลง image XObject เป็น image.Image
+func (d *PDFDocument) decodeImage(s *pdfStream) (image.Image, error) {
+	data, filter, err := d.decodeStream(s)
+	if err != nil {
+		return nil, err
+	}
+
+	switch filter {
+	case "DCTDecode", "DCT":
+		// ขนาดจริงอยู่ใน header ของ JPEG ซึ่งอาจไม่ตรงกับ /Width /Height
+		cfg, err := jpeg.DecodeConfig(bytes.NewReader(data))
+		if err != nil {
+			return nil, err
+		}
+		if !pdfImageSizeOK(cfg.Width, cfg.Height) {
+			return nil, fmt.Errorf("%w: image is too large", ErrPDFUnsupported)
+		}
+		return jpeg.Decode(bytes.NewReader(data))
+	case "":
+	default:
+		return nil, fmt.Errorf("%w: image filter %s", ErrPDFUnsupported, filter)
+	}
+
+	width, _ := pdfInt(d.resolve(s.Dict["Width"]))
+	height, _ := pdfInt(d.resolve(s.Dict["Height"]))
+	if !pdfImageSizeOK(width, height) {
+		return nil, fmt.Errorf("%w: image size %dx%d", ErrPDFMalformed, width, height)
+	}
+	bpc := pdfIntDefault(d.resolve(s.Dict["BitsPerComponent"]), 8)
+	if imageMask, _ := d.resolve(s.Dict["ImageMask"]).(bool); imageMask {
+		bpc = 1
+	}
+
+	components, palette, err := d.colorSpace(s.Dict["ColorSpace"], 0)
+	if err != nil {
+		return nil, err
+	}
+	if bpc != 8 && !(bpc == 1 && components == 1 && palette == nil) {
+		return nil, fmt.Errorf("%w: %d bits per component", ErrPDFUnsupported, bpc)
+	}
+
+	rowLen := (width*components*bpc + 7) / 8
+	if len(data) < rowLen*height {
+		return nil, fmt.Errorf("%w: image data is truncated", ErrPDFMalformed)
+	}
+
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		row := data[y*rowLen : (y+1)*rowLen]
+		for x := 0; x < width; x++ {
+			var c color.RGBA
+			switch {
+			case bpc == 1:
+				v := uint8(0)
+				if row[x/8]&(0x80>>(x%8)) != 0 {
+					v = 255
+				}
+				c = color.RGBA{v, v, v, 255}
+			case palette != nil:
+				idx := int(row[x])
+				if idx < len(palette) {
+					c = palette[idx]
+				}
+			case components == 1:
+				v := row[x]
+				c = color.RGBA{v, v, v, 255}
+			case components == 3:
+				c = color.RGBA{row[x*3], row[x*3+1], row[x*3+2], 255}
+			case components == 4:
+				r, g, b := color.CMYKToRGB(row[x*4], row[x*4+1], row[x*4+2], row[x*4+3])
+				c = color.RGBA{r, g, b, 255}
+			}
+			img.SetRGBA(x, y, c)
+		}
+	}
+	return img, nil
+}
+
+// colorSpace - คืนจำนวน component ต่อ pixel และ palette (กรณี /Indexed)
+// depth กันไฟล์ที่อ้าง color space วนกลับมาที่ตัวเอง
+func (d *PDFDocument) colorSpace(obj interface{}, depth int) (int, []color.RGBA, error) {
+	if depth > pdfMaxFormDepth {
+		return 0, nil, fmt.Errorf("%w: color space is nested too deeply", ErrPDFMalformed)
+	}
+	switch cs := d.resolve(obj).(type) {
+	case nil:
+		return 1, nil, nil
+	case pdfName:
+		switch cs {
+		case "DeviceGray", "G", "CalGray":
+			return 1, nil, nil
+		case "DeviceRGB", "RGB", "CalRGB":
+			return 3, nil, nil
+		case "DeviceCMYK", "CMYK":
+			return 4, nil, nil
+		}
+	case pdfArray:
+		if len(cs) == 0 {
+			break
+		}
+		family, _ := d.resolve(cs[0]).(pdfName)
+		switch family {
+		case "ICCBased":
+			if len(cs) > 1 {
+				if profile, ok := d.resolve(cs[1]).(*pdfStream); ok {
+					if n, ok := pdfInt(d.resolve(profile.Dict["N"])); ok && (n == 1 || n == 3 || n == 4) {
+						return n, nil, nil
+					}
+				}
+			}
+		case "CalGray", "CalRGB":
+			return d.colorSpace(cs[0], depth+1)
+		case "Indexed", "I":
+			if len(cs) < 4 {
+				break
+			}
+			base, _, err := d.colorSpace(cs[1], depth+1)
+			if err != nil {
+				return 0, nil, err
+			}
+			lookup, err := d.lookupBytes(cs[3])
+			if err != nil {
+				return 0, nil, err
+			}
+			palette := []color.RGBA{}
+			for i := 0; i+base <= len(lookup); i += base {
+				switch base {
+				case 1:
+					palette = append(palette, color.RGBA{lookup[i], lookup[i], lookup[i], 255})
+				case 3:
+					palette = append(palette, color.RGBA{lookup[i], lookup[i+1], lookup[i+2], 255})
+				case 4:
+					r, g, b := color.CMYKToRGB(lookup[i], lookup[i+1], lookup[i+2], lookup[i+3])
+					palette = append(palette, color.RGBA{r, g, b, 255})
+				}
+			}
+			return 1, palette, nil
+		}
+	}
+	return 0, nil, fmt.Errorf("%w: color space", ErrPDFUnsupported)
+}
+
+func (d *PDFDocument) lookupBytes(obj interface{}) ([]byte, error) {
+	switch v := d.resolve(obj).(type) {
+	case pdfString:
+		return v, nil
+	case *pdfStream:
+		data, filter, err := d.decodeStream(v)
+		if err == nil && filter != "" {
+			err = fmt.Errorf("%w: palette filter %s", ErrPDFUnsupported, filter)
+		}
+		return data, err
+	}
+	return nil, fmt.Errorf("%w: palette", ErrPDFMalformed)
+}
@@ -0,0 +1,303 @@
+package utils
+
+import (
+	"bytes"
+	"compress/zlib"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// buildPDF - สร้างไฟล์ PDF จาก body ของ object (object แรกคือ 1 0 obj) พร้อม xref และ trailer ที่ถูกต้อง
+func buildPDF(objects ...string) []byte {
+	var buf bytes.Buffer
+	buf.WriteString("%PDF-1.4\n")
+	offsets := make([]int, len(objects))
+	for i, obj := range objects {
+		offsets[i] = buf.Len()
+		fmt.Fprintf(&buf, "%d 0 obj\n%s\nendobj\n", i+1, obj)
+	}
+	xref := buf.Len()
+	fmt.Fprintf(&buf, "xref\n0 %d\n0000000000 65535 f \n", len(objects)+1)
+	for _, off := range offsets {
+		fmt.Fprintf(&buf, "%010d 00000 n \n", off)
+	}
+	fmt.Fprintf(&buf, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(objects)+1, xref)
+	return buf.Bytes()
+}
+
+// minimalPDF - เอกสาร 1 หน้า ตามด้วย object เพิ่มเติม (เริ่มที่ 4 0 obj)
+func minimalPDF(extra ...string) []byte {
+	objects := []string{
+		"<< /Type /Catalog /Pages 2 0 R >>",
+		"<< /Type /Pages /Kids [3 0 R] /Count 1 >>",
+		"<< /Type /Page /Parent 2 0 R /MediaBox [0 0 612 792] >>",
+	}
+	return buildPDF(append(objects, extra...)...)
+}
+
+func deflate(data []byte) []byte {
+	var buf bytes.Buffer
+	w := zlib.NewWriter(&buf)
+	w.Write(data)
+	w.Close()
+	return buf.Bytes()
+}
+
+// streamObject - stream object พร้อม /Length ที่ถูกต้อง (dict ไม่รวม << >>)
+func streamObject(dict string, data []byte) string {
+	return fmt.Sprintf("<< %s /Length %d >>\nstream\n%s\nendstream", dict, len(data), data)
+}
+
+func TestParsePDFMinimal(t *testing.T) {
+	doc, err := ParsePDF(minimalPDF())
+	if err != nil {
+		t.Fatalf("ParsePDF: %v", err)
+	}
+	if doc.PageCount() != 1 {
+		t.Errorf("PageCount = %d, want 1", doc.PageCount())
+	}
+	if doc.Version != "1.4" {
+		t.Errorf("Version = %q, want 1.4", doc.Version)
+	}
+}
+
+func TestParsePDFRejectsMalformed(t *testing.T) {
+	valid := minimalPDF()
+	xref := bytes.Index(valid, []byte("xref"))
+
+	tests := []struct {
+		name string
+		data []byte
+		want error
+	}{
+		{"empty", nil, ErrNotPDF},
+		{"not a pdf", []byte("hello world"), ErrNotPDF},
+		{"header only", []byte("%PDF-1.4\n%%EOF\n"), ErrPDFMalformed},
+		{"truncated xref", valid[:xref+20], ErrPDFMalformed},
+		{"truncated object", append(append([]byte{}, valid[:30]...), "\n%%EOF\n"...), ErrPDFMalformed},
+		{"no catalog", buildPDF("<< /Type /Pages /Kids [] /Count 0 >>"), ErrPDFMalformed},
+		{"no pages", buildPDF("<< /Type /Catalog /Pages 2 0 R >>", "<< /Type /Pages /Kids [] /Count 0 >>"), ErrPDFMalformed},
+		{"cyclic page tree", buildPDF("<< /Type /Catalog /Pages 2 0 R >>", "<< /Type /Pages /Kids [2 0 R] /Count 1 >>"), ErrPDFMalformed},
+		{"encrypted", bytes.Replace(valid, []byte("/Root 1 0 R"), []byte("/Root 1 0 R /Encrypt << >>"), 1), ErrPDFEncrypted},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := ParsePDF(tt.data); !errors.Is(err, tt.want) {
+				t.Errorf("err = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestParsePDFIgnoresBrokenXref(t *testing.T) {
+	// object ถูกอ่านจากการไล่ทั้งไฟล์ xref ที่ขาดหายจึงไม่ทำให้ parse ไม่ได้
+	valid := minimalPDF()
+	xref := bytes.Index(valid, []byte("xref"))
+	data := append(append([]byte{}, valid[:xref+20]...), "\n%%EOF\n"...)
+	doc, err := ParsePDF(data)
+	if err != nil {
+		t.Fatalf("ParsePDF: %v", err)
+	}
+	if doc.PageCount() != 1 {
+		t.Errorf("PageCount = %d, want 1", doc.PageCount())
+	}
+}
+
+func TestParsePDFRejectsDeepNesting(t *testing.T) {
+	for _, open := range []string{"[", "<< /A "} {
+		deep := strings.Repeat(open, 1<<20)
+		_, err := ParsePDF(minimalPDF(deep))
+		if !errors.Is(err, ErrPDFMalformed) {
+			t.Errorf("%q nesting: err = %v, want %v", open, err, ErrPDFMalformed)
+		}
+	}
+
+	// ซ้อนกันไม่เกินขีดจำกัดยัง parse ได้
+	nested := strings.Repeat("[", pdfMaxNestingDepth) + strings.Repeat("]", pdfMaxNestingDepth)
+	if _, err := ParsePDF(minimalPDF(nested)); err != nil {
+		t.Errorf("%d levels of nesting: %v", pdfMaxNestingDepth, err)
+	}
+}
+
+func TestParsePDFDeepNestingInContentStream(t *testing.T) {
+	content := []byte(strings.Repeat("[", 1<<20))
+	data := buildPDF(
+		"<< /Type /Catalog /Pages 2 0 R >>",
+		"<< /Type /Pages /Kids [3 0 R] /Count 1 >>",
+		"<< /Type /Page /Parent 2 0 R /Contents 4 0 R >>",
+		streamObject("/Filter /FlateDecode", deflate(content)),
+	)
+	doc, err := ParsePDF(data)
+	if err != nil {
+		t.Fatalf("ParsePDF: %v", err)
+	}
+	doc.Text()
+}
+
+func TestApplyPredictorRejectsHostileParams(t *testing.T) {
+	data := bytes.Repeat([]byte{0, 1, 2, 3}, 16)
+	tests := []struct {
+		name  string
+		param pdfDict
+	}{
+		{"huge columns", pdfDict{"Predictor": int64(12), "Columns": int64(100000000000)}},
+		{"overflowing columns", pdfDict{"Predictor": int64(12), "Columns": int64(1 << 62), "Colors": int64(4), "BitsPerComponent": int64(16)}},
+		{"negative columns", pdfDict{"Predictor": int64(12), "Columns": int64(-5)}},
+		{"row longer than data", pdfDict{"Predictor": int64(12), "Columns": int64(100)}},
+		{"huge colors", pdfDict{"Predictor": int64(12), "Colors": int64(1 << 40)}},
+		{"zero colors", pdfDict{"Predictor": int64(12), "Colors": int64(0)}},
+		{"odd bits per component", pdfDict{"Predictor": int64(12), "BitsPerComponent": int64(7)}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := applyPredictor(data, tt.param); !errors.Is(err, ErrPDFMalformed) {
+				t.Errorf("err = %v, want %v", err, ErrPDFMalformed)
+			}
+		})
+	}
+}
+
+func TestApplyPredictorUp(t *testing.T) {
+	// 2 แถว แถวละ 3 byte ใช้ filter Up (2)
+	data := []byte{0, 1, 2, 3, 2, 1, 1, 1}
+	out, err := applyPredictor(data, pdfDict{"Predictor": int64(12), "Columns": int64(3)})
+	if err != nil {
+		t.Fatalf("applyPredictor: %v", err)
+	}
+	if want := []byte{1, 2, 3, 2, 3, 4}; !bytes.Equal(out, want) {
+		t.Errorf("out = %v, want %v", out, want)
+	}
+}
+
+func TestParsePDFHugePredictorInObjectStream(t *testing.T) {
+	objstm := streamObject(
+		"/Type /ObjStm /N 1 /First 4 /Filter /FlateDecode /DecodeParms << /Predictor 12 /Columns 100000000000 >>",
+		deflate([]byte("5 0 << >>")),
+	)
+	if _, err := ParsePDF(minimalPDF(objstm)); err != nil {
+		t.Fatalf("ParsePDF: %v", err)
+	}
+}
+
+func TestDecodeStreamBudget(t *testing.T) {
+	raw := deflate(make([]byte, 1024))
+	doc := &PDFDocument{objects: map[int]interface{}{}, decoded: pdfMaxDecodedTotal - 100}
+	s := &pdfStream{Dict: pdfDict{"Filter": pdfName("FlateDecode")}, Raw: raw}
+	if _, _, err := doc.decodeStream(s); !errors.Is(err, errPDFStreamTooBig) {
+		t.Errorf("err = %v, want %v", err, errPDFStreamTooBig)
+	}
+
+	// งบใช้รวมกันทั้งเอกสาร ไม่ใช่ต่อ stream
+	doc = &PDFDocument{objects: map[int]interface{}{}, decoded: pdfMaxDecodedTotal - 1500}
+	if _, _, err := doc.decodeStream(s); err != nil {
+		t.Fatalf("first stream: %v", err)
+	}
+	if _, _, err := doc.decodeStream(s); !errors.Is(err, errPDFStreamTooBig) {
+		t.Errorf("second stream: err = %v, want %v", err, errPDFStreamTooBig)
+	}
+}
+
+func TestDecodeImageRejectsHugeSizes(t *testing.T) {
+	doc := &PDFDocument{objects: map[int]interface{}{}}
+	sizes := [][2]int64{
+		{1 << 40, 1 << 40},
+		{1 << 62, 2},
+		{100000, 100000},
+		{-1, 100},
+		{0, 0},
+	}
+	for _, size := range sizes {
+		s := &pdfStream{
+			Dict: pdfDict{"Width": size[0], "Height": size[1], "ColorSpace": pdfName("DeviceRGB")},
+			Raw:  make([]byte, 64),
+		}
+		if _, err := doc.decodeImage(s); err == nil {
+			t.Errorf("%dx%d: expected an error", size[0], size[1])
+		}
+	}
+}
+
+func TestDecodeImageCyclicColorSpace(t *testing.T) {
+	doc := &PDFDocument{objects: map[int]interface{}{}}
+	doc.objects[5] = pdfArray{pdfName("Indexed"), pdfRef{Num: 5}, int64(1), pdfString{0, 0, 0}}
+	s := &pdfStream{
+		Dict: pdfDict{"Width": int64(1), "Height": int64(1), "ColorSpace": pdfRef{Num: 5}},
+		Raw:  []byte{0},
+	}
+	if _, err := doc.decodeImage(s); !errors.Is(err, ErrPDFMalformed) {
+		t.Errorf("err = %v, want %v", err, ErrPDFMalformed)
+	}
+}
+
+func TestPreviewsFallbackCover(t *testing.T) {
+	doc, err := ParsePDF(minimalPDF())
+	if err != nil {
+		t.Fatalf("ParsePDF: %v", err)
+	}
+	if got := doc.PagePreviews(3); len(got) != 0 {
+		t.Fatalf("PagePreviews = %d previews, want 0", len(got))
+	}
+
+	previews := doc.Previews(3, "Database Final")
+	if len(previews) != 1 || !previews[0].Fallback || previews[0].Page != 1 {
+		t.Fatalf("Previews = %+v, want one fallback cover for page 1", previews)
+	}
+	if b := previews[0].Image.Bounds(); b.Dx() != coverWidth*coverScale || b.Dy() != coverHeight*coverScale {
+		t.Errorf("cover size = %v", b)
+	}
+
+	// ปิดการสร้างรูปตัวอย่าง (PDF_PREVIEW_PAGES=0) ไม่สร้างรูปปกด้วย
+	if got := doc.Previews(0, "Database Final"); len(got) != 0 {
+		t.Errorf("Previews(0) = %d previews, want 0", len(got))
+	}
+}
+
+func TestCoverTitleLines(t *testing.T) {
+	tests := []struct {
+		title string
+		want  []string
+	}{
+		{"Database Final", []string{"Database Final"}},
+		{"สรุป Database ปลายภาค", []string{"Database"}},
+		{"สรุปวิชาฐานข้อมูล", []string{}},
+		{strings.Repeat("x", 30), []string{strings.Repeat("x", 25), "xxxxx"}},
+	}
+	for _, tt := range tests {
+		got := coverTitleLines(tt.title)
+		if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+			t.Errorf("coverTitleLines(%q) = %q, want %q", tt.title, got, tt.want)
+		}
+	}
+
+	long := coverTitleLines(strings.Repeat("word ", 100))
+	if len(long) != coverMaxLines || !strings.HasSuffix(long[len(long)-1], "...") {
+		t.Errorf("long title = %q, want %d lines ending with ...", long, coverMaxLines)
+	}
+}
+
+func FuzzParsePDF(f *testing.F) {
+	f.Add(minimalPDF())
+	f.Add(minimalPDF(streamObject("/Filter /FlateDecode /DecodeParms << /Predictor 12 /Columns 4 >>",
+		deflate([]byte{0, 1, 2, 3, 4}))))
+	f.Add(minimalPDF(streamObject("/Type /ObjStm /N 1 /First 4 /Filter /FlateDecode", deflate([]byte("5 0 << /A [1 2] >>")))))
+	f.Add(buildPDF(
+		"<< /Type /Catalog /Pages 2 0 R >>",
+		"<< /Type /Pages /Kids [3 0 R] /Count 1 >>",
+		"<< /Type /Page /Parent 2 0 R /Contents 4 0 R /Resources << /XObject << /Im0 5 0 R >> >> >>",
+		streamObject("", []byte("BT (hello) Tj ET")),
+		streamObject("/Subtype /Image /Width 64 /Height 64 /ColorSpace /DeviceGray /BitsPerComponent 8", make([]byte, 64*64)),
+	))
+	f.Add([]byte("%PDF-1.4\n1 0 obj [[[[[[\n%%EOF"))
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		doc, err := ParsePDF(data)
+		if err != nil {
+			return
+		}
+		doc.PageCount()
+		doc.Text()
+		doc.PagePreviews(2)
+	})
+}
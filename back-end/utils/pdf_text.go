@@ -0,0 +1,324 @@
+package utils
+
+import (
+	"bytes"
+	"strings"
+	"unicode/utf16"
+)
+
+// ขีดจำกัดของข้อความที่ดึงออกมาจาก PDF
+const pdfMaxTextBytes = 4 * 1024 * 1024
+
+// pdfFont - ข้อมูลของ font ที่ใช้แปลง string ใน content stream เป็นข้อความ
+type pdfFont struct {
+	composite bool     // Type0 (ใช้ code หลาย byte แปลงไม่ได้ถ้าไม่มี ToUnicode)
+	toUnicode *pdfCMap // nil = ไม่มี /ToUnicode
+}
+
+// pdfCMap - ตาราง code -> ข้อความจาก /ToUnicode
+type pdfCMap struct {
+	codeLen int // จำนวน byte ต่อ code (จาก codespacerange)
+	chars   map[uint32]string
+}
+
+// Text - ดึงข้อความจาก content stream ของทุกหน้า (ใช้ /ToUnicode ของ font ถ้ามี)
+// ได้ผลแบบประมาณ เหมาะกับการเทียบเนื้อหา ไม่ได้จัดลำดับบรรทัดตามตำแหน่งจริง
+// หน้าที่เป็นรูปสแกนล้วนจะไม่มีข้อความ
+func (d *PDFDocument) Text() string {
+	var out strings.Builder
+	for _, page := range d.pages {
+		content := d.pageContent(page.dict)
+		if len(content) == 0 {
+			continue
+		}
+		d.extractText(content, d.pageFonts(page.resources), &out)
+		out.WriteByte('\n')
+		if out.Len() > pdfMaxTextBytes {
+			break
+		}
+	}
+	return out.String()
+}
+
+// pageContent - รวม /Contents ของหน้า (stream เดียวหรือ array ของ stream) ที่ถอด filter แล้ว
+func (d *PDFDocument) pageContent(page pdfDict) []byte {
+	var streams []*pdfStream
+	switch contents := d.resolve(page["Contents"]).(type) {
+	case *pdfStream:
+		streams = append(streams, contents)
+	case pdfArray:
+		for _, item := range contents {
+			if s, ok := d.resolve(item).(*pdfStream); ok {
+				streams = append(streams, s)
+			}
+		}
+	}
+
+	var buf bytes.Buffer
+	for _, s := range streams {
+		data, imageFilter, err := d.decodeStream(s)
+		if err != nil || imageFilter != "" {
+			continue
+		}
+		buf.Write(data)
+		buf.WriteByte('\n')
+	}
+	return buf.Bytes()
+}
+
+// pageFonts - font ใน /Resources /Font ของหน้า
+func (d *PDFDocument) pageFonts(resources pdfDict) map[pdfName]*pdfFont {
+	fonts := map[pdfName]*pdfFont{}
+	if resources == nil {
+		return fonts
+	}
+	dict, ok := d.resolve(resources["Font"]).(pdfDict)
+	if !ok {
+		return fonts
+	}
+	for name, ref := range dict {
+		fontDict, ok := d.resolve(ref).(pdfDict)
+		if !ok {
+			continue
+		}
+		font := &pdfFont{composite: d.resolve(fontDict["Subtype"]) == pdfName("Type0")}
+		if s, ok := d.resolve(fontDict["ToUnicode"]).(*pdfStream); ok {
+			if data, _, err := d.decodeStream(s); err == nil {
+				font.toUnicode = parseToUnicode(data)
+			}
+		}
+		fonts[name] = font
+	}
+	return fonts
+}
+
+// extractText - อ่าน operator ที่แสดงข้อความ (Tj, TJ, ', ") ใน content stream
+func (d *PDFDocument) extractText(content []byte, fonts map[pdfName]*pdfFont, out *strings.Builder) {
+	p := &pdfParser{data: content, doc: d}
+	var operands []interface{}
+	var font *pdfFont
+
+	for {
+		p.skipSpace()
+		if p.pos >= len(p.data) || out.Len() > pdfMaxTextBytes {
+			return
+		}
+
+		if startsPDFObject(p.data[p.pos]) {
+			obj, err := p.parseObject()
+			if err != nil {
+				// ข้าม byte ที่ parse ไม่ได้ แล้วอ่านต่อ
+				p.pos++
+				operands = operands[:0]
+				continue
+			}
+			operands = append(operands, obj)
+			continue
+		}
+
+		op := p.readToken()
+		if op == "" {
+			p.pos++
+			continue
+		}
+		switch op {
+		case "Tf":
+			if len(operands) > 0 {
+				if name, ok := operands[0].(pdfName); ok {
+					font = fonts[name]
+				}
+			}
+		case "Tj":
+			if len(operands) > 0 {
+				writeShownText(operands[len(operands)-1], font, out)
+			}
+		case "'", "\"":
+			out.WriteByte('\n')
+			if len(operands) > 0 {
+				writeShownText(operands[len(operands)-1], font, out)
+			}
+		case "TJ":
+			if len(operands) > 0 {
+				if arr, ok := operands[len(operands)-1].(pdfArray); ok {
+					for _, item := range arr {
+						// ระยะห่างที่มากพอถือเป็นช่องว่างระหว่างคำ
+						if n, ok := item.(int64); ok && n < -200 {
+							out.WriteByte(' ')
+						} else if f, ok := item.(float64); ok && f < -200 {
+							out.WriteByte(' ')
+						} else {
+							writeShownText(item, font, out)
+						}
+					}
+				}
+			}
+		case "Td", "TD", "T*", "Tm":
+			out.WriteByte(' ')
+		case "ET":
+			out.WriteByte('\n')
+		case "BI":
+			if !p.skipInlineImage() {
+				return
+			}
+		}
+		operands = operands[:0]
+	}
+}
+
+// startsPDFObject - byte แรกของ operand (ตัวอื่นเป็น operator)
+func startsPDFObject(b byte) bool {
+	return b == '/' || b == '(' || b == '<' || b == '[' || b == '+' || b == '-' || b == '.' || (b >= '0' && b <= '9')
+}
+
+// skipInlineImage - ข้ามข้อมูลรูปภาพ inline (BI ... ID <binary> EI) คืน false ถ้าไม่เจอ EI
+func (p *pdfParser) skipInlineImage() bool {
+	for p.pos < len(p.data) {
+		idx := bytes.Index(p.data[p.pos:], []byte("EI"))
+		if idx < 0 {
+			p.pos = len(p.data)
+			return false
+		}
+		end := p.pos + idx
+		p.pos = end + 2
+		if (end == 0 || isPDFSpace(p.data[end-1])) && (p.pos >= len(p.data) || !isPDFRegular(p.data[p.pos])) {
+			return true
+		}
+	}
+	return false
+}
+
+// writeShownText - แปลง string ที่แสดงด้วย font ปัจจุบันเป็นข้อความ
+func writeShownText(obj interface{}, font *pdfFont, out *strings.Builder) {
+	s, ok := obj.(pdfString)
+	if !ok {
+		return
+	}
+	if font != nil && font.toUnicode != nil {
+		font.toUnicode.decode(s, out)
+		return
+	}
+	if font != nil && font.composite {
+		return
+	}
+	// font ธรรมดาที่ไม่มี ToUnicode ถือว่าเป็น Latin-1 (ใกล้เคียง StandardEncoding/WinAnsi สำหรับตัวอักษรทั่วไป)
+	for _, c := range s {
+		out.WriteRune(rune(c))
+	}
+}
+
+// decode - แปลง string เป็นข้อความตาม cmap (code ที่ไม่มีในตารางจะถูกข้าม)
+func (m *pdfCMap) decode(s pdfString, out *strings.Builder) {
+	n := m.codeLen
+	if n < 1 || n > 4 {
+		n = 1
+	}
+	for i := 0; i+n <= len(s); i += n {
+		if text, ok := m.chars[bytesToCode(s[i:i+n])]; ok {
+			out.WriteString(text)
+		}
+	}
+}
+
+func bytesToCode(b []byte) uint32 {
+	var code uint32
+	for _, c := range b {
+		code = code<<8 | uint32(c)
+	}
+	return code
+}
+
+// decodeUTF16BE - ข้อความปลายทางใน ToUnicode เป็น UTF-16BE
+func decodeUTF16BE(b []byte) string {
+	units := make([]uint16, 0, len(b)/2)
+	for i := 0; i+1 < len(b); i += 2 {
+		units = append(units, uint16(b[i])<<8|uint16(b[i+1]))
+	}
+	return string(utf16.Decode(units))
+}
+
+// parseToUnicode - parse /ToUnicode CMap (codespacerange, bfchar และ bfrange)
+func parseToUnicode(data []byte) *pdfCMap {
+	m := &pdfCMap{chars: map[uint32]string{}}
+	p := &pdfParser{data: data}
+	var operands []interface{}
+
+	for {
+		p.skipSpace()
+		if p.pos >= len(p.data) {
+			break
+		}
+		if startsPDFObject(p.data[p.pos]) {
+			obj, err := p.parseObject()
+			if err != nil {
+				p.pos++
+				operands = operands[:0]
+				continue
+			}
+			operands = append(operands, obj)
+			continue
+		}
+
+		op := p.readToken()
+		if op == "" {
+			p.pos++
+			continue
+		}
+		switch op {
+		case "endcodespacerange":
+			if len(operands) > 0 {
+				if lo, ok := operands[0].(pdfString); ok {
+					m.codeLen = len(lo)
+				}
+			}
+		case "endbfchar":
+			for i := 0; i+1 < len(operands); i += 2 {
+				src, ok1 := operands[i].(pdfString)
+				dst, ok2 := operands[i+1].(pdfString)
+				if ok1 && ok2 {
+					m.chars[bytesToCode(src)] = decodeUTF16BE(dst)
+				}
+			}
+		case "endbfrange":
+			for i := 0; i+2 < len(operands); i += 3 {
+				lo, ok1 := operands[i].(pdfString)
+				hi, ok2 := operands[i+1].(pdfString)
+				if !ok1 || !ok2 {
+					continue
+				}
+				start, end := bytesToCode(lo), bytesToCode(hi)
+				if end < start || end-start > 0xFFFF {
+					continue
+				}
+				switch dst := operands[i+2].(type) {
+				case pdfString:
+					// code ถัดไปได้ตัวอักษรถัดไป (เพิ่มที่ byte สุดท้าย)
+					base := append([]byte{}, dst...)
+					for code := start; code <= end; code++ {
+						m.chars[code] = decodeUTF16BE(base)
+						if len(base) > 0 {
+							base[len(base)-1]++
+						}
+					}
+				case pdfArray:
+					for j, item := range dst {
+						if s, ok := item.(pdfString); ok && start+uint32(j) <= end {
+							m.chars[start+uint32(j)] = decodeUTF16BE(s)
+						}
+					}
+				}
+			}
+		}
+		operands = operands[:0]
+	}
+
+	if m.codeLen == 0 {
+		m.codeLen = 1
+		for code := range m.chars {
+			if code > 0xFF {
+				m.codeLen = 2
+				break
+			}
+		}
+	}
+	return m
+}
@@ -0,0 +1,59 @@
+package utils
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"sync"
+	"time"
+)
+
+// StreamTicketTTL - อายุของ stream ticket (ต้องเปิด stream ภายในเวลานี้)
+const StreamTicketTTL = 30 * time.Second
+
+// stream ticket ใช้แทน access token ใน query ของ endpoint แบบ stream (EventSource ส่ง header ไม่ได้)
+// ใช้ได้ครั้งเดียวและอายุสั้น ถ้าหลุดไปใน log ก็นำไปใช้ต่อไม่ได้
+// เก็บในหน่วยความจำเหมือน event broker (ต้องขอ ticket และเปิด stream กับ instance เดียวกัน)
+var (
+	streamTicketsMu sync.Mutex
+	streamTickets   = map[string]streamTicket{}
+)
+
+type streamTicket struct {
+	claims  JWTClaims
+	expires time.Time
+}
+
+// IssueStreamTicket - ออก ticket สำหรับเปิด stream ของเจ้าของ claims
+func IssueStreamTicket(claims JWTClaims) (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	ticket := base64.RawURLEncoding.EncodeToString(b)
+
+	streamTicketsMu.Lock()
+	defer streamTicketsMu.Unlock()
+	now := time.Now()
+	for t, st := range streamTickets {
+		if now.After(st.expires) {
+			delete(streamTickets, t)
+		}
+	}
+	streamTickets[ticket] = streamTicket{claims: claims, expires: now.Add(StreamTicketTTL)}
+	return ticket, nil
+}
+
+// RedeemStreamTicket - ใช้ ticket (ลบทิ้งทันที) คืน claims ถ้า ticket ยังไม่หมดอายุ
+func RedeemStreamTicket(ticket string) (*JWTClaims, bool) {
+	streamTicketsMu.Lock()
+	defer streamTicketsMu.Unlock()
+	st, ok := streamTickets[ticket]
+	if !ok {
+		return nil, false
+	}
+	delete(streamTickets, ticket)
+	if time.Now().After(st.expires) {
+		return nil, false
+	}
+	return &st.claims, true
+}
@@ -33,6 +33,19 @@ CREATE TABLE IF NOT EXISTS notes_for_sale (
 	FOREIGN KEY (course_id) REFERENCES courses(id) ON DELETE SET NULL
 );
 
+-- จำนวนหน้าของ PDF (ตรวจตอนอัปโหลด)
+ALTER TABLE notes_for_sale ADD COLUMN IF NOT EXISTS page_count INTEGER;
+
+-- soft delete: status = 'delisted' และ deleted_at มีค่า (status_before_delete ใช้ตอน restore)
+ALTER TABLE notes_for_sale ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMP WITH TIME ZONE;
+ALTER TABLE notes_for_sale ADD COLUMN IF NOT EXISTS status_before_delete VARCHAR(20);
+
+-- note ของ seller ต้องอยู่ต่อหลังปิดบัญชี (ผู้ซื้อยังดาวน์โหลดได้ และ buyed_note ห้ามลบ note ที่ขายแล้ว)
+-- จึงห้ามลบ user ที่เคยลง note แบบ cascade ให้ปิดบัญชีด้วยการแบน (delist note ทั้งหมด) แทนการลบ user
+ALTER TABLE notes_for_sale DROP CONSTRAINT IF EXISTS notes_for_sale_seller_id_fkey;
+ALTER TABLE notes_for_sale ADD CONSTRAINT notes_for_sale_seller_id_fkey
+    FOREIGN KEY (seller_id) REFERENCES users(id) ON DELETE RESTRICT;
+
 CREATE TABLE IF NOT EXISTS note_images (
     id SERIAL PRIMARY KEY,
     note_id INTEGER NOT NULL,
@@ -42,6 +55,45 @@ CREATE TABLE IF NOT EXISTS note_images (
     FOREIGN KEY (note_id) REFERENCES notes_for_sale(id) ON DELETE CASCADE
 );
 
+-- รูปตัวอย่างหน้า PDF ที่ระบบสร้างอัตโนมัติตอนอัปโหลด
+-- is_fallback = รูปปก (ชื่อและจำนวนหน้า) ที่สร้างแทนเมื่อดึงรูปจากหน้า PDF ไม่ได้
+CREATE TABLE IF NOT EXISTS note_previews (
+    id SERIAL PRIMARY KEY,
+    note_id INTEGER NOT NULL,
+    page_number INTEGER NOT NULL,
+    path TEXT NOT NULL,
+    is_fallback BOOLEAN NOT NULL DEFAULT false,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (note_id) REFERENCES notes_for_sale(id) ON DELETE CASCADE,
+    UNIQUE(note_id, page_number)
+);
+
+-- ตาราง bundles - ขาย note หลายเล่มของ seller คนเดียวกันรวมกันในราคาเดียว
+CREATE TABLE IF NOT EXISTS bundles (
+    id SERIAL PRIMARY KEY,
+    seller_id INTEGER NOT NULL,
+    title VARCHAR(255) NOT NULL,
+    description TEXT,
+    price DECIMAL(10,2) NOT NULL CHECK (price > 0),
+    status VARCHAR(20) NOT NULL DEFAULT 'available' CHECK (status IN ('available', 'inactive')),
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    -- bundle ที่ขายแล้วต้องอยู่ต่อ (bundle_purchases) จึงไม่ลบตาม seller เช่นเดียวกับ notes_for_sale
+    FOREIGN KEY (seller_id) REFERENCES users(id) ON DELETE RESTRICT
+);
+
+-- note ที่อยู่ใน bundle (position = ลำดับการแสดง)
+CREATE TABLE IF NOT EXISTS bundle_items (
+    bundle_id INTEGER NOT NULL,
+    note_id INTEGER NOT NULL,
+    position INTEGER NOT NULL DEFAULT 0,
+    PRIMARY KEY (bundle_id, note_id),
+    FOREIGN KEY (bundle_id) REFERENCES bundles(id) ON DELETE CASCADE,
+    FOREIGN KEY (note_id) REFERENCES notes_for_sale(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_bundle_items_note ON bundle_items(note_id);
+
 CREATE TABLE IF NOT EXISTS buyed_note (
     id SERIAL PRIMARY KEY,
     user_id INTEGER NOT NULL,
@@ -52,6 +104,16 @@ CREATE TABLE IF NOT EXISTS buyed_note (
     FOREIGN KEY (note_id) REFERENCES notes_for_sale(id) ON DELETE CASCADE
 );
 
+-- ห้ามลบ note ที่มีผู้ซื้อแล้ว (ใช้ soft delete แทน)
+ALTER TABLE buyed_note DROP CONSTRAINT IF EXISTS buyed_note_note_id_fkey;
+ALTER TABLE buyed_note ADD CONSTRAINT buyed_note_note_id_fkey
+    FOREIGN KEY (note_id) REFERENCES notes_for_sale(id) ON DELETE RESTRICT;
+
+-- ซื้อ note เดิมซ้ำไม่ได้ (ลบแถวซ้ำที่เกิดจากการรัน seed ซ้ำก่อนสร้าง index)
+DELETE FROM buyed_note a USING buyed_note b
+WHERE a.user_id = b.user_id AND a.note_id = b.note_id AND a.id > b.id;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_buyed_note_user_note ON buyed_note(user_id, note_id);
+
 -- ตารางตะกร้าสินค้า (cart) - รวมกับ cart_items
 CREATE TABLE IF NOT EXISTS cart (
     id SERIAL PRIMARY KEY,
@@ -63,6 +125,19 @@ CREATE TABLE IF NOT EXISTS cart (
     UNIQUE(user_id, note_id)
 );
 
+-- แต่ละแถวของ cart เป็น note หรือ bundle อย่างใดอย่างหนึ่ง
+ALTER TABLE cart ALTER COLUMN note_id DROP NOT NULL;
+ALTER TABLE cart ADD COLUMN IF NOT EXISTS bundle_id INTEGER REFERENCES bundles(id) ON DELETE CASCADE;
+ALTER TABLE cart DROP CONSTRAINT IF EXISTS cart_note_or_bundle_check;
+ALTER TABLE cart ADD CONSTRAINT cart_note_or_bundle_check CHECK (num_nonnulls(note_id, bundle_id) = 1);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_cart_user_bundle ON cart(user_id, bundle_id);
+
+-- สินค้าดิจิทัลมีได้ชิ้นเดียวเสมอ
+UPDATE cart SET quantity = 1 WHERE quantity IS DISTINCT FROM 1;
+ALTER TABLE cart ALTER COLUMN quantity SET NOT NULL;
+ALTER TABLE cart DROP CONSTRAINT IF EXISTS cart_quantity_check;
+ALTER TABLE cart ADD CONSTRAINT cart_quantity_check CHECK (quantity = 1);
+
 -- ตาราง roles
 CREATE TABLE IF NOT EXISTS roles (
     id SERIAL PRIMARY KEY,
@@ -367,6 +442,103 @@ INSERT INTO slider_images (image_path, display_order, link_url) VALUES
 ('uploads/images/slider_303fe25c-ef0f-49d6-aa01-4dc33d064e08.png', 2, '/Help')
 ON CONFLICT DO NOTHING;
 
+-- ตาราง image_variants เก็บรูปแต่ละขนาด (thumb, card, full) ที่สร้างจากรูปที่อัปโหลด
+-- แต่ละแถวเป็นของ note_images, avatar ของ users หรือ slider_images อย่างใดอย่างหนึ่ง
+CREATE TABLE IF NOT EXISTS image_variants (
+    id SERIAL PRIMARY KEY,
+    note_image_id INTEGER REFERENCES note_images(id) ON DELETE CASCADE,
+    user_id INTEGER REFERENCES users(id) ON DELETE CASCADE,
+    slider_image_id INTEGER REFERENCES slider_images(id) ON DELETE CASCADE,
+    variant VARCHAR(20) NOT NULL,
+    path TEXT NOT NULL,
+    width INTEGER NOT NULL,
+    height INTEGER NOT NULL,
+    mime_type VARCHAR(50) NOT NULL,
+    size_bytes INTEGER NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    CHECK (num_nonnulls(note_image_id, user_id, slider_image_id) = 1),
+    UNIQUE(note_image_id, variant),
+    UNIQUE(user_id, variant),
+    UNIQUE(slider_image_id, variant)
+);
+
+-- ตาราง background_jobs สำหรับคิวงาน background (worker ดึงงานด้วย FOR UPDATE SKIP LOCKED)
+-- status: pending (รอทำงาน/รอ retry), running, completed, dead (ล้มเหลวครบจำนวนครั้ง รอ admin retry)
+CREATE TABLE IF NOT EXISTS background_jobs (
+    id BIGSERIAL PRIMARY KEY,
+    job_type VARCHAR(100) NOT NULL,
+    payload JSONB NOT NULL DEFAULT '{}',
+    status VARCHAR(20) NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'running', 'completed', 'dead')),
+    attempts INTEGER NOT NULL DEFAULT 0,
+    max_attempts INTEGER NOT NULL DEFAULT 5,
+    run_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    unique_key VARCHAR(255) UNIQUE,
+    last_error TEXT,
+    locked_by VARCHAR(255),
+    locked_at TIMESTAMP WITH TIME ZONE,
+    completed_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_background_jobs_pending ON background_jobs(run_at) WHERE status = 'pending';
+CREATE INDEX IF NOT EXISTS idx_background_jobs_status ON background_jobs(status, id);
+
+-- ตาราง coupons สำหรับคูปองส่วนลด
+-- scope (seller_id, course_id, major, note_id) ที่เป็น NULL หมายถึงไม่จำกัด ถ้ากำหนดหลายค่าสินค้าต้องตรงทุกค่า
+-- รหัสไม่ซ้ำกันภายในผู้ขายเดียวกัน (และภายในคูปองทั้งเว็บที่ไม่จำกัดผู้ขาย) ผู้ขายต่างคนใช้รหัสเดียวกันได้
+CREATE TABLE IF NOT EXISTS coupons (
+    id SERIAL PRIMARY KEY,
+    code VARCHAR(50) NOT NULL,
+    description TEXT,
+    discount_type VARCHAR(10) NOT NULL CHECK (discount_type IN ('percent', 'fixed')),
+    discount_value DECIMAL(10,2) NOT NULL CHECK (discount_value > 0),
+    max_discount DECIMAL(10,2),
+    min_cart_value DECIMAL(10,2) NOT NULL DEFAULT 0,
+    usage_limit INTEGER,
+    per_user_limit INTEGER,
+    starts_at TIMESTAMP WITH TIME ZONE,
+    ends_at TIMESTAMP WITH TIME ZONE,
+    seller_id INTEGER REFERENCES users(id) ON DELETE CASCADE,
+    course_id INTEGER REFERENCES courses(id) ON DELETE CASCADE,
+    major VARCHAR(50),
+    note_id INTEGER REFERENCES notes_for_sale(id) ON DELETE CASCADE,
+    is_active BOOLEAN NOT NULL DEFAULT true,
+    created_by INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_coupons_seller_code ON coupons(seller_id, code) WHERE seller_id IS NOT NULL;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_coupons_sitewide_code ON coupons(code) WHERE seller_id IS NULL;
+CREATE INDEX IF NOT EXISTS idx_coupons_code ON coupons(code);
+
+-- ตาราง coupon_redemptions เก็บประวัติการใช้คูปองตอนซื้อ
+CREATE TABLE IF NOT EXISTS coupon_redemptions (
+    id SERIAL PRIMARY KEY,
+    coupon_id INTEGER NOT NULL REFERENCES coupons(id) ON DELETE CASCADE,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    note_ids INTEGER[] NOT NULL,
+    bundle_ids INTEGER[] NOT NULL DEFAULT '{}',
+    subtotal DECIMAL(10,2) NOT NULL,
+    discount_amount DECIMAL(10,2) NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_coupon_redemptions_coupon_user ON coupon_redemptions(coupon_id, user_id);
+
+-- ตาราง bundle_purchases เก็บประวัติการซื้อ bundle (note_ids = note ที่ได้รับจริง ไม่รวมเล่มที่มีอยู่แล้ว)
+CREATE TABLE IF NOT EXISTS bundle_purchases (
+    id SERIAL PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    bundle_id INTEGER NOT NULL REFERENCES bundles(id) ON DELETE RESTRICT,
+    note_ids INTEGER[] NOT NULL,
+    bundle_price DECIMAL(10,2) NOT NULL,
+    price_paid DECIMAL(10,2) NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_bundle_purchases_user ON bundle_purchases(user_id);
+
 
 
 -- -- Indexes
@@ -374,3 +546,390 @@ ON CONFLICT DO NOTHING;
 -- CREATE INDEX idx_user_roles_role ON user_roles(role_id);
 -- CREATE INDEX idx_refresh_tokens_user ON refresh_tokens(user_id);
 -- CREATE INDEX idx_refresh_tokens_token ON refresh_tokens(token);
+
+-- ตาราง orders เก็บคำสั่งซื้อ (ยอดทั้งหมดคำนวณที่ server)
+CREATE TABLE IF NOT EXISTS orders (
+    id SERIAL PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    status VARCHAR(20) NOT NULL DEFAULT 'paid',
+    subtotal DECIMAL(10,2) NOT NULL,
+    discount_amount DECIMAL(10,2) NOT NULL DEFAULT 0,
+    tax_rate DECIMAL(5,2) NOT NULL DEFAULT 0,
+    tax_amount DECIMAL(10,2) NOT NULL DEFAULT 0,
+    total DECIMAL(10,2) NOT NULL,
+    coupon_id INTEGER REFERENCES coupons(id) ON DELETE SET NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_orders_user ON orders(user_id, created_at DESC);
+
+-- รายการในคำสั่งซื้อ (note หรือ bundle อย่างใดอย่างหนึ่ง, note_ids = note ที่ได้รับจากรายการนี้)
+CREATE TABLE IF NOT EXISTS order_items (
+    id SERIAL PRIMARY KEY,
+    order_id INTEGER NOT NULL REFERENCES orders(id) ON DELETE CASCADE,
+    note_id INTEGER REFERENCES notes_for_sale(id),
+    bundle_id INTEGER REFERENCES bundles(id),
+    title VARCHAR(255) NOT NULL,
+    seller_id INTEGER NOT NULL,
+    price DECIMAL(10,2) NOT NULL,
+    discount_amount DECIMAL(10,2) NOT NULL DEFAULT 0,
+    tax_amount DECIMAL(10,2) NOT NULL DEFAULT 0,
+    total DECIMAL(10,2) NOT NULL,
+    note_ids INTEGER[] NOT NULL,
+    CHECK (num_nonnulls(note_id, bundle_id) = 1)
+);
+
+CREATE INDEX IF NOT EXISTS idx_order_items_order ON order_items(order_id);
+
+-- Idempotency-Key ของการสั่งซื้อ (เก็บผลลัพธ์ไว้ replay ประมาณ 24 ชั่วโมง)
+CREATE TABLE IF NOT EXISTS idempotency_keys (
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    idem_key VARCHAR(255) NOT NULL,
+    endpoint VARCHAR(50) NOT NULL,
+    request_hash CHAR(64) NOT NULL,
+    status_code INTEGER,
+    response JSONB,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (user_id, idem_key)
+);
+
+CREATE INDEX IF NOT EXISTS idx_idempotency_keys_created ON idempotency_keys(created_at);
+
+-- ตาราง wishlists (note ที่ user บันทึกไว้ดูภายหลัง)
+CREATE TABLE IF NOT EXISTS wishlists (
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    note_id INTEGER NOT NULL REFERENCES notes_for_sale(id) ON DELETE CASCADE,
+    saved_price DECIMAL(10, 2) NOT NULL, -- ราคาตอนบันทึก ใช้เทียบว่าราคาลดลงหรือไม่
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (user_id, note_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_wishlists_note ON wishlists(note_id);
+
+-- ตาราง notifications (การแจ้งเตือนในระบบ)
+CREATE TABLE IF NOT EXISTS notifications (
+    id SERIAL PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    type VARCHAR(50) NOT NULL,
+    title VARCHAR(255) NOT NULL,
+    message TEXT,
+    data JSONB, -- ข้อมูลประกอบสำหรับ frontend เช่น note_id
+    read_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_notifications_user ON notifications(user_id, created_at DESC);
+
+-- ตาราง notification_preferences (ช่องทางที่ user ต้องการรับการแจ้งเตือนแต่ละชนิด)
+-- ชนิดที่ไม่มีแถวใช้ค่าเริ่มต้นในโค้ด
+CREATE TABLE IF NOT EXISTS notification_preferences (
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    type VARCHAR(50) NOT NULL,
+    in_app BOOLEAN NOT NULL DEFAULT TRUE,
+    email BOOLEAN NOT NULL DEFAULT FALSE,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (user_id, type)
+);
+
+CREATE INDEX IF NOT EXISTS idx_notifications_unread ON notifications(user_id) WHERE read_at IS NULL;
+
+-- ตาราง webhook_endpoints (ปลายทางที่สมัครรับ webhook)
+-- user_id = NULL คือ endpoint ของระบบที่ admin สร้าง (รับ event ของทุก seller)
+CREATE TABLE IF NOT EXISTS webhook_endpoints (
+    id SERIAL PRIMARY KEY,
+    user_id INTEGER REFERENCES users(id) ON DELETE CASCADE,
+    url TEXT NOT NULL,
+    secret VARCHAR(100) NOT NULL, -- ใช้ sign payload ด้วย HMAC-SHA256
+    events TEXT[] NOT NULL,
+    is_active BOOLEAN NOT NULL DEFAULT TRUE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_webhook_endpoints_user ON webhook_endpoints(user_id);
+
+-- ตาราง webhook_deliveries (delivery log ของแต่ละ event ต่อ endpoint)
+CREATE TABLE IF NOT EXISTS webhook_deliveries (
+    id BIGSERIAL PRIMARY KEY,
+    endpoint_id INTEGER NOT NULL REFERENCES webhook_endpoints(id) ON DELETE CASCADE,
+    event_type VARCHAR(50) NOT NULL,
+    payload JSONB NOT NULL,
+    status VARCHAR(20) NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'succeeded', 'failed')),
+    attempts INTEGER NOT NULL DEFAULT 0,
+    response_status INTEGER,
+    response_body TEXT,
+    last_error TEXT,
+    job_id BIGINT, -- job ล่าสุดที่ส่งรายการนี้ (background_jobs ถูกลบตามอายุ จึงไม่ใส่ foreign key)
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    last_attempt_at TIMESTAMP WITH TIME ZONE,
+    delivered_at TIMESTAMP WITH TIME ZONE
+);
+
+CREATE INDEX IF NOT EXISTS idx_webhook_deliveries_endpoint ON webhook_deliveries(endpoint_id, created_at DESC);
+CREATE INDEX IF NOT EXISTS idx_webhook_deliveries_status ON webhook_deliveries(status, created_at DESC);
+
+-- ตาราง reviews (รีวิวของผู้ซื้อ 1 รีวิวต่อการซื้อ 1 ครั้ง)
+CREATE TABLE IF NOT EXISTS reviews (
+    id SERIAL PRIMARY KEY,
+    buyed_note_id INTEGER NOT NULL UNIQUE REFERENCES buyed_note(id) ON DELETE CASCADE, -- รีวิวได้เฉพาะคนที่ซื้อแล้ว
+    buyer_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    note_id INTEGER NOT NULL REFERENCES notes_for_sale(id) ON DELETE CASCADE,
+    rating SMALLINT NOT NULL CHECK (rating BETWEEN 1 AND 5),
+    body TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    edited_at TIMESTAMP WITH TIME ZONE -- NULL = ยังไม่เคยแก้ไข
+);
+
+CREATE INDEX IF NOT EXISTS idx_reviews_note ON reviews(note_id, created_at DESC);
+CREATE INDEX IF NOT EXISTS idx_reviews_buyer ON reviews(buyer_id);
+
+-- ตาราง review_edits (ค่าเดิมของรีวิวก่อนถูกแก้ไขแต่ละครั้ง)
+CREATE TABLE IF NOT EXISTS review_edits (
+    id SERIAL PRIMARY KEY,
+    review_id INTEGER NOT NULL REFERENCES reviews(id) ON DELETE CASCADE,
+    rating SMALLINT NOT NULL,
+    body TEXT NOT NULL,
+    replaced_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_review_edits_review ON review_edits(review_id, replaced_at);
+
+-- ย้ายรีวิวเดิมจาก buyed_note (ชอบ = 5 ดาว, ไม่ชอบ = 1 ดาว, ไม่ระบุ = 3 ดาว)
+-- ไม่มีเวลาที่รีวิวจริงเก็บไว้ จึงใช้เวลาที่ย้ายข้อมูล
+INSERT INTO reviews (buyed_note_id, buyer_id, note_id, rating, body)
+SELECT id, user_id, note_id,
+    CASE WHEN is_liked IS TRUE THEN 5 WHEN is_liked IS FALSE THEN 1 ELSE 3 END,
+    review
+FROM buyed_note
+WHERE review <> '' OR is_liked IS NOT NULL
+ON CONFLICT (buyed_note_id) DO NOTHING;
+
+-- review และ is_liked ของ buyed_note เลิกใช้แล้ว (ใช้ตาราง reviews แทน) การซื้อใหม่จึงไม่ต้องส่งค่า review
+ALTER TABLE buyed_note ALTER COLUMN review SET DEFAULT '';
+
+-- ตาราง review_replies (คำตอบของ seller ต่อรีวิว 1 คำตอบต่อรีวิว)
+CREATE TABLE IF NOT EXISTS review_replies (
+    id SERIAL PRIMARY KEY,
+    review_id INTEGER NOT NULL UNIQUE REFERENCES reviews(id) ON DELETE CASCADE,
+    seller_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    body TEXT NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    edited_at TIMESTAMP WITH TIME ZONE -- NULL = ยังไม่เคยแก้ไข
+);
+
+-- ตาราง review_votes (โหวตว่ารีวิวมีประโยชน์ 1 เสียงต่อ user ต่อรีวิว)
+CREATE TABLE IF NOT EXISTS review_votes (
+    review_id INTEGER NOT NULL REFERENCES reviews(id) ON DELETE CASCADE,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (review_id, user_id)
+);
+
+-- สถานะระงับบัญชี (จากการจัดการรายงาน)
+ALTER TABLE users ADD COLUMN IF NOT EXISTS suspended_until TIMESTAMP WITH TIME ZONE;
+ALTER TABLE users ADD COLUMN IF NOT EXISTS suspension_reason TEXT;
+
+-- ตาราง reports (รายงาน note รีวิว หรือ seller และคิวตรวจของ admin)
+CREATE TABLE IF NOT EXISTS reports (
+    id SERIAL PRIMARY KEY,
+    reporter_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    target_type VARCHAR(20) NOT NULL CHECK (target_type IN ('note', 'review', 'seller')),
+    target_id INTEGER NOT NULL,
+    target_user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE, -- เจ้าของเนื้อหาตอนที่ถูกรายงาน
+    category VARCHAR(30) NOT NULL,
+    details TEXT NOT NULL DEFAULT '',
+    status VARCHAR(20) NOT NULL DEFAULT 'open' CHECK (status IN ('open', 'in_review', 'resolved', 'dismissed')),
+    assigned_to INTEGER REFERENCES users(id) ON DELETE SET NULL,
+    resolution TEXT NOT NULL DEFAULT '',
+    action VARCHAR(30) CHECK (action IN ('none', 'delist_note', 'suspend_user')),
+    resolved_by INTEGER REFERENCES users(id) ON DELETE SET NULL,
+    resolved_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_reports_status ON reports(status, created_at);
+CREATE INDEX IF NOT EXISTS idx_reports_target ON reports(target_type, target_id);
+-- รายงานสิ่งเดิมซ้ำไม่ได้ระหว่างที่รายงานเดิมยังไม่ปิด
+CREATE UNIQUE INDEX IF NOT EXISTS idx_reports_open_unique ON reports(reporter_id, target_type, target_id)
+    WHERE status IN ('open', 'in_review');
+
+-- ตาราง note_fingerprints (fingerprint ของ PDF สำหรับตรวจ note ซ้ำ)
+CREATE TABLE IF NOT EXISTS note_fingerprints (
+    note_id INTEGER PRIMARY KEY REFERENCES notes_for_sale(id) ON DELETE CASCADE,
+    file_sha256 CHAR(64) NOT NULL,
+    text_minhash BIGINT[],                -- NULL = ข้อความไม่พอเทียบ (เช่นไฟล์สแกน)
+    text_shingles INTEGER NOT NULL DEFAULT 0,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_note_fingerprints_sha256 ON note_fingerprints(file_sha256);
+
+-- ตาราง note_fingerprint_bands (LSH band ของ MinHash ใช้หา note ที่น่าจะคล้ายโดยไม่ต้องเทียบทุก note)
+CREATE TABLE IF NOT EXISTS note_fingerprint_bands (
+    note_id INTEGER NOT NULL REFERENCES notes_for_sale(id) ON DELETE CASCADE,
+    band SMALLINT NOT NULL,
+    hash BIGINT NOT NULL,
+    PRIMARY KEY (note_id, band)
+);
+
+CREATE INDEX IF NOT EXISTS idx_note_fingerprint_bands_hash ON note_fingerprint_bands(band, hash);
+
+-- ตาราง note_duplicate_matches (note เดิมที่ note ใหม่น่าจะซ้ำ ให้ admin ตัดสิน)
+CREATE TABLE IF NOT EXISTS note_duplicate_matches (
+    note_id INTEGER NOT NULL REFERENCES notes_for_sale(id) ON DELETE CASCADE,
+    matched_note_id INTEGER NOT NULL REFERENCES notes_for_sale(id) ON DELETE CASCADE,
+    similarity NUMERIC(5, 4) NOT NULL,  -- 0-1
+    method VARCHAR(20) NOT NULL CHECK (method IN ('exact_file', 'text')),
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (note_id, matched_note_id)
+);
+
+-- การแบนบัญชี และ token version (เพิ่มค่าเพื่อยกเลิก access token ที่ออกไปแล้วทั้งหมด)
+ALTER TABLE users ADD COLUMN IF NOT EXISTS banned_at TIMESTAMP WITH TIME ZONE;
+ALTER TABLE users ADD COLUMN IF NOT EXISTS ban_reason TEXT;
+ALTER TABLE users ADD COLUMN IF NOT EXISTS token_version INTEGER NOT NULL DEFAULT 0;
+
+-- ตาราง audit_log (บันทึกการกระทำของ admin)
+-- เป็นแบบ append-only: เก็บ email ของ admin ไว้แทนการอ้างอิง users แบบ ON DELETE SET NULL
+-- (ซึ่งต้อง UPDATE แถวเดิม) และห้าม UPDATE / DELETE / TRUNCATE
+CREATE TABLE IF NOT EXISTS audit_log (
+    id BIGSERIAL PRIMARY KEY,
+    actor_id INTEGER,                      -- users.id ของ admin (ไม่มี FK เพื่อให้แถวไม่ถูกแก้เมื่อลบ user)
+    actor_email VARCHAR(255),
+    action VARCHAR(50) NOT NULL,           -- เช่น user.suspend, user.ban
+    target_type VARCHAR(30) NOT NULL,
+    target_id INTEGER NOT NULL,
+    diff JSONB NOT NULL DEFAULT '{}',      -- {"before": ..., "after": ...}
+    ip VARCHAR(45),
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_audit_log_created ON audit_log(created_at DESC);
+CREATE INDEX IF NOT EXISTS idx_audit_log_target ON audit_log(target_type, target_id);
+
+CREATE OR REPLACE FUNCTION audit_log_append_only() RETURNS trigger AS $$
+BEGIN
+    RAISE EXCEPTION 'audit_log is append-only';
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS audit_log_no_update ON audit_log;
+CREATE TRIGGER audit_log_no_update BEFORE UPDATE OR DELETE ON audit_log
+    FOR EACH ROW EXECUTE FUNCTION audit_log_append_only();
+
+DROP TRIGGER IF EXISTS audit_log_no_truncate ON audit_log;
+CREATE TRIGGER audit_log_no_truncate BEFORE TRUNCATE ON audit_log
+    FOR EACH STATEMENT EXECUTE FUNCTION audit_log_append_only();
+
+CREATE INDEX IF NOT EXISTS idx_audit_log_actor ON audit_log(actor_id, created_at DESC);
+CREATE INDEX IF NOT EXISTS idx_audit_log_action ON audit_log(action, created_at DESC);
+
+-- ตาราง permissions (สิทธิ์ที่ผูกกับ role, role admin มีทุกสิทธิ์โดยไม่ต้องผูก)
+CREATE TABLE IF NOT EXISTS permissions (
+    id SERIAL PRIMARY KEY,
+    name VARCHAR(50) NOT NULL UNIQUE,     -- รูปแบบ resource.action เช่น notes.approve
+    description TEXT
+);
+
+-- ตาราง role_permissions (many-to-many)
+CREATE TABLE IF NOT EXISTS role_permissions (
+    role_id INTEGER NOT NULL REFERENCES roles(id) ON DELETE CASCADE,
+    permission_id INTEGER NOT NULL REFERENCES permissions(id) ON DELETE CASCADE,
+    PRIMARY KEY (role_id, permission_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_role_permissions_permission ON role_permissions(permission_id);
+
+-- สิทธิ์ที่โค้ดใช้ตรวจ (ตรงกับ back-end/permissions)
+INSERT INTO permissions (name, description) VALUES
+    ('stats.view', 'View dashboard statistics'),
+    ('users.view', 'View users and sellers'),
+    ('users.ban', 'Suspend, ban and reinstate users'),
+    ('roles.manage', 'Manage roles, permissions and role assignments'),
+    ('notes.view', 'View all notes, including pending ones'),
+    ('notes.approve', 'Approve or reject pending notes'),
+    ('notes.edit', 'Edit, delete and restore notes'),
+    ('slider.manage', 'Manage homepage slider images'),
+    ('jobs.manage', 'View and retry background jobs'),
+    ('coupons.manage', 'Manage site-wide coupons'),
+    ('reports.manage', 'Work the content report queue'),
+    ('webhooks.manage', 'Manage site webhooks'),
+    ('audit.view', 'View and export the audit log')
+ON CONFLICT (name) DO NOTHING;
+
+-- role moderator: ตรวจ note ที่รออนุมัติและคิวรายงานได้ โดยไม่มีสิทธิ์เต็มแบบ admin
+INSERT INTO roles (name, description) VALUES ('moderator', 'Reviews pending notes and content reports')
+ON CONFLICT (name) DO NOTHING;
+
+INSERT INTO role_permissions (role_id, permission_id)
+SELECT r.id, p.id FROM roles r, permissions p
+WHERE r.name = 'moderator' AND p.name IN ('notes.view', 'notes.approve', 'reports.manage', 'users.view')
+ON CONFLICT DO NOTHING;
+
+-- ผู้ขายที่ผ่านการยืนยันตัวตน (แสดง badge ในหน้า note และโปรไฟล์) ลง note ขายได้เฉพาะ user ที่มีค่านี้
+-- ผู้ที่มี role seller อยู่แล้ว (เดิมได้ role อัตโนมัติเมื่อลง note ครั้งแรก) ไม่ถูกนับว่ายืนยันตัวตนแล้ว:
+-- note เดิมยังขายต่อได้ แต่ต้องส่งใบสมัครและผ่านการอนุมัติก่อนลง note ใหม่
+ALTER TABLE users ADD COLUMN IF NOT EXISTS seller_verified_at TIMESTAMP WITH TIME ZONE;
+
+-- ตาราง seller_applications (ใบสมัครเป็นผู้ขาย ตรวจโดย admin)
+CREATE TABLE IF NOT EXISTS seller_applications (
+    id SERIAL PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    student_id VARCHAR(20) NOT NULL,
+    student_id_card_path TEXT NOT NULL,    -- เก็บนอก /uploads ดูได้เฉพาะผู้มีสิทธิ์ sellers.verify
+    faculty VARCHAR(100) NOT NULL,
+    major VARCHAR(100) NOT NULL,
+    payout_method VARCHAR(20) NOT NULL CHECK (payout_method IN ('bank', 'promptpay')),
+    payout_bank VARCHAR(100),
+    payout_account_name VARCHAR(100) NOT NULL,
+    payout_account_number VARCHAR(20) NOT NULL,
+    status VARCHAR(20) NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'approved', 'rejected')),
+    reject_reason TEXT,
+    reviewed_by INTEGER REFERENCES users(id) ON DELETE SET NULL,
+    reviewed_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+-- มีใบสมัครที่รอตรวจได้ครั้งละหนึ่งใบต่อ user
+CREATE UNIQUE INDEX IF NOT EXISTS idx_seller_applications_pending
+    ON seller_applications(user_id) WHERE status = 'pending';
+CREATE INDEX IF NOT EXISTS idx_seller_applications_status ON seller_applications(status, created_at);
+
+INSERT INTO permissions (name, description) VALUES
+    ('sellers.verify', 'Review seller applications and student ID documents')
+ON CONFLICT (name) DO NOTHING;
+
+-- ข้อความแนะนำตัวในหน้าโปรไฟล์ผู้ขาย
+ALTER TABLE users ADD COLUMN IF NOT EXISTS bio TEXT;
+
+-- เวลาที่ note ผ่านการอนุมัติ (ใช้เรียง feed)
+ALTER TABLE notes_for_sale ADD COLUMN IF NOT EXISTS approved_at TIMESTAMP WITH TIME ZONE;
+
+-- ตาราง seller_follows (ผู้ซื้อติดตามผู้ขาย)
+CREATE TABLE IF NOT EXISTS seller_follows (
+    follower_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    seller_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (follower_id, seller_id),
+    CHECK (follower_id <> seller_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_seller_follows_seller ON seller_follows(seller_id);
+
+-- ตาราง note_similarities (note ที่คล้ายกัน สำหรับแนะนำ) คำนวณใหม่เป็นรอบโดย job recommendations.recompute
+CREATE TABLE IF NOT EXISTS note_similarities (
+    note_id INTEGER NOT NULL REFERENCES notes_for_sale(id) ON DELETE CASCADE,
+    similar_note_id INTEGER NOT NULL REFERENCES notes_for_sale(id) ON DELETE CASCADE,
+    score DOUBLE PRECISION NOT NULL,
+    co_purchases INTEGER NOT NULL DEFAULT 0,  -- จำนวนผู้ซื้อที่ซื้อทั้งสองเล่ม
+    reason VARCHAR(20) NOT NULL,              -- co_purchased, same_course, same_major
+    computed_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (note_id, similar_note_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_note_similarities_score ON note_similarities(note_id, score DESC);
+CREATE INDEX IF NOT EXISTS idx_buyed_note_user ON buyed_note(user_id);